@@ -5,10 +5,10 @@
 
 import { useMemo } from 'react'
 import { useFetch } from './useFetch'
-import type { TestRegression } from '@/types'
+import type { RegressionReport, TestRegression } from '@/types'
 
-// Stable empty array reference to prevent infinite re-renders
-const EMPTY_REGRESSIONS: TestRegression[] = []
+// Stable empty report reference to prevent infinite re-renders
+const EMPTY_REPORT: RegressionReport = { regressions: [], suppressed_flaky: 0 }
 
 export interface UseRegressionsOptions {
   /** Enable fetching (default: true) */
@@ -20,6 +20,8 @@ export interface UseRegressionsOptions {
 export interface UseRegressionsResult {
   /** List of test regressions */
   regressions: TestRegression[]
+  /** Number of flaky tests left out of the list */
+  suppressedFlaky: number
   /** Whether fetch is in progress */
   loading: boolean
   /** Error message if fetch failed */
@@ -57,19 +59,20 @@ export function useRegressions(
     return base
   }, [since])
 
-  const { data, loading, error, refetch } = useFetch<TestRegression[]>(
+  const { data, loading, error, refetch } = useFetch<RegressionReport>(
     url,
     {
       enabled,
-      initialData: EMPTY_REGRESSIONS,
+      initialData: EMPTY_REPORT,
       errorPrefix: 'Failed to fetch regressions',
     }
   )
 
-  const regressions = useMemo(() => data ?? [], [data])
+  const regressions = useMemo(() => data?.regressions ?? [], [data])
 
   return {
     regressions,
+    suppressedFlaky: data?.suppressed_flaky ?? 0,
     loading,
     error,
     refetch,
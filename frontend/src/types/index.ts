@@ -420,3 +420,10 @@ export interface TestRegression {
   first_failed_commit?: string;
   error_message?: string;
 }
+
+// Response of GET /api/telemetry/regressions
+export interface RegressionReport {
+  regressions: TestRegression[];
+  by_commit?: Record<string, TestRegression[]>;
+  suppressed_flaky: number;
+}
@@ -4,6 +4,7 @@
 //
 //	go test -json ./... | record-tests --agent crew/jeremy --bead to-abc123
 //	go test -json ./... | record-tests  # agent ID auto-detected from environment
+//	go test -json ./... | record-tests --dry-run --output summary
 //
 // The tool parses go test -json output, extracts test results, and POSTs them
 // to the townview telemetry endpoint.
@@ -15,11 +16,15 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"os/exec"
+	"regexp"
 	"strings"
 	"time"
+
+	"github.com/gastown/townview/internal/telemetry"
 )
 
 // TestEvent represents a single event from `go test -json` output.
@@ -54,7 +59,9 @@ type TestRun struct {
 	Passed     int          `json:"passed"`
 	Failed     int          `json:"failed"`
 	Skipped    int          `json:"skipped"`
+	Errored    int          `json:"errored"`
 	DurationMS int          `json:"duration_ms"`
+	Model      string       `json:"model,omitempty"`
 	Results    []TestResult `json:"results"`
 }
 
@@ -65,15 +72,32 @@ func main() {
 		endpoint string
 		command  string
 		dryRun   bool
+		output   string
+		beadRe   string
+		maxErr   int
+		model    string
 	)
 
 	flag.StringVar(&agentID, "agent", "", "Agent ID (e.g., 'crew/jeremy'). Auto-detected from environment if not provided.")
-	flag.StringVar(&beadID, "bead", "", "Bead ID for the current work (e.g., 'to-abc123')")
+	flag.StringVar(&beadID, "bead", "", "Bead ID for the current work (e.g., 'to-abc123'). Auto-detected from the git branch if not provided.")
+	flag.StringVar(&beadRe, "bead-pattern", defaultBeadPattern, "Regex used to extract the bead ID from the git branch name")
 	flag.StringVar(&endpoint, "endpoint", "http://localhost:8080/api/telemetry/tests", "Telemetry API endpoint")
 	flag.StringVar(&command, "command", "go test -json ./...", "Test command that was run")
 	flag.BoolVar(&dryRun, "dry-run", false, "Parse and print results without posting")
+	flag.StringVar(&output, "output", "json", "Dry-run output format: json, summary, or ndjson")
+	flag.IntVar(&maxErr, "max-error-len", defaultMaxErrorLen, "Maximum error message length; the tail is kept when truncating (0 for unlimited)")
+	flag.StringVar(&model, "model", os.Getenv("GT_MODEL"), "Model the agent runs on; must be a priced model family (default: $GT_MODEL)")
 	flag.Parse()
 
+	if output != "json" && output != "summary" && output != "ndjson" {
+		fmt.Fprintf(os.Stderr, "error: unknown output format %q (use json, summary, or ndjson)\n", output)
+		os.Exit(1)
+	}
+	if model != "" && !telemetry.KnownModel(model) {
+		fmt.Fprintf(os.Stderr, "error: unknown model %q (must name a priced model family)\n", model)
+		os.Exit(1)
+	}
+
 	// Auto-detect agent ID if not provided
 	if agentID == "" {
 		agentID = detectAgentID()
@@ -84,7 +108,7 @@ func main() {
 	}
 
 	// Parse go test -json from stdin
-	results, totalDuration, err := parseGoTestJSON(os.Stdin)
+	results, totalDuration, err := parseGoTestJSON(os.Stdin, maxErr)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error parsing test output: %v\n", err)
 		os.Exit(1)
@@ -99,8 +123,17 @@ func main() {
 	commitSHA := getGitCommitSHA()
 	branch := getGitBranch()
 
+	// Auto-detect bead ID from branch name if not provided
+	if beadID == "" {
+		beadID, err = detectBeadID(branch, beadRe)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: invalid --bead-pattern: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	// Count results
-	var passed, failed, skipped int
+	var passed, failed, skipped, errored int
 	for _, r := range results {
 		switch r.Status {
 		case "passed":
@@ -109,6 +142,8 @@ func main() {
 			failed++
 		case "skipped":
 			skipped++
+		case "error":
+			errored++
 		}
 	}
 
@@ -124,14 +159,18 @@ func main() {
 		Passed:     passed,
 		Failed:     failed,
 		Skipped:    skipped,
+		Errored:    errored,
 		DurationMS: totalDuration,
+		Model:      model,
 		Results:    results,
 	}
 
 	if dryRun {
 		// Print results without posting
-		data, _ := json.MarshalIndent(run, "", "  ")
-		fmt.Println(string(data))
+		if err := writeOutput(os.Stdout, run, output); err != nil {
+			fmt.Fprintf(os.Stderr, "error writing output: %v\n", err)
+			os.Exit(1)
+		}
 		return
 	}
 
@@ -141,13 +180,40 @@ func main() {
 		os.Exit(1)
 	}
 
-	fmt.Printf("Recorded %d tests (%d passed, %d failed, %d skipped) for agent %s\n",
-		run.Total, run.Passed, run.Failed, run.Skipped, run.AgentID)
+	fmt.Printf("Recorded %d tests (%d passed, %d failed, %d errored, %d skipped) for agent %s\n",
+		run.Total, run.Passed, run.Failed, run.Errored, run.Skipped, run.AgentID)
+}
+
+// writeOutput writes the test run in the given format:
+// json (indented TestRun), summary (one line of counts), or ndjson (one TestResult per line).
+func writeOutput(w io.Writer, run TestRun, format string) error {
+	switch format {
+	case "summary":
+		_, err := fmt.Fprintf(w, "%d tests: %d passed, %d failed, %d skipped, %d errored (%dms)\n",
+			run.Total, run.Passed, run.Failed, run.Skipped, run.Errored, run.DurationMS)
+		return err
+	case "ndjson":
+		enc := json.NewEncoder(w)
+		for _, r := range run.Results {
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		data, err := json.MarshalIndent(run, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	}
 }
 
 // parseGoTestJSON parses go test -json output from the given reader.
+// Error messages longer than maxErrorLen are truncated (0 disables truncation).
 // Returns test results and total duration in milliseconds.
-func parseGoTestJSON(r *os.File) ([]TestResult, int, error) {
+func parseGoTestJSON(r *os.File, maxErrorLen int) ([]TestResult, int, error) {
 	scanner := bufio.NewScanner(r)
 
 	// Track test states: package/test -> events
@@ -216,6 +282,10 @@ func parseGoTestJSON(r *os.File) ([]TestResult, int, error) {
 		durationMS := int(state.elapsed * 1000)
 		totalDuration += durationMS
 
+		if state.status == "failed" && isErrorOutput(state.output) {
+			state.status = "error"
+		}
+
 		result := TestResult{
 			TestFile:   state.pkg,
 			TestName:   state.name,
@@ -223,12 +293,8 @@ func parseGoTestJSON(r *os.File) ([]TestResult, int, error) {
 			DurationMS: durationMS,
 		}
 
-		if state.status == "failed" && state.output != "" {
-			result.ErrorMessage = strings.TrimSpace(state.output)
-			// Truncate if too long
-			if len(result.ErrorMessage) > 2000 {
-				result.ErrorMessage = result.ErrorMessage[:2000] + "..."
-			}
+		if (state.status == "failed" || state.status == "error") && state.output != "" {
+			result.ErrorMessage = truncateErrorMessage(strings.TrimSpace(state.output), maxErrorLen)
 		}
 
 		results = append(results, result)
@@ -237,6 +303,34 @@ func parseGoTestJSON(r *os.File) ([]TestResult, int, error) {
 	return results, totalDuration, nil
 }
 
+// isErrorOutput reports whether failure output shows the test errored (panicked or
+// timed out) rather than failing an assertion.
+func isErrorOutput(output string) bool {
+	return strings.Contains(output, "panic: ") || strings.Contains(output, "test timed out after")
+}
+
+// defaultMaxErrorLen is the default cap on captured failure output.
+const defaultMaxErrorLen = 2000
+
+// truncateErrorMessage shortens msg to at most maxLen bytes, keeping the tail
+// (where assertion diffs usually are) and cutting at a line boundary when possible.
+// A maxLen of 0 or less disables truncation.
+func truncateErrorMessage(msg string, maxLen int) string {
+	const marker = "...\n"
+	if maxLen <= 0 || len(msg) <= maxLen {
+		return msg
+	}
+	if maxLen <= len(marker) {
+		return msg[len(msg)-maxLen:]
+	}
+
+	tail := msg[len(msg)-(maxLen-len(marker)):]
+	if i := strings.IndexByte(tail, '\n'); i >= 0 && i < len(tail)-1 {
+		tail = tail[i+1:]
+	}
+	return marker + tail
+}
+
 // testState tracks the state of a single test during parsing.
 type testState struct {
 	pkg     string
@@ -310,6 +404,25 @@ func detectAgentID() string {
 	return ""
 }
 
+// defaultBeadPattern matches bead IDs like "to-abc123" embedded in branch names.
+const defaultBeadPattern = `[a-z]{2,}-[a-z0-9]+`
+
+// detectBeadID extracts a bead ID from a git branch name using the given pattern.
+// Branches are typically named like "feature/to-abc123-thing".
+// Returns an empty string if nothing matches.
+func detectBeadID(branch, pattern string) (string, error) {
+	if branch == "" {
+		return "", nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", err
+	}
+
+	return re.FindString(branch), nil
+}
+
 // getGitCommitSHA returns the current git commit SHA.
 func getGitCommitSHA() string {
 	cmd := exec.Command("git", "rev-parse", "HEAD")
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"os"
 	"strings"
 	"testing"
@@ -28,7 +29,7 @@ func TestParseGoTestJSON(t *testing.T) {
 	}
 	tmpFile.Seek(0, 0)
 
-	results, totalDuration, err := parseGoTestJSON(tmpFile)
+	results, totalDuration, err := parseGoTestJSON(tmpFile, defaultMaxErrorLen)
 	if err != nil {
 		t.Fatalf("parseGoTestJSON: %v", err)
 	}
@@ -136,3 +137,152 @@ func TestDetectAgentID(t *testing.T) {
 		})
 	}
 }
+
+func TestWriteOutput(t *testing.T) {
+	run := TestRun{
+		AgentID: "townview/crew/jeremy",
+		Command: "go test -json ./...",
+		Total:   2,
+		Passed:  1,
+		Failed:  1,
+		Results: []TestResult{
+			{TestFile: "example/pkg", TestName: "TestFoo", Status: "passed"},
+			{TestFile: "example/pkg", TestName: "TestBar", Status: "failed"},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := writeOutput(&buf, run, "summary"); err != nil {
+		t.Fatalf("writeOutput summary: %v", err)
+	}
+	if got := buf.String(); !strings.HasPrefix(got, "2 tests: 1 passed, 1 failed, 0 skipped") {
+		t.Errorf("unexpected summary output: %q", got)
+	}
+
+	buf.Reset()
+	if err := writeOutput(&buf, run, "ndjson"); err != nil {
+		t.Fatalf("writeOutput ndjson: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 ndjson lines, got %d", len(lines))
+	}
+	if !strings.Contains(lines[1], `"test_name":"TestBar"`) {
+		t.Errorf("unexpected ndjson line: %s", lines[1])
+	}
+
+	buf.Reset()
+	if err := writeOutput(&buf, run, "json"); err != nil {
+		t.Fatalf("writeOutput json: %v", err)
+	}
+	if !strings.Contains(buf.String(), `"agent_id": "townview/crew/jeremy"`) {
+		t.Errorf("unexpected json output: %s", buf.String())
+	}
+}
+
+func TestDetectBeadID(t *testing.T) {
+	tests := []struct {
+		name     string
+		branch   string
+		pattern  string
+		expected string
+	}{
+		{
+			name:     "feature branch with bead",
+			branch:   "feature/to-abc123-thing",
+			pattern:  defaultBeadPattern,
+			expected: "to-abc123",
+		},
+		{
+			name:     "bare bead branch",
+			branch:   "to-2e0s",
+			pattern:  defaultBeadPattern,
+			expected: "to-2e0s",
+		},
+		{
+			name:     "no match",
+			branch:   "main",
+			pattern:  defaultBeadPattern,
+			expected: "",
+		},
+		{
+			name:     "custom pattern",
+			branch:   "polecat/obsidian/hq-42",
+			pattern:  `hq-[0-9]+`,
+			expected: "hq-42",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := detectBeadID(tc.branch, tc.pattern)
+			if err != nil {
+				t.Fatalf("detectBeadID: %v", err)
+			}
+			if result != tc.expected {
+				t.Errorf("detectBeadID(%q) = %q, want %q", tc.branch, result, tc.expected)
+			}
+		})
+	}
+}
+
+func TestTruncateErrorMessage(t *testing.T) {
+	msg := "=== RUN TestBig\nnoise line one\nnoise line two\n    want: 1\n    got: 2"
+
+	if got := truncateErrorMessage(msg, 0); got != msg {
+		t.Errorf("expected no truncation with limit 0, got %q", got)
+	}
+	if got := truncateErrorMessage(msg, len(msg)); got != msg {
+		t.Errorf("expected no truncation at exact length, got %q", got)
+	}
+
+	got := truncateErrorMessage(msg, 30)
+	if len(got) > 30 {
+		t.Errorf("expected at most 30 bytes, got %d: %q", len(got), got)
+	}
+	if !strings.HasPrefix(got, "...\n") {
+		t.Errorf("expected truncation marker prefix, got %q", got)
+	}
+	if !strings.HasSuffix(got, "    want: 1\n    got: 2") {
+		t.Errorf("expected tail lines to be kept, got %q", got)
+	}
+}
+
+func TestParseGoTestJSON_PanicIsError(t *testing.T) {
+	input := `{"Time":"2025-01-24T12:00:00Z","Action":"run","Package":"example/pkg","Test":"TestPanics"}
+{"Time":"2025-01-24T12:00:00Z","Action":"output","Package":"example/pkg","Test":"TestPanics","Output":"panic: runtime error: index out of range [recovered]\n"}
+{"Time":"2025-01-24T12:00:01Z","Action":"fail","Package":"example/pkg","Test":"TestPanics","Elapsed":0.1}
+{"Time":"2025-01-24T12:00:01Z","Action":"run","Package":"example/pkg","Test":"TestAsserts"}
+{"Time":"2025-01-24T12:00:01Z","Action":"output","Package":"example/pkg","Test":"TestAsserts","Output":"    want 1, got 2\n"}
+{"Time":"2025-01-24T12:00:02Z","Action":"fail","Package":"example/pkg","Test":"TestAsserts","Elapsed":0.1}
+`
+	tmpFile, err := os.CreateTemp("", "test-*.json")
+	if err != nil {
+		t.Fatalf("creating temp file: %v", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.WriteString(input); err != nil {
+		t.Fatalf("writing temp file: %v", err)
+	}
+	tmpFile.Seek(0, 0)
+
+	results, _, err := parseGoTestJSON(tmpFile, defaultMaxErrorLen)
+	if err != nil {
+		t.Fatalf("parseGoTestJSON: %v", err)
+	}
+
+	statuses := make(map[string]TestResult)
+	for _, r := range results {
+		statuses[r.TestName] = r
+	}
+	if got := statuses["TestPanics"].Status; got != "error" {
+		t.Errorf("expected TestPanics status error, got %q", got)
+	}
+	if !strings.Contains(statuses["TestPanics"].ErrorMessage, "panic:") {
+		t.Errorf("expected panic output in error message, got %q", statuses["TestPanics"].ErrorMessage)
+	}
+	if got := statuses["TestAsserts"].Status; got != "failed" {
+		t.Errorf("expected TestAsserts status failed, got %q", got)
+	}
+}
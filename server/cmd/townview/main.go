@@ -2,14 +2,19 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/gastown/townview/internal/events"
+	"github.com/gastown/townview/internal/execlimit"
 	"github.com/gastown/townview/internal/handlers"
 	"github.com/gastown/townview/internal/mail"
 	"github.com/gastown/townview/internal/registry"
@@ -21,7 +26,30 @@ func main() {
 	// Parse flags
 	port := flag.Int("port", 8080, "HTTP server port")
 	townRoot := flag.String("town", "", "Gas Town root directory (default: ~/gt)")
+	dataDir := flag.String("data-dir", "", "Directory for townview's own databases (default: <town>/.townview)")
+	templatesDir := flag.String("templates-dir", "", "Directory of <name>.md issue templates (default: <data-dir>/templates)")
 	logLevel := flag.String("log-level", "info", "Log level (debug, info, warn, error)")
+	logFormat := flag.String("log-format", "json", "Log output format (json, text)")
+	logFile := flag.String("log-file", "", "Write logs to this file instead of stdout; its directory is created if needed")
+	maxPageSize := flag.Int("max-page-size", handlers.DefaultMaxPageSize, "Maximum number of results returned by list endpoints (0 for no cap)")
+	requestTimeout := flag.Duration("request-timeout", 60*time.Second, "Maximum time to serve a request before replying 503 (0 disables; WebSocket and streaming routes are exempt)")
+	degradedAfter := flag.Int("health-degraded-after", rigmanager.DefaultDegradedAfterMissed, "Missed heartbeats before an agent shows as degraded in rig health")
+	unhealthyAfter := flag.Int("health-unhealthy-after", rigmanager.DefaultUnhealthyAfterMissed, "Missed heartbeats before an agent shows as unhealthy in rig health; must exceed -health-degraded-after")
+	warmCache := flag.Bool("warm-cache", false, "Pre-load each rig's common issue lists into the cache at startup and after invalidations")
+	snapshotInterval := flag.Duration("issue-snapshot-interval", 15*time.Minute, "How often changed issues are snapshotted for history diffs (0 disables)")
+	serveStale := flag.Bool("serve-stale", false, "Serve the last good cached data when a rig database query fails")
+	eventBuffer := flag.Int("event-buffer", events.DefaultConfig().SubscriberBuffer, "Per-subscriber event buffer size; events are dropped for subscribers that fall this far behind")
+	writeToken := flag.String("write-token", os.Getenv("TOWNVIEW_WRITE_TOKEN"), "Bearer token required by privileged write endpoints (default: $TOWNVIEW_WRITE_TOKEN; empty leaves them open)")
+	telemetryToken := flag.String("telemetry-token", os.Getenv("TOWNVIEW_TELEMETRY_TOKEN"), "Bearer token required to post telemetry, including heartbeats that report tokens, independent of --write-token (default: $TOWNVIEW_TELEMETRY_TOKEN; empty leaves ingestion open)")
+	testOwners := flag.String("test-owners", "", "CODEOWNERS-style file mapping test path prefixes to owners (optional)")
+	telemetryPerRig := flag.Bool("telemetry-per-rig", false, "Keep each rig's telemetry in its own database under <data-dir>/telemetry; telemetry.db stays readable and keeps rows for unknown rigs. Once rig databases exist the server refuses to start without this flag")
+	tokenCoalesce := flag.Duration("token-coalesce-window", 0, "Fold token usage for the same agent, bead, model and request type within this window into one row (0 stores every call)")
+	maxTestOutput := flag.Int("max-test-output", telemetry.DefaultMaxRunOutputBytes, "Maximum bytes of error/stack output stored per test run (0 for no cap)")
+	defaultModel := flag.String("model", os.Getenv("GT_MODEL"), "Model recorded for reported token usage that names none; must be a priced model family (default: $GT_MODEL)")
+	anomalyMultiplier := flag.Float64("token-anomaly-multiplier", telemetry.DefaultAnomalyMultiplier, "Flag agents whose token usage exceeds this multiple of their expected usage")
+	readOnly := flag.Bool("readonly", false, "Start in read-only maintenance mode: mutating requests get 503 until toggled off via PUT /api/admin/readonly")
+	maxSubprocesses := flag.Int("max-subprocesses", execlimit.DefaultMaxProcesses, "Maximum concurrent bd/gt/tmux processes; further calls queue (0 for no cap)")
+	wsCompression := flag.Bool("ws-compression", true, "Negotiate permessage-deflate compression on WebSocket connections")
 	flag.Parse()
 
 	// Set up logging
@@ -34,9 +62,39 @@ func main() {
 	case "error":
 		level = slog.LevelError
 	}
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level}))
+	logOut := os.Stdout
+	if *logFile != "" {
+		if err := os.MkdirAll(filepath.Dir(*logFile), 0755); err != nil {
+			slog.Error("Failed to create log directory", "path", *logFile, "error", err)
+			os.Exit(1)
+		}
+		f, err := os.OpenFile(*logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			slog.Error("Failed to open log file", "path", *logFile, "error", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		logOut = f
+	}
+	handlerOpts := &slog.HandlerOptions{Level: level}
+	var logHandler slog.Handler
+	switch *logFormat {
+	case "json":
+		logHandler = slog.NewJSONHandler(logOut, handlerOpts)
+	case "text":
+		logHandler = slog.NewTextHandler(logOut, handlerOpts)
+	default:
+		slog.Error("Invalid log format, must be json or text", "format", *logFormat)
+		os.Exit(1)
+	}
+	logger := slog.New(logHandler)
 	slog.SetDefault(logger)
 
+	if *defaultModel != "" && !telemetry.KnownModel(*defaultModel) {
+		slog.Error("Unknown default model, must name a priced model family", "model", *defaultModel)
+		os.Exit(1)
+	}
+
 	// Determine town root
 	root := *townRoot
 	if root == "" {
@@ -52,12 +110,24 @@ func main() {
 		slog.Error("Town root directory not found", "path", root)
 		os.Exit(1)
 	}
-	slog.Info("Starting Town View", "town_root", root, "port", *port)
+	// Determine data directory for townview's own state
+	data := *dataDir
+	if data == "" {
+		data = filepath.Join(root, ".townview")
+	}
+	if err := os.MkdirAll(data, 0755); err != nil {
+		slog.Error("Failed to create data directory", "path", data, "error", err)
+		os.Exit(1)
+	}
+	slog.Info("Starting Town View", "town_root", root, "data_dir", data, "port", *port)
 
 	// Initialize Service Layer components
 
-	// Event Store - central event collection (in-memory for now)
-	eventStore, err := events.NewStore(events.DefaultConfig())
+	// Event Store - central event collection
+	eventConfig := events.DefaultConfig()
+	eventConfig.DBPath = filepath.Join(data, "events.db")
+	eventConfig.SubscriberBuffer = *eventBuffer
+	eventStore, err := events.NewStore(eventConfig)
 	if err != nil {
 		slog.Error("Failed to create EventStore", "error", err)
 		os.Exit(1)
@@ -69,9 +139,16 @@ func main() {
 	agentRegistry.Start()
 	defer agentRegistry.Stop()
 
+	// Exec limiter - caps concurrent bd/gt/tmux processes across the server
+	execLimiter := execlimit.New(*maxSubprocesses)
+
 	// Rig Manager - discovers rigs and manages Query Services
 	rigMgr, err := rigmanager.New(rigmanager.Config{
-		TownRoot: root,
+		TownRoot:             root,
+		ServeStaleOnError:    *serveStale,
+		DegradedAfterMissed:  *degradedAfter,
+		UnhealthyAfterMissed: *unhealthyAfter,
+		ExecLimiter:          execLimiter,
 	}, eventStore, agentRegistry)
 	if err != nil {
 		slog.Error("Failed to create RigManager", "error", err)
@@ -81,54 +158,143 @@ func main() {
 
 	// Mail client - still uses CLI (no replacement yet)
 	mailClient := mail.NewClient(root)
+	mailClient.SetExecLimiter(execLimiter)
 
 	// Telemetry Collector - tracks test results, token usage, git changes
-	telemetryDBPath := filepath.Join(root, "telemetry.db")
-	telemetryCollector, err := telemetry.NewSQLiteCollector(telemetryDBPath)
+	telemetryDBPath := filepath.Join(data, "telemetry.db")
+	legacyTelemetryDBPath := filepath.Join(root, "telemetry.db")
+	if _, err := os.Stat(telemetryDBPath); os.IsNotExist(err) {
+		if _, err := os.Stat(legacyTelemetryDBPath); err == nil {
+			slog.Warn("Found telemetry database in the town root; move it into the data directory to keep its history",
+				"from", legacyTelemetryDBPath, "to", telemetryDBPath)
+		}
+	}
+	telemetryCollector, err := openTelemetry(telemetryDBPath, filepath.Join(data, "telemetry"), *telemetryPerRig, func(rig string) bool {
+		_, err := rigMgr.GetRig(rig)
+		return err == nil
+	})
+	if errors.Is(err, errRigTelemetryUnread) {
+		slog.Error("Refusing to start", "error", err)
+		os.Exit(1)
+	}
 	if err != nil {
 		slog.Warn("Failed to create telemetry collector, telemetry endpoints will be disabled", "error", err)
 	}
 	if telemetryCollector != nil {
 		defer telemetryCollector.Close()
+		rigMgr.SetProgressRecorder(telemetryCollector)
+		telemetryCollector.SetAnomalyMultiplier(*anomalyMultiplier)
+		telemetryCollector.SetTokenCoalesceWindow(*tokenCoalesce)
+		if *testOwners != "" {
+			owners, err := telemetry.LoadOwners(*testOwners)
+			if err != nil {
+				slog.Warn("Failed to load test owners, tests will have no owner", "path", *testOwners, "error", err)
+			} else {
+				telemetryCollector.SetOwners(owners)
+				slog.Info("Loaded test owners", "path", *testOwners, "rules", len(owners))
+			}
+		}
 	}
 
 	// Set up HTTP handlers with Service Layer
 	h := handlers.New(rigMgr, eventStore, agentRegistry, mailClient, telemetryCollector, root)
+	h.SetMaxPageSize(*maxPageSize)
+	h.SetWriteToken(*writeToken)
+	h.SetTelemetryToken(*telemetryToken)
+	h.SetExecLimiter(execLimiter)
+	if *templatesDir == "" {
+		*templatesDir = filepath.Join(data, "templates")
+	}
+	h.SetTemplatesDir(*templatesDir)
+	h.SetMaxRunOutput(*maxTestOutput)
+	h.SetDefaultModel(*defaultModel)
+	h.SetReadOnly(*readOnly)
+	rigMgr.SetReadOnlyCheck(h.ReadOnly)
+	rigMgr.StartSnapshots(*snapshotInterval)
+	stopAgentStatusEvents := rigMgr.StartAgentStatusEvents()
+	defer stopAgentStatusEvents()
+	if *warmCache {
+		rigMgr.SetWarmFilters(h.WarmIssueFilters())
+	}
+	if *readOnly {
+		slog.Warn("Starting in read-only mode, writes are disabled")
+	}
+	h.Preflight()
 	wsHandler := handlers.NewWebSocketHandler(rigMgr, eventStore, agentRegistry, mailClient)
+	wsHandler.SetCompression(*wsCompression)
 
 	// Start WebSocket hub
 	go wsHandler.Hub().Run()
+	stopEventBridge := wsHandler.StartEventBridge()
+	defer stopEventBridge()
 
 	// Routes
 	mux := http.NewServeMux()
 
 	// API routes
 	mux.HandleFunc("GET /api/rigs", h.ListRigs)
+	mux.HandleFunc("POST /api/rigs/rediscover", h.RediscoverRigs)
 	mux.HandleFunc("GET /api/rigs/{rigId}", h.GetRig)
 	mux.HandleFunc("GET /api/rigs/{rigId}/issues", h.ListIssues)
+	mux.HandleFunc("POST /api/rigs/{rigId}/issues", h.CreateIssue)
+	mux.HandleFunc("GET /api/rigs/{rigId}/issues/closed", h.ListClosedIssues)
 	mux.HandleFunc("GET /api/rigs/{rigId}/issues/{issueId}", h.GetIssue)
 	mux.HandleFunc("PATCH /api/rigs/{rigId}/issues/{issueId}", h.UpdateIssue)
+	mux.HandleFunc("DELETE /api/rigs/{rigId}/issues/{issueId}", h.DeleteIssue)
+	mux.HandleFunc("POST /api/rigs/{rigId}/issues/{issueId}/move", h.MoveIssue)
 	mux.HandleFunc("GET /api/rigs/{rigId}/issues/{issueId}/dependencies", h.GetIssueDependencies)
+	mux.HandleFunc("GET /api/rigs/{rigId}/issues/{issueId}/dependencies/raw", h.GetRawIssueDependencies)
+	mux.HandleFunc("GET /api/rigs/{rigId}/issues/{issueId}/graph", h.GetDependencyGraph)
+	mux.HandleFunc("GET /api/rigs/{rigId}/issues/{issueId}/unblocks", h.GetIssueUnblocks)
+	mux.HandleFunc("GET /api/rigs/{rigId}/issues/{issueId}/snapshot", h.GetIssueSnapshot)
+	mux.HandleFunc("GET /api/rigs/{rigId}/issues/{issueId}/snapshots/diff", h.DiffIssueSnapshots)
 	mux.HandleFunc("POST /api/rigs/{rigId}/issues/{issueId}/dependencies", h.AddIssueDependency)
 	mux.HandleFunc("DELETE /api/rigs/{rigId}/issues/{issueId}/dependencies/{blockerId}", h.RemoveIssueDependency)
+	mux.HandleFunc("POST /api/rigs/{rigId}/issues/{issueId}/labels/{label}", h.AddIssueLabel)
+	mux.HandleFunc("DELETE /api/rigs/{rigId}/issues/{issueId}/labels/{label}", h.RemoveIssueLabel)
 	mux.HandleFunc("GET /api/rigs/{rigId}/agents", h.ListAgents)
+	mux.HandleFunc("GET /api/rigs/{rigId}/agents/stuck", h.ListStuckAgents)
 	mux.HandleFunc("GET /api/rigs/{rigId}/agents/{agentId}/peek", h.PeekAgent)
 	mux.HandleFunc("GET /api/rigs/{rigId}/agents/{agentId}/mail", h.GetAgentMail)
+	mux.HandleFunc("GET /api/rigs/{rigId}/agents/{agentId}/mail/unread-count", h.GetAgentUnreadMailCount)
 	mux.HandleFunc("GET /api/mail/{mailId}", h.GetMailMessage)
+	mux.HandleFunc("GET /api/templates", h.ListTemplates)
+	mux.HandleFunc("POST /api/agents/heartbeat", h.AgentHeartbeat)
+	mux.HandleFunc("GET /api/agents/active", h.ListActiveAgents)
+	mux.HandleFunc("GET /api/agents/stuck", h.ListStuckAgents)
+	mux.HandleFunc("GET /api/agents/stale-state", h.ListStaleStateAgents)
 	mux.HandleFunc("GET /api/rigs/{rigId}/dependencies", h.ListDependencies)
+	mux.HandleFunc("POST /api/rigs/{rigId}/dependencies/batch", h.AddDependenciesBatch)
 	mux.HandleFunc("GET /api/rigs/{rigId}/issues/{issueId}/progress", h.GetMoleculeProgress)
+	mux.HandleFunc("GET /api/rigs/{rigId}/convoys/{id}/history", h.GetConvoyProgressHistory)
 	mux.HandleFunc("GET /api/rigs/{rigId}/activity", h.GetRecentActivity)
 	mux.HandleFunc("GET /api/rigs/{rigId}/mail", h.ListRigMail)
+	mux.HandleFunc("GET /api/rigs/{rigId}/telemetry/tokens/summary", h.GetRigTokenSummary)
+	mux.HandleFunc("GET /api/rigs/{rigId}/cache/stats", h.GetRigCacheStats)
+
+	// Cache (town-level)
+	mux.HandleFunc("GET /api/cache/stats", h.GetAllCacheStats)
+
+	// Events (town-level)
+	mux.HandleFunc("GET /api/events/export", h.ExportEvents)
+	mux.HandleFunc("GET /api/events/search", h.SearchEvents)
+	mux.HandleFunc("GET /api/events/stats", h.GetEventStats)
 
 	// Mail (town-level)
 	mux.HandleFunc("GET /api/mail", h.ListMail)
 
 	// Telemetry (test suite status)
+	mux.HandleFunc("GET /api/telemetry/summary", h.GetTelemetrySummary)
 	mux.HandleFunc("GET /api/telemetry/tests", h.GetTestSuiteStatus)
 	mux.HandleFunc("POST /api/telemetry/tests", h.CreateTestRun)
+	mux.HandleFunc("GET /api/telemetry/runs", h.GetTestRuns)
 	mux.HandleFunc("GET /api/telemetry/tests/{testName}/history", h.GetTestHistory)
 	mux.HandleFunc("GET /api/telemetry/regressions", h.GetRegressions)
+	mux.HandleFunc("GET /api/telemetry/commits/{sha}", h.GetCommitActivity)
+	mux.HandleFunc("GET /api/telemetry/coverage-gaps", h.GetCoverageGaps)
+	mux.HandleFunc("GET /api/telemetry/commits/{sha}/gate", h.GetCommitGate)
 	mux.HandleFunc("GET /api/telemetry/tokens/summary", h.GetTokenSummary)
+	mux.HandleFunc("GET /api/telemetry/tokens/anomalies", h.GetTokenAnomalies)
 
 	// Telemetry (git changes)
 	mux.HandleFunc("GET /api/telemetry/git", h.GetGitChanges)
@@ -137,7 +303,19 @@ func main() {
 
 	// Telemetry (agent/bead)
 	mux.HandleFunc("GET /api/telemetry/agents/{agentId}", h.GetAgentTelemetry)
+	mux.HandleFunc("GET /api/telemetry/agents/{agentId}/test-health", h.GetAgentTestHealth)
 	mux.HandleFunc("GET /api/telemetry/beads/{beadId}", h.GetBeadTelemetry)
+	mux.HandleFunc("GET /api/telemetry/beads/{beadId}/budget", h.GetBeadBudget)
+	mux.HandleFunc("PUT /api/telemetry/beads/{beadId}/budget", h.SetBeadBudget)
+
+	// Readiness (tooling preflight)
+	mux.HandleFunc("GET /readyz", h.Readyz)
+
+	// Maintenance mode
+	mux.HandleFunc("GET /api/admin/readonly", h.GetReadOnly)
+	mux.HandleFunc("PUT /api/admin/readonly", h.SetReadOnlyMode)
+	mux.HandleFunc("DELETE /api/admin/rigs/{rigId}", h.RemoveRig)
+	mux.HandleFunc("DELETE /api/admin/rigs/{rigId}/events", h.PurgeRigEvents)
 
 	// WebSocket (real-time data streaming)
 	mux.Handle("GET /ws", wsHandler)
@@ -145,8 +323,15 @@ func main() {
 	// Static files (frontend build)
 	mux.Handle("/", http.FileServer(http.Dir("./static")))
 
+	// Reject writes during maintenance
+	handler := h.ReadOnlyMiddleware(mux)
+
+	// Bound request latency, except for long-lived streams. http.TimeoutHandler
+	// buffers the response, so streamed issue lists must bypass it to flush.
+	handler = timeoutMiddleware(handler, *requestTimeout, "/ws", "/api/events/export")
+
 	// CORS middleware for development
-	handler := corsMiddleware(mux)
+	handler = corsMiddleware(handler)
 
 	// Start server
 	addr := fmt.Sprintf(":%d", *port)
@@ -157,12 +342,102 @@ func main() {
 	}
 }
 
+// telemetryStore is a telemetry collector that can be configured at startup.
+type telemetryStore interface {
+	telemetry.Collector
+	SetOwners(owners telemetry.Owners)
+	SetAnomalyMultiplier(m float64)
+	SetTokenCoalesceWindow(window time.Duration)
+}
+
+// errRigTelemetryUnread means per-rig telemetry databases exist but per-rig
+// mode is off, so their history would silently disappear from every query.
+var errRigTelemetryUnread = errors.New("per-rig telemetry databases exist; pass --telemetry-per-rig to keep reading them, or move them aside")
+
+// openTelemetry opens the single shared telemetry database, or one database
+// per rig under rigDir when perRig is set. Only rigs rigExists accepts get
+// their own database. The shared database is the same file in both modes, so
+// enabling per-rig mode keeps its history; disabling it fails with
+// errRigTelemetryUnread while rig databases remain in rigDir.
+func openTelemetry(dbPath, rigDir string, perRig bool, rigExists func(rig string) bool) (telemetryStore, error) {
+	if perRig {
+		c, err := telemetry.NewPerRigCollector(dbPath, rigDir)
+		if err != nil {
+			return nil, err
+		}
+		c.SetRigValidator(rigExists)
+		slog.Info("Telemetry stored per rig", "dir", rigDir)
+		return c, nil
+	}
+	rigs, err := telemetry.RigDatabases(rigDir)
+	if err != nil {
+		return nil, err
+	}
+	if len(rigs) > 0 {
+		return nil, fmt.Errorf("%w (%s: %s)", errRigTelemetryUnread, rigDir, strings.Join(rigs, ", "))
+	}
+	c, err := telemetry.NewSQLiteCollector(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// timeoutMiddleware replies 503 with a structured error when a request runs
+// longer than timeout. Requests matching an exempt pattern are passed through
+// untouched. Patterns are path.Match paths, optionally prefixed by a method,
+// e.g. "GET /api/rigs/*/issues".
+func timeoutMiddleware(next http.Handler, timeout time.Duration, exempt ...string) http.Handler {
+	if timeout <= 0 {
+		return next
+	}
+
+	timed := http.TimeoutHandler(next, timeout, handlers.ErrorBody(handlers.ErrCodeRequestTimeout, "Request timed out"))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, pattern := range exempt {
+			if exemptMatches(pattern, r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		timed.ServeHTTP(timeoutJSONWriter{w}, r)
+	})
+}
+
+// timeoutJSONWriter labels http.TimeoutHandler's 503 body as JSON. On timeout
+// it writes the body straight to the client without any handler headers; a
+// handler's own response arrives with its Content-Type already copied over.
+type timeoutJSONWriter struct {
+	http.ResponseWriter
+}
+
+func (w timeoutJSONWriter) WriteHeader(code int) {
+	if code == http.StatusServiceUnavailable && w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// exemptMatches reports whether a request matches a timeout exemption pattern.
+func exemptMatches(pattern string, r *http.Request) bool {
+	if method, rest, ok := strings.Cut(pattern, " "); ok {
+		if r.Method != method {
+			return false
+		}
+		pattern = rest
+	}
+	matched, _ := path.Match(pattern, r.URL.Path)
+	return matched
+}
+
 // corsMiddleware adds CORS headers for development.
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PATCH, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Total-Count, X-Has-More, X-Page-Limit, X-Cache, X-Suppressed-Flaky")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gastown/townview/internal/handlers"
+	"github.com/gastown/townview/internal/telemetry"
+)
+
+func TestTimeoutMiddleware_ExemptStreamsFlush(t *testing.T) {
+	var flushable bool
+	stream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var flusher http.Flusher
+		flusher, flushable = w.(http.Flusher)
+		w.Write([]byte("["))
+		if flushable {
+			flusher.Flush()
+		}
+		w.Write([]byte("]"))
+	})
+	handler := timeoutMiddleware(stream, time.Minute, "/ws", "GET /api/rigs/*/issues")
+
+	tests := []struct {
+		method  string
+		path    string
+		flushed bool
+	}{
+		{http.MethodGet, "/api/rigs/townview/issues", true},
+		{http.MethodGet, "/ws", true},
+		{http.MethodPost, "/api/rigs/townview/issues", false},       // exemption is GET only
+		{http.MethodGet, "/api/rigs/townview/issues/to-1", false},   // not the list route
+		{http.MethodGet, "/api/rigs/townview/issues/closed", false}, // not the list route
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if flushable != tt.flushed || rec.Flushed != tt.flushed {
+			t.Errorf("%s %s: flushable=%v flushed=%v, want %v", tt.method, tt.path, flushable, rec.Flushed, tt.flushed)
+		}
+		if rec.Body.String() != "[]" {
+			t.Errorf("%s %s: body %q, want []", tt.method, tt.path, rec.Body.String())
+		}
+	}
+}
+
+func TestTimeoutMiddleware_SlowHandlerGetsJSONError(t *testing.T) {
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	handler := timeoutMiddleware(slow, 10*time.Millisecond)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/rigs", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected application/json, got %q", ct)
+	}
+	var resp handlers.ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("expected a JSON error body, got %q: %v", rec.Body.String(), err)
+	}
+	if resp.Error.Code != handlers.ErrCodeRequestTimeout {
+		t.Errorf("expected %s, got %s", handlers.ErrCodeRequestTimeout, resp.Error.Code)
+	}
+}
+
+func TestTimeoutMiddleware_FastHandlerKeepsItsContentType(t *testing.T) {
+	csv := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/csv")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	rec := httptest.NewRecorder()
+	timeoutMiddleware(csv, time.Minute).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/rigs", nil))
+
+	if ct := rec.Header().Get("Content-Type"); ct != "text/csv" {
+		t.Errorf("expected the handler's text/csv, got %q", ct)
+	}
+}
+
+func TestOpenTelemetry_SwitchingModesKeepsHistory(t *testing.T) {
+	data := t.TempDir()
+	dbPath, rigDir := filepath.Join(data, "telemetry.db"), filepath.Join(data, "telemetry")
+	anyRig := func(string) bool { return true }
+	record := func(c telemetryStore, rig string) {
+		t.Helper()
+		usage := telemetry.TokenUsage{AgentID: rig + "/polecats/a1", Rig: rig, Timestamp: "2026-01-24T10:00:00Z", InputTokens: 10, Model: "m"}
+		if err := c.RecordTokenUsage(usage); err != nil {
+			t.Fatalf("RecordTokenUsage failed: %v", err)
+		}
+	}
+
+	shared, err := openTelemetry(dbPath, rigDir, false, anyRig)
+	if err != nil {
+		t.Fatalf("openTelemetry failed: %v", err)
+	}
+	record(shared, "rig-a")
+	shared.Close()
+
+	// Enabling per-rig mode still reads rows recorded in the shared database
+	perRig, err := openTelemetry(dbPath, rigDir, true, anyRig)
+	if err != nil {
+		t.Fatalf("openTelemetry per rig failed: %v", err)
+	}
+	record(perRig, "rig-a")
+	usage, err := perRig.GetTokenUsage(telemetry.TelemetryFilter{Rig: "rig-a"})
+	if err != nil || len(usage) != 2 {
+		t.Fatalf("expected shared and rig rows, got %+v, %v", usage, err)
+	}
+	perRig.Close()
+
+	// Disabling it again would hide the rig database, so it is refused
+	if c, err := openTelemetry(dbPath, rigDir, false, anyRig); !errors.Is(err, errRigTelemetryUnread) {
+		if c != nil {
+			c.Close()
+		}
+		t.Fatalf("expected errRigTelemetryUnread, got %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Event types with a typed payload.
+const (
+	TypeBeadUpdated        = "bead.updated"
+	TypeAgentStatusChanged = "agent.status_changed"
+)
+
+// BeadUpdatedPayload is the payload of a bead.updated event.
+type BeadUpdatedPayload struct {
+	IssueID  string `json:"issue_id"`
+	Title    string `json:"title,omitempty"`
+	Rig      string `json:"rig,omitempty"`
+	OldValue string `json:"old_value,omitempty"`
+	NewValue string `json:"new_value,omitempty"`
+}
+
+func (p BeadUpdatedPayload) validate() error {
+	if p.IssueID == "" {
+		return errors.New("missing issue_id")
+	}
+	return nil
+}
+
+// AgentStatusChangedPayload is the payload of an agent.status_changed event.
+type AgentStatusChangedPayload struct {
+	AgentID  string `json:"agent_id"`
+	Rig      string `json:"rig,omitempty"`
+	OldValue string `json:"old_value,omitempty"` // Previous status
+	NewValue string `json:"new_value"`           // Current status
+}
+
+func (p AgentStatusChangedPayload) validate() error {
+	if p.AgentID == "" {
+		return errors.New("missing agent_id")
+	}
+	if p.NewValue == "" {
+		return errors.New("missing new_value")
+	}
+	return nil
+}
+
+// UnmarshalPayload decodes an event's payload as T. It fails when the payload
+// is empty, has a field of the wrong JSON type, or is missing a field T
+// requires, so callers can tell a malformed event from one with blank values.
+func UnmarshalPayload[T any](e Event) (T, error) {
+	var payload T
+	if len(e.Payload) == 0 {
+		return payload, fmt.Errorf("event %d (%s): empty payload", e.ID, e.Type)
+	}
+	if err := json.Unmarshal(e.Payload, &payload); err != nil {
+		return payload, fmt.Errorf("event %d (%s): %w", e.ID, e.Type, err)
+	}
+	if v, ok := any(payload).(interface{ validate() error }); ok {
+		if err := v.validate(); err != nil {
+			return payload, fmt.Errorf("event %d (%s): %w", e.ID, e.Type, err)
+		}
+	}
+	return payload, nil
+}
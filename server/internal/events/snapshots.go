@@ -0,0 +1,247 @@
+package events
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// IssueSnapshot is the full state of an issue at a point in time.
+type IssueSnapshot struct {
+	ID        int64           `json:"id"`
+	Rig       string          `json:"rig"`
+	IssueID   string          `json:"issue_id"`
+	Timestamp time.Time       `json:"timestamp"`
+	State     json.RawMessage `json:"state"`             // The issue as served by the API; null once deleted
+	Deleted   bool            `json:"deleted,omitempty"` // The issue was gone from the rig at this time
+}
+
+// deletedState is the state recorded for an issue that left its rig.
+const deletedState = "null"
+
+// FieldChange is one top-level issue field that differs between two snapshots.
+type FieldChange struct {
+	Field string          `json:"field"`
+	From  json.RawMessage `json:"from"` // null when the field was absent
+	To    json.RawMessage `json:"to"`   // null when the field was removed
+}
+
+// RecordIssueSnapshots stores a snapshot of each issue in states (issue ID to
+// JSON state) taken at the given time, skipping issues whose state is
+// unchanged since their latest snapshot. Returns how many were stored.
+func (s *Store) RecordIssueSnapshots(rig string, at time.Time, states map[string][]byte) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin snapshot transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	latest, err := tx.Prepare(`
+		SELECT state FROM issue_snapshots
+		WHERE rig = ? AND issue_id = ?
+		ORDER BY timestamp DESC, id DESC LIMIT 1`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare snapshot lookup: %w", err)
+	}
+	defer latest.Close()
+
+	insert, err := tx.Prepare(`INSERT INTO issue_snapshots (rig, issue_id, timestamp, state) VALUES (?, ?, ?, ?)`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare snapshot insert: %w", err)
+	}
+	defer insert.Close()
+
+	at = at.UTC()
+	stored := 0
+	for issueID, state := range states {
+		var prev string
+		err := latest.QueryRow(rig, issueID).Scan(&prev)
+		if err != nil && err != sql.ErrNoRows {
+			return 0, fmt.Errorf("failed to query latest snapshot: %w", err)
+		}
+		if err == nil && prev == string(state) {
+			continue
+		}
+		if _, err := insert.Exec(rig, issueID, at, string(state)); err != nil {
+			return 0, fmt.Errorf("failed to insert snapshot: %w", err)
+		}
+		stored++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit snapshots: %w", err)
+	}
+	return stored, nil
+}
+
+// RecordDeletedIssues stores a deletion snapshot, taken at the given time, for
+// each issue of the rig whose latest snapshot is live but which is absent from
+// states (the rig's full set of current issues). Returns how many were stored.
+func (s *Store) RecordDeletedIssues(rig string, at time.Time, states map[string][]byte) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin snapshot transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT issue_id FROM issue_snapshots
+		WHERE rig = ? AND state != ? AND id = (
+			SELECT id FROM issue_snapshots AS latest
+			WHERE latest.rig = issue_snapshots.rig AND latest.issue_id = issue_snapshots.issue_id
+			ORDER BY latest.timestamp DESC, latest.id DESC LIMIT 1
+		)`, rig, deletedState)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query live snapshots: %w", err)
+	}
+	var gone []string
+	for rows.Next() {
+		var issueID string
+		if err := rows.Scan(&issueID); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan live snapshot: %w", err)
+		}
+		if _, ok := states[issueID]; !ok {
+			gone = append(gone, issueID)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to iterate live snapshots: %w", err)
+	}
+
+	at = at.UTC()
+	for _, issueID := range gone {
+		if _, err := tx.Exec(`INSERT INTO issue_snapshots (rig, issue_id, timestamp, state) VALUES (?, ?, ?, ?)`,
+			rig, issueID, at, deletedState); err != nil {
+			return 0, fmt.Errorf("failed to insert deletion snapshot: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit deletion snapshots: %w", err)
+	}
+	return len(gone), nil
+}
+
+// PurgeRigSnapshots deletes every issue snapshot recorded for a rig and
+// returns how many were removed.
+func (s *Store) PurgeRigSnapshots(rigID string) (int64, error) {
+	result, err := s.db.Exec("DELETE FROM issue_snapshots WHERE rig = ?", rigID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge rig snapshots: %w", err)
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count purged snapshots: %w", err)
+	}
+	return count, nil
+}
+
+// pruneIssueSnapshots deletes snapshots taken before cutoff, except each
+// issue's latest, which stays as the baseline for change detection and for
+// lookups at later times. Returns how many were removed.
+func (s *Store) pruneIssueSnapshots(cutoff time.Time) (int64, error) {
+	result, err := s.db.Exec(`
+		DELETE FROM issue_snapshots
+		WHERE timestamp < ? AND id NOT IN (
+			SELECT id FROM issue_snapshots AS latest
+			WHERE latest.rig = issue_snapshots.rig AND latest.issue_id = issue_snapshots.issue_id
+			ORDER BY latest.timestamp DESC, latest.id DESC LIMIT 1
+		)`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune snapshots: %w", err)
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count pruned snapshots: %w", err)
+	}
+	return count, nil
+}
+
+// GetIssueSnapshot returns the latest snapshot of a rig's issue taken at or
+// before at, or nil if the issue had not been snapshotted by then.
+func (s *Store) GetIssueSnapshot(rig, issueID string, at time.Time) (*IssueSnapshot, error) {
+	var snap IssueSnapshot
+	var state string
+	err := s.db.QueryRow(`
+		SELECT id, rig, issue_id, timestamp, state FROM issue_snapshots
+		WHERE rig = ? AND issue_id = ? AND timestamp <= ?
+		ORDER BY timestamp DESC, id DESC LIMIT 1`, rig, issueID, at.UTC()).Scan(
+		&snap.ID, &snap.Rig, &snap.IssueID, &snap.Timestamp, &state)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query snapshot: %w", err)
+	}
+	snap.State = json.RawMessage(state)
+	snap.Deleted = state == deletedState
+	return &snap, nil
+}
+
+// DiffIssueSnapshots lists the top-level fields whose values differ between
+// two snapshot states, by field name. A nil or deletion snapshot diffs as an
+// empty issue.
+func DiffIssueSnapshots(from, to *IssueSnapshot) ([]FieldChange, error) {
+	fromFields, err := snapshotFields(from)
+	if err != nil {
+		return nil, err
+	}
+	toFields, err := snapshotFields(to)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool, len(fromFields)+len(toFields))
+	for name := range fromFields {
+		names[name] = true
+	}
+	for name := range toFields {
+		names[name] = true
+	}
+
+	changes := []FieldChange{}
+	for name := range names {
+		a, b := fromFields[name], toFields[name]
+		if bytes.Equal(a, b) {
+			continue
+		}
+		change := FieldChange{Field: name, From: json.RawMessage("null"), To: json.RawMessage("null")}
+		if a != nil {
+			change.From = a
+		}
+		if b != nil {
+			change.To = b
+		}
+		changes = append(changes, change)
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Field < changes[j].Field
+	})
+	return changes, nil
+}
+
+// snapshotFields splits a snapshot's state into compacted top-level field values.
+func snapshotFields(snap *IssueSnapshot) (map[string][]byte, error) {
+	fields := map[string][]byte{}
+	if snap == nil || snap.Deleted || len(snap.State) == 0 {
+		return fields, nil
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(snap.State, &raw); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot %d: %w", snap.ID, err)
+	}
+	for name, value := range raw {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, value); err != nil {
+			return nil, fmt.Errorf("failed to compact snapshot field %s: %w", name, err)
+		}
+		fields[name] = buf.Bytes()
+	}
+	return fields, nil
+}
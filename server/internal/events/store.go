@@ -6,9 +6,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/gastown/townview/internal/migrate"
 	_ "github.com/mattn/go-sqlite3"
 )
 
@@ -24,12 +27,17 @@ type Event struct {
 
 // EventFilter defines query parameters for filtering events.
 type EventFilter struct {
-	Type      string     // Filter by event type (empty for all)
-	Source    string     // Filter by source (empty for all)
-	Rig       string     // Filter by rig (empty for all)
-	StartTime *time.Time // Filter events after this time
-	EndTime   *time.Time // Filter events before this time
-	Limit     int        // Maximum events to return (0 for no limit)
+	Type         string   // Filter by event type (empty for all)
+	Source       string   // Filter by source (empty for all)
+	Rig          string   // Filter by rig (empty for all)
+	ExcludeTypes []string // Event types to leave out (empty for none)
+
+	// ExcludeAgentTransitions leaves out agent.* events whose payload old_value
+	// and new_value are both in the set, e.g. routine idle<->working flaps
+	ExcludeAgentTransitions []string
+	StartTime               *time.Time // Filter events after this time
+	EndTime                 *time.Time // Filter events before this time
+	Limit                   int        // Maximum events to return (0 for no limit)
 }
 
 // subscriber represents a subscription to event notifications.
@@ -40,17 +48,33 @@ type subscriber struct {
 
 // StoreConfig holds configuration for the event store.
 type StoreConfig struct {
-	DBPath         string        // Path to SQLite database file
-	RetentionDays  int           // Number of days to retain events (default 30)
-	CleanupPeriod  time.Duration // How often to run cleanup (default 1 hour)
+	DBPath        string        // Path to SQLite database file
+	RetentionDays int           // Number of days to retain events and issue snapshots (default 30)
+	CleanupPeriod time.Duration // How often to run cleanup (default 1 hour)
+
+	// Per-subscriber channel capacity; events are dropped when it fills (default 256)
+	SubscriberBuffer int
+}
+
+// StoreStats reports event delivery counters since the store was created.
+// Delivered and Dropped count per-subscriber deliveries, so one event can add
+// to both when several subscribers match it.
+type StoreStats struct {
+	Emitted          uint64 `json:"emitted"`
+	Delivered        uint64 `json:"delivered"`
+	Dropped          uint64 `json:"dropped"`
+	Subscribers      int    `json:"subscribers"`
+	SubscriberBuffer int    `json:"subscriber_buffer"`
 }
 
 // DefaultConfig returns a default store configuration.
 func DefaultConfig() StoreConfig {
 	return StoreConfig{
-		DBPath:         ":memory:",
-		RetentionDays:  30,
-		CleanupPeriod:  time.Hour,
+		DBPath:        ":memory:",
+		RetentionDays: 30,
+		CleanupPeriod: time.Hour,
+
+		SubscriberBuffer: 256,
 	}
 }
 
@@ -61,6 +85,52 @@ type Store struct {
 	subscribers map[*subscriber]bool
 	mu          sync.RWMutex
 	stopCleanup chan struct{}
+
+	// Delivery counters (atomic access)
+	emitted   uint64
+	delivered uint64
+	dropped   uint64
+}
+
+// migrations is the ordered event store schema history.
+var migrations = []migrate.Migration{
+	{Version: 1, Name: "create events", Up: func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			CREATE TABLE IF NOT EXISTS events (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				type TEXT NOT NULL,
+				source TEXT NOT NULL,
+				rig TEXT NOT NULL,
+				payload TEXT,
+				timestamp DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX IF NOT EXISTS idx_events_timestamp ON events(timestamp);
+			CREATE INDEX IF NOT EXISTS idx_events_type ON events(type);
+			CREATE INDEX IF NOT EXISTS idx_events_source ON events(source);
+			CREATE INDEX IF NOT EXISTS idx_events_rig ON events(rig);
+		`)
+		return err
+	}},
+	{Version: 2, Name: "create issue snapshots", Up: func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			CREATE TABLE IF NOT EXISTS issue_snapshots (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				rig TEXT NOT NULL,
+				issue_id TEXT NOT NULL,
+				timestamp DATETIME NOT NULL,
+				state TEXT NOT NULL
+			);
+			CREATE INDEX IF NOT EXISTS idx_issue_snapshots_issue ON issue_snapshots(issue_id, timestamp);
+		`)
+		return err
+	}},
+	{Version: 3, Name: "index issue snapshots by rig", Up: func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			DROP INDEX IF EXISTS idx_issue_snapshots_issue;
+			CREATE INDEX IF NOT EXISTS idx_issue_snapshots_rig_issue ON issue_snapshots(rig, issue_id, timestamp);
+		`)
+		return err
+	}},
 }
 
 // NewStore creates a new event store with the given configuration.
@@ -70,34 +140,13 @@ func NewStore(config StoreConfig) (*Store, error) {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	// Create events table
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS events (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			type TEXT NOT NULL,
-			source TEXT NOT NULL,
-			rig TEXT NOT NULL,
-			payload TEXT,
-			timestamp DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
-		)
-	`)
-	if err != nil {
+	if err := migrate.Run(db, migrations); err != nil {
 		db.Close()
-		return nil, fmt.Errorf("failed to create events table: %w", err)
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
 
-	// Create indexes for efficient querying
-	indexes := []string{
-		"CREATE INDEX IF NOT EXISTS idx_events_timestamp ON events(timestamp)",
-		"CREATE INDEX IF NOT EXISTS idx_events_type ON events(type)",
-		"CREATE INDEX IF NOT EXISTS idx_events_source ON events(source)",
-		"CREATE INDEX IF NOT EXISTS idx_events_rig ON events(rig)",
-	}
-	for _, idx := range indexes {
-		if _, err := db.Exec(idx); err != nil {
-			db.Close()
-			return nil, fmt.Errorf("failed to create index: %w", err)
-		}
+	if config.SubscriberBuffer <= 0 {
+		config.SubscriberBuffer = 256
 	}
 
 	s := &Store{
@@ -167,32 +216,77 @@ func (s *Store) Emit(eventType, source, rig string, payload interface{}) error {
 	return nil
 }
 
-// Query retrieves events matching the filter criteria.
-func (s *Store) Query(filter EventFilter) ([]Event, error) {
-	query := "SELECT id, type, source, rig, payload, timestamp FROM events WHERE 1=1"
-	args := []interface{}{}
+// EventInput describes an event to be emitted as part of a batch.
+type EventInput struct {
+	Type    string
+	Source  string
+	Rig     string
+	Payload interface{}
+}
 
-	if filter.Type != "" {
-		query += " AND type = ?"
-		args = append(args, filter.Type)
+// EmitBatch stores all events in a single transaction and notifies subscribers
+// once per event after the transaction commits. Either all events are stored or none are.
+func (s *Store) EmitBatch(inputs []EventInput) error {
+	if len(inputs) == 0 {
+		return nil
 	}
-	if filter.Source != "" {
-		query += " AND source = ?"
-		args = append(args, filter.Source)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
-	if filter.Rig != "" {
-		query += " AND rig = ?"
-		args = append(args, filter.Rig)
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare("INSERT INTO events (type, source, rig, payload, timestamp) VALUES (?, ?, ?, ?, ?)")
+	if err != nil {
+		return fmt.Errorf("failed to prepare insert: %w", err)
 	}
-	if filter.StartTime != nil {
-		query += " AND timestamp >= ?"
-		args = append(args, filter.StartTime.UTC())
+	defer stmt.Close()
+
+	timestamp := time.Now().UTC()
+	batch := make([]Event, 0, len(inputs))
+	for _, in := range inputs {
+		var payloadJSON []byte
+		if in.Payload != nil {
+			payloadJSON, err = json.Marshal(in.Payload)
+			if err != nil {
+				return fmt.Errorf("failed to marshal payload: %w", err)
+			}
+		}
+
+		result, err := stmt.Exec(in.Type, in.Source, in.Rig, string(payloadJSON), timestamp)
+		if err != nil {
+			return fmt.Errorf("failed to insert event: %w", err)
+		}
+		id, _ := result.LastInsertId()
+
+		batch = append(batch, Event{
+			ID:        id,
+			Type:      in.Type,
+			Source:    in.Source,
+			Rig:       in.Rig,
+			Payload:   payloadJSON,
+			Timestamp: timestamp,
+		})
 	}
-	if filter.EndTime != nil {
-		query += " AND timestamp <= ?"
-		args = append(args, filter.EndTime.UTC())
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit events: %w", err)
 	}
 
+	// Notify subscribers
+	for _, event := range batch {
+		s.notifySubscribers(event)
+	}
+
+	return nil
+}
+
+// Query retrieves events matching the filter criteria.
+func (s *Store) Query(filter EventFilter) ([]Event, error) {
+	where, args := filterClause(filter)
+	query := "SELECT id, type, source, rig, payload, timestamp FROM events WHERE " + where
+
 	query += " ORDER BY timestamp ASC"
 
 	if filter.Limit > 0 {
@@ -208,37 +302,193 @@ func (s *Store) Query(filter EventFilter) ([]Event, error) {
 
 	var events []Event
 	for rows.Next() {
-		var e Event
-		var payloadStr sql.NullString
-		var timestampStr string
+		e, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
 
-		if err := rows.Scan(&e.ID, &e.Type, &e.Source, &e.Rig, &payloadStr, &timestampStr); err != nil {
-			return nil, fmt.Errorf("failed to scan event: %w", err)
+	return events, rows.Err()
+}
+
+// CountByRig returns the number of events matching the filter per rig, in one
+// grouped query. filter.Limit is ignored.
+func (s *Store) CountByRig(filter EventFilter) (map[string]int, error) {
+	where, args := filterClause(filter)
+	rows, err := s.db.Query("SELECT rig, COUNT(*) FROM events WHERE "+where+" GROUP BY rig", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count events: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var rig string
+		var count int
+		if err := rows.Scan(&rig, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan event count: %w", err)
 		}
+		counts[rig] = count
+	}
+	return counts, rows.Err()
+}
+
+// exportPageSize is the number of rows fetched per page by Export.
+const exportPageSize = 500
 
-		if payloadStr.Valid {
-			e.Payload = json.RawMessage(payloadStr.String)
+// Export calls fn for every event matching the filter, in insertion order.
+// Events are read in id-keyed pages so large tables are never held in memory
+// and no read transaction stays open while fn runs. filter.Limit is ignored.
+// Iteration stops at the first error returned by fn.
+func (s *Store) Export(filter EventFilter, fn func(Event) error) error {
+	where, baseArgs := filterClause(filter)
+	query := "SELECT id, type, source, rig, payload, timestamp FROM events WHERE " + where +
+		" AND id > ? ORDER BY id ASC LIMIT ?"
+
+	var cursor int64
+	for {
+		args := append(append([]interface{}{}, baseArgs...), cursor, exportPageSize)
+		page, err := s.queryPage(query, args)
+		if err != nil {
+			return err
 		}
 
-		// Parse timestamp
-		e.Timestamp, _ = time.Parse("2006-01-02 15:04:05.999999999-07:00", timestampStr)
-		if e.Timestamp.IsZero() {
-			e.Timestamp, _ = time.Parse("2006-01-02 15:04:05", timestampStr)
+		for _, e := range page {
+			if err := fn(e); err != nil {
+				return err
+			}
 		}
-		if e.Timestamp.IsZero() {
-			e.Timestamp, _ = time.Parse(time.RFC3339, timestampStr)
+
+		if len(page) < exportPageSize {
+			return nil
 		}
+		cursor = page[len(page)-1].ID
+	}
+}
 
-		events = append(events, e)
+// likeEscaper escapes LIKE wildcards so a search term matches literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// Search returns events matching the filter whose payload, type, source or rig
+// contains q (case-insensitive for ASCII), newest first. Substring matches
+// can't use an index, so callers should scope the search with StartTime and
+// keep Limit small; the timestamp index narrows the rows scanned.
+func (s *Store) Search(q string, filter EventFilter) ([]Event, error) {
+	where, args := filterClause(filter)
+	pattern := "%" + likeEscaper.Replace(q) + "%"
+	where += ` AND (payload LIKE ? ESCAPE '\' OR type LIKE ? ESCAPE '\' OR source LIKE ? ESCAPE '\' OR rig LIKE ? ESCAPE '\')`
+	args = append(args, pattern, pattern, pattern, pattern)
+
+	query := "SELECT id, type, source, rig, payload, timestamp FROM events WHERE " + where +
+		" ORDER BY timestamp DESC, id DESC"
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
 	}
 
-	return events, rows.Err()
+	return s.queryPage(query, args)
+}
+
+// queryPage runs a query and returns all scanned events.
+func (s *Store) queryPage(query string, args []interface{}) ([]Event, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events: %w", err)
+	}
+	defer rows.Close()
+
+	page := make([]Event, 0, exportPageSize)
+	for rows.Next() {
+		e, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		page = append(page, e)
+	}
+	return page, rows.Err()
+}
+
+// filterClause builds the SQL WHERE clause and arguments for a filter.
+func filterClause(filter EventFilter) (string, []interface{}) {
+	where := "1=1"
+	args := []interface{}{}
+
+	if filter.Type != "" {
+		where += " AND type = ?"
+		args = append(args, filter.Type)
+	}
+	if filter.Source != "" {
+		where += " AND source = ?"
+		args = append(args, filter.Source)
+	}
+	if filter.Rig != "" {
+		where += " AND rig = ?"
+		args = append(args, filter.Rig)
+	}
+	if len(filter.ExcludeTypes) > 0 {
+		placeholders := make([]string, len(filter.ExcludeTypes))
+		for i, t := range filter.ExcludeTypes {
+			placeholders[i] = "?"
+			args = append(args, t)
+		}
+		where += " AND type NOT IN (" + strings.Join(placeholders, ",") + ")"
+	}
+	if n := len(filter.ExcludeAgentTransitions); n > 0 {
+		// CASE guards json_extract from payloads that aren't valid JSON
+		in := "(" + strings.TrimSuffix(strings.Repeat("?,", n), ",") + ")"
+		where += " AND NOT CASE WHEN type LIKE 'agent.%' AND json_valid(payload) THEN" +
+			" COALESCE(json_extract(payload, '$.old_value'), '') IN " + in +
+			" AND COALESCE(json_extract(payload, '$.new_value'), '') IN " + in +
+			" ELSE 0 END"
+		for i := 0; i < 2; i++ {
+			for _, v := range filter.ExcludeAgentTransitions {
+				args = append(args, v)
+			}
+		}
+	}
+	if filter.StartTime != nil {
+		where += " AND timestamp >= ?"
+		args = append(args, filter.StartTime.UTC())
+	}
+	if filter.EndTime != nil {
+		where += " AND timestamp <= ?"
+		args = append(args, filter.EndTime.UTC())
+	}
+
+	return where, args
+}
+
+// scanEvent scans one events row.
+func scanEvent(rows *sql.Rows) (Event, error) {
+	var e Event
+	var payloadStr sql.NullString
+	var timestampStr string
+
+	if err := rows.Scan(&e.ID, &e.Type, &e.Source, &e.Rig, &payloadStr, &timestampStr); err != nil {
+		return e, fmt.Errorf("failed to scan event: %w", err)
+	}
+
+	if payloadStr.Valid {
+		e.Payload = json.RawMessage(payloadStr.String)
+	}
+
+	// Parse timestamp
+	e.Timestamp, _ = time.Parse("2006-01-02 15:04:05.999999999-07:00", timestampStr)
+	if e.Timestamp.IsZero() {
+		e.Timestamp, _ = time.Parse("2006-01-02 15:04:05", timestampStr)
+	}
+	if e.Timestamp.IsZero() {
+		e.Timestamp, _ = time.Parse(time.RFC3339, timestampStr)
+	}
+
+	return e, nil
 }
 
 // Subscribe creates a subscription for events matching the filter.
 // Returns a channel that receives events. Call Unsubscribe to stop.
 func (s *Store) Subscribe(filter EventFilter) <-chan Event {
-	ch := make(chan Event, 256)
+	ch := make(chan Event, s.config.SubscriberBuffer)
 	sub := &subscriber{ch: ch, filter: filter}
 
 	s.mu.Lock()
@@ -270,6 +520,8 @@ func (s *Store) Replay(from time.Time, filter EventFilter) ([]Event, error) {
 
 // notifySubscribers sends an event to all matching subscribers.
 func (s *Store) notifySubscribers(event Event) {
+	atomic.AddUint64(&s.emitted, 1)
+
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -277,13 +529,30 @@ func (s *Store) notifySubscribers(event Event) {
 		if s.matchesFilter(event, sub.filter) {
 			select {
 			case sub.ch <- event:
+				atomic.AddUint64(&s.delivered, 1)
 			default:
+				atomic.AddUint64(&s.dropped, 1)
 				slog.Warn("Subscriber buffer full, dropping event", "type", event.Type)
 			}
 		}
 	}
 }
 
+// Stats returns the store's delivery counters.
+func (s *Store) Stats() StoreStats {
+	s.mu.RLock()
+	subscribers := len(s.subscribers)
+	s.mu.RUnlock()
+
+	return StoreStats{
+		Emitted:          atomic.LoadUint64(&s.emitted),
+		Delivered:        atomic.LoadUint64(&s.delivered),
+		Dropped:          atomic.LoadUint64(&s.dropped),
+		Subscribers:      subscribers,
+		SubscriberBuffer: s.config.SubscriberBuffer,
+	}
+}
+
 // matchesFilter checks if an event matches the subscription filter.
 func (s *Store) matchesFilter(event Event, filter EventFilter) bool {
 	if filter.Type != "" && event.Type != filter.Type {
@@ -295,9 +564,41 @@ func (s *Store) matchesFilter(event Event, filter EventFilter) bool {
 	if filter.Rig != "" && event.Rig != filter.Rig {
 		return false
 	}
+	for _, t := range filter.ExcludeTypes {
+		if event.Type == t {
+			return false
+		}
+	}
+	if len(filter.ExcludeAgentTransitions) > 0 && isAgentTransition(event, filter.ExcludeAgentTransitions) {
+		return false
+	}
 	return true
 }
 
+// isAgentTransition reports whether event is an agent.* event moving between
+// two of states, matching the ExcludeAgentTransitions SQL clause.
+func isAgentTransition(event Event, states []string) bool {
+	if !strings.HasPrefix(event.Type, "agent.") {
+		return false
+	}
+	var p struct {
+		OldValue string `json:"old_value"`
+		NewValue string `json:"new_value"`
+	}
+	if json.Unmarshal(event.Payload, &p) != nil {
+		return false
+	}
+	in := func(v string) bool {
+		for _, s := range states {
+			if v == s {
+				return true
+			}
+		}
+		return false
+	}
+	return in(p.OldValue) && in(p.NewValue)
+}
+
 // cleanupLoop periodically removes old events.
 func (s *Store) cleanupLoop() {
 	ticker := time.NewTicker(s.config.CleanupPeriod)
@@ -313,7 +614,21 @@ func (s *Store) cleanupLoop() {
 	}
 }
 
-// cleanup removes events older than the retention period.
+// PurgeRigEvents deletes every event recorded for a rig, regardless of age,
+// and returns how many were removed.
+func (s *Store) PurgeRigEvents(rigID string) (int64, error) {
+	result, err := s.db.Exec("DELETE FROM events WHERE rig = ?", rigID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge rig events: %w", err)
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count purged events: %w", err)
+	}
+	return count, nil
+}
+
+// cleanup removes events and issue snapshots older than the retention period.
 func (s *Store) cleanup() {
 	cutoff := time.Now().UTC().AddDate(0, 0, -s.config.RetentionDays)
 	result, err := s.db.Exec("DELETE FROM events WHERE timestamp < ?", cutoff)
@@ -326,4 +641,13 @@ func (s *Store) cleanup() {
 	if count > 0 {
 		slog.Info("Cleaned up old events", "count", count, "cutoff", cutoff)
 	}
+
+	pruned, err := s.pruneIssueSnapshots(cutoff)
+	if err != nil {
+		slog.Error("Failed to cleanup old issue snapshots", "error", err)
+		return
+	}
+	if pruned > 0 {
+		slog.Info("Cleaned up old issue snapshots", "count", pruned, "cutoff", cutoff)
+	}
 }
@@ -1,6 +1,7 @@
 package events
 
 import (
+	"strings"
 	"testing"
 	"time"
 )
@@ -389,3 +390,542 @@ func TestEventStore_NilPayload(t *testing.T) {
 		t.Fatalf("Expected 1 event, got %d", len(events))
 	}
 }
+
+func TestEventStore_Query_ExcludesTypes(t *testing.T) {
+	config := DefaultConfig()
+	store, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	store.Emit("agent.heartbeat", "src", "rig", nil)
+	store.Emit("bead.updated", "src", "rig", nil)
+	store.Emit("agent.heartbeat", "src", "rig", nil)
+
+	events, err := store.Query(EventFilter{ExcludeTypes: []string{"agent.heartbeat"}})
+	if err != nil {
+		t.Fatalf("Failed to query events: %v", err)
+	}
+
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 event after exclusion, got %d", len(events))
+	}
+	if events[0].Type != "bead.updated" {
+		t.Errorf("Expected type 'bead.updated', got '%s'", events[0].Type)
+	}
+}
+
+func TestEventStore_ExcludeAgentTransitions(t *testing.T) {
+	store, err := NewStore(DefaultConfig())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	routine := []string{"idle", "working"}
+	ch := store.Subscribe(EventFilter{ExcludeAgentTransitions: routine})
+	defer store.Unsubscribe(ch)
+
+	flap := map[string]string{"agent_id": "a1", "old_value": "idle", "new_value": "working"}
+	for i := 0; i < 3; i++ {
+		store.Emit("agent.status_changed", "src", "rig", flap)
+	}
+	store.Emit("agent.status_changed", "src", "rig", map[string]string{"agent_id": "a1", "old_value": "working", "new_value": "stuck"})
+	store.Emit("bead.updated", "src", "rig", map[string]string{"old_value": "idle", "new_value": "working"})
+	store.Emit("agent.registered", "src", "rig", nil)
+
+	// The filter runs in SQL, so a limit still returns a full page
+	events, err := store.Query(EventFilter{ExcludeAgentTransitions: routine, Limit: 2})
+	if err != nil {
+		t.Fatalf("Failed to query events: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("Expected a full page of 2 events, got %d", len(events))
+	}
+	for _, e := range events {
+		if e.Type == "agent.status_changed" && strings.Contains(string(e.Payload), `"old_value":"idle"`) {
+			t.Errorf("Expected routine transition to be excluded, got %s", e.Payload)
+		}
+	}
+
+	all, err := store.Query(EventFilter{ExcludeAgentTransitions: routine})
+	if err != nil {
+		t.Fatalf("Failed to query events: %v", err)
+	}
+	if len(all) != 3 {
+		t.Errorf("Expected 3 events after exclusion, got %d", len(all))
+	}
+
+	// Live subscribers apply the same rule
+	var live []string
+	for len(live) < 3 {
+		select {
+		case e := <-ch:
+			live = append(live, e.Type)
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for events, got %v", live)
+		}
+	}
+	select {
+	case e := <-ch:
+		t.Errorf("Expected no more live events, got %s %s", e.Type, e.Payload)
+	default:
+	}
+}
+
+func TestEventStore_EmitBatch(t *testing.T) {
+	store, err := NewStore(DefaultConfig())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	ch := store.Subscribe(EventFilter{Rig: "townview"})
+
+	err = store.EmitBatch([]EventInput{
+		{Type: "bead.dependency_added", Source: "townview/server", Rig: "townview", Payload: map[string]string{"issue_id": "to-1"}},
+		{Type: "bead.dependency_added", Source: "townview/server", Rig: "townview", Payload: map[string]string{"issue_id": "to-2"}},
+		{Type: "bead.dependencies_added", Source: "townview/server", Rig: "townview"},
+	})
+	if err != nil {
+		t.Fatalf("EmitBatch failed: %v", err)
+	}
+
+	events, err := store.Query(EventFilter{Rig: "townview"})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 stored events, got %d", len(events))
+	}
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-ch:
+		case <-time.After(time.Second):
+			t.Fatalf("Expected 3 notifications, got %d", i)
+		}
+	}
+}
+
+func TestEventStore_Export_PagesThroughAllEvents(t *testing.T) {
+	store, err := NewStore(DefaultConfig())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	total := exportPageSize + 10
+	for i := 0; i < total; i++ {
+		eventType := "bead.updated"
+		if i%2 == 1 {
+			eventType = "agent.heartbeat"
+		}
+		if err := store.Emit(eventType, "test", "townview", map[string]int{"n": i}); err != nil {
+			t.Fatalf("Failed to emit event: %v", err)
+		}
+	}
+
+	var count int
+	var lastID int64
+	err = store.Export(EventFilter{}, func(e Event) error {
+		if e.ID <= lastID {
+			t.Fatalf("Expected ascending ids, got %d after %d", e.ID, lastID)
+		}
+		lastID = e.ID
+		count++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if count != total {
+		t.Errorf("Expected %d exported events, got %d", total, count)
+	}
+
+	count = 0
+	err = store.Export(EventFilter{Type: "bead.updated"}, func(e Event) error {
+		count++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Export with filter failed: %v", err)
+	}
+	if count != (total+1)/2 {
+		t.Errorf("Expected %d filtered events, got %d", (total+1)/2, count)
+	}
+}
+
+func TestEventStore_Stats_CountsDrops(t *testing.T) {
+	config := DefaultConfig()
+	config.SubscriberBuffer = 2
+	store, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	// Never drained, so only the first two events fit
+	ch := store.Subscribe(EventFilter{})
+	defer store.Unsubscribe(ch)
+
+	for i := 0; i < 5; i++ {
+		if err := store.Emit("test.event", "test-source", "test-rig", nil); err != nil {
+			t.Fatalf("Failed to emit event: %v", err)
+		}
+	}
+
+	stats := store.Stats()
+	if stats.Emitted != 5 {
+		t.Errorf("Expected 5 emitted, got %d", stats.Emitted)
+	}
+	if stats.Delivered != 2 {
+		t.Errorf("Expected 2 delivered, got %d", stats.Delivered)
+	}
+	if stats.Dropped != 3 {
+		t.Errorf("Expected 3 dropped, got %d", stats.Dropped)
+	}
+	if stats.Subscribers != 1 || stats.SubscriberBuffer != 2 {
+		t.Errorf("Unexpected subscriber stats: %+v", stats)
+	}
+}
+
+// TestEventStore_CountByRig verifies events are counted per rig within the filter window.
+func TestEventStore_CountByRig(t *testing.T) {
+	store, err := NewStore(DefaultConfig())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	for _, rig := range []string{"rig-a", "rig-a", "rig-b"} {
+		if err := store.Emit("bead.updated", "test-source", rig, nil); err != nil {
+			t.Fatalf("Failed to emit event: %v", err)
+		}
+	}
+
+	since := time.Now().Add(-time.Minute)
+	counts, err := store.CountByRig(EventFilter{StartTime: &since})
+	if err != nil {
+		t.Fatalf("CountByRig failed: %v", err)
+	}
+	if counts["rig-a"] != 2 || counts["rig-b"] != 1 {
+		t.Errorf("expected rig-a=2 rig-b=1, got %v", counts)
+	}
+
+	future := time.Now().Add(time.Minute)
+	counts, err = store.CountByRig(EventFilter{StartTime: &future})
+	if err != nil {
+		t.Fatalf("CountByRig failed: %v", err)
+	}
+	if len(counts) != 0 {
+		t.Errorf("expected no counts after the last event, got %v", counts)
+	}
+}
+
+func TestEventStore_PurgeRigEvents(t *testing.T) {
+	store, err := NewStore(DefaultConfig())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	for _, rig := range []string{"rig-a", "rig-a", "rig-b"} {
+		if err := store.Emit("bead.updated", "test-source", rig, nil); err != nil {
+			t.Fatalf("Failed to emit event: %v", err)
+		}
+	}
+
+	purged, err := store.PurgeRigEvents("rig-a")
+	if err != nil {
+		t.Fatalf("PurgeRigEvents failed: %v", err)
+	}
+	if purged != 2 {
+		t.Errorf("expected 2 events purged, got %d", purged)
+	}
+
+	since := time.Now().Add(-time.Minute)
+	counts, err := store.CountByRig(EventFilter{StartTime: &since})
+	if err != nil {
+		t.Fatalf("CountByRig failed: %v", err)
+	}
+	if counts["rig-a"] != 0 || counts["rig-b"] != 1 {
+		t.Errorf("expected only rig-b=1 to remain, got %v", counts)
+	}
+
+	purged, err = store.PurgeRigEvents("rig-a")
+	if err != nil {
+		t.Fatalf("PurgeRigEvents failed: %v", err)
+	}
+	if purged != 0 {
+		t.Errorf("expected nothing left to purge, got %d", purged)
+	}
+}
+
+func TestEventStore_UnmarshalPayload(t *testing.T) {
+	good := Event{ID: 1, Type: TypeBeadUpdated, Payload: []byte(`{"issue_id":"to-1","title":"Fix it","extra":true}`)}
+	p, err := UnmarshalPayload[BeadUpdatedPayload](good)
+	if err != nil {
+		t.Fatalf("UnmarshalPayload failed: %v", err)
+	}
+	if p.IssueID != "to-1" || p.Title != "Fix it" {
+		t.Errorf("unexpected payload: %+v", p)
+	}
+
+	bad := []Event{
+		{ID: 2, Type: TypeBeadUpdated},
+		{ID: 3, Type: TypeBeadUpdated, Payload: []byte(`{"issue_id":42}`)},
+		{ID: 4, Type: TypeBeadUpdated, Payload: []byte(`{"title":"no id"}`)},
+		{ID: 5, Type: TypeAgentStatusChanged, Payload: []byte(`{"agent_id":"to/witness"}`)},
+	}
+	for _, e := range bad {
+		var err error
+		if e.Type == TypeAgentStatusChanged {
+			_, err = UnmarshalPayload[AgentStatusChangedPayload](e)
+		} else {
+			_, err = UnmarshalPayload[BeadUpdatedPayload](e)
+		}
+		if err == nil {
+			t.Errorf("expected event %d payload %s to be rejected", e.ID, e.Payload)
+		}
+	}
+}
+
+func TestEventStore_Search(t *testing.T) {
+	store, err := NewStore(DefaultConfig())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	store.Emit("bead.updated", "townview/server", "townview", map[string]string{"issue_id": "to-abc12"})
+	store.Emit("git.commit", "agent", "townview", map[string]string{"commit_sha": "deadbeef", "bead": "TO-ABC12"})
+	store.Emit("bead.updated", "townview/server", "other", map[string]string{"issue_id": "to-zzz99"})
+	store.Emit("bead.updated", "townview/server", "other", map[string]string{"issue_id": "100%_done"})
+
+	results, err := store.Search("to-abc12", EventFilter{})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 matches, got %d", len(results))
+	}
+	if results[0].Type != "git.commit" {
+		t.Errorf("Expected newest match first, got %s", results[0].Type)
+	}
+
+	results, err = store.Search("abc12", EventFilter{Rig: "townview", Limit: 1})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 {
+		t.Errorf("Expected limit to cap results at 1, got %d", len(results))
+	}
+
+	// Wildcards are matched literally
+	results, err = store.Search("0%_", EventFilter{})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 {
+		t.Errorf("Expected 1 literal wildcard match, got %d", len(results))
+	}
+	results, err = store.Search("%", EventFilter{Rig: "townview"})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("Expected %% to match nothing in townview, got %d", len(results))
+	}
+}
+
+func TestEventStore_IssueSnapshots(t *testing.T) {
+	store, err := NewStore(DefaultConfig())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	t0 := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	steps := []struct {
+		at    time.Time
+		state string
+		want  int
+	}{
+		{t0, `{"id":"to-1","status":"open","title":"Fix it"}`, 1},
+		{t0.Add(time.Hour), `{"id":"to-1","status":"open","title":"Fix it"}`, 0}, // unchanged, skipped
+		{t0.Add(2 * time.Hour), `{"id":"to-1","status":"closed","title":"Fix it","close_reason":"done"}`, 1},
+	}
+	for i, step := range steps {
+		stored, err := store.RecordIssueSnapshots("town", step.at, map[string][]byte{"to-1": []byte(step.state)})
+		if err != nil {
+			t.Fatalf("RecordIssueSnapshots step %d failed: %v", i, err)
+		}
+		if stored != step.want {
+			t.Errorf("step %d: expected %d stored, got %d", i, step.want, stored)
+		}
+	}
+
+	before, err := store.GetIssueSnapshot("town", "to-1", t0.Add(-time.Minute))
+	if err != nil || before != nil {
+		t.Errorf("expected no snapshot before the first, got %+v, %v", before, err)
+	}
+
+	from, err := store.GetIssueSnapshot("town", "to-1", t0.Add(90*time.Minute))
+	if err != nil || from == nil || !from.Timestamp.Equal(t0) {
+		t.Fatalf("expected the first snapshot as nearest prior state, got %+v, %v", from, err)
+	}
+	to, err := store.GetIssueSnapshot("town", "to-1", t0.Add(3*time.Hour))
+	if err != nil || to == nil {
+		t.Fatalf("expected the latest snapshot, got %+v, %v", to, err)
+	}
+
+	changes, err := DiffIssueSnapshots(from, to)
+	if err != nil {
+		t.Fatalf("DiffIssueSnapshots failed: %v", err)
+	}
+	if len(changes) != 2 {
+		t.Fatalf("expected 2 changed fields, got %+v", changes)
+	}
+	if changes[0].Field != "close_reason" || string(changes[0].From) != "null" || string(changes[0].To) != `"done"` {
+		t.Errorf("expected close_reason added, got %+v", changes[0])
+	}
+	if changes[1].Field != "status" || string(changes[1].From) != `"open"` || string(changes[1].To) != `"closed"` {
+		t.Errorf("expected status open -> closed, got %+v", changes[1])
+	}
+}
+
+func TestEventStore_IssueSnapshots_ScopedByRig(t *testing.T) {
+	store, err := NewStore(DefaultConfig())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	t0 := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	state := []byte(`{"id":"x-1","status":"open"}`)
+	for _, rig := range []string{"rig-a", "rig-b"} {
+		stored, err := store.RecordIssueSnapshots(rig, t0, map[string][]byte{"x-1": state})
+		if err != nil {
+			t.Fatalf("RecordIssueSnapshots failed: %v", err)
+		}
+		if stored != 1 {
+			t.Errorf("%s: expected its own first snapshot to be stored, got %d", rig, stored)
+		}
+	}
+
+	if _, err := store.RecordIssueSnapshots("rig-b", t0.Add(time.Hour), map[string][]byte{"x-1": []byte(`{"id":"x-1","status":"closed"}`)}); err != nil {
+		t.Fatalf("RecordIssueSnapshots failed: %v", err)
+	}
+
+	snap, err := store.GetIssueSnapshot("rig-a", "x-1", t0.Add(2*time.Hour))
+	if err != nil || snap == nil {
+		t.Fatalf("expected rig-a's snapshot, got %+v, %v", snap, err)
+	}
+	if snap.Rig != "rig-a" || string(snap.State) != string(state) {
+		t.Errorf("expected rig-a's unchanged state, got %+v", snap)
+	}
+
+	snap, err = store.GetIssueSnapshot("rig-c", "x-1", t0.Add(2*time.Hour))
+	if err != nil || snap != nil {
+		t.Errorf("expected no snapshot for another rig, got %+v, %v", snap, err)
+	}
+}
+
+func TestEventStore_RecordDeletedIssues(t *testing.T) {
+	store, err := NewStore(DefaultConfig())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	t0 := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	live := map[string][]byte{
+		"to-1": []byte(`{"id":"to-1","status":"open"}`),
+		"to-2": []byte(`{"id":"to-2","status":"open"}`),
+	}
+	if _, err := store.RecordIssueSnapshots("town", t0, live); err != nil {
+		t.Fatalf("RecordIssueSnapshots failed: %v", err)
+	}
+	if _, err := store.RecordIssueSnapshots("other", t0, live); err != nil {
+		t.Fatalf("RecordIssueSnapshots failed: %v", err)
+	}
+
+	remaining := map[string][]byte{"to-2": live["to-2"]}
+	for i, want := range []int{1, 0} { // the second pass finds to-1 already deleted
+		deleted, err := store.RecordDeletedIssues("town", t0.Add(time.Duration(i+1)*time.Hour), remaining)
+		if err != nil {
+			t.Fatalf("RecordDeletedIssues pass %d failed: %v", i, err)
+		}
+		if deleted != want {
+			t.Errorf("pass %d: expected %d deletions, got %d", i, want, deleted)
+		}
+	}
+
+	gone, err := store.GetIssueSnapshot("town", "to-1", t0.Add(3*time.Hour))
+	if err != nil || gone == nil || !gone.Deleted || string(gone.State) != "null" {
+		t.Fatalf("expected a deletion snapshot for to-1, got %+v, %v", gone, err)
+	}
+	if !gone.Timestamp.Equal(t0.Add(time.Hour)) {
+		t.Errorf("expected the deletion at the first pass, got %v", gone.Timestamp)
+	}
+	if kept, err := store.GetIssueSnapshot("town", "to-2", t0.Add(3*time.Hour)); err != nil || kept == nil || kept.Deleted {
+		t.Errorf("expected to-2 to stay live, got %+v, %v", kept, err)
+	}
+	if other, err := store.GetIssueSnapshot("other", "to-1", t0.Add(3*time.Hour)); err != nil || other == nil || other.Deleted {
+		t.Errorf("expected another rig's to-1 to stay live, got %+v, %v", other, err)
+	}
+
+	before, err := store.GetIssueSnapshot("town", "to-1", t0)
+	if err != nil {
+		t.Fatalf("GetIssueSnapshot failed: %v", err)
+	}
+	changes, err := DiffIssueSnapshots(before, gone)
+	if err != nil {
+		t.Fatalf("DiffIssueSnapshots failed: %v", err)
+	}
+	if len(changes) != 2 || string(changes[0].To) != "null" || string(changes[1].To) != "null" {
+		t.Errorf("expected every field removed, got %+v", changes)
+	}
+
+	// An issue that comes back is snapshotted again
+	stored, err := store.RecordIssueSnapshots("town", t0.Add(4*time.Hour), live)
+	if err != nil || stored != 1 {
+		t.Errorf("expected the restored issue to be snapshotted, got %d, %v", stored, err)
+	}
+}
+
+func TestEventStore_Cleanup_PrunesIssueSnapshots(t *testing.T) {
+	store, err := NewStore(DefaultConfig())
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	old := time.Now().AddDate(0, 0, -store.config.RetentionDays-10)
+	for i, state := range []string{`{"status":"open"}`, `{"status":"closed"}`} {
+		if _, err := store.RecordIssueSnapshots("town", old.Add(time.Duration(i)*time.Hour), map[string][]byte{"to-1": []byte(state)}); err != nil {
+			t.Fatalf("RecordIssueSnapshots failed: %v", err)
+		}
+	}
+	if _, err := store.RecordIssueSnapshots("town", time.Now(), map[string][]byte{"to-2": []byte(`{"status":"open"}`)}); err != nil {
+		t.Fatalf("RecordIssueSnapshots failed: %v", err)
+	}
+
+	store.cleanup()
+
+	// The expired earlier snapshot is gone; the latest stays as the baseline
+	first, err := store.GetIssueSnapshot("town", "to-1", old.Add(30*time.Minute))
+	if err != nil || first != nil {
+		t.Errorf("expected the expired snapshot to be pruned, got %+v, %v", first, err)
+	}
+	latest, err := store.GetIssueSnapshot("town", "to-1", time.Now())
+	if err != nil || latest == nil || string(latest.State) != `{"status":"closed"}` {
+		t.Errorf("expected the latest expired snapshot to be kept, got %+v, %v", latest, err)
+	}
+	recent, err := store.GetIssueSnapshot("town", "to-2", time.Now())
+	if err != nil || recent == nil {
+		t.Errorf("expected the recent snapshot to be kept, got %+v, %v", recent, err)
+	}
+}
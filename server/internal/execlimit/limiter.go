@@ -0,0 +1,68 @@
+// Package execlimit bounds how many subprocesses (bd, gt, tmux) townview runs
+// at once, so bursts of requests queue instead of forking without limit.
+package execlimit
+
+import (
+	"context"
+	"os/exec"
+)
+
+// DefaultMaxProcesses is the default cap on concurrent subprocesses.
+const DefaultMaxProcesses = 16
+
+// Limiter is a semaphore shared by everything that spawns processes.
+// A nil Limiter, or one created with max <= 0, doesn't limit anything.
+type Limiter struct {
+	slots chan struct{}
+}
+
+// New creates a Limiter allowing up to max concurrent processes.
+func New(max int) *Limiter {
+	if max <= 0 {
+		return &Limiter{}
+	}
+	return &Limiter{slots: make(chan struct{}, max)}
+}
+
+// Acquire waits for a free slot. It returns ctx's error if ctx ends first;
+// otherwise the caller must call the returned release function when done.
+func (l *Limiter) Acquire(ctx context.Context) (release func(), err error) {
+	if l == nil || l.slots == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return func() { <-l.slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Run starts cmd once a slot is free and waits for it to finish.
+func (l *Limiter) Run(ctx context.Context, cmd *exec.Cmd) error {
+	release, err := l.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return cmd.Run()
+}
+
+// Output is like Run but returns the command's stdout, as exec.Cmd.Output does.
+func (l *Limiter) Output(ctx context.Context, cmd *exec.Cmd) ([]byte, error) {
+	release, err := l.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return cmd.Output()
+}
+
+// InUse returns how many slots are currently held.
+func (l *Limiter) InUse() int {
+	if l == nil || l.slots == nil {
+		return 0
+	}
+	return len(l.slots)
+}
@@ -0,0 +1,49 @@
+package execlimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLimiter_BlocksAtCapacity(t *testing.T) {
+	l := New(1)
+
+	release, err := l.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	if l.InUse() != 1 {
+		t.Errorf("expected 1 slot in use, got %d", l.InUse())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected second Acquire to time out, got %v", err)
+	}
+
+	release()
+	release2, err := l.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire after release failed: %v", err)
+	}
+	release2()
+	if l.InUse() != 0 {
+		t.Errorf("expected no slots in use, got %d", l.InUse())
+	}
+}
+
+func TestLimiter_Unlimited(t *testing.T) {
+	for _, l := range []*Limiter{nil, New(0)} {
+		for i := 0; i < 100; i++ {
+			if _, err := l.Acquire(context.Background()); err != nil {
+				t.Fatalf("Acquire failed: %v", err)
+			}
+		}
+		if l.InUse() != 0 {
+			t.Errorf("unlimited limiter should report 0 in use, got %d", l.InUse())
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// SetWriteToken sets the bearer token required by privileged write endpoints.
+// An empty token leaves them open.
+func (h *Handlers) SetWriteToken(token string) {
+	h.writeToken = token
+}
+
+// SetTelemetryToken sets the bearer token required to post telemetry
+// (POST /api/telemetry/*). It is independent of the write token, so CI runners
+// can hold a credential that can't edit issues. An empty token leaves them open.
+func (h *Handlers) SetTelemetryToken(token string) {
+	h.telemetryToken = token
+}
+
+// authorizeWrite checks the request's bearer token against the write token.
+// It writes a 401 and returns false when the token is missing or wrong.
+func (h *Handlers) authorizeWrite(w http.ResponseWriter, r *http.Request) bool {
+	return checkBearer(w, r, h.writeToken, "Valid write token required")
+}
+
+// authorizeTelemetry checks the request's bearer token against the telemetry
+// token. It writes a 401 and returns false when the token is missing or wrong.
+func (h *Handlers) authorizeTelemetry(w http.ResponseWriter, r *http.Request) bool {
+	return checkBearer(w, r, h.telemetryToken, "Valid telemetry token required")
+}
+
+// checkBearer reports whether the request carries want as its bearer token,
+// writing a 401 with message when it doesn't. An empty want allows everything.
+func checkBearer(w http.ResponseWriter, r *http.Request, want, message string) bool {
+	if want == "" {
+		return true
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(want)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, http.StatusUnauthorized, ErrCodeUnauthorized, message)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gastown/townview/internal/events"
+	"github.com/gastown/townview/internal/rigmanager"
+	"github.com/gastown/townview/internal/telemetry"
+)
+
+func TestAgentHeartbeat_TokensNeedTelemetryToken(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		auth       string
+		wantCode   int
+		wantTokens int
+	}{
+		{"liveness only without token", `{"agent_id":"rig-a/polecats/a1"}`, "", http.StatusOK, 0},
+		{"tokens without token", `{"agent_id":"rig-a/polecats/a1","tokens_since_last":10}`, "", http.StatusUnauthorized, 0},
+		{"tokens with write token", `{"agent_id":"rig-a/polecats/a1","tokens_since_last":10}`, "Bearer write-secret", http.StatusUnauthorized, 0},
+		{"tokens with telemetry token", `{"agent_id":"rig-a/polecats/a1","tokens_since_last":10}`, "Bearer telemetry-secret", http.StatusOK, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, collector := newTelemetryTestHandlers(t)
+			h.SetDefaultModel("claude-sonnet-4")
+			h.SetWriteToken("write-secret")
+			h.SetTelemetryToken("telemetry-secret")
+
+			req := httptest.NewRequest(http.MethodPost, "/api/agents/heartbeat", strings.NewReader(tt.body))
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			h.AgentHeartbeat(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("expected %d, got %d: %s", tt.wantCode, rec.Code, rec.Body.String())
+			}
+			if tt.wantCode == http.StatusUnauthorized {
+				assertErrorCode(t, rec, ErrCodeUnauthorized)
+				if agent := h.agentRegistry.GetAgent("rig-a/polecats/a1"); agent.HasHeartbeated {
+					t.Error("expected a rejected heartbeat to leave the registry alone")
+				}
+			}
+
+			summary, err := collector.GetTokenSummary(telemetry.TelemetryFilter{})
+			if err != nil {
+				t.Fatalf("GetTokenSummary failed: %v", err)
+			}
+			if summary.TotalInput != tt.wantTokens {
+				t.Errorf("expected %d tokens recorded, got %d", tt.wantTokens, summary.TotalInput)
+			}
+		})
+	}
+}
+
+func TestCreateTestRun_NeedsTelemetryToken(t *testing.T) {
+	body := `{"agent_id":"rig-a/polecats/a1","command":"go test","results":[{"test_name":"TestX","status":"passed"}]}`
+	tests := []struct {
+		name     string
+		auth     string
+		wantCode int
+	}{
+		{"missing", "", http.StatusUnauthorized},
+		{"wrong scheme", "Basic telemetry-secret", http.StatusUnauthorized},
+		{"write token", "Bearer write-secret", http.StatusUnauthorized},
+		{"telemetry token", "Bearer telemetry-secret", http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := newTelemetryTestHandlers(t)
+			h.SetWriteToken("write-secret")
+			h.SetTelemetryToken("telemetry-secret")
+
+			req := httptest.NewRequest(http.MethodPost, "/api/telemetry/tests", strings.NewReader(body))
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			h.CreateTestRun(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("expected %d, got %d: %s", tt.wantCode, rec.Code, rec.Body.String())
+			}
+			if rec.Code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") != "Bearer" {
+				t.Error("expected a WWW-Authenticate challenge")
+			}
+		})
+	}
+}
+
+func TestAdminRigEndpoints(t *testing.T) {
+	newAdminHandlers := func(t *testing.T) (*Handlers, *events.Store) {
+		t.Helper()
+		store, err := events.NewStore(events.DefaultConfig())
+		if err != nil {
+			t.Fatalf("failed to create event store: %v", err)
+		}
+		t.Cleanup(func() { store.Close() })
+		for _, rig := range []string{"rig-a", "gone"} {
+			if err := store.Emit("bead.created", "test", rig, map[string]string{}); err != nil {
+				t.Fatalf("Emit failed: %v", err)
+			}
+		}
+
+		townRoot := newTestTown(t)
+		addTestRig(t, townRoot, "rig-b")
+		h := New(newTestManager(t, townRoot), store, nil, nil, nil, townRoot)
+		h.SetWriteToken("write-secret")
+		return h, store
+	}
+
+	tests := []struct {
+		name       string
+		remove     bool // DELETE the rig rather than its events
+		rig        string
+		auth       string
+		wantStatus int
+		wantPurged int64
+	}{
+		{name: "purge without token", rig: "rig-a", wantStatus: http.StatusUnauthorized},
+		{name: "purge with wrong token", rig: "rig-a", auth: "Bearer nope", wantStatus: http.StatusUnauthorized},
+		{name: "purge tracked rig", rig: "rig-a", auth: "Bearer write-secret", wantStatus: http.StatusOK, wantPurged: 1},
+		{name: "purge tracked rig without events", rig: "rig-b", auth: "Bearer write-secret", wantStatus: http.StatusOK},
+		{name: "purge removed rig's events", rig: "gone", auth: "Bearer write-secret", wantStatus: http.StatusOK, wantPurged: 1},
+		{name: "purge unknown rig", rig: "rig-x", auth: "Bearer write-secret", wantStatus: http.StatusNotFound},
+		{name: "remove without token", remove: true, rig: "rig-a", wantStatus: http.StatusUnauthorized},
+		{name: "remove tracked rig", remove: true, rig: "rig-a", auth: "Bearer write-secret", wantStatus: http.StatusNoContent},
+		{name: "remove unknown rig", remove: true, rig: "rig-x", auth: "Bearer write-secret", wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, store := newAdminHandlers(t)
+
+			target, handle := "/api/admin/rigs/"+tt.rig+"/events", h.PurgeRigEvents
+			if tt.remove {
+				target, handle = "/api/admin/rigs/"+tt.rig, h.RemoveRig
+			}
+			req := httptest.NewRequest(http.MethodDelete, target, nil)
+			req.SetPathValue("rigId", tt.rig)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			handle(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			switch tt.wantStatus {
+			case http.StatusUnauthorized:
+				assertErrorCode(t, rec, ErrCodeUnauthorized)
+			case http.StatusNotFound:
+				assertErrorCode(t, rec, ErrCodeRigNotFound)
+			case http.StatusOK:
+				var purge RigEventPurge
+				if err := json.NewDecoder(rec.Body).Decode(&purge); err != nil {
+					t.Fatalf("failed to decode purge: %v", err)
+				}
+				if purge.Rig != tt.rig || purge.Purged != tt.wantPurged {
+					t.Errorf("expected %d purged from %s, got %+v", tt.wantPurged, tt.rig, purge)
+				}
+			}
+
+			// Rejected requests leave the rig's events in place
+			if tt.wantStatus == http.StatusUnauthorized {
+				recent, err := store.Query(events.EventFilter{Rig: tt.rig})
+				if err != nil {
+					t.Fatalf("Query failed: %v", err)
+				}
+				if len(recent) != 1 {
+					t.Errorf("expected the rig's event to survive, got %d", len(recent))
+				}
+			}
+		})
+	}
+}
+
+func TestRediscoverRigs_AuthorizeWrite(t *testing.T) {
+	tests := []struct {
+		name       string
+		writeToken string // "" leaves writes open
+		auth       string
+		wantStatus int
+	}{
+		{"open without token", "", "", http.StatusOK},
+		{"open ignores a stray token", "", "Bearer anything", http.StatusOK},
+		{"missing", "write-secret", "", http.StatusUnauthorized},
+		{"wrong scheme", "write-secret", "Basic write-secret", http.StatusUnauthorized},
+		{"lowercase scheme", "write-secret", "bearer write-secret", http.StatusUnauthorized},
+		{"empty bearer", "write-secret", "Bearer ", http.StatusUnauthorized},
+		{"wrong token", "write-secret", "Bearer nope", http.StatusUnauthorized},
+		{"token prefix", "write-secret", "Bearer write", http.StatusUnauthorized},
+		{"telemetry token", "write-secret", "Bearer telemetry-secret", http.StatusUnauthorized},
+		{"write token", "write-secret", "Bearer write-secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			townRoot := newTestTown(t)
+			h := New(newTestManager(t, townRoot), nil, nil, nil, nil, townRoot)
+			h.SetWriteToken(tt.writeToken)
+			h.SetTelemetryToken("telemetry-secret")
+			// Only a rescan finds a rig added after startup
+			addTestRig(t, townRoot, "rig-b")
+
+			req := httptest.NewRequest(http.MethodPost, "/api/rigs/rediscover", nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			h.RediscoverRigs(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			wantRigs := 2
+			if tt.wantStatus == http.StatusUnauthorized {
+				assertErrorCode(t, rec, ErrCodeUnauthorized)
+				if rec.Header().Get("WWW-Authenticate") != "Bearer" {
+					t.Error("expected a WWW-Authenticate challenge")
+				}
+				wantRigs = 1
+			} else {
+				var result rigmanager.DiscoveryResult
+				if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+					t.Fatalf("failed to decode result: %v", err)
+				}
+				if result.Rigs != 2 {
+					t.Errorf("expected 2 rigs after rediscovery, got %+v", result)
+				}
+			}
+			if rigs := h.rigManager.ListRigs(); len(rigs) != wantRigs {
+				t.Errorf("expected %d tracked rigs, got %d", wantRigs, len(rigs))
+			}
+		})
+	}
+}
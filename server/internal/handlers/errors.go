@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/gastown/townview/internal/telemetry"
+)
+
+// Error codes returned in structured error responses.
+// These are stable identifiers clients can branch on.
+const (
+	ErrCodeRigNotFound          = "RIG_NOT_FOUND"
+	ErrCodeIssueNotFound        = "ISSUE_NOT_FOUND"
+	ErrCodeNotFound             = "NOT_FOUND"
+	ErrCodeBDCommandFailed      = "BD_COMMAND_FAILED"
+	ErrCodeBDUnavailable        = "BD_UNAVAILABLE"
+	ErrCodeValidationFailed     = "VALIDATION_FAILED"
+	ErrCodeTelemetryUnavailable = "TELEMETRY_UNAVAILABLE"
+	ErrCodeInternal             = "INTERNAL_ERROR"
+	ErrCodeRequestTimeout       = "REQUEST_TIMEOUT"
+	ErrCodeUnauthorized         = "UNAUTHORIZED"
+	ErrCodeActiveDependents     = "ACTIVE_DEPENDENTS"
+	ErrCodeReadOnly             = "READ_ONLY"
+	ErrCodePayloadTooLarge      = "PAYLOAD_TOO_LARGE"
+)
+
+// ErrorDetail describes a failed request.
+type ErrorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ErrorResponse is the JSON body written for failed requests.
+type ErrorResponse struct {
+	Error ErrorDetail `json:"error"`
+}
+
+// writeError writes a structured JSON error response with the given status code.
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err := io.WriteString(w, ErrorBody(code, message)); err != nil {
+		slog.Error("Failed to write error response", "error", err)
+	}
+}
+
+// ErrorBody returns the JSON body of a structured error response, for errors
+// written outside a handler, such as the request timeout's 503.
+func ErrorBody(code, message string) string {
+	body, err := json.Marshal(ErrorResponse{Error: ErrorDetail{Code: code, Message: message}})
+	if err != nil {
+		slog.Error("Failed to encode error response", "error", err)
+	}
+	return string(body) + "\n"
+}
+
+// writeTelemetryError writes the error response for a failed telemetry
+// operation: 503 when no collector is configured, 500 otherwise.
+func writeTelemetryError(w http.ResponseWriter, err error, message string) {
+	if errors.Is(err, telemetry.ErrUnavailable) {
+		writeError(w, http.StatusServiceUnavailable, ErrCodeTelemetryUnavailable, "Telemetry collector not configured")
+		return
+	}
+	writeError(w, http.StatusInternalServerError, ErrCodeInternal, message)
+}
@@ -5,19 +5,27 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"net/url"
 	"os/exec"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gastown/townview/internal/events"
+	"github.com/gastown/townview/internal/execlimit"
 	"github.com/gastown/townview/internal/mail"
 	"github.com/gastown/townview/internal/query"
 	"github.com/gastown/townview/internal/registry"
 	"github.com/gastown/townview/internal/rigmanager"
+	"github.com/gastown/townview/internal/session"
 	"github.com/gastown/townview/internal/telemetry"
 	"github.com/gastown/townview/internal/types"
 )
@@ -30,9 +38,29 @@ type Handlers struct {
 	mailClient         *mail.Client
 	telemetryCollector telemetry.Collector
 	townRoot           string
+	bdPath             string
+	tools              *ToolAvailability // nil until Preflight runs
+	maxPageSize        int
+	writeToken         string // Bearer token for privileged writes; empty disables the check
+	telemetryToken     string // Bearer token for telemetry ingestion; empty disables the check
+	maxRunOutput       int    // Cap on error/stack bytes stored per test run; 0 disables
+
+	// Maintenance mode: writes are rejected, see ReadOnlyMiddleware
+	readOnly atomic.Bool
+
+	// Shared cap on concurrent bd/tmux processes; nil runs them uncapped
+	execLimiter *execlimit.Limiter
+
+	// Directory of <name>.md issue templates; empty disables them
+	templatesDir string
+
+	// Model recorded for reported tokens that name none; empty leaves such
+	// tokens out of telemetry
+	defaultModel string
 }
 
-// New creates a new Handlers instance.
+// New creates a new Handlers instance. telemetryCollector may be nil, in which
+// case telemetry reads return empty results and writes 503.
 func New(rigManager *rigmanager.Manager, eventStore *events.Store, agentRegistry *registry.Registry, mailClient *mail.Client, telemetryCollector telemetry.Collector, townRoot string) *Handlers {
 	return &Handlers{
 		rigManager:         rigManager,
@@ -41,7 +69,106 @@ func New(rigManager *rigmanager.Manager, eventStore *events.Store, agentRegistry
 		mailClient:         mailClient,
 		telemetryCollector: telemetryCollector,
 		townRoot:           townRoot,
+		bdPath:             toolPath("BD_PATH", "bd"),
+		maxPageSize:        DefaultMaxPageSize,
+		maxRunOutput:       telemetry.DefaultMaxRunOutputBytes,
+	}
+}
+
+// DefaultMaxPageSize is the default cap on results returned by list endpoints.
+const DefaultMaxPageSize = 500
+
+// SetMaxPageSize sets the server-wide cap applied to every list endpoint's limit.
+// Values <= 0 disable the cap.
+func (h *Handlers) SetMaxPageSize(n int) {
+	h.maxPageSize = n
+}
+
+// collector returns the telemetry collector, or telemetry.NopCollector when
+// none is configured.
+func (h *Handlers) collector() telemetry.Collector {
+	if h.telemetryCollector == nil {
+		return telemetry.NopCollector{}
+	}
+	return h.telemetryCollector
+}
+
+// RediscoverRigs handles POST /api/rigs/rediscover
+// Forces an immediate rig and agent rescan and returns the resulting counts.
+func (h *Handlers) RediscoverRigs(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeWrite(w, r) {
+		return
+	}
+
+	result, err := h.rigManager.Rediscover()
+	if err != nil {
+		slog.Error("Failed to rediscover rigs", "error", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to rediscover rigs")
+		return
+	}
+
+	writeJSON(w, result)
+}
+
+// RemoveRig handles DELETE /api/admin/rigs/{rigId}
+// Stops tracking a rig and purges its events and issue snapshots. Discovery
+// re-adds the rig if its beads directory still exists. Requires the write token.
+func (h *Handlers) RemoveRig(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeWrite(w, r) {
+		return
+	}
+
+	rigID := r.PathValue("rigId")
+	err := h.rigManager.RemoveRig(rigID)
+	if errors.Is(err, rigmanager.ErrRigNotFound) {
+		writeError(w, http.StatusNotFound, ErrCodeRigNotFound, "Rig not found")
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to remove rig", "rig", rigID, "error", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to remove rig")
+		return
 	}
+	slog.Warn("Removed rig", "rig", rigID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RigEventPurge is the response to a rig event purge.
+type RigEventPurge struct {
+	Rig    string `json:"rig"`
+	Purged int64  `json:"purged"`
+}
+
+// PurgeRigEvents handles DELETE /api/admin/rigs/{rigId}/events
+// Deletes every stored event for the rig. The rig need not still exist, so
+// history left behind by removed rigs can be cleared; a rig that is neither
+// tracked nor has events is a 404. Requires the write token.
+func (h *Handlers) PurgeRigEvents(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeWrite(w, r) {
+		return
+	}
+	if h.eventStore == nil {
+		writeError(w, http.StatusServiceUnavailable, ErrCodeInternal, "Event store not configured")
+		return
+	}
+
+	rigID := r.PathValue("rigId")
+	purged, err := h.eventStore.PurgeRigEvents(rigID)
+	if err != nil {
+		slog.Error("Failed to purge rig events", "rig", rigID, "error", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to purge rig events")
+		return
+	}
+	if purged == 0 {
+		if _, err := h.rigManager.GetRig(rigID); err != nil {
+			writeError(w, http.StatusNotFound, ErrCodeRigNotFound, "Rig not found")
+			return
+		}
+	}
+	slog.Warn("Purged rig events", "rig", rigID, "count", purged)
+
+	writeJSON(w, RigEventPurge{Rig: rigID, Purged: purged})
 }
 
 // ListRigs handles GET /api/rigs
@@ -56,7 +183,7 @@ func (h *Handlers) GetRig(w http.ResponseWriter, r *http.Request) {
 
 	rig, err := h.rigManager.GetRig(rigID)
 	if err != nil {
-		http.Error(w, "Rig not found", http.StatusNotFound)
+		writeError(w, http.StatusNotFound, ErrCodeRigNotFound, "Rig not found")
 		return
 	}
 
@@ -72,35 +199,110 @@ func (h *Handlers) GetRig(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, result)
 }
 
-// ListIssues handles GET /api/rigs/{rigId}/issues
-func (h *Handlers) ListIssues(w http.ResponseWriter, r *http.Request) {
-	rigID := r.PathValue("rigId")
+// warmIssueViews are the ListIssues query strings of the board's default all
+// and open views.
+var warmIssueViews = []url.Values{{}, {"status": {"open"}}}
 
-	// Build filter from query params
+// snapshotIssueFilter is the filter the WebSocket snapshot lists issues with.
+// The snapshot feeds issue detail views, so it keeps descriptions.
+func snapshotIssueFilter() query.IssueFilter {
+	return query.IssueFilter{IncludeDescription: true}
+}
+
+// WarmIssueFilters returns the issue-list filters worth keeping warm: the
+// filters ListIssues builds for the board's default views, and the WebSocket
+// snapshot's. With no page cap ListIssues streams past the cache, so only the
+// snapshot's filter is returned then.
+func (h *Handlers) WarmIssueFilters() []query.IssueFilter {
+	filters := []query.IssueFilter{snapshotIssueFilter()}
+	for _, view := range warmIssueViews {
+		filter, err := h.issueFilter(view)
+		if err != nil || filter.Limit == 0 {
+			continue
+		}
+		filters = append(filters, filter)
+	}
+	return filters
+}
+
+// issueFilter builds the issue-list filter for ListIssues query params, with
+// the limit clamped to the server's max page size.
+func (h *Handlers) issueFilter(params url.Values) (query.IssueFilter, error) {
 	filter := query.IssueFilter{}
 
-	if status := r.URL.Query().Get("status"); status != "" && status != "all" {
+	if status := params.Get("status"); status != "" && status != "all" {
 		filter.Status = []string{status}
 	}
-	if issueType := r.URL.Query().Get("type"); issueType != "" {
+	if issueType := params.Get("type"); issueType != "" {
 		filter.Type = []string{issueType}
 	}
-	if assignee := r.URL.Query().Get("assignee"); assignee != "" {
+	if assignee := params.Get("assignee"); assignee != "" {
 		filter.Assignee = assignee
 	}
+	if owner := params.Get("owner"); owner != "" {
+		filter.Owner = owner
+	}
+	if convoy := params.Get("convoy"); convoy != "" {
+		filter.Convoy = convoy
+	}
+	if v := params.Get("blocked"); v != "" {
+		blocked, err := strconv.ParseBool(v)
+		if err != nil {
+			return filter, errors.New("blocked must be true or false")
+		}
+		filter.Blocked = &blocked
+	}
+	if v := params.Get("include_description"); v != "" {
+		include, err := strconv.ParseBool(v)
+		if err != nil {
+			return filter, errors.New("include_description must be true or false")
+		}
+		filter.IncludeDescription = include
+	}
 
 	// Handle multiple types (comma-separated)
-	if typeFilter := r.URL.Query().Get("types"); typeFilter != "" {
+	if typeFilter := params.Get("types"); typeFilter != "" {
 		filter.Type = strings.Split(typeFilter, ",")
 		for i := range filter.Type {
 			filter.Type[i] = strings.TrimSpace(filter.Type[i])
 		}
 	}
 
+	filter.Limit = h.clampLimit(params, 0)
+	if offsetStr := params.Get("offset"); offsetStr != "" {
+		if parsed, err := strconv.Atoi(offsetStr); err == nil && parsed >= 0 {
+			filter.Offset = parsed
+		}
+	}
+	return filter, nil
+}
+
+// ListIssues handles GET /api/rigs/{rigId}/issues
+// Without a page limit (--max-page-size 0) the array is streamed as rows are
+// read instead of being built in memory.
+func (h *Handlers) ListIssues(w http.ResponseWriter, r *http.Request) {
+	rigID := r.PathValue("rigId")
+
+	filter, err := h.issueFilter(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, err.Error())
+		return
+	}
+	if filter.Limit > 0 {
+		w.Header().Set("X-Page-Limit", strconv.Itoa(filter.Limit))
+	}
+
+	if filter.Limit == 0 && h.streamIssues(w, r, rigID, filter) {
+		return
+	}
+
 	issues, err := h.rigManager.ListIssues(rigID, filter)
+	if markStale(w, err) {
+		err = nil
+	}
 	if err != nil {
 		slog.Error("Failed to list issues", "rigId", rigID, "error", err)
-		http.Error(w, "Failed to list issues", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to list issues")
 		return
 	}
 
@@ -112,23 +314,280 @@ func (h *Handlers) ListIssues(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, issues)
 }
 
+// streamIssues writes the matching issues as a JSON array, one element at a
+// time. Nothing is written until the first row has been read, so it returns
+// false when the query fails before then and the caller can still answer
+// through the cached path, which serves stale data or a proper error.
+func (h *Handlers) streamIssues(w http.ResponseWriter, r *http.Request, rigID string, filter query.IssueFilter) bool {
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	started := false
+	written := 0
+	err := h.rigManager.StreamIssues(rigID, filter, func(issue types.Issue) error {
+		sep := ","
+		if !started {
+			started = true
+			w.Header().Set("Content-Type", "application/json")
+			sep = "["
+		}
+		if _, err := io.WriteString(w, sep); err != nil {
+			return err
+		}
+		if err := enc.Encode(issue); err != nil {
+			return err
+		}
+		written++
+		if flusher != nil && written%500 == 0 {
+			flusher.Flush()
+		}
+		return r.Context().Err()
+	})
+	if err != nil {
+		if !started {
+			slog.Warn("Failed to stream issues, falling back to cached list", "rigId", rigID, "error", err)
+			return false
+		}
+		// Headers are already sent; the truncated array is all we can signal
+		slog.Error("Failed to stream issues", "rigId", rigID, "written", written, "error", err)
+		return true
+	}
+	if !started {
+		writeJSON(w, []types.Issue{})
+		return true
+	}
+	io.WriteString(w, "]\n")
+	return true
+}
+
+// ListClosedIssues handles GET /api/rigs/{rigId}/issues/closed
+// Returns issues closed within [since, until), most recently closed first.
+// since defaults to 7 days ago; until defaults to now.
+func (h *Handlers) ListClosedIssues(w http.ResponseWriter, r *http.Request) {
+	rigID := r.PathValue("rigId")
+
+	since := time.Now().Add(-7 * 24 * time.Hour)
+	if v := r.URL.Query().Get("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, "since must be an RFC3339 timestamp")
+			return
+		}
+		since = t
+	}
+
+	filter := query.IssueFilter{
+		Status:        []string{"closed"},
+		ClosedSince:   &since,
+		OrderByClosed: true,
+	}
+
+	if v := r.URL.Query().Get("until"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, "until must be an RFC3339 timestamp")
+			return
+		}
+		filter.ClosedUntil = &t
+	}
+	filter.Limit = h.pageLimit(w, r, 0)
+
+	issues, err := h.rigManager.ListIssues(rigID, filter)
+	if markStale(w, err) {
+		err = nil
+	}
+	if err != nil {
+		slog.Error("Failed to list closed issues", "rigId", rigID, "error", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to list closed issues")
+		return
+	}
+
+	if issues == nil {
+		issues = []types.Issue{}
+	}
+
+	writeJSON(w, issues)
+}
+
+// IssueDetail is an issue with related data embedded via ?expand=.
+type IssueDetail struct {
+	types.Issue
+	DependencyLinks *types.IssueDependencies `json:"dependency_links,omitempty"` // expand=dependencies
+	Telemetry       *telemetry.BeadTelemetry `json:"telemetry,omitempty"`        // expand=telemetry
+	ConvoyETA       *telemetry.ConvoyETA     `json:"convoy_eta,omitempty"`       // expand=convoy, when history allows
+}
+
 // GetIssue handles GET /api/rigs/{rigId}/issues/{issueId}
+// ?expand=dependencies,convoy,telemetry embeds related data in one response;
+// convoy only applies to convoy issues and telemetry is empty when disabled.
 func (h *Handlers) GetIssue(w http.ResponseWriter, r *http.Request) {
 	rigID := r.PathValue("rigId")
 	issueID := r.PathValue("issueId")
 
 	issue, err := h.rigManager.GetIssue(rigID, issueID)
+	if markStale(w, err) {
+		err = nil
+	}
 	if err != nil {
 		slog.Error("Failed to get issue", "rigId", rigID, "issueId", issueID, "error", err)
-		http.Error(w, "Failed to get issue", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to get issue")
 		return
 	}
 
 	if issue == nil {
-		http.Error(w, "Issue not found", http.StatusNotFound)
+		writeError(w, http.StatusNotFound, ErrCodeIssueNotFound, "Issue not found")
 		return
 	}
 
+	expand := r.URL.Query().Get("expand")
+	if expand == "" {
+		writeJSON(w, issue)
+		return
+	}
+
+	detail := IssueDetail{Issue: *issue}
+	for _, part := range strings.Split(expand, ",") {
+		switch strings.TrimSpace(part) {
+		case "dependencies":
+			deps, err := h.rigManager.GetDependencies(rigID, issueID)
+			if err != nil {
+				slog.Error("Failed to get issue dependencies", "rigId", rigID, "issueId", issueID, "error", err)
+				writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to get issue dependencies")
+				return
+			}
+			detail.DependencyLinks = deps
+		case "convoy":
+			if issue.IssueType != types.TypeConvoy {
+				continue
+			}
+			progress, err := h.rigManager.GetConvoyProgress(rigID, issueID)
+			if err != nil {
+				slog.Error("Failed to get convoy progress", "rigId", rigID, "issueId", issueID, "error", err)
+				writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to get convoy progress")
+				return
+			}
+			detail.Convoy = &types.ConvoyInfo{ID: issue.ID, Title: issue.Title, Progress: *progress}
+			eta, err := h.collector().EstimateConvoyCompletion(rigID, issueID)
+			if err != nil {
+				slog.Error("Failed to estimate convoy completion", "rigId", rigID, "issueId", issueID, "error", err)
+				writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to estimate convoy completion")
+				return
+			}
+			detail.ConvoyETA = eta
+		case "telemetry":
+			bt, err := h.collector().GetBeadTelemetry(issueID)
+			if err != nil {
+				slog.Error("Failed to get bead telemetry", "beadId", issueID, "error", err)
+				writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to get bead telemetry")
+				return
+			}
+			detail.Telemetry = &bt
+		case "":
+		default:
+			writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, "expand must be a list of dependencies, convoy, telemetry")
+			return
+		}
+	}
+
+	writeJSON(w, detail)
+}
+
+// CreateIssue handles POST /api/rigs/{rigId}/issues
+// Creates an issue with bd. ?template=<name> fills the description from an
+// issue template, substituting the request's variables.
+func (h *Handlers) CreateIssue(w http.ResponseWriter, r *http.Request) {
+	rigID := r.PathValue("rigId")
+
+	var req types.IssueCreate
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid request body")
+		return
+	}
+	if strings.TrimSpace(req.Title) == "" {
+		writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, "title is required")
+		return
+	}
+	if req.Priority != nil && !req.Priority.Valid() {
+		writeError(w, http.StatusBadRequest, ErrCodeValidationFailed,
+			fmt.Sprintf("priority must be between %d and %d", types.PriorityMin, types.PriorityMax))
+		return
+	}
+
+	if name := r.URL.Query().Get("template"); name != "" {
+		if req.Description != "" {
+			writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, "description cannot be combined with template")
+			return
+		}
+		tmpl, err := h.loadTemplate(name)
+		if errors.Is(err, errTemplateNotFound) {
+			writeError(w, http.StatusNotFound, ErrCodeNotFound, "Template not found")
+			return
+		}
+		if err != nil {
+			slog.Error("Failed to load template", "template", name, "error", err)
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to load template")
+			return
+		}
+		req.Description, err = renderTemplate(tmpl, req.Variables)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, err.Error())
+			return
+		}
+	}
+
+	issueType := req.Type
+	if issueType == "" {
+		issueType = types.TypeTask
+	}
+	// --title keeps a title starting with "-" from being parsed as a flag
+	args := []string{"create", "--title", req.Title, "--type", issueType, "--json"}
+	if req.Priority != nil {
+		args = append(args, "--priority", strconv.Itoa(int(*req.Priority)))
+	}
+	if req.Description != "" {
+		args = append(args, "--description", req.Description)
+	}
+	if req.Assignee != "" {
+		args = append(args, "--assignee", req.Assignee)
+	}
+	if len(req.Labels) > 0 {
+		args = append(args, "--labels", strings.Join(req.Labels, ","))
+	}
+
+	out, err := h.runBDOutput(rigID, args...)
+	if err != nil {
+		slog.Error("Failed to create issue", "rigId", rigID, "error", err)
+		writeBDError(w, err, "Failed to create issue")
+		return
+	}
+
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(out, &created); err != nil || created.ID == "" {
+		slog.Error("Failed to parse created issue", "rigId", rigID, "output", string(out), "error", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeBDCommandFailed, "Failed to read created issue ID")
+		return
+	}
+
+	h.rigManager.RefreshRig(rigID)
+
+	if h.eventStore != nil {
+		h.eventStore.Emit("bead.created", "townview/server", rigID, map[string]interface{}{
+			"issue_id": created.ID,
+			"title":    req.Title,
+			"rig":      rigID,
+		})
+	}
+
+	issue, err := h.rigManager.GetIssue(rigID, created.ID)
+	if err != nil || issue == nil {
+		slog.Error("Failed to read back created issue", "rigId", rigID, "issueId", created.ID, "error", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal,
+			fmt.Sprintf("Issue %s created but failed to read it back", created.ID))
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
 	writeJSON(w, issue)
 }
 
@@ -140,7 +599,12 @@ func (h *Handlers) UpdateIssue(w http.ResponseWriter, r *http.Request) {
 
 	var update types.IssueUpdate
 	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid request body")
+		return
+	}
+	if update.Priority != nil && !update.Priority.Valid() {
+		writeError(w, http.StatusBadRequest, ErrCodeValidationFailed,
+			fmt.Sprintf("priority must be between %d and %d", types.PriorityMin, types.PriorityMax))
 		return
 	}
 
@@ -150,7 +614,7 @@ func (h *Handlers) UpdateIssue(w http.ResponseWriter, r *http.Request) {
 		args = append(args, "--status", *update.Status)
 	}
 	if update.Priority != nil {
-		args = append(args, "--priority", strconv.Itoa(*update.Priority))
+		args = append(args, "--priority", strconv.Itoa(int(*update.Priority)))
 	}
 	if update.Title != nil {
 		args = append(args, "--title", *update.Title)
@@ -162,7 +626,7 @@ func (h *Handlers) UpdateIssue(w http.ResponseWriter, r *http.Request) {
 	// Execute bd update
 	if err := h.runBD(rigID, args...); err != nil {
 		slog.Error("Failed to update issue", "rigId", rigID, "issueId", issueID, "error", err)
-		http.Error(w, "Failed to update issue", http.StatusInternalServerError)
+		writeBDError(w, err, "Failed to update issue")
 		return
 	}
 
@@ -171,49 +635,549 @@ func (h *Handlers) UpdateIssue(w http.ResponseWriter, r *http.Request) {
 
 	issue, err := h.rigManager.GetIssue(rigID, issueID)
 	if err != nil {
-		http.Error(w, "Failed to get updated issue", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to get updated issue")
 		return
 	}
 
 	// Emit event
 	if h.eventStore != nil {
-		h.eventStore.Emit("bead.updated", "townview/server", rigID, map[string]interface{}{
+		h.eventStore.Emit(events.TypeBeadUpdated, "townview/server", rigID, events.BeadUpdatedPayload{
+			IssueID: issueID,
+			Rig:     rigID,
+		})
+	}
+
+	writeJSON(w, issue)
+}
+
+// GetDependencyGraph handles GET /api/rigs/{rigId}/issues/{issueId}/graph
+// Returns the tree of issues blocked by the issue. ?max_depth= and ?max_children=
+// bound it, capped to the server maximums (max_children=0 means the cap); nodes
+// with children left out report them in more_children.
+func (h *Handlers) GetDependencyGraph(w http.ResponseWriter, r *http.Request) {
+	rigID := r.PathValue("rigId")
+	issueID := r.PathValue("issueId")
+
+	opts := query.DefaultGraphOptions()
+	for param, target := range map[string]*int{"max_depth": &opts.MaxDepth, "max_children": &opts.MaxChildren} {
+		v := r.URL.Query().Get(param)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, param+" must be a non-negative integer")
+			return
+		}
+		*target = n
+	}
+	opts = opts.Clamped()
+
+	graph, err := h.rigManager.GetDependencyGraph(rigID, issueID, opts)
+	if errors.Is(err, query.ErrIssueNotFound) {
+		writeError(w, http.StatusNotFound, ErrCodeIssueNotFound, "Issue not found")
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to get dependency graph", "rigId", rigID, "issueId", issueID, "error", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to get dependency graph")
+		return
+	}
+
+	writeJSON(w, graph)
+}
+
+// DeleteIssue handles DELETE /api/rigs/{rigId}/issues/{issueId}
+// Deletes the issue via bd and returns 204. Refuses with 409 while open issues
+// still depend on it, unless ?force=true.
+func (h *Handlers) DeleteIssue(w http.ResponseWriter, r *http.Request) {
+	rigID := r.PathValue("rigId")
+	issueID := r.PathValue("issueId")
+	force := r.URL.Query().Get("force") == "true"
+
+	issue, err := h.rigManager.GetIssue(rigID, issueID)
+	if err != nil && !errors.Is(err, query.ErrStale) {
+		slog.Error("Failed to get issue", "rigId", rigID, "issueId", issueID, "error", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to get issue")
+		return
+	}
+	if issue == nil {
+		writeError(w, http.StatusNotFound, ErrCodeIssueNotFound, "Issue not found")
+		return
+	}
+
+	if !force {
+		deps, err := h.rigManager.GetDependencies(rigID, issueID)
+		if err != nil {
+			slog.Error("Failed to get issue dependencies", "rigId", rigID, "issueId", issueID, "error", err)
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to get issue dependencies")
+			return
+		}
+		active := 0
+		for _, dependent := range deps.BlockedBy {
+			if dependent.Status != types.StatusClosed && dependent.Status != types.StatusTombstone {
+				active++
+			}
+		}
+		if active > 0 {
+			writeError(w, http.StatusConflict, ErrCodeActiveDependents,
+				fmt.Sprintf("%d open issue(s) depend on this issue; pass force=true to delete anyway", active))
+			return
+		}
+	}
+
+	// bd delete only previews without --force
+	if err := h.runBD(rigID, "delete", issueID, "--force"); err != nil {
+		slog.Error("Failed to delete issue", "rigId", rigID, "issueId", issueID, "error", err)
+		writeBDError(w, err, "Failed to delete issue")
+		return
+	}
+
+	h.rigManager.RefreshRig(rigID)
+
+	if h.eventStore != nil {
+		h.eventStore.Emit("bead.deleted", "townview/server", rigID, map[string]interface{}{
 			"issue_id": issueID,
+			"title":    issue.Title,
 			"rig":      rigID,
 		})
 	}
 
-	writeJSON(w, issue)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AgentHeartbeat handles POST /api/agents/heartbeat
+// Updates the agent's registry state and persists any reported token delta to
+// telemetry so cost reports match the live token count, under the heartbeat's
+// model or the server default. Heartbeats reporting tokens need the telemetry
+// token; liveness-only heartbeats don't. In read-only mode, or when neither names a
+// model, the token delta only updates the registry.
+func (h *Handlers) AgentHeartbeat(w http.ResponseWriter, r *http.Request) {
+	if h.agentRegistry == nil {
+		writeError(w, http.StatusServiceUnavailable, ErrCodeInternal, "Agent registry not configured")
+		return
+	}
+
+	var beat registry.Heartbeat
+	if err := json.NewDecoder(r.Body).Decode(&beat); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid request body")
+		return
+	}
+	if beat.AgentID == "" {
+		writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, "agent_id is required")
+		return
+	}
+	if beat.TokensSinceLast != nil && *beat.TokensSinceLast < 0 {
+		writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, "tokens_since_last must not be negative")
+		return
+	}
+	// Token counts become telemetry, so they need the telemetry token
+	if beat.TokensSinceLast != nil && !h.authorizeTelemetry(w, r) {
+		return
+	}
+
+	current := h.agentRegistry.GetAgent(beat.AgentID)
+	if current == nil {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "Agent not registered")
+		return
+	}
+	if beat.Timestamp.IsZero() {
+		beat.Timestamp = time.Now()
+	}
+	if beat.Status == "" {
+		beat.Status = current.Status
+	}
+
+	state := h.agentRegistry.Heartbeat(beat)
+	if state == nil {
+		// Deregistered between the lookup and the heartbeat
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "Agent not registered")
+		return
+	}
+
+	model := h.usageModel(beat.Model)
+	if beat.TokensSinceLast != nil && *beat.TokensSinceLast > 0 && model != "" && !h.ReadOnly() {
+		usage := telemetry.TokenUsage{
+			AgentID:     state.ID,
+			Rig:         state.Rig,
+			Timestamp:   beat.Timestamp.UTC().Format(time.RFC3339),
+			InputTokens: *beat.TokensSinceLast, // Heartbeats report a combined count
+			Model:       model,
+			RequestType: "heartbeat",
+		}
+		if state.CurrentBead != nil {
+			usage.BeadID = *state.CurrentBead
+		}
+		if err := h.collector().RecordTokenUsage(usage); err != nil {
+			if !errors.Is(err, telemetry.ErrUnavailable) {
+				slog.Warn("Failed to record heartbeat token usage", "agentId", state.ID, "error", err)
+			}
+		} else if usage.BeadID != "" {
+			h.checkBeadBudget(usage.BeadID)
+		}
+	}
+
+	writeJSON(w, state)
 }
 
 // ListAgents handles GET /api/rigs/{rigId}/agents
+// With ?include_health=true, returns {agents, health} including the per-role health roll-up.
+// ?label=key=value (repeatable) and ?bead=<id> narrow the list.
 func (h *Handlers) ListAgents(w http.ResponseWriter, r *http.Request) {
 	rigID := r.PathValue("rigId")
+	includeHealth := r.URL.Query().Get("include_health") == "true"
 
 	if h.agentRegistry == nil {
+		if includeHealth {
+			writeJSON(w, types.AgentList{Agents: []types.Agent{}, Health: &types.AgentHealth{}})
+			return
+		}
 		writeJSON(w, []types.Agent{})
 		return
 	}
 
-	agents := h.agentRegistry.ListAgents(&registry.AgentFilter{Rig: &rigID})
+	// Repeatable ?label=key=value params; agents must match all of them
+	labels, err := parseLabelFilter(r.URL.Query()["label"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, err.Error())
+		return
+	}
+
+	filter := &registry.AgentFilter{Rig: &rigID, Labels: labels}
+	if bead := r.URL.Query().Get("bead"); bead != "" {
+		filter.CurrentBead = &bead
+	}
+
+	agents := h.agentRegistry.ListAgents(filter)
 
 	// Convert to types.Agent
 	result := make([]types.Agent, 0, len(agents))
 	for _, a := range agents {
-		agent := types.Agent{
-			ID:        a.ID,
-			Name:      a.Name,
-			RoleType:  string(a.Role),
-			Rig:       a.Rig,
-			State:     string(a.Status),
-			UpdatedAt: a.LastHeartbeat,
+		result = append(result, toAPIAgent(a))
+	}
+
+	if includeHealth {
+		health := h.rigManager.ComputeAgentHealth(agents)
+		writeJSON(w, types.AgentList{Agents: result, Health: &health})
+		return
+	}
+
+	writeJSON(w, result)
+}
+
+// toAPIAgent converts a registry agent to its API representation.
+func toAPIAgent(a registry.AgentState) types.Agent {
+	agent := types.Agent{
+		ID:        a.ID,
+		Name:      a.Name,
+		RoleType:  string(a.Role),
+		Rig:       a.Rig,
+		State:     string(a.Status),
+		UpdatedAt: a.LastHeartbeat,
+		Labels:    a.Labels,
+
+		HasHeartbeated: a.HasHeartbeated,
+	}
+	if a.LastSelfHeartbeat != nil {
+		beat := *a.LastSelfHeartbeat
+		agent.LastSelfHeartbeat = &beat
+	}
+	if a.CurrentBead != nil {
+		agent.HookBead = *a.CurrentBead
+		if a.CurrentBeadStarted != nil {
+			started := *a.CurrentBeadStarted
+			agent.CurrentBeadStarted = &started
+			agent.BeadDurationSeconds = int64(time.Since(started).Seconds())
+		}
+	}
+	return agent
+}
+
+// defaultActiveWithin is how recent a heartbeat must be for ListActiveAgents
+// when ?within= is omitted.
+const defaultActiveWithin = 5 * time.Minute
+
+// ListActiveAgents handles GET /api/agents/active
+// Returns agents across all rigs that reported their own heartbeat within
+// ?within= (a Go duration, default 5m), most recent first. Discovery refreshes
+// don't count, so agents known only from discovery, or whose session is alive
+// but silent, are left out; see ListStaleStateAgents.
+func (h *Handlers) ListActiveAgents(w http.ResponseWriter, r *http.Request) {
+	within := defaultActiveWithin
+	if withinStr := r.URL.Query().Get("within"); withinStr != "" {
+		parsed, err := time.ParseDuration(withinStr)
+		if err != nil || parsed <= 0 {
+			writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, "within must be a positive duration, e.g. 5m")
+			return
+		}
+		within = parsed
+	}
+
+	if h.agentRegistry == nil {
+		writeJSON(w, []types.Agent{})
+		return
+	}
+
+	cutoff := time.Now().Add(-within)
+	var active []registry.AgentState
+	for _, a := range h.agentRegistry.ListAgents(nil) {
+		if a.LastSelfHeartbeat != nil && a.LastSelfHeartbeat.After(cutoff) {
+			active = append(active, a)
+		}
+	}
+	sort.Slice(active, func(i, j int) bool {
+		return active[i].LastSelfHeartbeat.After(*active[j].LastSelfHeartbeat)
+	})
+
+	result := make([]types.Agent, 0, len(active))
+	for _, a := range active {
+		result = append(result, toAPIAgent(a))
+	}
+
+	writeJSON(w, result)
+}
+
+// ListStaleStateAgents handles GET /api/agents/stale-state
+// Returns agents that have never sent a heartbeat of their own, so their status
+// and current bead are inferred from discovery. Oldest registration first.
+func (h *Handlers) ListStaleStateAgents(w http.ResponseWriter, r *http.Request) {
+	if h.agentRegistry == nil {
+		writeJSON(w, []types.Agent{})
+		return
+	}
+
+	var stale []registry.AgentState
+	for _, a := range h.agentRegistry.ListAgents(nil) {
+		if !a.HasHeartbeated {
+			stale = append(stale, a)
+		}
+	}
+	sort.Slice(stale, func(i, j int) bool {
+		return stale[i].StartedAt.Before(stale[j].StartedAt)
+	})
+
+	result := make([]types.Agent, 0, len(stale))
+	for _, a := range stale {
+		result = append(result, toAPIAgent(a))
+	}
+
+	writeJSON(w, result)
+}
+
+// ListStuckAgents handles GET /api/agents/stuck and GET /api/rigs/{rigId}/agents/stuck
+// Returns stuck agents, longest stuck first. Stuck time is measured from when the
+// agent started its current bead, or from its last status change if it has none.
+func (h *Handlers) ListStuckAgents(w http.ResponseWriter, r *http.Request) {
+	if h.agentRegistry == nil {
+		writeJSON(w, []types.StuckAgent{})
+		return
+	}
+
+	status := registry.StatusStuck
+	filter := &registry.AgentFilter{Status: &status}
+	if rigID := r.PathValue("rigId"); rigID != "" {
+		filter.Rig = &rigID
+	}
+
+	now := time.Now()
+	result := []types.StuckAgent{}
+	for _, a := range h.agentRegistry.ListAgents(filter) {
+		since := a.StatusChangedAt
+		if a.CurrentBeadStarted != nil {
+			since = *a.CurrentBeadStarted
+		}
+		stuck := types.StuckAgent{
+			Agent:                toAPIAgent(a),
+			StuckSince:           since,
+			StuckDurationSeconds: int64(now.Sub(since).Seconds()),
 		}
-		if a.CurrentBead != nil {
-			agent.HookBead = *a.CurrentBead
+		if a.StuckReason != nil {
+			stuck.StuckReason = *a.StuckReason
 		}
-		result = append(result, agent)
+		result = append(result, stuck)
 	}
 
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].StuckSince.Before(result[j].StuckSince)
+	})
+
+	writeJSON(w, result)
+}
+
+// parseLabelFilter parses key=value pairs into a label filter.
+// Returns nil when no pairs are given.
+func parseLabelFilter(pairs []string) (map[string]string, error) {
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+	labels := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("label must be key=value, got %q", pair)
+		}
+		labels[key] = value
+	}
+	return labels, nil
+}
+
+// MoveIssue handles POST /api/rigs/{rigId}/issues/{issueId}/move
+// Recreates the issue in the target rig, rewrites its dependencies as external
+// references back to the source rig, re-points the source rig's dependents at
+// the new issue, and closes the original with reason "moved". Status or edges
+// that fail to carry over are listed in the result's warnings.
+func (h *Handlers) MoveIssue(w http.ResponseWriter, r *http.Request) {
+	rigID := r.PathValue("rigId")
+	issueID := r.PathValue("issueId")
+
+	var req types.IssueMove
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid request body")
+		return
+	}
+	if req.TargetRig == "" {
+		writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, "target_rig is required")
+		return
+	}
+	if req.TargetRig == rigID {
+		writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, "target_rig must differ from the source rig")
+		return
+	}
+	if _, err := h.rigManager.GetRig(req.TargetRig); err != nil {
+		writeError(w, http.StatusNotFound, ErrCodeRigNotFound, "Target rig not found")
+		return
+	}
+
+	issue, err := h.rigManager.GetIssue(rigID, issueID)
+	if err != nil {
+		slog.Error("Failed to get issue for move", "rigId", rigID, "issueId", issueID, "error", err)
+		writeBDError(w, err, "Failed to get issue")
+		return
+	}
+	if issue == nil {
+		writeError(w, http.StatusNotFound, ErrCodeIssueNotFound, "Issue not found")
+		return
+	}
+
+	deps, err := h.rigManager.GetRawDependencies(rigID, issueID)
+	var dependents []types.IssueDependency
+	if err == nil {
+		dependents, err = h.rigManager.GetRawDependents(rigID, issueID)
+	}
+	if err != nil {
+		slog.Error("Failed to get dependencies for move", "rigId", rigID, "issueId", issueID, "error", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to read issue dependencies")
+		return
+	}
+
+	// Create the copy in the target rig; the title goes through --title so a
+	// leading "-" is not parsed as a flag
+	args := []string{"create",
+		"--title", issue.Title,
+		"--type", issue.IssueType,
+		"--priority", strconv.Itoa(issue.Priority),
+		"--json",
+	}
+	if issue.Description != "" {
+		args = append(args, "--description", issue.Description)
+	}
+	if issue.Assignee != "" {
+		args = append(args, "--assignee", issue.Assignee)
+	}
+	if len(issue.Labels) > 0 {
+		args = append(args, "--labels", strings.Join(issue.Labels, ","))
+	}
+
+	out, err := h.runBDOutput(req.TargetRig, args...)
+	if err != nil {
+		slog.Error("Failed to create moved issue", "rigId", req.TargetRig, "issueId", issueID, "error", err)
+		writeBDError(w, err, "Failed to create issue in target rig")
+		return
+	}
+
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(out, &created); err != nil || created.ID == "" {
+		slog.Error("Failed to parse created issue", "rigId", req.TargetRig, "output", string(out), "error", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeBDCommandFailed, "Failed to read created issue ID")
+		return
+	}
+
+	// Anything that can't be carried over is reported back rather than lost
+	var warnings []string
+
+	// bd create always starts open
+	if issue.Status != "" && issue.Status != "open" {
+		if err := h.runBD(req.TargetRig, "update", created.ID, "--status", issue.Status); err != nil {
+			slog.Warn("Failed to carry status over to moved issue", "rigId", req.TargetRig, "issueId", created.ID, "status", issue.Status, "error", err)
+			warnings = append(warnings, fmt.Sprintf("status %s was not carried over", issue.Status))
+		}
+	}
+
+	// Same-rig dependencies now point back across rigs
+	for _, dep := range deps {
+		target := dep.DependsOnID
+		if !strings.HasPrefix(target, "external:") {
+			target = "external:" + rigID + ":" + target
+		}
+		if err := h.runBD(req.TargetRig, "dep", "add", created.ID, target, "--type", dep.Type); err != nil {
+			slog.Warn("Failed to carry dependency over to moved issue", "rigId", req.TargetRig, "issueId", created.ID, "dependsOn", target, "error", err)
+			warnings = append(warnings, fmt.Sprintf("dependency on %s (%s) was not carried over", target, dep.Type))
+		}
+	}
+
+	// Source-rig issues that depended on the original now depend on the copy
+	movedRef := "external:" + req.TargetRig + ":" + created.ID
+	for _, dep := range dependents {
+		if err := h.runBD(rigID, "dep", "add", dep.IssueID, movedRef, "--type", dep.Type); err != nil {
+			slog.Warn("Failed to re-point dependent at moved issue", "rigId", rigID, "issueId", dep.IssueID, "dependsOn", movedRef, "error", err)
+			warnings = append(warnings, fmt.Sprintf("%s still depends on the original (%s)", dep.IssueID, dep.Type))
+			continue
+		}
+		if err := h.runBD(rigID, "dep", "remove", dep.IssueID, issueID); err != nil {
+			slog.Warn("Failed to drop dependent's edge to moved issue", "rigId", rigID, "issueId", dep.IssueID, "error", err)
+			warnings = append(warnings, fmt.Sprintf("%s depends on both the original and the copy", dep.IssueID))
+		}
+	}
+
+	// Close the original; the copy stays, so the error names it
+	if err := h.runBD(rigID, "close", issueID, "--reason", "moved"); err != nil {
+		slog.Error("Failed to close moved issue", "rigId", rigID, "issueId", issueID, "targetId", created.ID, "error", err)
+		h.rigManager.RefreshRig(rigID)
+		h.rigManager.RefreshRig(req.TargetRig)
+		writeBDError(w, err, fmt.Sprintf("Issue copied to %s as %s but failed to close the original", req.TargetRig, created.ID))
+		return
+	}
+
+	h.rigManager.RefreshRig(rigID)
+	h.rigManager.RefreshRig(req.TargetRig)
+
+	result := types.IssueMoveResult{
+		SourceRig: rigID,
+		SourceID:  issueID,
+		TargetRig: req.TargetRig,
+		TargetID:  created.ID,
+		Warnings:  warnings,
+	}
+
+	// Emit events in both rigs
+	if h.eventStore != nil {
+		payload := map[string]interface{}{
+			"source_rig": rigID,
+			"source_id":  issueID,
+			"target_rig": req.TargetRig,
+			"target_id":  created.ID,
+		}
+		if err := h.eventStore.EmitBatch([]events.EventInput{
+			{Type: "bead.moved_out", Source: "townview/server", Rig: rigID, Payload: payload},
+			{Type: "bead.moved_in", Source: "townview/server", Rig: req.TargetRig, Payload: payload},
+		}); err != nil {
+			slog.Error("Failed to emit move events", "rigId", rigID, "issueId", issueID, "error", err)
+		}
+	}
+
+	w.WriteHeader(http.StatusCreated)
 	writeJSON(w, result)
 }
 
@@ -222,99 +1186,375 @@ func (h *Handlers) GetIssueDependencies(w http.ResponseWriter, r *http.Request)
 	rigID := r.PathValue("rigId")
 	issueID := r.PathValue("issueId")
 
-	deps, err := h.rigManager.GetDependencies(rigID, issueID)
-	if err != nil {
-		slog.Error("Failed to get issue dependencies", "rigId", rigID, "issueId", issueID, "error", err)
-		http.Error(w, "Failed to get issue dependencies", http.StatusInternalServerError)
+	deps, err := h.rigManager.GetDependencies(rigID, issueID)
+	if err != nil {
+		slog.Error("Failed to get issue dependencies", "rigId", rigID, "issueId", issueID, "error", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to get issue dependencies")
+		return
+	}
+
+	writeJSON(w, deps)
+}
+
+// GetRawIssueDependencies handles GET /api/rigs/{rigId}/issues/{issueId}/dependencies/raw
+// Returns the issue's dependency edges with their type, created_at and created_by.
+// Optional ?depends_on= narrows the result to the edge to one issue.
+func (h *Handlers) GetRawIssueDependencies(w http.ResponseWriter, r *http.Request) {
+	rigID := r.PathValue("rigId")
+	issueID := r.PathValue("issueId")
+
+	deps, err := h.rigManager.GetRawDependencies(rigID, issueID)
+	if err != nil {
+		slog.Error("Failed to get raw issue dependencies", "rigId", rigID, "issueId", issueID, "error", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to get issue dependencies")
+		return
+	}
+
+	if dependsOn := r.URL.Query().Get("depends_on"); dependsOn != "" {
+		edges := []types.IssueDependency{}
+		for _, dep := range deps {
+			if dep.DependsOnID == dependsOn {
+				edges = append(edges, dep)
+			}
+		}
+		deps = edges
+	}
+
+	writeJSON(w, deps)
+}
+
+// GetIssueUnblocks handles GET /api/rigs/{rigId}/issues/{issueId}/unblocks
+// Returns the open issues whose only remaining blocker is this issue.
+func (h *Handlers) GetIssueUnblocks(w http.ResponseWriter, r *http.Request) {
+	rigID := r.PathValue("rigId")
+	issueID := r.PathValue("issueId")
+
+	issues, err := h.rigManager.GetUnblockedBy(rigID, issueID)
+	if errors.Is(err, query.ErrIssueNotFound) {
+		writeError(w, http.StatusNotFound, ErrCodeIssueNotFound, "Issue not found")
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to get unblocked issues", "rigId", rigID, "issueId", issueID, "error", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to get unblocked issues")
+		return
+	}
+
+	writeJSON(w, issues)
+}
+
+// AddIssueDependency handles POST /api/rigs/{rigId}/issues/{issueId}/dependencies
+func (h *Handlers) AddIssueDependency(w http.ResponseWriter, r *http.Request) {
+	rigID := r.PathValue("rigId")
+	issueID := r.PathValue("issueId")
+
+	var req types.DependencyAdd
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid request body")
+		return
+	}
+
+	if req.BlockerID == "" {
+		writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, "blocker_id is required")
+		return
+	}
+
+	// Use bd dep add
+	if err := h.runBD(rigID, "dep", "add", issueID, req.BlockerID); err != nil {
+		slog.Error("Failed to add dependency", "rigId", rigID, "issueId", issueID, "blockerId", req.BlockerID, "error", err)
+		writeBDError(w, err, "Failed to add dependency")
+		return
+	}
+
+	// Refresh cache
+	h.rigManager.RefreshRig(rigID)
+
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, map[string]string{"status": "ok"})
+}
+
+// validDependencyTypes are the dependency types accepted by the batch endpoint.
+var validDependencyTypes = map[string]bool{
+	"blocks":       true,
+	"tracks":       true,
+	"parent-child": true,
+}
+
+// AddDependenciesBatch handles POST /api/rigs/{rigId}/dependencies/batch
+// The whole batch is validated and cycle-checked before any edge is added, so a
+// cycle check that can't load dependencies fails it with 500 having changed
+// nothing. Valid edges are then added in order, each bd failure reported in its
+// own result, and the cache is refreshed once at the end.
+func (h *Handlers) AddDependenciesBatch(w http.ResponseWriter, r *http.Request) {
+	rigID := r.PathValue("rigId")
+
+	var edges []types.DependencyEdge
+	if err := json.NewDecoder(r.Body).Decode(&edges); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid request body")
+		return
+	}
+	if len(edges) == 0 {
+		writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, "At least one edge is required")
+		return
+	}
+	if _, err := h.rigManager.GetRig(rigID); err != nil {
+		writeError(w, http.StatusNotFound, ErrCodeRigNotFound, "Rig not found")
+		return
+	}
+	if !h.bdAvailable() {
+		writeBDError(w, errBDUnavailable, "Failed to add dependencies")
+		return
+	}
+
+	result := types.DependencyBatchResult{Results: make([]types.DependencyEdgeResult, 0, len(edges))}
+	accepted := make(map[string][]types.DependencyEdge) // edges accepted earlier in this batch, keyed by issue
+
+	// Validate everything first; only "pending" results are applied below
+	for _, edge := range edges {
+		edge.IssueID = strings.TrimSpace(edge.IssueID)
+		edge.BlockerID = strings.TrimSpace(edge.BlockerID)
+		if edge.Type == "" {
+			edge.Type = "blocks"
+		}
+		res := types.DependencyEdgeResult{DependencyEdge: edge}
+
+		switch {
+		case edge.IssueID == "" || edge.BlockerID == "":
+			res.Status = "invalid"
+			res.Error = "issue_id and blocker_id are required"
+		case edge.IssueID == edge.BlockerID:
+			res.Status = "invalid"
+			res.Error = "an issue cannot depend on itself"
+		case !validDependencyTypes[edge.Type]:
+			res.Status = "invalid"
+			res.Error = "unknown dependency type: " + edge.Type
+		default:
+			cycle, err := h.dependencyReaches(rigID, edge.BlockerID, edge.IssueID, edge.Type, accepted)
+			if err != nil {
+				// Without the dependency data the edge can't be proven acyclic
+				slog.Error("Failed to check dependency cycle", "rigId", rigID, "issueId", edge.IssueID, "blockerId", edge.BlockerID, "error", err)
+				writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to check dependency cycle")
+				return
+			}
+			if cycle {
+				res.Status = "cycle"
+				res.Error = "edge would create a dependency cycle"
+				break
+			}
+			res.Status = "pending"
+			accepted[edge.IssueID] = append(accepted[edge.IssueID], edge)
+		}
+		result.Results = append(result.Results, res)
+	}
+
+	// Dropping an edge can't create a cycle, so a failed add leaves the
+	// later edges' cycle checks valid
+	for i := range result.Results {
+		res := &result.Results[i]
+		if res.Status == "pending" {
+			if err := h.runBD(rigID, "dep", "add", res.IssueID, res.BlockerID, "--type", res.Type); err != nil {
+				slog.Error("Failed to add dependency", "rigId", rigID, "issueId", res.IssueID, "blockerId", res.BlockerID, "error", err)
+				res.Status = "failed"
+				res.Error = err.Error()
+			} else {
+				res.Status = "added"
+			}
+		}
+		if res.Status == "added" {
+			result.Added++
+		} else {
+			result.Failed++
+		}
+	}
+
+	if result.Added > 0 {
+		// Refresh cache once for the whole batch
+		h.rigManager.RefreshRig(rigID)
+
+		if h.eventStore != nil {
+			var addedEdges []types.DependencyEdge
+			var batch []events.EventInput
+			for _, res := range result.Results {
+				if res.Status == "added" {
+					addedEdges = append(addedEdges, res.DependencyEdge)
+					batch = append(batch, events.EventInput{
+						Type:   "bead.dependency_added",
+						Source: "townview/server",
+						Rig:    rigID,
+						Payload: map[string]interface{}{
+							"issue_id":   res.IssueID,
+							"blocker_id": res.BlockerID,
+							"type":       res.Type,
+							"rig":        rigID,
+						},
+					})
+				}
+			}
+			batch = append(batch, events.EventInput{
+				Type:   "bead.dependencies_added",
+				Source: "townview/server",
+				Rig:    rigID,
+				Payload: map[string]interface{}{
+					"rig":   rigID,
+					"edges": addedEdges,
+					"count": result.Added,
+				},
+			})
+			if err := h.eventStore.EmitBatch(batch); err != nil {
+				slog.Error("Failed to emit dependency events", "rigId", rigID, "error", err)
+			}
+		}
+	}
+
+	writeJSON(w, result)
+}
+
+// dependencyReaches reports whether "to" is reachable from "from" by following
+// dependency edges of the given type, including edges added earlier in a batch.
+// It fails if any issue's dependencies can't be loaded.
+func (h *Handlers) dependencyReaches(rigID, from, to, depType string, pending map[string][]types.DependencyEdge) (bool, error) {
+	visited := map[string]bool{}
+	stack := []string{from}
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if id == to {
+			return true, nil
+		}
+		if visited[id] {
+			continue
+		}
+		visited[id] = true
+
+		deps, err := h.rigManager.GetRawDependencies(rigID, id)
+		if err != nil {
+			return false, fmt.Errorf("load dependencies of %s: %w", id, err)
+		}
+		for _, dep := range deps {
+			if dep.Type == depType {
+				stack = append(stack, dep.DependsOnID)
+			}
+		}
+		for _, edge := range pending[id] {
+			if edge.Type == depType {
+				stack = append(stack, edge.BlockerID)
+			}
+		}
+	}
+	return false, nil
+}
+
+// RemoveIssueDependency handles DELETE /api/rigs/{rigId}/issues/{issueId}/dependencies/{blockerId}
+func (h *Handlers) RemoveIssueDependency(w http.ResponseWriter, r *http.Request) {
+	rigID := r.PathValue("rigId")
+	issueID := r.PathValue("issueId")
+	blockerID := r.PathValue("blockerId")
+
+	// Use bd dep remove
+	if err := h.runBD(rigID, "dep", "remove", issueID, blockerID); err != nil {
+		slog.Error("Failed to remove dependency", "rigId", rigID, "issueId", issueID, "blockerId", blockerID, "error", err)
+		writeBDError(w, err, "Failed to remove dependency")
 		return
 	}
 
-	writeJSON(w, deps)
+	// Refresh cache
+	h.rigManager.RefreshRig(rigID)
+
+	w.WriteHeader(http.StatusOK)
+	writeJSON(w, map[string]string{"status": "ok"})
 }
 
-// AddIssueDependency handles POST /api/rigs/{rigId}/issues/{issueId}/dependencies
-func (h *Handlers) AddIssueDependency(w http.ResponseWriter, r *http.Request) {
+// AddIssueLabel handles POST /api/rigs/{rigId}/issues/{issueId}/labels/{label}
+func (h *Handlers) AddIssueLabel(w http.ResponseWriter, r *http.Request) {
+	h.changeIssueLabel(w, r, "add", "bead.label_added", http.StatusCreated)
+}
+
+// RemoveIssueLabel handles DELETE /api/rigs/{rigId}/issues/{issueId}/labels/{label}
+func (h *Handlers) RemoveIssueLabel(w http.ResponseWriter, r *http.Request) {
+	h.changeIssueLabel(w, r, "remove", "bead.label_removed", http.StatusOK)
+}
+
+// changeIssueLabel runs bd label add/remove for a single label, then refreshes the cache and emits an event.
+func (h *Handlers) changeIssueLabel(w http.ResponseWriter, r *http.Request, action, eventType string, status int) {
 	rigID := r.PathValue("rigId")
 	issueID := r.PathValue("issueId")
+	label := strings.TrimSpace(r.PathValue("label"))
 
-	var req types.DependencyAdd
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
-		return
-	}
-
-	if req.BlockerID == "" {
-		http.Error(w, "blocker_id is required", http.StatusBadRequest)
+	if label == "" {
+		writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, "label is required")
 		return
 	}
 
-	// Use bd dep add
-	if err := h.runBD(rigID, "dep", "add", issueID, req.BlockerID); err != nil {
-		slog.Error("Failed to add dependency", "rigId", rigID, "issueId", issueID, "blockerId", req.BlockerID, "error", err)
-		http.Error(w, "Failed to add dependency", http.StatusInternalServerError)
+	if err := h.runBD(rigID, "label", action, issueID, label); err != nil {
+		slog.Error("Failed to change label", "rigId", rigID, "issueId", issueID, "label", label, "action", action, "error", err)
+		writeBDError(w, err, "Failed to "+action+" label")
 		return
 	}
 
 	// Refresh cache
 	h.rigManager.RefreshRig(rigID)
 
-	w.WriteHeader(http.StatusCreated)
+	// Emit event
+	if h.eventStore != nil {
+		h.eventStore.Emit(eventType, "townview/server", rigID, map[string]interface{}{
+			"issue_id": issueID,
+			"rig":      rigID,
+			"label":    label,
+		})
+	}
+
+	w.WriteHeader(status)
 	writeJSON(w, map[string]string{"status": "ok"})
 }
 
-// RemoveIssueDependency handles DELETE /api/rigs/{rigId}/issues/{issueId}/dependencies/{blockerId}
-func (h *Handlers) RemoveIssueDependency(w http.ResponseWriter, r *http.Request) {
+// GetRigCacheStats handles GET /api/rigs/{rigId}/cache/stats
+func (h *Handlers) GetRigCacheStats(w http.ResponseWriter, r *http.Request) {
 	rigID := r.PathValue("rigId")
-	issueID := r.PathValue("issueId")
-	blockerID := r.PathValue("blockerId")
 
-	// Use bd dep remove
-	if err := h.runBD(rigID, "dep", "remove", issueID, blockerID); err != nil {
-		slog.Error("Failed to remove dependency", "rigId", rigID, "issueId", issueID, "blockerId", blockerID, "error", err)
-		http.Error(w, "Failed to remove dependency", http.StatusInternalServerError)
+	stats, err := h.rigManager.GetCacheStats(rigID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, ErrCodeRigNotFound, "Rig not found")
 		return
 	}
 
-	// Refresh cache
-	h.rigManager.RefreshRig(rigID)
+	writeJSON(w, stats)
+}
 
-	w.WriteHeader(http.StatusOK)
-	writeJSON(w, map[string]string{"status": "ok"})
+// GetAllCacheStats handles GET /api/cache/stats
+func (h *Handlers) GetAllCacheStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, h.rigManager.GetAllCacheStats())
+}
+
+// GetEventStats handles GET /api/events/stats
+// Reports how many events were delivered to or dropped by slow subscribers.
+func (h *Handlers) GetEventStats(w http.ResponseWriter, r *http.Request) {
+	if h.eventStore == nil {
+		writeJSON(w, events.StoreStats{})
+		return
+	}
+	writeJSON(w, h.eventStore.Stats())
 }
 
 // ListDependencies handles GET /api/rigs/{rigId}/dependencies
+// Returns every dependency edge in the rig; ?type= restricts to one dependency type.
 func (h *Handlers) ListDependencies(w http.ResponseWriter, r *http.Request) {
 	rigID := r.PathValue("rigId")
 
-	// Get all issues and their dependencies
-	issues, err := h.rigManager.ListIssues(rigID, query.IssueFilter{})
+	edges, err := h.rigManager.ListDependencyEdges(rigID)
 	if err != nil {
 		slog.Error("Failed to list dependencies", "rigId", rigID, "error", err)
-		http.Error(w, "Failed to list dependencies", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to list dependencies")
 		return
 	}
 
-	// Build dependency list
-	var deps []types.Dependency
-	for _, issue := range issues {
-		if issue.DependencyCount > 0 {
-			issueDeps, err := h.rigManager.GetDependencies(rigID, issue.ID)
-			if err == nil && issueDeps != nil {
-				for _, blocker := range issueDeps.Blockers {
-					deps = append(deps, types.Dependency{
-						FromID: issue.ID,
-						ToID:   blocker.ID,
-						Type:   "blocks",
-					})
-				}
-			}
+	deps := []types.Dependency{}
+	depType := r.URL.Query().Get("type")
+	for _, edge := range edges {
+		if depType == "" || edge.Type == depType {
+			deps = append(deps, edge)
 		}
 	}
 
-	if deps == nil {
-		deps = []types.Dependency{}
-	}
-
 	writeJSON(w, deps)
 }
 
@@ -326,42 +1566,75 @@ func (h *Handlers) GetMoleculeProgress(w http.ResponseWriter, r *http.Request) {
 	progress, err := h.rigManager.GetConvoyProgress(rigID, issueID)
 	if err != nil {
 		slog.Error("Failed to get molecule progress", "rigId", rigID, "issueId", issueID, "error", err)
-		http.Error(w, "Failed to get molecule progress", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to get molecule progress")
 		return
 	}
 
 	writeJSON(w, progress)
 }
 
+// GetConvoyProgressHistory handles GET /api/rigs/{rigId}/convoys/{id}/history
+// Returns the rig's progress snapshots for the convoy oldest first, for burndown charts.
+func (h *Handlers) GetConvoyProgressHistory(w http.ResponseWriter, r *http.Request) {
+	rigID := r.PathValue("rigId")
+	convoyID := r.PathValue("id")
+
+	history, err := h.collector().GetConvoyProgressHistory(rigID, convoyID)
+	if err != nil {
+		slog.Error("Failed to get convoy progress history", "rigId", rigID, "convoyId", convoyID, "error", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to get convoy progress history")
+		return
+	}
+
+	writeJSON(w, history)
+}
+
 // PeekAgent handles GET /api/rigs/{rigId}/agents/{agentId}/peek
 // This requires tmux access, uses gt peek command
 func (h *Handlers) PeekAgent(w http.ResponseWriter, r *http.Request) {
 	rigID := r.PathValue("rigId")
 	agentID := r.PathValue("agentId")
 
-	// Parse lines query param (default: 50)
+	// Parse lines query param (default: 50, capped at maxPeekLines)
 	lines := 50
 	if linesStr := r.URL.Query().Get("lines"); linesStr != "" {
 		if parsed, err := strconv.Atoi(linesStr); err == nil && parsed > 0 {
 			lines = parsed
 		}
 	}
+	if lines > maxPeekLines {
+		lines = maxPeekLines
+	}
 
-	// Build session name based on agent
-	sessionName := "gt-" + rigID + "-" + agentID
+	// raw=true keeps terminal escape sequences; by default they are stripped
+	raw := r.URL.Query().Get("raw") == "true"
+	captureArgs := []string{"capture-pane", "-p", "-S", strconv.Itoa(-lines)}
+	if raw {
+		captureArgs = append(captureArgs, "-e")
+	}
 
-	// Use tmux capture-pane
+	// Use tmux capture-pane, trying each candidate session name in turn
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "tmux", "capture-pane", "-t", sessionName, "-p", "-S", strconv.Itoa(-lines))
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	var stdout bytes.Buffer
+	captured := false
+	for _, sessionName := range h.peekSessionNames(rigID, agentID) {
+		stdout.Reset()
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, "tmux", append(captureArgs, "-t", sessionName)...)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+
+		if err := h.execLimiter.Run(ctx, cmd); err != nil {
+			slog.Debug("Failed to peek agent", "session", sessionName, "error", err, "stderr", stderr.String())
+			continue
+		}
+		captured = true
+		break
+	}
 
-	err := cmd.Run()
-	if err != nil {
-		slog.Debug("Failed to peek agent", "session", sessionName, "error", err, "stderr", stderr.String())
+	if !captured {
 		// Return empty output instead of error
 		writeJSON(w, types.PeekOutput{
 			AgentID:   agentID,
@@ -371,98 +1644,169 @@ func (h *Handlers) PeekAgent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	output := strings.Split(strings.TrimRight(stdout.String(), "\n"), "\n")
+	text := stdout.String()
+	if !raw {
+		text = ansiEscape.ReplaceAllString(text, "")
+	}
 
 	writeJSON(w, types.PeekOutput{
 		AgentID:   agentID,
-		Lines:     output,
+		Lines:     peekLines(text, lines),
 		Timestamp: time.Now(),
 	})
 }
 
+// maxPeekLines caps how much scrollback a single peek may capture.
+const maxPeekLines = 1000
+
+// ansiEscape matches terminal escape sequences: CSI (colors, cursor movement),
+// OSC (titles, hyperlinks), and two-byte escapes.
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b[@-Z\\-_]`)
+
+// peekLines splits captured pane output into lines, drops trailing blank
+// lines, and keeps at most the last max lines.
+func peekLines(text string, max int) []string {
+	lines := strings.Split(text, "\n")
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) > max {
+		lines = lines[len(lines)-max:]
+	}
+	return lines
+}
+
+// peekSessionNames returns the tmux sessions to try when peeking an agent.
+// A registered agent's own session comes first, followed by the standard candidates for its role.
+func (h *Handlers) peekSessionNames(rigID, agentID string) []string {
+	if h.agentRegistry != nil {
+		for _, agent := range h.agentRegistry.GetAgentsByRig(rigID) {
+			if agent.ID != agentID && agent.Name != agentID && agent.ID != rigID+"/"+agentID {
+				continue
+			}
+			var names []string
+			if agent.SessionID != nil {
+				names = append(names, *agent.SessionID)
+			}
+			return append(names, session.SessionNames(agent.Rig, string(agent.Role), agent.Name)...)
+		}
+	}
+
+	// Unknown agent: guess the role from the name
+	if session.IsSingletonRole(agentID) {
+		return session.SessionNames(rigID, agentID, agentID)
+	}
+	return append(session.SessionNames(rigID, "polecat", agentID), session.SessionNames(rigID, "crew", agentID)...)
+}
+
 // GetRecentActivity handles GET /api/rigs/{rigId}/activity
 func (h *Handlers) GetRecentActivity(w http.ResponseWriter, r *http.Request) {
 	rigID := r.PathValue("rigId")
 
 	// Parse limit query param (default: 50)
-	limit := 50
-	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
-		if parsed, err := strconv.Atoi(limitStr); err == nil && parsed > 0 {
-			limit = parsed
-		}
-	}
+	limit := h.pageLimit(w, r, 50)
 
 	if h.eventStore == nil {
 		writeJSON(w, []types.ActivityEvent{})
 		return
 	}
 
+	// Routine heartbeats and status flaps are hidden unless explicitly requested
+	includeNoise := r.URL.Query().Get("include_noise") == "true"
+
 	// Query events from event store
-	eventList, err := h.eventStore.Query(events.EventFilter{
+	filter := events.EventFilter{
 		Rig:   rigID,
 		Limit: limit,
-	})
+	}
+	if !includeNoise {
+		filter.ExcludeTypes = noisyEventTypes
+		filter.ExcludeAgentTransitions = routineAgentStatuses
+	}
+	eventList, err := h.eventStore.Query(filter)
 	if err != nil {
 		slog.Error("Failed to get recent activity", "rigId", rigID, "error", err)
-		http.Error(w, "Failed to get recent activity", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to get recent activity")
 		return
 	}
 
 	// Convert to ActivityEvent format
 	activity := make([]types.ActivityEvent, 0, len(eventList))
 	for _, e := range eventList {
-		// Extract fields from payload (json.RawMessage)
-		var payload map[string]interface{}
-		if len(e.Payload) > 0 {
-			json.Unmarshal(e.Payload, &payload)
-		}
-
-		issueID, _ := payload["issue_id"].(string)
-		title, _ := payload["title"].(string)
-		oldValue, _ := payload["old_value"].(string)
-		newValue, _ := payload["new_value"].(string)
-
-		activity = append(activity, types.ActivityEvent{
-			ID:        strconv.FormatInt(e.ID, 10),
-			IssueID:   issueID,
-			EventType: e.Type,
-			Title:     title,
-			OldValue:  oldValue,
-			NewValue:  newValue,
-			Actor:     e.Source,
-			Timestamp: e.Timestamp,
-		})
+		activity = append(activity, toActivityEvent(e))
 	}
 
 	writeJSON(w, activity)
 }
 
+// activityFields are the payload fields the activity feed shows for event
+// types without a typed payload.
+type activityFields struct {
+	IssueID  string `json:"issue_id"`
+	Title    string `json:"title"`
+	OldValue string `json:"old_value"`
+	NewValue string `json:"new_value"`
+}
+
+// toActivityEvent converts a stored event for the activity feed, decoding its
+// payload by event type. A payload that doesn't match its type is logged and
+// the event is shown without payload details.
+func toActivityEvent(e events.Event) types.ActivityEvent {
+	a := types.ActivityEvent{
+		ID:        strconv.FormatInt(e.ID, 10),
+		EventType: e.Type,
+		Actor:     e.Source,
+		Timestamp: e.Timestamp,
+	}
+
+	var err error
+	switch e.Type {
+	case events.TypeBeadUpdated:
+		var p events.BeadUpdatedPayload
+		if p, err = events.UnmarshalPayload[events.BeadUpdatedPayload](e); err == nil {
+			a.IssueID, a.Title, a.OldValue, a.NewValue = p.IssueID, p.Title, p.OldValue, p.NewValue
+		}
+	case events.TypeAgentStatusChanged:
+		var p events.AgentStatusChangedPayload
+		if p, err = events.UnmarshalPayload[events.AgentStatusChangedPayload](e); err == nil {
+			a.Title, a.OldValue, a.NewValue = p.AgentID, p.OldValue, p.NewValue
+		}
+	default:
+		if len(e.Payload) == 0 {
+			break
+		}
+		var p activityFields
+		if p, err = events.UnmarshalPayload[activityFields](e); err == nil {
+			a.IssueID, a.Title, a.OldValue, a.NewValue = p.IssueID, p.Title, p.OldValue, p.NewValue
+		}
+	}
+	if err != nil {
+		slog.Warn("Event payload does not match its type", "id", e.ID, "type", e.Type, "error", err)
+	}
+
+	return a
+}
+
+// noisyEventTypes are routine event types excluded from the activity feed by default.
+var noisyEventTypes = []string{"agent.heartbeat"}
+
+// routineAgentStatuses are the statuses whose transitions between each other
+// are excluded from the activity feed by default.
+var routineAgentStatuses = []string{string(registry.StatusIdle), string(registry.StatusWorking)}
+
 // GetAgentMail handles GET /api/rigs/{rigId}/agents/{agentId}/mail
 func (h *Handlers) GetAgentMail(w http.ResponseWriter, r *http.Request) {
 	rigID := r.PathValue("rigId")
 	agentID := r.PathValue("agentId")
 
-	// Build agent address from agentID
-	var agentAddress string
-	if agentID == "witness" || agentID == "refinery" {
-		agentAddress = rigID + "/" + agentID
-	} else if len(agentID) > 5 && agentID[:5] == "crew/" {
-		agentAddress = rigID + "/" + agentID
-	} else {
-		agentAddress = rigID + "/crew/" + agentID
-	}
+	agentAddress := agentMailAddress(rigID, agentID)
 
 	// Parse limit (default 10)
-	limit := 10
-	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
-		if parsed, err := strconv.Atoi(limitStr); err == nil && parsed > 0 {
-			limit = parsed
-		}
-	}
+	limit := h.pageLimit(w, r, 10)
 
 	rig, err := h.rigManager.GetRig(rigID)
 	if err != nil {
-		http.Error(w, "Rig not found", http.StatusNotFound)
+		writeError(w, http.StatusNotFound, ErrCodeRigNotFound, "Rig not found")
 		return
 	}
 
@@ -471,11 +1815,11 @@ func (h *Handlers) GetAgentMail(w http.ResponseWriter, r *http.Request) {
 		Address: agentAddress,
 	}
 
-	messages, err := h.mailClient.ListMail(rig.Path, opts)
+	messages, err := h.mailClient.ListMail(r.Context(), rig.Path, opts)
 	if err != nil {
 		// Try polecats prefix
 		opts.Address = rigID + "/polecats/" + agentID
-		messages, err = h.mailClient.ListMail(rig.Path, opts)
+		messages, err = h.mailClient.ListMail(r.Context(), rig.Path, opts)
 		if err != nil {
 			writeJSON(w, []interface{}{})
 			return
@@ -485,14 +1829,290 @@ func (h *Handlers) GetAgentMail(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, messages)
 }
 
+// agentMailAddress builds an agent's inbox address from its ID. Agents that
+// are not witness, refinery or crew/ get the crew address; callers fall back
+// to the polecats address when that inbox doesn't exist.
+func agentMailAddress(rigID, agentID string) string {
+	if agentID == "witness" || agentID == "refinery" {
+		return rigID + "/" + agentID
+	} else if len(agentID) > 5 && agentID[:5] == "crew/" {
+		return rigID + "/" + agentID
+	}
+	return rigID + "/crew/" + agentID
+}
+
+// MailUnreadCount is the response for an agent's unread mail count.
+type MailUnreadCount struct {
+	AgentID string `json:"agent_id"`
+	Address string `json:"address"` // Inbox that was counted
+	Unread  int    `json:"unread"`
+}
+
+// GetAgentUnreadMailCount handles GET /api/rigs/{rigId}/agents/{agentId}/mail/unread-count
+// Counts unread messages in the agent's inbox, trying the crew address and
+// then the polecats address. If gt fails for both, the last failure is
+// reported with 502 rather than passed off as zero.
+func (h *Handlers) GetAgentUnreadMailCount(w http.ResponseWriter, r *http.Request) {
+	rigID := r.PathValue("rigId")
+	agentID := r.PathValue("agentId")
+
+	rig, err := h.rigManager.GetRig(rigID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, ErrCodeRigNotFound, "Rig not found")
+		return
+	}
+
+	var lastErr error
+	for _, address := range []string{agentMailAddress(rigID, agentID), rigID + "/polecats/" + agentID} {
+		count, err := h.mailClient.CountMail(r.Context(), rig.Path, mail.ListMailOptions{UnreadOnly: true, Address: address})
+		if err != nil {
+			slog.Debug("Failed to count unread mail", "address", address, "error", err)
+			lastErr = err
+			continue
+		}
+		writeJSON(w, MailUnreadCount{AgentID: agentID, Address: address, Unread: count})
+		return
+	}
+
+	slog.Warn("Failed to count unread mail", "rigId", rigID, "agentId", agentID, "error", lastErr)
+	writeError(w, http.StatusBadGateway, ErrCodeInternal, "Failed to count unread mail: "+lastErr.Error())
+}
+
+// Event search scope: without ?since= only recent events are searched, and
+// results are capped at the page size.
+const (
+	defaultEventSearchWindow = 7 * 24 * time.Hour
+	defaultEventSearchLimit  = 100
+)
+
+// SearchEvents handles GET /api/events/search?q=
+// Returns events whose payload, type, source or rig contains q, newest first.
+// ?since= and ?until= (RFC3339) scope the search, defaulting to the last 7 days;
+// ?rig= and ?type= narrow it further.
+func (h *Handlers) SearchEvents(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, "q is required")
+		return
+	}
+
+	filter := events.EventFilter{
+		Rig:  r.URL.Query().Get("rig"),
+		Type: r.URL.Query().Get("type"),
+	}
+	since := time.Now().Add(-defaultEventSearchWindow)
+	if v := r.URL.Query().Get("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, "since must be an RFC3339 timestamp")
+			return
+		}
+		since = t
+	}
+	filter.StartTime = &since
+	if v := r.URL.Query().Get("until"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, "until must be an RFC3339 timestamp")
+			return
+		}
+		filter.EndTime = &t
+	}
+	filter.Limit = h.pageLimit(w, r, defaultEventSearchLimit)
+
+	if h.eventStore == nil {
+		writeJSON(w, []events.Event{})
+		return
+	}
+
+	results, err := h.eventStore.Search(q, filter)
+	if err != nil {
+		slog.Error("Failed to search events", "q", q, "error", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to search events")
+		return
+	}
+
+	writeJSON(w, results)
+}
+
+// IssueSnapshotDiff compares an issue's state at two points in time.
+type IssueSnapshotDiff struct {
+	IssueID string                `json:"issue_id"`
+	From    *events.IssueSnapshot `json:"from"` // null when the issue had no snapshot yet
+	To      *events.IssueSnapshot `json:"to"`
+	Changes []events.FieldChange  `json:"changes"`
+}
+
+// GetIssueSnapshot handles GET /api/rigs/{rigId}/issues/{issueId}/snapshot
+// Returns the issue's state as of ?at= (RFC3339, default now): its latest
+// snapshot taken at or before that time, marked deleted if the issue had
+// disappeared by then.
+func (h *Handlers) GetIssueSnapshot(w http.ResponseWriter, r *http.Request) {
+	rigID := r.PathValue("rigId")
+	issueID := r.PathValue("issueId")
+
+	at := time.Now()
+	if v := r.URL.Query().Get("at"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, "at must be an RFC3339 timestamp")
+			return
+		}
+		at = t
+	}
+
+	var snap *events.IssueSnapshot
+	if h.eventStore != nil {
+		var err error
+		snap, err = h.eventStore.GetIssueSnapshot(rigID, issueID, at)
+		if err != nil {
+			slog.Error("Failed to get issue snapshot", "issueId", issueID, "error", err)
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to get issue snapshot")
+			return
+		}
+	}
+	if snap == nil {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "No snapshot of issue at or before that time")
+		return
+	}
+
+	writeJSON(w, snap)
+}
+
+// DiffIssueSnapshots handles GET /api/rigs/{rigId}/issues/{issueId}/snapshots/diff
+// Compares the issue's state as of ?from= with its state as of ?to= (both
+// RFC3339; to defaults to now), listing each top-level field that changed.
+func (h *Handlers) DiffIssueSnapshots(w http.ResponseWriter, r *http.Request) {
+	rigID := r.PathValue("rigId")
+	issueID := r.PathValue("issueId")
+
+	v := r.URL.Query().Get("from")
+	if v == "" {
+		writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, "from is required")
+		return
+	}
+	from, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, "from must be an RFC3339 timestamp")
+		return
+	}
+	to := time.Now()
+	if v := r.URL.Query().Get("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, "to must be an RFC3339 timestamp")
+			return
+		}
+		to = t
+	}
+
+	diff := IssueSnapshotDiff{IssueID: issueID, Changes: []events.FieldChange{}}
+	if h.eventStore != nil {
+		if diff.From, err = h.eventStore.GetIssueSnapshot(rigID, issueID, from); err == nil {
+			diff.To, err = h.eventStore.GetIssueSnapshot(rigID, issueID, to)
+		}
+		if err != nil {
+			slog.Error("Failed to get issue snapshots", "issueId", issueID, "error", err)
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to get issue snapshots")
+			return
+		}
+	}
+	if diff.From == nil && diff.To == nil {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "No snapshots of issue in that range")
+		return
+	}
+
+	diff.Changes, err = events.DiffIssueSnapshots(diff.From, diff.To)
+	if err != nil {
+		slog.Error("Failed to diff issue snapshots", "issueId", issueID, "error", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to diff issue snapshots")
+		return
+	}
+
+	writeJSON(w, diff)
+}
+
+// ExportEvents handles GET /api/events/export
+// Streams events matching since/until (RFC3339) and optional rig/type as
+// newline-delimited JSON, without buffering the result set.
+func (h *Handlers) ExportEvents(w http.ResponseWriter, r *http.Request) {
+	if format := r.URL.Query().Get("format"); format != "" && format != "ndjson" {
+		writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Unsupported format (use ndjson)")
+		return
+	}
+
+	filter := events.EventFilter{
+		Rig:  r.URL.Query().Get("rig"),
+		Type: r.URL.Query().Get("type"),
+	}
+	if v := r.URL.Query().Get("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, "since must be an RFC3339 timestamp")
+			return
+		}
+		filter.StartTime = &t
+	}
+	if v := r.URL.Query().Get("until"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, "until must be an RFC3339 timestamp")
+			return
+		}
+		filter.EndTime = &t
+	}
+
+	// Headers go out with the first event, so a failure loading the first
+	// page can still be reported as an error response
+	started := false
+	start := func() {
+		if !started {
+			started = true
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.Header().Set("Content-Disposition", `attachment; filename="events.ndjson"`)
+		}
+	}
+
+	if h.eventStore == nil {
+		start()
+		return
+	}
+
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	written := 0
+	err := h.eventStore.Export(filter, func(e events.Event) error {
+		start()
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+		written++
+		if flusher != nil && written%500 == 0 {
+			flusher.Flush()
+		}
+		return r.Context().Err()
+	})
+	if err != nil && !started {
+		slog.Error("Failed to export events", "error", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to export events")
+		return
+	}
+	if err != nil {
+		// Headers are already sent; the truncated stream is all we can signal
+		slog.Error("Failed to export events", "written", written, "error", err)
+		return
+	}
+	start()
+}
+
 // GetMailMessage handles GET /api/mail/{mailId}
 func (h *Handlers) GetMailMessage(w http.ResponseWriter, r *http.Request) {
 	mailID := r.PathValue("mailId")
 
-	message, err := h.mailClient.GetMail("", mailID)
+	message, err := h.mailClient.GetMail(r.Context(), "", mailID)
 	if err != nil {
 		slog.Error("Failed to get mail message", "mailId", mailID, "error", err)
-		http.Error(w, "Failed to get mail message", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to get mail message")
 		return
 	}
 
@@ -500,15 +2120,10 @@ func (h *Handlers) GetMailMessage(w http.ResponseWriter, r *http.Request) {
 }
 
 // ListMail handles GET /api/mail
+// Sets X-Total-Count and X-Has-More headers for pagination.
 func (h *Handlers) ListMail(w http.ResponseWriter, r *http.Request) {
 	opts := mail.ListMailOptions{
-		Limit: 50,
-	}
-
-	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
-		if parsed, err := strconv.Atoi(limitStr); err == nil && parsed > 0 {
-			opts.Limit = parsed
-		}
+		Limit: h.pageLimit(w, r, 50),
 	}
 
 	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
@@ -521,34 +2136,30 @@ func (h *Handlers) ListMail(w http.ResponseWriter, r *http.Request) {
 		opts.UnreadOnly = true
 	}
 
-	messages, err := h.mailClient.ListMail("", opts)
+	messages, total, err := h.mailClient.ListMailPage(r.Context(), "", opts)
 	if err != nil {
 		slog.Error("Failed to list mail", "error", err)
-		http.Error(w, "Failed to list mail", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to list mail")
 		return
 	}
 
+	setPaginationHeaders(w, total, opts.Offset, len(messages))
 	writeJSON(w, messages)
 }
 
 // ListRigMail handles GET /api/rigs/{rigId}/mail
+// Sets X-Total-Count and X-Has-More headers for pagination.
 func (h *Handlers) ListRigMail(w http.ResponseWriter, r *http.Request) {
 	rigID := r.PathValue("rigId")
 
 	rig, err := h.rigManager.GetRig(rigID)
 	if err != nil {
-		http.Error(w, "Rig not found", http.StatusNotFound)
+		writeError(w, http.StatusNotFound, ErrCodeRigNotFound, "Rig not found")
 		return
 	}
 
 	opts := mail.ListMailOptions{
-		Limit: 50,
-	}
-
-	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
-		if parsed, err := strconv.Atoi(limitStr); err == nil && parsed > 0 {
-			opts.Limit = parsed
-		}
+		Limit: h.pageLimit(w, r, 50),
 	}
 
 	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
@@ -561,67 +2172,114 @@ func (h *Handlers) ListRigMail(w http.ResponseWriter, r *http.Request) {
 		opts.UnreadOnly = true
 	}
 
-	messages, err := h.mailClient.ListMail(rig.Path, opts)
+	messages, total, err := h.mailClient.ListMailPage(r.Context(), rig.Path, opts)
 	if err != nil {
 		slog.Error("Failed to list rig mail", "rigId", rigID, "error", err)
-		http.Error(w, "Failed to list mail", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to list mail")
 		return
 	}
 
+	setPaginationHeaders(w, total, opts.Offset, len(messages))
 	writeJSON(w, messages)
 }
 
 // GetTestSuiteStatus handles GET /api/telemetry/tests
 // Returns the current status of all tests with their last_passed info.
+// ?status=failing|passing|flaky|missing narrows the result (default all).
+// ?owner= keeps only tests owned by that team.
 func (h *Handlers) GetTestSuiteStatus(w http.ResponseWriter, r *http.Request) {
-	if h.telemetryCollector == nil {
-		writeJSON(w, []telemetry.TestStatus{})
+	filter := telemetry.StatusFilter(r.URL.Query().Get("status"))
+	if !filter.Valid() {
+		writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, "status must be one of all, failing, passing, flaky, missing")
 		return
 	}
 
-	status, err := h.telemetryCollector.GetTestSuiteStatus()
+	status, err := h.collector().GetTestSuiteStatus(filter)
 	if err != nil {
 		slog.Error("Failed to get test suite status", "error", err)
-		http.Error(w, "Failed to get test suite status", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to get test suite status")
 		return
 	}
 
+	if owner := r.URL.Query().Get("owner"); owner != "" {
+		owned := []telemetry.TestStatus{}
+		for _, s := range status {
+			if s.Owner == owner {
+				owned = append(owned, s)
+			}
+		}
+		status = owned
+	}
+
 	writeJSON(w, status)
 }
 
+// RegressionReport is the response for GET /api/telemetry/regressions.
+type RegressionReport struct {
+	Regressions     []telemetry.TestRegression            `json:"regressions"`
+	ByCommit        map[string][]telemetry.TestRegression `json:"by_commit,omitempty"` // Only with ?group_by=commit
+	SuppressedFlaky int                                   `json:"suppressed_flaky"`    // Flaky tests left out
+}
+
 // GetRegressions handles GET /api/telemetry/regressions
 // Returns tests that have regressed (were passing, now failing).
+// With ?group_by=commit, regressions are grouped by first-failed commit SHA.
+// ?owner= keeps only regressions in tests owned by that team.
+// Flaky tests are left out unless ?suppress_flaky=false; the number left out
+// is reported as suppressed_flaky and in the X-Suppressed-Flaky header.
 func (h *Handlers) GetRegressions(w http.ResponseWriter, r *http.Request) {
-	if h.telemetryCollector == nil {
-		writeJSON(w, []telemetry.TestRegression{})
-		return
+	// Parse query params; 'since' is a timestamp filter
+	opts := telemetry.RegressionOptions{
+		Since:         r.URL.Query().Get("since"),
+		SuppressFlaky: true,
 	}
+	if v := r.URL.Query().Get("suppress_flaky"); v != "" {
+		suppress, err := strconv.ParseBool(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, "suppress_flaky must be true or false")
+			return
+		}
+		opts.SuppressFlaky = suppress
+	}
+	owner := r.URL.Query().Get("owner")
 
-	// Parse 'since' query param (timestamp filter)
-	since := r.URL.Query().Get("since")
-
-	regressions, err := h.telemetryCollector.GetRegressions(since)
+	regressions, suppressed, err := h.collector().GetRegressionsWithOptions(opts)
 	if err != nil {
 		slog.Error("Failed to get regressions", "error", err)
-		http.Error(w, "Failed to get regressions", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to get regressions")
 		return
 	}
+	w.Header().Set("X-Suppressed-Flaky", strconv.Itoa(suppressed))
+
+	if owner != "" {
+		regressions = filterRegressionsByOwner(regressions, owner)
+	}
+
+	report := RegressionReport{
+		Regressions:     regressions,
+		SuppressedFlaky: suppressed,
+	}
+	if r.URL.Query().Get("group_by") == "commit" {
+		report.ByCommit = telemetry.GroupRegressionsByCommit(regressions)
+	}
 
-	writeJSON(w, regressions)
+	writeJSON(w, report)
 }
 
+// filterRegressionsByOwner keeps regressions in tests owned by owner.
+func filterRegressionsByOwner(regressions []telemetry.TestRegression, owner string) []telemetry.TestRegression {
+	owned := []telemetry.TestRegression{}
+	for _, r := range regressions {
+		if r.Owner == owner {
+			owned = append(owned, r)
+		}
+	}
+	return owned
+}
 
 // GetTokenSummary handles GET /api/telemetry/tokens/summary
 // Returns aggregated token usage statistics with optional filtering.
 func (h *Handlers) GetTokenSummary(w http.ResponseWriter, r *http.Request) {
-	if h.telemetryCollector == nil {
-		writeJSON(w, telemetry.TokenSummary{
-			ByModel: make(map[string]telemetry.TokenModelSummary),
-			ByAgent: make(map[string]telemetry.TokenModelSummary),
-		})
-		return
-	}
-
 	// Build filter from query params
 	filter := telemetry.TelemetryFilter{
 		AgentID: r.URL.Query().Get("agent_id"),
@@ -630,24 +2288,106 @@ func (h *Handlers) GetTokenSummary(w http.ResponseWriter, r *http.Request) {
 		Until:   r.URL.Query().Get("until"),
 	}
 
-	summary, err := h.telemetryCollector.GetTokenSummary(filter)
+	summary, err := h.collector().GetTokenSummary(filter)
 	if err != nil {
 		slog.Error("Failed to get token summary", "error", err)
-		http.Error(w, "Failed to get token summary", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to get token summary")
 		return
 	}
 
 	writeJSON(w, summary)
 }
 
-// GetGitChanges handles GET /api/telemetry/git
-// Returns git changes with optional filtering by agent_id, bead_id, since, until, limit.
-func (h *Handlers) GetGitChanges(w http.ResponseWriter, r *http.Request) {
-	if h.telemetryCollector == nil {
-		writeJSON(w, []telemetry.GitChange{})
+// GetTokenAnomalies handles GET /api/telemetry/tokens/anomalies
+// Returns agents burning tokens well above their usual rate since ?since=
+// (RFC3339, default the last 24 hours), most anomalous first.
+func (h *Handlers) GetTokenAnomalies(w http.ResponseWriter, r *http.Request) {
+	since := r.URL.Query().Get("since")
+	if since != "" {
+		if _, err := time.Parse(time.RFC3339, since); err != nil {
+			writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, "since must be an RFC3339 timestamp")
+			return
+		}
+	}
+
+	anomalies, err := h.collector().GetTokenAnomalies(since)
+	if err != nil {
+		slog.Error("Failed to get token anomalies", "error", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to get token anomalies")
+		return
+	}
+
+	writeJSON(w, anomalies)
+}
+
+// TelemetryOverview combines token, git and test summaries over one filter.
+type TelemetryOverview struct {
+	Tokens telemetry.TokenSummary `json:"tokens"`
+	Git    telemetry.GitSummary   `json:"git"`
+	Tests  telemetry.TestSummary  `json:"tests"`
+}
+
+// GetTelemetrySummary handles GET /api/telemetry/summary
+// Returns token, git and test summaries in one response, with optional since/until/rig.
+func (h *Handlers) GetTelemetrySummary(w http.ResponseWriter, r *http.Request) {
+	overview := TelemetryOverview{
+		Tokens: telemetry.TokenSummary{
+			ByModel: make(map[string]telemetry.TokenModelSummary),
+			ByAgent: make(map[string]telemetry.TokenModelSummary),
+		},
+		Git:   telemetry.GitSummary{ByAgent: make(map[string]int)},
+		Tests: telemetry.TestSummary{ByAgent: make(map[string]int)},
+	}
+	filter := telemetry.TelemetryFilter{
+		Rig:   r.URL.Query().Get("rig"),
+		Since: r.URL.Query().Get("since"),
+		Until: r.URL.Query().Get("until"),
+	}
+
+	var err error
+	if overview.Tokens, err = h.collector().GetTokenSummary(filter); err != nil {
+		slog.Error("Failed to get token summary", "error", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to get telemetry summary")
+		return
+	}
+	if overview.Git, err = h.collector().GetGitSummary(filter); err != nil {
+		slog.Error("Failed to get git summary", "error", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to get telemetry summary")
+		return
+	}
+	if overview.Tests, err = h.collector().GetTestSummary(filter); err != nil {
+		slog.Error("Failed to get test summary", "error", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to get telemetry summary")
+		return
+	}
+
+	writeJSON(w, overview)
+}
+
+// GetRigTokenSummary handles GET /api/rigs/{rigId}/telemetry/tokens/summary
+// Returns token usage statistics scoped to a single rig, with optional since/until.
+func (h *Handlers) GetRigTokenSummary(w http.ResponseWriter, r *http.Request) {
+	rigID := r.PathValue("rigId")
+
+	filter := telemetry.TelemetryFilter{
+		Rig:   rigID,
+		Since: r.URL.Query().Get("since"),
+		Until: r.URL.Query().Get("until"),
+	}
+
+	summary, err := h.collector().GetTokenSummary(filter)
+	if err != nil {
+		slog.Error("Failed to get rig token summary", "rigId", rigID, "error", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to get token summary")
 		return
 	}
 
+	writeJSON(w, summary)
+}
+
+// GetGitChanges handles GET /api/telemetry/git
+// Returns git changes with optional filtering by agent_id, bead_id, since, until, limit.
+func (h *Handlers) GetGitChanges(w http.ResponseWriter, r *http.Request) {
 	// Build filter from query params
 	filter := telemetry.TelemetryFilter{
 		AgentID: r.URL.Query().Get("agent_id"),
@@ -657,16 +2397,12 @@ func (h *Handlers) GetGitChanges(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Parse limit
-	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
-		if parsed, err := strconv.Atoi(limitStr); err == nil && parsed > 0 {
-			filter.Limit = parsed
-		}
-	}
+	filter.Limit = h.pageLimit(w, r, 0)
 
-	changes, err := h.telemetryCollector.GetGitChanges(filter)
+	changes, err := h.collector().GetGitChanges(filter)
 	if err != nil {
 		slog.Error("Failed to get git changes", "error", err)
-		http.Error(w, "Failed to get git changes", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to get git changes")
 		return
 	}
 
@@ -678,28 +2414,74 @@ func (h *Handlers) GetGitChanges(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, changes)
 }
 
+// GetTestRuns handles GET /api/telemetry/runs
+// Returns recent test runs, newest first, filtered by agent_id, bead_id, rig,
+// since, until and limit (default 50). ?include_results=false leaves out each
+// run's per-test results for a lightweight list.
+func (h *Handlers) GetTestRuns(w http.ResponseWriter, r *http.Request) {
+	filter := telemetry.TelemetryFilter{
+		AgentID: r.URL.Query().Get("agent_id"),
+		BeadID:  r.URL.Query().Get("bead_id"),
+		Rig:     r.URL.Query().Get("rig"),
+		Since:   r.URL.Query().Get("since"),
+		Until:   r.URL.Query().Get("until"),
+	}
+	if v := r.URL.Query().Get("include_results"); v != "" {
+		include, err := strconv.ParseBool(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, "include_results must be true or false")
+			return
+		}
+		filter.ExcludeResults = !include
+	}
+	filter.Limit = h.pageLimit(w, r, 50)
+
+	runs, err := h.collector().GetTestRuns(filter)
+	if err != nil {
+		slog.Error("Failed to get test runs", "error", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to get test runs")
+		return
+	}
+
+	if runs == nil {
+		runs = []telemetry.TestRun{}
+	}
+
+	writeJSON(w, runs)
+}
+
 // CreateGitChange handles POST /api/telemetry/git
-// Records a git commit from an agent.
+// Records a git commit from an agent. The diff summary is stored as per-file
+// "path: +N -M" churn unless ?diff=full asks for the unified diff verbatim.
 func (h *Handlers) CreateGitChange(w http.ResponseWriter, r *http.Request) {
-	if h.telemetryCollector == nil {
-		http.Error(w, "Telemetry not configured", http.StatusServiceUnavailable)
+	if !h.authorizeTelemetry(w, r) {
+		return
+	}
+
+	diffFormat := telemetry.DiffFormat(r.URL.Query().Get("diff"))
+	if !diffFormat.Valid() {
+		writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, "diff must be compact or full")
 		return
 	}
 
 	var change telemetry.GitChange
 	if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid request body")
 		return
 	}
 
+	if diffFormat != telemetry.DiffFormatFull {
+		change.DiffSummary = telemetry.CompactDiff(change.DiffSummary)
+	}
+
 	// Set timestamp if not provided
 	if change.Timestamp == "" {
 		change.Timestamp = telemetry.Now()
 	}
 
-	if err := h.telemetryCollector.RecordGitChange(change); err != nil {
+	if err := h.collector().RecordGitChange(change); err != nil {
 		slog.Error("Failed to record git change", "error", err)
-		http.Error(w, "Failed to record git change", http.StatusInternalServerError)
+		writeTelemetryError(w, err, "Failed to record git change")
 		return
 	}
 
@@ -710,13 +2492,6 @@ func (h *Handlers) CreateGitChange(w http.ResponseWriter, r *http.Request) {
 // GetGitSummary handles GET /api/telemetry/git/summary
 // Returns aggregated git statistics with optional filtering.
 func (h *Handlers) GetGitSummary(w http.ResponseWriter, r *http.Request) {
-	if h.telemetryCollector == nil {
-		writeJSON(w, telemetry.GitSummary{
-			ByAgent: make(map[string]int),
-		})
-		return
-	}
-
 	// Build filter from query params
 	filter := telemetry.TelemetryFilter{
 		AgentID: r.URL.Query().Get("agent_id"),
@@ -725,10 +2500,10 @@ func (h *Handlers) GetGitSummary(w http.ResponseWriter, r *http.Request) {
 		Until:   r.URL.Query().Get("until"),
 	}
 
-	summary, err := h.telemetryCollector.GetGitSummary(filter)
+	summary, err := h.collector().GetGitSummary(filter)
 	if err != nil {
 		slog.Error("Failed to get git summary", "error", err)
-		http.Error(w, "Failed to get git summary", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to get git summary")
 		return
 	}
 
@@ -740,110 +2515,305 @@ func (h *Handlers) GetGitSummary(w http.ResponseWriter, r *http.Request) {
 func (h *Handlers) GetAgentTelemetry(w http.ResponseWriter, r *http.Request) {
 	agentID := r.PathValue("agentId")
 
-	if h.telemetryCollector == nil {
-		http.Error(w, "Telemetry collector not configured", http.StatusServiceUnavailable)
-		return
-	}
-
-	telemetry, err := h.telemetryCollector.GetAgentTelemetry(agentID)
+	telemetry, err := h.collector().GetAgentTelemetry(agentID)
 	if err != nil {
 		slog.Error("Failed to get agent telemetry", "agentId", agentID, "error", err)
-		http.Error(w, "Failed to get agent telemetry", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to get agent telemetry")
 		return
 	}
 
 	if len(telemetry.TokenUsage) == 0 && len(telemetry.GitChanges) == 0 && len(telemetry.TestRuns) == 0 {
-		http.Error(w, "No telemetry found for agent", http.StatusNotFound)
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "No telemetry found for agent")
 		return
 	}
 
 	writeJSON(w, telemetry)
 }
 
+// GetAgentTestHealth handles GET /api/telemetry/agents/{agentId}/test-health
+// Returns the agent's pass rate, most-failed tests and attributed regressions.
+func (h *Handlers) GetAgentTestHealth(w http.ResponseWriter, r *http.Request) {
+	agentID := r.PathValue("agentId")
+
+	health, err := h.collector().GetAgentTestHealth(agentID)
+	if err != nil {
+		slog.Error("Failed to get agent test health", "agentId", agentID, "error", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to get agent test health")
+		return
+	}
+
+	writeJSON(w, health)
+}
+
 // GetBeadTelemetry handles GET /api/telemetry/beads/{beadId}
 // Returns full telemetry for the specified bead including tokens, git, and tests.
 func (h *Handlers) GetBeadTelemetry(w http.ResponseWriter, r *http.Request) {
 	beadID := r.PathValue("beadId")
 
-	if h.telemetryCollector == nil {
-		http.Error(w, "Telemetry collector not configured", http.StatusServiceUnavailable)
-		return
-	}
-
-	telemetry, err := h.telemetryCollector.GetBeadTelemetry(beadID)
+	telemetry, err := h.collector().GetBeadTelemetry(beadID)
 	if err != nil {
 		slog.Error("Failed to get bead telemetry", "beadId", beadID, "error", err)
-		http.Error(w, "Failed to get bead telemetry", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to get bead telemetry")
 		return
 	}
 
 	if len(telemetry.TokenUsage) == 0 && len(telemetry.GitChanges) == 0 && len(telemetry.TestRuns) == 0 {
-		http.Error(w, "No telemetry found for bead", http.StatusNotFound)
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "No telemetry found for bead")
 		return
 	}
 
 	writeJSON(w, telemetry)
 }
 
-// GetTestHistory handles GET /api/telemetry/tests/{testName}/history
-// Returns historical test runs for a specific test with optional limit.
-func (h *Handlers) GetTestHistory(w http.ResponseWriter, r *http.Request) {
-	if h.telemetryCollector == nil {
-		writeJSON(w, []telemetry.TestHistoryEntry{})
+// SetBeadBudget handles PUT /api/telemetry/beads/{beadId}/budget
+// Stores a token cost budget and alert threshold for the bead.
+func (h *Handlers) SetBeadBudget(w http.ResponseWriter, r *http.Request) {
+	beadID := r.PathValue("beadId")
+
+	var budget telemetry.BeadBudget
+	if err := json.NewDecoder(r.Body).Decode(&budget); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid request body")
+		return
+	}
+	if budget.BudgetUSD <= 0 {
+		writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, "budget_usd must be positive")
+		return
+	}
+	budget.BeadID = beadID
+
+	if err := h.collector().SetBeadBudget(budget); err != nil {
+		slog.Error("Failed to set bead budget", "beadId", beadID, "error", err)
+		writeTelemetryError(w, err, "Failed to set bead budget")
+		return
+	}
+
+	h.GetBeadBudget(w, r)
+}
+
+// GetCommitGate handles GET /api/telemetry/commits/{sha}/gate
+// Returns a single pass/fail merge verdict combining tests, regressions and cost.
+func (h *Handlers) GetCommitGate(w http.ResponseWriter, r *http.Request) {
+	sha := r.PathValue("sha")
+
+	gate, err := h.collector().GetCommitGate(sha)
+	if err != nil {
+		slog.Error("Failed to get commit gate", "commitSha", sha, "error", err)
+		writeTelemetryError(w, err, "Failed to get commit gate")
+		return
+	}
+
+	writeJSON(w, gate)
+}
+
+// GetCommitActivity handles GET /api/telemetry/commits/{sha}
+// Returns the commit's git changes alongside its test runs and results.
+func (h *Handlers) GetCommitActivity(w http.ResponseWriter, r *http.Request) {
+	sha := r.PathValue("sha")
+
+	activity, err := h.collector().GetCommitActivity(sha)
+	if err != nil {
+		slog.Error("Failed to get commit activity", "commitSha", sha, "error", err)
+		writeTelemetryError(w, err, "Failed to get commit activity")
+		return
+	}
+
+	if len(activity.GitChanges) == 0 && len(activity.TestRuns) == 0 {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "No telemetry found for commit")
+		return
+	}
+
+	writeJSON(w, activity)
+}
+
+// GetCoverageGaps handles GET /api/telemetry/coverage-gaps
+// Returns bead commits with no test run recorded at the same commit, filtered
+// by ?agent_id=, ?bead_id=, ?rig= and ?since=/?until= (RFC3339).
+func (h *Handlers) GetCoverageGaps(w http.ResponseWriter, r *http.Request) {
+	filter := telemetry.TelemetryFilter{
+		AgentID: r.URL.Query().Get("agent_id"),
+		BeadID:  r.URL.Query().Get("bead_id"),
+		Rig:     r.URL.Query().Get("rig"),
+		Since:   r.URL.Query().Get("since"),
+		Until:   r.URL.Query().Get("until"),
+	}
+	for name, v := range map[string]string{"since": filter.Since, "until": filter.Until} {
+		if v == "" {
+			continue
+		}
+		if _, err := time.Parse(time.RFC3339, v); err != nil {
+			writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, name+" must be an RFC3339 timestamp")
+			return
+		}
+	}
+	filter.Limit = h.pageLimit(w, r, 100)
+
+	gaps, err := h.collector().GetCoverageGaps(filter)
+	if err != nil {
+		slog.Error("Failed to get coverage gaps", "error", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to get coverage gaps")
+		return
+	}
+
+	writeJSON(w, gaps)
+}
+
+// GetBeadBudget handles GET /api/telemetry/beads/{beadId}/budget
+// Returns spend against the bead's budget.
+func (h *Handlers) GetBeadBudget(w http.ResponseWriter, r *http.Request) {
+	beadID := r.PathValue("beadId")
+
+	budget, err := h.collector().GetBeadBudget(beadID)
+	if err != nil {
+		slog.Error("Failed to get bead budget", "beadId", beadID, "error", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to get bead budget")
+		return
+	}
+	if budget == nil {
+		writeError(w, http.StatusNotFound, ErrCodeNotFound, "No budget set for bead")
+		return
+	}
+
+	status, err := h.collector().CheckBeadBudget(beadID, budget.BudgetUSD)
+	if err != nil {
+		slog.Error("Failed to check bead budget", "beadId", beadID, "error", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to check bead budget")
+		return
+	}
+
+	writeJSON(w, status)
+}
+
+// checkBeadBudget emits bead.budget_alert the first time recorded spend
+// reaches the bead's alert threshold and bead.budget_exceeded the first time it
+// crosses the budget. Called after token usage is recorded for it.
+func (h *Handlers) checkBeadBudget(beadID string) {
+	budget, err := h.collector().GetBeadBudget(beadID)
+	if err != nil || budget == nil {
+		if err != nil {
+			slog.Warn("Failed to get bead budget", "beadId", beadID, "error", err)
+		}
+		return
+	}
+
+	status, err := h.collector().CheckBeadBudget(beadID, budget.BudgetUSD)
+	if err != nil {
+		slog.Warn("Failed to check bead budget", "beadId", beadID, "error", err)
+		return
+	}
+	if !status.AlertTriggered {
 		return
 	}
 
+	first, err := h.collector().MarkBudgetAlerted(beadID)
+	if err != nil {
+		slog.Warn("Failed to mark bead budget alerted", "beadId", beadID, "error", err)
+	} else if first && h.eventStore != nil {
+		h.eventStore.Emit("bead.budget_alert", "townview/server", "", map[string]interface{}{
+			"bead_id":             beadID,
+			"budget_usd":          status.BudgetUSD,
+			"spent_usd":           status.SpentUSD,
+			"percent_consumed":    status.PercentConsumed,
+			"alert_threshold_pct": budget.AlertThresholdPct,
+		})
+	}
+	if !status.OverBudget {
+		return
+	}
+
+	first, err = h.collector().MarkBudgetExceeded(beadID)
+	if err != nil {
+		slog.Warn("Failed to mark bead budget exceeded", "beadId", beadID, "error", err)
+	} else if first && h.eventStore != nil {
+		h.eventStore.Emit("bead.budget_exceeded", "townview/server", "", map[string]interface{}{
+			"bead_id":    beadID,
+			"budget_usd": status.BudgetUSD,
+			"spent_usd":  status.SpentUSD,
+		})
+	}
+}
+
+// GetTestHistory handles GET /api/telemetry/tests/{testName}/history
+// Returns historical test runs for a specific test with optional limit.
+func (h *Handlers) GetTestHistory(w http.ResponseWriter, r *http.Request) {
 	// Get test name from path and URL-decode it
 	testName := r.PathValue("testName")
 	decodedTestName, err := url.PathUnescape(testName)
 	if err != nil {
-		http.Error(w, "Invalid test name encoding", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid test name encoding")
 		return
 	}
 
 	// Parse limit query param (default: 100)
-	limit := 100
-	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
-		if parsed, err := strconv.Atoi(limitStr); err == nil && parsed > 0 {
-			limit = parsed
-		}
-	}
+	limit := h.pageLimit(w, r, 100)
 
-	history, err := h.telemetryCollector.GetTestHistory(decodedTestName, limit)
+	history, err := h.collector().GetTestHistory(decodedTestName, limit)
 	if err != nil {
 		slog.Error("Failed to get test history", "testName", decodedTestName, "error", err)
-		http.Error(w, "Failed to get test history", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to get test history")
 		return
 	}
 
 	writeJSON(w, history)
 }
 
+// SetMaxRunOutput caps the error and stack output stored per test run (0 for no cap).
+func (h *Handlers) SetMaxRunOutput(bytes int) {
+	h.maxRunOutput = bytes
+}
+
+// usageModel returns the model to record reported tokens under: the reported
+// model, else the server default, else "" for tokens not to record.
+func (h *Handlers) usageModel(reported string) string {
+	if reported != "" {
+		return reported
+	}
+	return h.defaultModel
+}
+
+// SetDefaultModel sets the model recorded for token usage and test runs that
+// do not name one. With none set, such token usage is left out of telemetry
+// rather than recorded without a model. Reported models are recorded as given;
+// ones outside the pricing table are costed at the default price.
+func (h *Handlers) SetDefaultModel(model string) {
+	h.defaultModel = model
+}
+
+// maxTestRunBodyBytes caps a test run request body before it is decoded.
+// Output within it but past the per-run cap is truncated after decoding.
+const maxTestRunBodyBytes = 16 << 20
+
 // CreateTestRun handles POST /api/telemetry/tests
 // Accepts TestRun JSON payload and records it via the telemetry collector.
+// Bodies over maxTestRunBodyBytes are rejected with 413. Error and stack output
+// beyond the per-run cap is truncated and reported in a warning.
 func (h *Handlers) CreateTestRun(w http.ResponseWriter, r *http.Request) {
-	if h.telemetryCollector == nil {
-		http.Error(w, "Telemetry collector not configured", http.StatusServiceUnavailable)
+	if !h.authorizeTelemetry(w, r) {
 		return
 	}
 
+	r.Body = http.MaxBytesReader(w, r.Body, maxTestRunBodyBytes)
 	var run telemetry.TestRun
 	if err := json.NewDecoder(r.Body).Decode(&run); err != nil {
-		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, ErrCodePayloadTooLarge, fmt.Sprintf("Request body exceeds %d bytes", tooLarge.Limit))
+			return
+		}
+		writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid request body: "+err.Error())
 		return
 	}
 
 	// Validate required fields
 	if run.AgentID == "" {
-		http.Error(w, "agent_id is required", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, "agent_id is required")
 		return
 	}
 	if run.Command == "" {
-		http.Error(w, "command is required", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, "command is required")
 		return
 	}
 	if len(run.Results) == 0 {
-		http.Error(w, "results is required and must not be empty", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, "results is required and must not be empty")
 		return
 	}
 
@@ -851,40 +2821,108 @@ func (h *Handlers) CreateTestRun(w http.ResponseWriter, r *http.Request) {
 	if run.Timestamp == "" {
 		run.Timestamp = time.Now().UTC().Format(time.RFC3339)
 	}
+	run.Model = h.usageModel(run.Model)
+
+	truncated := telemetry.CapRunOutput(&run, h.maxRunOutput)
 
 	// Record the test run
-	if err := h.telemetryCollector.RecordTestRun(run); err != nil {
+	if err := h.collector().RecordTestRun(run); err != nil {
 		slog.Error("Failed to record test run", "error", err)
-		http.Error(w, "Failed to record test run", http.StatusInternalServerError)
+		writeTelemetryError(w, err, "Failed to record test run")
 		return
 	}
 
+	response := map[string]string{"status": "created"}
+	if truncated > 0 {
+		slog.Warn("Truncated test run output", "agentId", run.AgentID, "fields", truncated, "limit", h.maxRunOutput)
+		response["warning"] = fmt.Sprintf("failure output exceeded %d bytes; %d field(s) truncated", h.maxRunOutput, truncated)
+	}
+
 	w.WriteHeader(http.StatusCreated)
-	writeJSON(w, map[string]string{"status": "created"})
+	writeJSON(w, response)
+}
+
+// SetExecLimiter bounds bd and tmux invocations by a limiter shared with the
+// rest of the server. Call before serving requests.
+func (h *Handlers) SetExecLimiter(limiter *execlimit.Limiter) {
+	h.execLimiter = limiter
 }
 
 // runBD executes a bd CLI command for write operations
 func (h *Handlers) runBD(rigID string, args ...string) error {
+	_, err := h.runBDOutput(rigID, args...)
+	return err
+}
+
+// runBDOutput executes a bd CLI command in the rig's directory and returns its stdout.
+func (h *Handlers) runBDOutput(rigID string, args ...string) ([]byte, error) {
+	if !h.bdAvailable() {
+		return nil, errBDUnavailable
+	}
+
 	rig, err := h.rigManager.GetRig(rigID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "bd", args...)
+	cmd := exec.CommandContext(ctx, h.bdPath, args...)
 	cmd.Dir = rig.AbsPath
 
-	var stderr bytes.Buffer
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
-	if err := cmd.Run(); err != nil {
+	if err := h.execLimiter.Run(ctx, cmd); err != nil {
 		slog.Error("bd command failed", "args", args, "stderr", stderr.String(), "error", err)
-		return err
+		return nil, err
 	}
 
-	return nil
+	return stdout.Bytes(), nil
+}
+
+// pageLimit parses the "limit" query param, falling back to defaultLimit
+// (0 meaning unlimited), and clamps the result to the server's max page size.
+// The effective limit is reported in the X-Page-Limit header.
+func (h *Handlers) pageLimit(w http.ResponseWriter, r *http.Request, defaultLimit int) int {
+	limit := h.clampLimit(r.URL.Query(), defaultLimit)
+	if limit > 0 {
+		w.Header().Set("X-Page-Limit", strconv.Itoa(limit))
+	}
+	return limit
+}
+
+// clampLimit parses the "limit" param like pageLimit, without reporting it.
+func (h *Handlers) clampLimit(params url.Values, defaultLimit int) int {
+	limit := defaultLimit
+	if limitStr := params.Get("limit"); limitStr != "" {
+		if parsed, err := strconv.Atoi(limitStr); err == nil && parsed > 0 {
+			limit = parsed
+		}
+	}
+
+	if h.maxPageSize > 0 && (limit <= 0 || limit > h.maxPageSize) {
+		limit = h.maxPageSize
+	}
+	return limit
+}
+
+// markStale sets X-Cache: stale and returns true when err signals that the
+// query service served stale data in place of a failed query.
+func markStale(w http.ResponseWriter, err error) bool {
+	if !errors.Is(err, query.ErrStale) {
+		return false
+	}
+	w.Header().Set("X-Cache", "stale")
+	return true
+}
+
+// setPaginationHeaders sets X-Total-Count and X-Has-More for a page of results.
+func setPaginationHeaders(w http.ResponseWriter, total, offset, count int) {
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	w.Header().Set("X-Has-More", strconv.FormatBool(offset+count < total))
 }
 
 // writeJSON writes a JSON response.
@@ -0,0 +1,1608 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gastown/townview/internal/events"
+	"github.com/gastown/townview/internal/execlimit"
+	"github.com/gastown/townview/internal/mail"
+	"github.com/gastown/townview/internal/query"
+	"github.com/gastown/townview/internal/registry"
+	"github.com/gastown/townview/internal/rigmanager"
+	"github.com/gastown/townview/internal/telemetry"
+	"github.com/gastown/townview/internal/types"
+)
+
+func TestDependencyReaches_FailsWhenDependenciesUnreadable(t *testing.T) {
+	townRoot := t.TempDir()
+	m, err := rigmanager.New(rigmanager.Config{TownRoot: townRoot}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create rig manager: %v", err)
+	}
+	defer m.Close()
+	h := New(m, nil, nil, nil, nil, townRoot)
+
+	// The rig is unknown, so no dependencies can be loaded
+	cycle, err := h.dependencyReaches("missing-rig", "issue-b", "issue-a", "blocks", nil)
+	if err == nil {
+		t.Fatalf("expected an error, got cycle=%v", cycle)
+	}
+	if cycle {
+		t.Error("expected no cycle to be reported alongside the error")
+	}
+}
+
+func TestCreateTestRun_RejectsOversizedBody(t *testing.T) {
+	h := New(nil, nil, nil, nil, nil, t.TempDir())
+
+	body := `{"agent_id":"` + strings.Repeat("a", maxTestRunBodyBytes) + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/telemetry/tests", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.CreateTestRun(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), ErrCodePayloadTooLarge) {
+		t.Errorf("expected %s in body, got %s", ErrCodePayloadTooLarge, rec.Body.String())
+	}
+}
+
+func TestUsageModel_FallbackOrder(t *testing.T) {
+	tests := []struct {
+		name         string
+		defaultModel string
+		reported     string
+		want         string
+	}{
+		{"reported wins", "claude-sonnet-4", "claude-opus-4", "claude-opus-4"},
+		{"default fills in", "claude-sonnet-4", "", "claude-sonnet-4"},
+		{"neither skips", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New(nil, nil, nil, nil, nil, t.TempDir())
+			h.SetDefaultModel(tt.defaultModel)
+			if got := h.usageModel(tt.reported); got != tt.want {
+				t.Errorf("usageModel(%q) = %q, want %q", tt.reported, got, tt.want)
+			}
+		})
+	}
+}
+
+// newTelemetryTestHandlers returns Handlers with a registry holding agent
+// "rig-a/polecats/a1" and a SQLite telemetry collector.
+func newTelemetryTestHandlers(t *testing.T) (*Handlers, *telemetry.SQLiteCollector) {
+	t.Helper()
+	collector, err := telemetry.NewSQLiteCollector(filepath.Join(t.TempDir(), "telemetry.db"))
+	if err != nil {
+		t.Fatalf("failed to create collector: %v", err)
+	}
+	t.Cleanup(func() { collector.Close() })
+	reg := registry.NewWithDefaults()
+	t.Cleanup(reg.Stop)
+	reg.Register(registry.AgentRegistration{ID: "rig-a/polecats/a1", Rig: "rig-a", Role: registry.RolePolecat})
+	return New(nil, nil, reg, nil, collector, t.TempDir()), collector
+}
+
+func TestAgentHeartbeat_RecordsTokensUnderReportedOrDefaultModel(t *testing.T) {
+	tests := []struct {
+		name         string
+		defaultModel string
+		model        string
+		want         string // "" for no usage recorded
+	}{
+		{"unpriced model accepted as reported", "claude-sonnet-4", "gpt-4o", "gpt-4o"},
+		{"default fills in", "claude-sonnet-4", "", "claude-sonnet-4"},
+		{"no model skips telemetry", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, collector := newTelemetryTestHandlers(t)
+			h.SetDefaultModel(tt.defaultModel)
+
+			body := `{"agent_id":"rig-a/polecats/a1","tokens_since_last":10,"model":"` + tt.model + `"}`
+			rec := httptest.NewRecorder()
+			h.AgentHeartbeat(rec, httptest.NewRequest(http.MethodPost, "/api/agents/heartbeat", strings.NewReader(body)))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+			}
+
+			usage, err := collector.GetTokenUsage(telemetry.TelemetryFilter{})
+			if err != nil {
+				t.Fatalf("GetTokenUsage failed: %v", err)
+			}
+			if tt.want == "" {
+				if len(usage) != 0 {
+					t.Errorf("expected no usage recorded, got %+v", usage)
+				}
+				return
+			}
+			if len(usage) != 1 || usage[0].Model != tt.want || usage[0].InputTokens != 10 {
+				t.Errorf("expected 10 tokens under %s, got %+v", tt.want, usage)
+			}
+		})
+	}
+}
+
+func TestAgentHeartbeat_EmitsBudgetAlertsOnce(t *testing.T) {
+	h, collector := newTelemetryTestHandlers(t)
+	h.SetDefaultModel("claude-sonnet-4") // $3 per million input tokens
+	store, err := events.NewStore(events.DefaultConfig())
+	if err != nil {
+		t.Fatalf("failed to create event store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	h.eventStore = store
+	if err := collector.SetBeadBudget(telemetry.BeadBudget{BeadID: "a-1", BudgetUSD: 10, AlertThresholdPct: 50}); err != nil {
+		t.Fatalf("SetBeadBudget failed: %v", err)
+	}
+
+	// Each beat spends $3: 30%, 60%, 90%, then 120% of the budget
+	want := [][]string{{}, {"bead.budget_alert"}, {"bead.budget_alert"}, {"bead.budget_alert", "bead.budget_exceeded"}}
+	for i, wantTypes := range want {
+		body := `{"agent_id":"rig-a/polecats/a1","status":"working","current_bead":"a-1","tokens_since_last":1000000}`
+		rec := httptest.NewRecorder()
+		h.AgentHeartbeat(rec, httptest.NewRequest(http.MethodPost, "/api/agents/heartbeat", strings.NewReader(body)))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("beat %d: expected 200, got %d: %s", i, rec.Code, rec.Body.String())
+		}
+
+		emitted, err := store.Query(events.EventFilter{})
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		sort.Slice(emitted, func(a, b int) bool { return emitted[a].ID < emitted[b].ID })
+		got := []string{}
+		for _, e := range emitted {
+			got = append(got, e.Type)
+		}
+		if !reflect.DeepEqual(got, wantTypes) {
+			t.Fatalf("beat %d: expected events %v, got %v", i, wantTypes, got)
+		}
+	}
+
+	alerts, err := store.Query(events.EventFilter{Type: "bead.budget_alert"})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(alerts[0].Payload, &payload); err != nil {
+		t.Fatalf("failed to decode payload: %v", err)
+	}
+	if payload["bead_id"] != "a-1" || payload["percent_consumed"] != 60.0 || payload["alert_threshold_pct"] != 50.0 {
+		t.Errorf("unexpected bead.budget_alert payload %v", payload)
+	}
+}
+
+func TestCreateTestRun_DefaultsModel(t *testing.T) {
+	h, collector := newTelemetryTestHandlers(t)
+	h.SetDefaultModel("claude-sonnet-4")
+
+	for _, model := range []string{"", "claude-opus-4"} {
+		body := `{"agent_id":"rig-a/polecats/a1","command":"go test","model":"` + model + `","results":[{"test_name":"TestX","status":"passed"}]}`
+		rec := httptest.NewRecorder()
+		h.CreateTestRun(rec, httptest.NewRequest(http.MethodPost, "/api/telemetry/tests", strings.NewReader(body)))
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+
+	runs, err := collector.GetTestRuns(telemetry.TelemetryFilter{})
+	if err != nil {
+		t.Fatalf("GetTestRuns failed: %v", err)
+	}
+	models := map[string]bool{}
+	for _, run := range runs {
+		models[run.Model] = true
+	}
+	if len(runs) != 2 || !models["claude-sonnet-4"] || !models["claude-opus-4"] {
+		t.Errorf("expected one run under the default and one as reported, got %+v", runs)
+	}
+}
+
+// newTestTown creates a town with one rig, "rig-a", whose beads.db is empty.
+func newTestTown(t *testing.T) string {
+	t.Helper()
+	townRoot := t.TempDir()
+	beadsPath := filepath.Join(townRoot, "rig-a", ".beads")
+	if err := os.MkdirAll(beadsPath, 0755); err != nil {
+		t.Fatalf("failed to create beads dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(beadsPath, "beads.db"), nil, 0644); err != nil {
+		t.Fatalf("failed to create beads db: %v", err)
+	}
+	return townRoot
+}
+
+// addTestRig creates a rig whose beads.db has the issues and dependencies
+// tables, then runs stmts against it.
+func addTestRig(t *testing.T, townRoot, name string, stmts ...string) {
+	t.Helper()
+	beadsPath := filepath.Join(townRoot, name, ".beads")
+	if err := os.MkdirAll(beadsPath, 0755); err != nil {
+		t.Fatalf("failed to create beads dir: %v", err)
+	}
+	db, err := sql.Open("sqlite3", filepath.Join(beadsPath, "beads.db"))
+	if err != nil {
+		t.Fatalf("failed to open beads db: %v", err)
+	}
+	defer db.Close()
+
+	schema := []string{`
+		CREATE TABLE issues (
+			id TEXT PRIMARY KEY,
+			title TEXT NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			status TEXT NOT NULL DEFAULT 'open',
+			priority INTEGER NOT NULL DEFAULT 2,
+			issue_type TEXT NOT NULL DEFAULT 'task',
+			owner TEXT,
+			assignee TEXT,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			created_by TEXT DEFAULT '',
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			closed_at DATETIME,
+			close_reason TEXT DEFAULT '',
+			deleted_at DATETIME,
+			source_repo TEXT DEFAULT '.'
+		)`, `
+		CREATE TABLE dependencies (
+			issue_id TEXT NOT NULL,
+			depends_on_id TEXT NOT NULL,
+			type TEXT NOT NULL DEFAULT 'blocks',
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			created_by TEXT NOT NULL DEFAULT '',
+			PRIMARY KEY (issue_id, depends_on_id, type)
+		)`}
+	for _, stmt := range append(schema, stmts...) {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("failed to run %q: %v", stmt, err)
+		}
+	}
+}
+
+// stubBD points BD_PATH at a shell script standing in for bd. Every call's
+// arguments are appended to the returned log file, one call per line.
+func stubBD(t *testing.T, script string) string {
+	t.Helper()
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "calls.log")
+	bdPath := filepath.Join(dir, "bd")
+	body := "#!/bin/sh\necho \"$*\" >> " + logPath + "\n" + script + "\n"
+	if err := os.WriteFile(bdPath, []byte(body), 0755); err != nil {
+		t.Fatalf("failed to write bd stub: %v", err)
+	}
+	t.Setenv("BD_PATH", bdPath)
+	return logPath
+}
+
+// bdCalls returns the bd invocations logged by a stubBD script.
+func bdCalls(t *testing.T, logPath string) []string {
+	t.Helper()
+	data, err := os.ReadFile(logPath)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatalf("failed to read bd log: %v", err)
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+// newTestManager returns a rig manager over townRoot, closed at test cleanup.
+func newTestManager(t *testing.T, townRoot string) *rigmanager.Manager {
+	t.Helper()
+	m, err := rigmanager.New(rigmanager.Config{TownRoot: townRoot}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create rig manager: %v", err)
+	}
+	t.Cleanup(func() { m.Close() })
+	return m
+}
+
+// newMailTestHandlers returns Handlers over a town with one rig, "rig-a",
+// whose mail client runs the given shell script as gt.
+func newMailTestHandlers(t *testing.T, gtScript string) *Handlers {
+	t.Helper()
+	townRoot := newTestTown(t)
+	gtPath := filepath.Join(t.TempDir(), "gt")
+	if err := os.WriteFile(gtPath, []byte("#!/bin/sh\n"+gtScript+"\n"), 0755); err != nil {
+		t.Fatalf("failed to write gt stub: %v", err)
+	}
+	t.Setenv("GT_PATH", gtPath)
+
+	return New(newTestManager(t, townRoot), nil, nil, mail.NewClient(townRoot), nil, townRoot)
+}
+
+func TestGetAgentUnreadMailCount(t *testing.T) {
+	tests := []struct {
+		name      string
+		gtScript  string
+		wantCode  int
+		wantCount int
+	}{
+		{name: "counted", gtScript: `echo '[{"id":"m1"},{"id":"m2"}]'`, wantCode: http.StatusOK, wantCount: 2},
+		{name: "none unread", gtScript: `echo '[]'`, wantCode: http.StatusOK, wantCount: 0},
+		{name: "gt fails", gtScript: `echo "inbox unavailable" >&2; exit 1`, wantCode: http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newMailTestHandlers(t, tt.gtScript)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/rigs/rig-a/agents/joe/mail/unread-count", nil)
+			req.SetPathValue("rigId", "rig-a")
+			req.SetPathValue("agentId", "joe")
+			rec := httptest.NewRecorder()
+			h.GetAgentUnreadMailCount(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("expected %d, got %d: %s", tt.wantCode, rec.Code, rec.Body.String())
+			}
+			if tt.wantCode != http.StatusOK {
+				assertErrorCode(t, rec, ErrCodeInternal)
+				return
+			}
+			var got MailUnreadCount
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if got.Unread != tt.wantCount || got.Address != "rig-a/crew/joe" {
+				t.Errorf("got %+v, want %d unread at rig-a/crew/joe", got, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestGetAgentUnreadMailCount_StopsWaitingWhenRequestEnds(t *testing.T) {
+	h := newMailTestHandlers(t, `echo '[]'`)
+
+	// Hold the only subprocess slot so gt would queue forever
+	limiter := execlimit.New(1)
+	release, err := limiter.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("failed to acquire slot: %v", err)
+	}
+	defer release()
+	h.mailClient.SetExecLimiter(limiter)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(http.MethodGet, "/api/rigs/rig-a/agents/joe/mail/unread-count", nil).WithContext(ctx)
+	req.SetPathValue("rigId", "rig-a")
+	req.SetPathValue("agentId", "joe")
+	rec := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		h.GetAgentUnreadMailCount(rec, req)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("handler kept waiting for a slot after the request ended")
+	}
+	if !strings.Contains(rec.Body.String(), context.Canceled.Error()) {
+		t.Errorf("expected the cancellation to be reported, got %s", rec.Body.String())
+	}
+}
+
+func TestListIssues_UncappedQueryFailureIsJSONError(t *testing.T) {
+	townRoot := newTestTown(t)
+	h := New(newTestManager(t, townRoot), nil, nil, nil, nil, townRoot)
+	h.SetMaxPageSize(0)
+
+	// rig-a's beads.db has no schema, so the query fails before any row
+	req := httptest.NewRequest(http.MethodGet, "/api/rigs/rig-a/issues", nil)
+	req.SetPathValue("rigId", "rig-a")
+	rec := httptest.NewRecorder()
+	h.ListIssues(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d: %s", rec.Code, rec.Body.String())
+	}
+	assertErrorCode(t, rec, ErrCodeInternal)
+}
+
+func TestListIssues_OwnerFilter(t *testing.T) {
+	townRoot := t.TempDir()
+	addTestRig(t, townRoot, "rig-a",
+		`INSERT INTO issues (id, title, owner, assignee) VALUES
+			('a-1', 'Alice owns, Bob works', 'alice', 'bob'),
+			('a-2', 'Bob owns, Alice works', 'bob', 'alice'),
+			('a-3', 'Alice owns and works', 'alice', 'alice'),
+			('a-4', 'Unowned', NULL, 'alice')`,
+	)
+	h := New(newTestManager(t, townRoot), nil, nil, nil, nil, townRoot)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"?owner=alice", []string{"a-1", "a-3"}},
+		// A different owner must not hit the first query's cache entry
+		{"?owner=bob", []string{"a-2"}},
+		{"?owner=carol", []string{}},
+		// Owner and assignee are separate dimensions
+		{"?assignee=alice", []string{"a-2", "a-3", "a-4"}},
+		{"?owner=alice&assignee=alice", []string{"a-3"}},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/rigs/rig-a/issues"+tt.query, nil)
+		req.SetPathValue("rigId", "rig-a")
+		rec := httptest.NewRecorder()
+		h.ListIssues(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%q: expected 200, got %d: %s", tt.query, rec.Code, rec.Body.String())
+		}
+		var issues []types.Issue
+		if err := json.Unmarshal(rec.Body.Bytes(), &issues); err != nil {
+			t.Fatalf("%q: failed to decode issues: %v", tt.query, err)
+		}
+		got := []string{}
+		for _, issue := range issues {
+			got = append(got, issue.ID)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+// assertErrorCode checks that rec holds a JSON error response with code.
+func assertErrorCode(t *testing.T, rec *httptest.ResponseRecorder, code string) {
+	t.Helper()
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected application/json, got %q", ct)
+	}
+	var resp ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("expected a JSON error body, got %q: %v", rec.Body.String(), err)
+	}
+	if resp.Error.Code != code {
+		t.Errorf("expected error code %s, got %s", code, resp.Error.Code)
+	}
+}
+
+func TestMoveIssue(t *testing.T) {
+	newMoveHandlers := func(t *testing.T, bdScript string) (*Handlers, string) {
+		townRoot := t.TempDir()
+		addTestRig(t, townRoot, "rig-a",
+			`INSERT INTO issues (id, title, status) VALUES ('a-1', 'Move me', 'in_progress'), ('a-2', 'Blocker', 'open'), ('a-3', 'Dependent', 'open')`,
+			`INSERT INTO dependencies (issue_id, depends_on_id, type) VALUES ('a-1', 'a-2', 'blocks'), ('a-3', 'a-1', 'blocks')`,
+		)
+		addTestRig(t, townRoot, "rig-b")
+		logPath := stubBD(t, bdScript)
+		return New(newTestManager(t, townRoot), nil, nil, nil, nil, townRoot), logPath
+	}
+	move := func(h *Handlers, issueID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/rigs/rig-a/issues/"+issueID+"/move", strings.NewReader(`{"target_rig":"rig-b"}`))
+		req.SetPathValue("rigId", "rig-a")
+		req.SetPathValue("issueId", issueID)
+		rec := httptest.NewRecorder()
+		h.MoveIssue(rec, req)
+		return rec
+	}
+	const createsB9 = `case "$1" in create) echo '{"id":"b-9"}';; esac`
+
+	t.Run("unknown issue is 404", func(t *testing.T) {
+		h, logPath := newMoveHandlers(t, createsB9)
+		rec := move(h, "a-404")
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("expected 404, got %d: %s", rec.Code, rec.Body.String())
+		}
+		assertErrorCode(t, rec, ErrCodeIssueNotFound)
+		if calls := bdCalls(t, logPath); calls[0] != "" {
+			t.Errorf("expected no bd calls, got %v", calls)
+		}
+	})
+
+	t.Run("carries status and edges", func(t *testing.T) {
+		h, logPath := newMoveHandlers(t, createsB9)
+		rec := move(h, "a-1")
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var result types.IssueMoveResult
+		if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+			t.Fatalf("failed to decode result: %v", err)
+		}
+		if result.TargetID != "b-9" || len(result.Warnings) != 0 {
+			t.Errorf("unexpected result %+v", result)
+		}
+		want := []string{
+			"update b-9 --status in_progress",
+			"dep add b-9 external:rig-a:a-2 --type blocks",
+			"dep add a-3 external:rig-b:b-9 --type blocks",
+			"dep remove a-3 a-1",
+			"close a-1 --reason moved",
+		}
+		calls := bdCalls(t, logPath)[1:]
+		if !reflect.DeepEqual(calls, want) {
+			t.Errorf("bd calls = %q, want %q", calls, want)
+		}
+	})
+
+	t.Run("reports edges that fail to carry over", func(t *testing.T) {
+		h, _ := newMoveHandlers(t, createsB9+`
+case "$1 $2" in "dep add") exit 1;; esac`)
+		rec := move(h, "a-1")
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var result types.IssueMoveResult
+		if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+			t.Fatalf("failed to decode result: %v", err)
+		}
+		if len(result.Warnings) != 2 {
+			t.Errorf("expected a warning per failed edge, got %q", result.Warnings)
+		}
+	})
+
+	t.Run("close failure names the copy", func(t *testing.T) {
+		h, _ := newMoveHandlers(t, createsB9+`
+case "$1" in close) exit 1;; esac`)
+		rec := move(h, "a-1")
+		if rec.Code != http.StatusInternalServerError {
+			t.Fatalf("expected 500, got %d: %s", rec.Code, rec.Body.String())
+		}
+		assertErrorCode(t, rec, ErrCodeBDCommandFailed)
+		if !strings.Contains(rec.Body.String(), "b-9") {
+			t.Errorf("expected the copy's ID in the error, got %s", rec.Body.String())
+		}
+	})
+}
+
+func TestDeleteIssue(t *testing.T) {
+	newDeleteHandlers := func(t *testing.T, bdScript string) (*Handlers, *events.Store, string) {
+		townRoot := t.TempDir()
+		addTestRig(t, townRoot, "rig-a",
+			`INSERT INTO issues (id, title, status) VALUES
+				('a-1', 'Depended on', 'open'), ('a-2', 'Open dependent', 'open'),
+				('a-3', 'Closed dependent', 'closed'), ('a-4', 'Done with', 'open')`,
+			`INSERT INTO dependencies (issue_id, depends_on_id, type) VALUES
+				('a-2', 'a-1', 'blocks'), ('a-3', 'a-1', 'blocks'), ('a-3', 'a-4', 'blocks')`,
+		)
+		store, err := events.NewStore(events.DefaultConfig())
+		if err != nil {
+			t.Fatalf("failed to create event store: %v", err)
+		}
+		t.Cleanup(func() { store.Close() })
+		logPath := stubBD(t, bdScript)
+		return New(newTestManager(t, townRoot), store, nil, nil, nil, townRoot), store, logPath
+	}
+	del := func(h *Handlers, issueID, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodDelete, "/api/rigs/rig-a/issues/"+issueID+query, nil)
+		req.SetPathValue("rigId", "rig-a")
+		req.SetPathValue("issueId", issueID)
+		rec := httptest.NewRecorder()
+		h.DeleteIssue(rec, req)
+		return rec
+	}
+	deletedEvents := func(t *testing.T, store *events.Store) []events.Event {
+		t.Helper()
+		deleted, err := store.Query(events.EventFilter{Type: "bead.deleted"})
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		return deleted
+	}
+
+	tests := []struct {
+		name      string
+		issue     string
+		query     string
+		bdScript  string
+		wantCode  int
+		wantError string
+	}{
+		{name: "unknown issue", issue: "a-9", wantCode: http.StatusNotFound, wantError: ErrCodeIssueNotFound},
+		{name: "open dependents refuse", issue: "a-1", wantCode: http.StatusConflict, wantError: ErrCodeActiveDependents},
+		{name: "force=false still refuses", issue: "a-1", query: "?force=false", wantCode: http.StatusConflict, wantError: ErrCodeActiveDependents},
+		{name: "force overrides open dependents", issue: "a-1", query: "?force=true", wantCode: http.StatusNoContent},
+		{name: "closed dependents don't block", issue: "a-4", wantCode: http.StatusNoContent},
+		{name: "bd failure", issue: "a-4", bdScript: "exit 1", wantCode: http.StatusInternalServerError, wantError: ErrCodeBDCommandFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, store, logPath := newDeleteHandlers(t, tt.bdScript)
+			rec := del(h, tt.issue, tt.query)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("expected %d, got %d: %s", tt.wantCode, rec.Code, rec.Body.String())
+			}
+			if tt.wantError != "" {
+				assertErrorCode(t, rec, tt.wantError)
+			} else if rec.Body.Len() != 0 {
+				t.Errorf("expected an empty 204 body, got %q", rec.Body.String())
+			}
+
+			calls := bdCalls(t, logPath)
+			switch tt.wantCode {
+			case http.StatusNotFound, http.StatusConflict:
+				if calls[0] != "" {
+					t.Errorf("expected no bd calls, got %q", calls)
+				}
+			default:
+				// bd delete only previews without --force, whatever ?force says
+				if want := []string{"delete " + tt.issue + " --force"}; !reflect.DeepEqual(calls, want) {
+					t.Errorf("bd calls = %q, want %q", calls, want)
+				}
+			}
+
+			deleted := deletedEvents(t, store)
+			if tt.wantCode != http.StatusNoContent {
+				if len(deleted) != 0 {
+					t.Errorf("expected no bead.deleted event, got %d", len(deleted))
+				}
+				return
+			}
+			if len(deleted) != 1 {
+				t.Fatalf("expected one bead.deleted event, got %d", len(deleted))
+			}
+			var payload map[string]string
+			if err := json.Unmarshal(deleted[0].Payload, &payload); err != nil {
+				t.Fatalf("failed to decode payload: %v", err)
+			}
+			if deleted[0].Rig != "rig-a" || payload["issue_id"] != tt.issue || payload["rig"] != "rig-a" {
+				t.Errorf("unexpected bead.deleted event %+v with payload %v", deleted[0], payload)
+			}
+		})
+	}
+}
+
+func TestListActiveAgents(t *testing.T) {
+	reg := registry.NewWithDefaults()
+	defer reg.Stop()
+	now := time.Now()
+	for _, id := range []string{"rig-a/polecats/recent", "rig-a/polecats/older", "rig-a/polecats/silent", "rig-a/polecats/discovered"} {
+		reg.Register(registry.AgentRegistration{ID: id, Rig: "rig-a", Role: registry.RolePolecat})
+	}
+	reg.Heartbeat(registry.Heartbeat{AgentID: "rig-a/polecats/recent", Timestamp: now, Status: registry.StatusWorking})
+	reg.Heartbeat(registry.Heartbeat{AgentID: "rig-a/polecats/older", Timestamp: now.Add(-2 * time.Minute), Status: registry.StatusIdle})
+	// Reported long ago; only discovery has refreshed it since
+	reg.Heartbeat(registry.Heartbeat{AgentID: "rig-a/polecats/silent", Timestamp: now.Add(-time.Hour), Status: registry.StatusWorking})
+	reg.Heartbeat(registry.Heartbeat{AgentID: "rig-a/polecats/silent", Timestamp: now, Status: registry.StatusIdle, Inferred: true})
+	reg.Heartbeat(registry.Heartbeat{AgentID: "rig-a/polecats/discovered", Timestamp: now, Status: registry.StatusIdle, Inferred: true})
+	h := New(nil, nil, reg, nil, nil, t.TempDir())
+
+	tests := []struct {
+		query string
+		code  int
+		want  []string
+	}{
+		{"", http.StatusOK, []string{"rig-a/polecats/recent", "rig-a/polecats/older"}},
+		{"?within=1m", http.StatusOK, []string{"rig-a/polecats/recent"}},
+		{"?within=2h", http.StatusOK, []string{"rig-a/polecats/recent", "rig-a/polecats/older", "rig-a/polecats/silent"}},
+		{"?within=soon", http.StatusBadRequest, nil},
+		{"?within=-5m", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.ListActiveAgents(rec, httptest.NewRequest(http.MethodGet, "/api/agents/active"+tt.query, nil))
+		if rec.Code != tt.code {
+			t.Fatalf("%q: expected %d, got %d: %s", tt.query, tt.code, rec.Code, rec.Body.String())
+		}
+		if tt.code != http.StatusOK {
+			assertErrorCode(t, rec, ErrCodeValidationFailed)
+			continue
+		}
+		var agents []types.Agent
+		if err := json.Unmarshal(rec.Body.Bytes(), &agents); err != nil {
+			t.Fatalf("%q: failed to decode agents: %v", tt.query, err)
+		}
+		got := make([]string, len(agents))
+		for i, a := range agents {
+			got[i] = a.ID
+			if a.LastSelfHeartbeat == nil {
+				t.Errorf("%q: expected last_self_heartbeat on %s", tt.query, a.ID)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestListStuckAgents(t *testing.T) {
+	reg := registry.NewWithDefaults()
+	defer reg.Stop()
+	now := time.Now()
+	bead := func(id string) *string { return &id }
+	agents := []struct {
+		id, rig string
+		beat    registry.Heartbeat
+	}{
+		// Stuck on a bead started 30m ago, though its status changed later
+		{"rig-a/polecats/oldest", "rig-a", registry.Heartbeat{Timestamp: now.Add(-30 * time.Minute), Status: registry.StatusWorking, CurrentBead: bead("a-1")}},
+		{"rig-b/polecats/middle", "rig-b", registry.Heartbeat{Timestamp: now.Add(-20 * time.Minute), Status: registry.StatusStuck}},
+		{"rig-a/polecats/newest", "rig-a", registry.Heartbeat{Timestamp: now.Add(-5 * time.Minute), Status: registry.StatusStuck, CurrentBead: bead("a-2")}},
+		{"rig-a/polecats/working", "rig-a", registry.Heartbeat{Timestamp: now, Status: registry.StatusWorking}},
+	}
+	for _, a := range agents {
+		reg.Register(registry.AgentRegistration{ID: a.id, Rig: a.rig, Role: registry.RolePolecat})
+		a.beat.AgentID = a.id
+		reg.Heartbeat(a.beat)
+	}
+	reg.Heartbeat(registry.Heartbeat{AgentID: "rig-a/polecats/oldest", Timestamp: now.Add(-time.Minute), Status: registry.StatusStuck, CurrentBead: bead("a-1")})
+
+	tests := []struct {
+		name  string
+		rig   string
+		reg   *registry.Registry
+		want  []string
+		since []time.Duration // stuck duration of each wanted agent
+	}{
+		{"all rigs", "", reg, []string{"rig-a/polecats/oldest", "rig-b/polecats/middle", "rig-a/polecats/newest"},
+			[]time.Duration{30 * time.Minute, 20 * time.Minute, 5 * time.Minute}},
+		{"one rig", "rig-a", reg, []string{"rig-a/polecats/oldest", "rig-a/polecats/newest"},
+			[]time.Duration{30 * time.Minute, 5 * time.Minute}},
+		{"rig without stuck agents", "rig-c", reg, []string{}, nil},
+		{"no registry", "", nil, []string{}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New(nil, nil, nil, nil, nil, t.TempDir())
+			if tt.reg != nil {
+				h = New(nil, nil, tt.reg, nil, nil, t.TempDir())
+			}
+			target := "/api/agents/stuck"
+			if tt.rig != "" {
+				target = "/api/rigs/" + tt.rig + "/agents/stuck"
+			}
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			req.SetPathValue("rigId", tt.rig)
+			rec := httptest.NewRecorder()
+			h.ListStuckAgents(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+			}
+			var stuck []types.StuckAgent
+			if err := json.Unmarshal(rec.Body.Bytes(), &stuck); err != nil {
+				t.Fatalf("failed to decode stuck agents %q: %v", rec.Body.String(), err)
+			}
+			got := make([]string, len(stuck))
+			for i, a := range stuck {
+				got[i] = a.ID
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i, a := range stuck {
+				want := int64(tt.since[i].Seconds())
+				// Allow for the clock moving on while the test runs
+				if a.StuckDurationSeconds < want || a.StuckDurationSeconds > want+5 {
+					t.Errorf("%s: expected about %ds stuck, got %d", a.ID, want, a.StuckDurationSeconds)
+				}
+				if !a.StuckSince.Equal(now.Add(-tt.since[i])) {
+					t.Errorf("%s: expected stuck since %v, got %v", a.ID, now.Add(-tt.since[i]), a.StuckSince)
+				}
+			}
+		})
+	}
+}
+
+func TestAddDependenciesBatch(t *testing.T) {
+	batch := func(h *Handlers, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/rigs/rig-a/dependencies/batch", strings.NewReader(body))
+		req.SetPathValue("rigId", "rig-a")
+		rec := httptest.NewRecorder()
+		h.AddDependenciesBatch(rec, req)
+		return rec
+	}
+
+	t.Run("validates before adding and reports each edge", func(t *testing.T) {
+		townRoot := t.TempDir()
+		addTestRig(t, townRoot, "rig-a", `INSERT INTO issues (id, title) VALUES ('a-1', 'One'), ('a-2', 'Two'), ('a-3', 'Three')`)
+		logPath := stubBD(t, `case "$3" in a-3) exit 1;; esac`)
+		h := New(newTestManager(t, townRoot), nil, nil, nil, nil, townRoot)
+
+		rec := batch(h, `[
+			{"issue_id":"a-1","blocker_id":"a-2"},
+			{"issue_id":"a-2","blocker_id":"a-1"},
+			{"issue_id":"a-3","blocker_id":"a-2"},
+			{"issue_id":"a-1","blocker_id":"a-1"}
+		]`)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var result types.DependencyBatchResult
+		if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+			t.Fatalf("failed to decode result: %v", err)
+		}
+		var statuses []string
+		for _, res := range result.Results {
+			statuses = append(statuses, res.Status)
+		}
+		if want := []string{"added", "cycle", "failed", "invalid"}; !reflect.DeepEqual(statuses, want) {
+			t.Errorf("statuses = %v, want %v", statuses, want)
+		}
+		if result.Added != 1 || result.Failed != 3 {
+			t.Errorf("expected 1 added and 3 failed, got %d and %d", result.Added, result.Failed)
+		}
+		want := []string{"dep add a-1 a-2 --type blocks", "dep add a-3 a-2 --type blocks"}
+		if calls := bdCalls(t, logPath); !reflect.DeepEqual(calls, want) {
+			t.Errorf("bd calls = %q, want %q", calls, want)
+		}
+	})
+
+	t.Run("cycle check failure changes nothing", func(t *testing.T) {
+		// rig-a's beads.db has no schema, so no dependencies can be loaded
+		townRoot := newTestTown(t)
+		logPath := stubBD(t, "")
+		h := New(newTestManager(t, townRoot), nil, nil, nil, nil, townRoot)
+
+		rec := batch(h, `[{"issue_id":"a-1","blocker_id":"a-1"},{"issue_id":"a-1","blocker_id":"a-2"}]`)
+		if rec.Code != http.StatusInternalServerError {
+			t.Fatalf("expected 500, got %d: %s", rec.Code, rec.Body.String())
+		}
+		assertErrorCode(t, rec, ErrCodeInternal)
+		if calls := bdCalls(t, logPath); calls[0] != "" {
+			t.Errorf("expected no bd calls, got %q", calls)
+		}
+	})
+}
+
+// newSnapshotTestHandlers returns Handlers whose event store holds snapshots
+// of issue x-1 in rig-a (open at t0, closed an hour later) and in rig-b (open
+// at t0), plus a deletion of x-2 from rig-a at t0+1h.
+func newSnapshotTestHandlers(t *testing.T, t0 time.Time) *Handlers {
+	t.Helper()
+	store, err := events.NewStore(events.DefaultConfig())
+	if err != nil {
+		t.Fatalf("failed to create event store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	open := map[string][]byte{
+		"x-1": []byte(`{"id":"x-1","status":"open"}`),
+		"x-2": []byte(`{"id":"x-2","status":"open"}`),
+	}
+	closed := map[string][]byte{"x-1": []byte(`{"id":"x-1","status":"closed"}`)}
+	for _, rig := range []string{"rig-a", "rig-b"} {
+		if _, err := store.RecordIssueSnapshots(rig, t0, open); err != nil {
+			t.Fatalf("RecordIssueSnapshots failed: %v", err)
+		}
+	}
+	if _, err := store.RecordIssueSnapshots("rig-a", t0.Add(time.Hour), closed); err != nil {
+		t.Fatalf("RecordIssueSnapshots failed: %v", err)
+	}
+	if _, err := store.RecordDeletedIssues("rig-a", t0.Add(time.Hour), closed); err != nil {
+		t.Fatalf("RecordDeletedIssues failed: %v", err)
+	}
+
+	townRoot := newTestTown(t)
+	return New(newTestManager(t, townRoot), store, nil, nil, nil, townRoot)
+}
+
+func TestGetIssueSnapshot(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	h := newSnapshotTestHandlers(t, t0)
+	later := t0.Add(2 * time.Hour).Format(time.RFC3339)
+
+	tests := []struct {
+		name        string
+		rig, issue  string
+		at          string
+		wantStatus  int
+		wantState   string
+		wantDeleted bool
+	}{
+		{name: "latest by default", rig: "rig-a", issue: "x-1", wantStatus: http.StatusOK, wantState: `{"id":"x-1","status":"closed"}`},
+		{name: "as of an earlier time", rig: "rig-a", issue: "x-1", at: t0.Add(30 * time.Minute).Format(time.RFC3339), wantStatus: http.StatusOK, wantState: `{"id":"x-1","status":"open"}`},
+		{name: "scoped to the rig", rig: "rig-b", issue: "x-1", at: later, wantStatus: http.StatusOK, wantState: `{"id":"x-1","status":"open"}`},
+		{name: "deleted issue", rig: "rig-a", issue: "x-2", at: later, wantStatus: http.StatusOK, wantState: "null", wantDeleted: true},
+		{name: "other rig has none", rig: "rig-c", issue: "x-1", at: later, wantStatus: http.StatusNotFound},
+		{name: "before the first snapshot", rig: "rig-a", issue: "x-1", at: t0.Add(-time.Minute).Format(time.RFC3339), wantStatus: http.StatusNotFound},
+		{name: "bad time", rig: "rig-a", issue: "x-1", at: "yesterday", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := "/api/rigs/" + tt.rig + "/issues/" + tt.issue + "/snapshot"
+			if tt.at != "" {
+				target += "?at=" + tt.at
+			}
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			req.SetPathValue("rigId", tt.rig)
+			req.SetPathValue("issueId", tt.issue)
+			rec := httptest.NewRecorder()
+
+			h.GetIssueSnapshot(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				code := ErrCodeNotFound
+				if tt.wantStatus == http.StatusBadRequest {
+					code = ErrCodeValidationFailed
+				}
+				assertErrorCode(t, rec, code)
+				return
+			}
+			var snap events.IssueSnapshot
+			if err := json.NewDecoder(rec.Body).Decode(&snap); err != nil {
+				t.Fatalf("failed to decode snapshot: %v", err)
+			}
+			if snap.Rig != tt.rig || string(snap.State) != tt.wantState || snap.Deleted != tt.wantDeleted {
+				t.Errorf("expected %s state %s (deleted %v), got %+v", tt.rig, tt.wantState, tt.wantDeleted, snap)
+			}
+		})
+	}
+}
+
+func TestDiffIssueSnapshots(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	h := newSnapshotTestHandlers(t, t0)
+	from := t0.Add(30 * time.Minute).Format(time.RFC3339)
+	to := t0.Add(2 * time.Hour).Format(time.RFC3339)
+
+	tests := []struct {
+		name        string
+		rig, issue  string
+		query       string
+		wantStatus  int
+		wantChanges []string // "field:from->to"
+	}{
+		{name: "status changed", rig: "rig-a", issue: "x-1", query: "from=" + from + "&to=" + to, wantStatus: http.StatusOK, wantChanges: []string{`status:"open"->"closed"`}},
+		{name: "scoped to the rig", rig: "rig-b", issue: "x-1", query: "from=" + from + "&to=" + to, wantStatus: http.StatusOK, wantChanges: []string{}},
+		{name: "deletion removes every field", rig: "rig-a", issue: "x-2", query: "from=" + from + "&to=" + to, wantStatus: http.StatusOK, wantChanges: []string{`id:"x-2"->null`, `status:"open"->null`}},
+		{name: "other rig has none", rig: "rig-c", issue: "x-1", query: "from=" + from + "&to=" + to, wantStatus: http.StatusNotFound},
+		{name: "missing from", rig: "rig-a", issue: "x-1", query: "to=" + to, wantStatus: http.StatusBadRequest},
+		{name: "bad from", rig: "rig-a", issue: "x-1", query: "from=yesterday", wantStatus: http.StatusBadRequest},
+		{name: "bad to", rig: "rig-a", issue: "x-1", query: "from=" + from + "&to=later", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/rigs/"+tt.rig+"/issues/"+tt.issue+"/snapshots/diff?"+tt.query, nil)
+			req.SetPathValue("rigId", tt.rig)
+			req.SetPathValue("issueId", tt.issue)
+			rec := httptest.NewRecorder()
+
+			h.DiffIssueSnapshots(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				code := ErrCodeNotFound
+				if tt.wantStatus == http.StatusBadRequest {
+					code = ErrCodeValidationFailed
+				}
+				assertErrorCode(t, rec, code)
+				return
+			}
+			var diff IssueSnapshotDiff
+			if err := json.NewDecoder(rec.Body).Decode(&diff); err != nil {
+				t.Fatalf("failed to decode diff: %v", err)
+			}
+			if diff.From == nil || diff.From.Rig != tt.rig || diff.To == nil || diff.To.Rig != tt.rig {
+				t.Errorf("expected both snapshots from %s, got %+v -> %+v", tt.rig, diff.From, diff.To)
+			}
+			got := []string{}
+			for _, c := range diff.Changes {
+				got = append(got, c.Field+":"+string(c.From)+"->"+string(c.To))
+			}
+			if !reflect.DeepEqual(got, tt.wantChanges) {
+				t.Errorf("expected changes %v, got %v", tt.wantChanges, got)
+			}
+		})
+	}
+}
+
+func TestTelemetryHandlers_WithoutCollector(t *testing.T) {
+	h := &Handlers{} // no collector, as when the telemetry database can't be opened
+
+	rec := httptest.NewRecorder()
+	h.GetTokenSummary(rec, httptest.NewRequest(http.MethodGet, "/api/telemetry/tokens/summary", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected an empty summary, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/telemetry/commits/abc", nil)
+	req.SetPathValue("sha", "abc")
+	rec = httptest.NewRecorder()
+	h.GetCommitActivity(rec, req)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 for commit activity, got %d: %s", rec.Code, rec.Body.String())
+	}
+	assertErrorCode(t, rec, ErrCodeTelemetryUnavailable)
+}
+
+func TestGetTelemetrySummary(t *testing.T) {
+	h, collector := newTelemetryTestHandlers(t)
+	now := time.Now().UTC()
+	old := now.Add(-48 * time.Hour).Format(time.RFC3339)
+	recent := now.Add(-time.Hour).Format(time.RFC3339)
+	for _, r := range []struct{ agent, rig, at string }{
+		{"rig-a/polecats/a1", "rig-a", recent},
+		{"rig-b/polecats/b1", "rig-b", recent},
+		{"rig-a/polecats/a1", "rig-a", old},
+	} {
+		if err := collector.RecordTokenUsage(telemetry.TokenUsage{
+			AgentID: r.agent, Rig: r.rig, Timestamp: r.at, InputTokens: 100, OutputTokens: 10, Model: "claude-sonnet-4",
+		}); err != nil {
+			t.Fatalf("RecordTokenUsage failed: %v", err)
+		}
+		if err := collector.RecordGitChange(telemetry.GitChange{
+			AgentID: r.agent, Rig: r.rig, Timestamp: r.at, CommitSHA: r.rig + r.at, FilesChanged: 2, Insertions: 5, Deletions: 1,
+		}); err != nil {
+			t.Fatalf("RecordGitChange failed: %v", err)
+		}
+		if err := collector.RecordTestRun(telemetry.TestRun{
+			AgentID: r.agent, Rig: r.rig, Timestamp: r.at, Command: "go test", Total: 3, Passed: 2, Failed: 1,
+		}); err != nil {
+			t.Fatalf("RecordTestRun failed: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		query   string
+		records int // matching records of each kind
+	}{
+		{"everything", "", 3},
+		{"one rig", "?rig=rig-a", 2},
+		{"since", "?since=" + now.Add(-24*time.Hour).Format(time.RFC3339), 2},
+		{"until", "?until=" + now.Add(-24*time.Hour).Format(time.RFC3339), 1},
+		{"rig and since", "?rig=rig-b&since=" + now.Add(-24*time.Hour).Format(time.RFC3339), 1},
+		{"no matches", "?rig=rig-x", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.GetTelemetrySummary(rec, httptest.NewRequest(http.MethodGet, "/api/telemetry/summary"+tt.query, nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+			}
+			var overview TelemetryOverview
+			if err := json.Unmarshal(rec.Body.Bytes(), &overview); err != nil {
+				t.Fatalf("failed to decode overview: %v", err)
+			}
+
+			n := tt.records
+			if overview.Tokens.TotalInput != 100*n || overview.Tokens.TotalOutput != 10*n {
+				t.Errorf("expected %d token records, got %+v", n, overview.Tokens)
+			}
+			if overview.Git.TotalCommits != n || overview.Git.TotalInsertions != 5*n {
+				t.Errorf("expected %d commits, got %+v", n, overview.Git)
+			}
+			if overview.Tests.TotalRuns != n || overview.Tests.TotalTests != 3*n || overview.Tests.TotalFailed != n {
+				t.Errorf("expected %d test runs, got %+v", n, overview.Tests)
+			}
+			// Empty summaries still render their breakdowns as objects
+			for _, key := range []string{`"by_model":{`, `"by_agent":{`} {
+				if !strings.Contains(rec.Body.String(), key) {
+					t.Errorf("expected %s in %s", key, rec.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestGetRegressions_ReportsSuppressedFlaky(t *testing.T) {
+	h, collector := newTelemetryTestHandlers(t)
+
+	// TestFlaky alternates pass/fail; TestBroken passed then failed once.
+	statuses := [][2]string{
+		{"passed", "passed"},
+		{"failed", "passed"},
+		{"passed", "passed"},
+		{"failed", "failed"},
+	}
+	for i, st := range statuses {
+		if err := collector.RecordTestRun(telemetry.TestRun{
+			AgentID:   "rig-a/polecats/a1",
+			Timestamp: "2026-01-24T1" + strconv.Itoa(i) + ":00:00Z",
+			CommitSHA: "commit-" + strconv.Itoa(i),
+			Command:   "go test",
+			Results: []telemetry.TestResult{
+				{TestFile: "flaky_test.go", TestName: "TestFlaky", Status: st[0], DurationMS: 10},
+				{TestFile: "broken_test.go", TestName: "TestBroken", Status: st[1], DurationMS: 10},
+			},
+		}); err != nil {
+			t.Fatalf("RecordTestRun failed: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		tests      []string
+		suppressed int
+		byCommit   bool
+	}{
+		{"flaky suppressed by default", "", []string{"TestBroken"}, 1, false},
+		{"suppression off", "&suppress_flaky=false", []string{"TestBroken", "TestFlaky"}, 0, false},
+		{"grouped by commit", "&group_by=commit", []string{"TestBroken"}, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/api/telemetry/regressions?since=2026-01-24T12:30:00Z"+tt.query, nil)
+			h.GetRegressions(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+			}
+			var report RegressionReport
+			if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+				t.Fatalf("failed to decode report: %v", err)
+			}
+
+			var names []string
+			for _, r := range report.Regressions {
+				names = append(names, r.TestName)
+			}
+			sort.Strings(names)
+			if !reflect.DeepEqual(names, tt.tests) {
+				t.Errorf("Expected regressions %v, got %v", tt.tests, names)
+			}
+			if report.SuppressedFlaky != tt.suppressed {
+				t.Errorf("Expected suppressed_flaky %d, got %d", tt.suppressed, report.SuppressedFlaky)
+			}
+			if got := rec.Header().Get("X-Suppressed-Flaky"); got != strconv.Itoa(tt.suppressed) {
+				t.Errorf("Expected X-Suppressed-Flaky %d, got %q", tt.suppressed, got)
+			}
+			if tt.byCommit && len(report.ByCommit["commit-3"]) != 1 {
+				t.Errorf("Expected one regression under commit-3, got %+v", report.ByCommit)
+			}
+			if !tt.byCommit && report.ByCommit != nil {
+				t.Errorf("Expected no by_commit without group_by, got %+v", report.ByCommit)
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	h.GetRegressions(rec, httptest.NewRequest(http.MethodGet, "/api/telemetry/regressions?suppress_flaky=maybe", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("Expected 400 for a bad suppress_flaky, got %d", rec.Code)
+	}
+	assertErrorCode(t, rec, ErrCodeValidationFailed)
+}
+
+func TestGetRawIssueDependencies(t *testing.T) {
+	townRoot := newTestTown(t)
+	addTestRig(t, townRoot, "rig-b",
+		`INSERT INTO issues (id, title) VALUES ('b-1', 'Convoy'), ('b-2', 'First'), ('b-3', 'Second')`,
+		`INSERT INTO dependencies (issue_id, depends_on_id, type, created_at, created_by) VALUES
+			('b-1', 'b-2', 'tracks', '2026-01-02 03:04:05', 'mayor'),
+			('b-1', 'b-3', 'blocks', '2026-01-02 04:00:00', '')`,
+	)
+	h := New(newTestManager(t, townRoot), nil, nil, nil, nil, townRoot)
+
+	tests := []struct {
+		name      string
+		issue     string
+		dependsOn string
+		want      string
+	}{
+		{
+			name:  "every edge",
+			issue: "b-1",
+			want:  `[{"issue_id":"b-1","depends_on_id":"b-2","type":"tracks","created_at":"2026-01-02T03:04:05Z","created_by":"mayor"},{"issue_id":"b-1","depends_on_id":"b-3","type":"blocks","created_at":"2026-01-02T04:00:00Z"}]`,
+		},
+		{
+			name:      "narrowed to one target",
+			issue:     "b-1",
+			dependsOn: "b-3",
+			want:      `[{"issue_id":"b-1","depends_on_id":"b-3","type":"blocks","created_at":"2026-01-02T04:00:00Z"}]`,
+		},
+		{name: "no edge to the target", issue: "b-1", dependsOn: "b-9", want: `[]`},
+		{name: "no edges", issue: "b-2", want: `[]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := "/api/rigs/rig-b/issues/" + tt.issue + "/dependencies/raw"
+			if tt.dependsOn != "" {
+				target += "?depends_on=" + tt.dependsOn
+			}
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			req.SetPathValue("rigId", "rig-b")
+			req.SetPathValue("issueId", tt.issue)
+			rec := httptest.NewRecorder()
+
+			h.GetRawIssueDependencies(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+			}
+			if got := strings.TrimSpace(rec.Body.String()); got != tt.want {
+				t.Errorf("expected body\n%s\ngot\n%s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestWarmIssueFilters_MatchListIssuesCacheKeys(t *testing.T) {
+	townRoot := newTestTown(t)
+	addTestRig(t, townRoot, "rig-b", `INSERT INTO issues (id, title) VALUES ('b-1', 'Open')`)
+	m := newTestManager(t, townRoot)
+	h := New(m, nil, nil, nil, nil, townRoot)
+	h.SetMaxPageSize(50)
+
+	filters := h.WarmIssueFilters()
+	if len(filters) != 3 {
+		t.Fatalf("expected the snapshot and two board filters, got %+v", filters)
+	}
+	m.SetWarmFilters(filters)
+
+	stats := func() query.CacheStats {
+		t.Helper()
+		s, err := m.GetCacheStats("rig-b")
+		if err != nil {
+			t.Fatalf("GetCacheStats failed: %v", err)
+		}
+		return *s
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for stats().IssueListEntries < len(filters) {
+		if time.Now().After(deadline) {
+			t.Fatalf("warm-up never filled the cache: %+v", stats())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	before := stats()
+
+	for _, target := range []string{"/api/rigs/rig-b/issues", "/api/rigs/rig-b/issues?status=open", "/api/rigs/rig-b/issues?status=all&limit=50"} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.SetPathValue("rigId", "rig-b")
+		rec := httptest.NewRecorder()
+		h.ListIssues(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", target, rec.Code, rec.Body.String())
+		}
+	}
+
+	after := stats()
+	if after.MissCount != before.MissCount || after.HitCount != before.HitCount+3 {
+		t.Errorf("expected every board request to hit a warm entry, got %+v then %+v", before, after)
+	}
+}
+
+func TestGetCoverageGaps(t *testing.T) {
+	h, collector := newTelemetryTestHandlers(t)
+	changes := []telemetry.GitChange{
+		{AgentID: "rig-a/polecats/a1", BeadID: "a-1", Rig: "rig-a", Timestamp: "2026-01-10T10:00:00Z", CommitSHA: "aaa", Message: "untested"},
+		{AgentID: "rig-a/polecats/a1", BeadID: "a-2", Rig: "rig-a", Timestamp: "2026-01-12T10:00:00Z", CommitSHA: "bbb", Message: "tested"},
+		{AgentID: "rig-b/polecats/b1", BeadID: "b-1", Rig: "rig-b", Timestamp: "2026-01-14T10:00:00Z", CommitSHA: "ccc", Message: "other rig"},
+		{AgentID: "rig-a/polecats/a1", Rig: "rig-a", Timestamp: "2026-01-15T10:00:00Z", CommitSHA: "ddd", Message: "no bead"},
+	}
+	for _, c := range changes {
+		if err := collector.RecordGitChange(c); err != nil {
+			t.Fatalf("RecordGitChange failed: %v", err)
+		}
+	}
+	run := telemetry.TestRun{AgentID: "rig-a/polecats/a1", Rig: "rig-a", Timestamp: "2026-01-12T11:00:00Z", CommitSHA: "bbb", Total: 1, Passed: 1}
+	if err := collector.RecordTestRun(run); err != nil {
+		t.Fatalf("RecordTestRun failed: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantSHAs   []string
+	}{
+		{name: "every gap, newest first", wantStatus: http.StatusOK, wantSHAs: []string{"ccc", "aaa"}},
+		{name: "by rig", query: "rig=rig-a", wantStatus: http.StatusOK, wantSHAs: []string{"aaa"}},
+		{name: "by agent", query: "agent_id=rig-b/polecats/b1", wantStatus: http.StatusOK, wantSHAs: []string{"ccc"}},
+		{name: "by bead", query: "bead_id=a-1", wantStatus: http.StatusOK, wantSHAs: []string{"aaa"}},
+		{name: "time range", query: "since=2026-01-11T00:00:00Z&until=2026-01-20T00:00:00Z", wantStatus: http.StatusOK, wantSHAs: []string{"ccc"}},
+		{name: "limit", query: "limit=1", wantStatus: http.StatusOK, wantSHAs: []string{"ccc"}},
+		{name: "bad since", query: "since=yesterday", wantStatus: http.StatusBadRequest},
+		{name: "bad until", query: "until=2026-01-20", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.GetCoverageGaps(rec, httptest.NewRequest(http.MethodGet, "/api/telemetry/coverage-gaps?"+tt.query, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				assertErrorCode(t, rec, ErrCodeValidationFailed)
+				return
+			}
+			var gaps []telemetry.CoverageGap
+			if err := json.NewDecoder(rec.Body).Decode(&gaps); err != nil {
+				t.Fatalf("failed to decode gaps: %v", err)
+			}
+			got := []string{}
+			for _, g := range gaps {
+				got = append(got, g.CommitSHA)
+			}
+			if !reflect.DeepEqual(got, tt.wantSHAs) {
+				t.Errorf("expected gaps at %v, got %v", tt.wantSHAs, got)
+			}
+		})
+	}
+}
+
+func TestSearchEvents(t *testing.T) {
+	store, err := events.NewStore(events.DefaultConfig())
+	if err != nil {
+		t.Fatalf("failed to create event store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	emits := []struct {
+		eventType, rig string
+		payload        map[string]string
+	}{
+		{"bead.created", "rig-a", map[string]string{"title": "Disk full on web-1"}},
+		{"bead.created", "rig-b", map[string]string{"title": "Disk full on web-2"}},
+		{"bead.closed", "rig-a", map[string]string{"title": "Unrelated"}},
+	}
+	for _, e := range emits {
+		if err := store.Emit(e.eventType, "test", e.rig, e.payload); err != nil {
+			t.Fatalf("Emit failed: %v", err)
+		}
+	}
+	h := New(nil, store, nil, nil, nil, t.TempDir())
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantRigs   []string // rigs of the matches, newest first
+	}{
+		{name: "payload match", query: "q=disk+full", wantStatus: http.StatusOK, wantRigs: []string{"rig-b", "rig-a"}},
+		{name: "by rig", query: "q=disk&rig=rig-a", wantStatus: http.StatusOK, wantRigs: []string{"rig-a"}},
+		{name: "by type", query: "q=rig-a&type=bead.closed", wantStatus: http.StatusOK, wantRigs: []string{"rig-a"}},
+		{name: "limit", query: "q=disk&limit=1", wantStatus: http.StatusOK, wantRigs: []string{"rig-b"}},
+		{name: "after the window", query: "q=disk&since=" + future, wantStatus: http.StatusOK, wantRigs: []string{}},
+		{name: "no match", query: "q=nothing-like-this", wantStatus: http.StatusOK, wantRigs: []string{}},
+		{name: "missing q", query: "rig=rig-a", wantStatus: http.StatusBadRequest},
+		{name: "blank q", query: "q=+++", wantStatus: http.StatusBadRequest},
+		{name: "bad since", query: "q=disk&since=yesterday", wantStatus: http.StatusBadRequest},
+		{name: "bad until", query: "q=disk&until=tomorrow", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.SearchEvents(rec, httptest.NewRequest(http.MethodGet, "/api/events/search?"+tt.query, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				assertErrorCode(t, rec, ErrCodeValidationFailed)
+				return
+			}
+			var results []events.Event
+			if err := json.NewDecoder(rec.Body).Decode(&results); err != nil {
+				t.Fatalf("failed to decode results: %v", err)
+			}
+			got := []string{}
+			for _, e := range results {
+				got = append(got, e.Rig)
+			}
+			if !reflect.DeepEqual(got, tt.wantRigs) {
+				t.Errorf("expected matches in %v, got %v", tt.wantRigs, got)
+			}
+		})
+	}
+}
+
+func TestExportEvents(t *testing.T) {
+	newExportHandlers := func(t *testing.T) *Handlers {
+		t.Helper()
+		store, err := events.NewStore(events.DefaultConfig())
+		if err != nil {
+			t.Fatalf("failed to create event store: %v", err)
+		}
+		t.Cleanup(func() { store.Close() })
+		for _, rig := range []string{"rig-a", "rig-b", "rig-a"} {
+			if err := store.Emit("bead.created", "test", rig, map[string]string{}); err != nil {
+				t.Fatalf("Emit failed: %v", err)
+			}
+		}
+		return New(nil, store, nil, nil, nil, t.TempDir())
+	}
+	export := func(h *Handlers, query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ExportEvents(rec, httptest.NewRequest(http.MethodGet, "/api/events/export?"+query, nil))
+		return rec
+	}
+
+	t.Run("streams matching events", func(t *testing.T) {
+		h := newExportHandlers(t)
+		rec := export(h, "rig=rig-a")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+			t.Errorf("expected application/x-ndjson, got %q", ct)
+		}
+		lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("expected rig-a's 2 events, got %q", lines)
+		}
+		for _, line := range lines {
+			var e events.Event
+			if err := json.Unmarshal([]byte(line), &e); err != nil || e.Rig != "rig-a" {
+				t.Errorf("expected a rig-a event, got %q (%v)", line, err)
+			}
+		}
+	})
+
+	t.Run("no matches is an empty export", func(t *testing.T) {
+		h := newExportHandlers(t)
+		rec := export(h, "rig=rig-x")
+		if rec.Code != http.StatusOK || rec.Body.Len() != 0 {
+			t.Fatalf("expected an empty 200, got %d: %q", rec.Code, rec.Body.String())
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+			t.Errorf("expected application/x-ndjson, got %q", ct)
+		}
+	})
+
+	t.Run("first page failure is a JSON error", func(t *testing.T) {
+		store, err := events.NewStore(events.DefaultConfig())
+		if err != nil {
+			t.Fatalf("failed to create event store: %v", err)
+		}
+		store.Close()
+		rec := export(New(nil, store, nil, nil, nil, t.TempDir()), "")
+		if rec.Code != http.StatusInternalServerError {
+			t.Fatalf("expected 500, got %d: %s", rec.Code, rec.Body.String())
+		}
+		assertErrorCode(t, rec, ErrCodeInternal)
+		if rec.Header().Get("Content-Disposition") != "" {
+			t.Error("expected no attachment header on an error")
+		}
+	})
+
+	t.Run("bad since", func(t *testing.T) {
+		h := newExportHandlers(t)
+		rec := export(h, "since=yesterday")
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400, got %d: %s", rec.Code, rec.Body.String())
+		}
+		assertErrorCode(t, rec, ErrCodeValidationFailed)
+	})
+}
+
+func TestGetIssue_Expand(t *testing.T) {
+	townRoot := t.TempDir()
+	addTestRig(t, townRoot, "rig-a",
+		`INSERT INTO issues (id, title, status, issue_type) VALUES
+			('a-1', 'Blocked', 'open', 'task'),
+			('a-2', 'Blocker', 'closed', 'task'),
+			('a-3', 'Tracked', 'open', 'task'),
+			('c-1', 'Convoy', 'open', 'convoy')`,
+		`INSERT INTO dependencies (issue_id, depends_on_id, type) VALUES
+			('a-1', 'a-2', 'blocks'), ('c-1', 'a-2', 'tracks'), ('c-1', 'a-3', 'tracks')`,
+	)
+	collector, err := telemetry.NewSQLiteCollector(filepath.Join(t.TempDir(), "telemetry.db"))
+	if err != nil {
+		t.Fatalf("failed to create collector: %v", err)
+	}
+	t.Cleanup(func() { collector.Close() })
+	if err := collector.RecordTokenUsage(telemetry.TokenUsage{
+		AgentID: "rig-a/polecats/a1", BeadID: "a-1", Rig: "rig-a",
+		Timestamp: time.Now().UTC().Format(time.RFC3339), InputTokens: 100, OutputTokens: 50, Model: "claude-sonnet-4",
+	}); err != nil {
+		t.Fatalf("failed to record token usage: %v", err)
+	}
+	h := New(newTestManager(t, townRoot), nil, nil, nil, collector, townRoot)
+
+	get := func(issueID, expand string) *httptest.ResponseRecorder {
+		target := "/api/rigs/rig-a/issues/" + issueID
+		if expand != "" {
+			target += "?expand=" + expand
+		}
+		req := httptest.NewRequest("GET", target, nil)
+		req.SetPathValue("rigId", "rig-a")
+		req.SetPathValue("issueId", issueID)
+		rec := httptest.NewRecorder()
+		h.GetIssue(rec, req)
+		return rec
+	}
+	decode := func(t *testing.T, rec *httptest.ResponseRecorder) map[string]json.RawMessage {
+		t.Helper()
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var body map[string]json.RawMessage
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return body
+	}
+
+	t.Run("no expand returns the bare issue", func(t *testing.T) {
+		body := decode(t, get("a-1", ""))
+		for _, key := range []string{"dependency_links", "telemetry", "convoy", "convoy_eta"} {
+			if _, ok := body[key]; ok {
+				t.Errorf("expected no %s without expand, got %s", key, body[key])
+			}
+		}
+	})
+
+	t.Run("dependencies", func(t *testing.T) {
+		body := decode(t, get("a-1", "dependencies"))
+		var deps types.IssueDependencies
+		if err := json.Unmarshal(body["dependency_links"], &deps); err != nil {
+			t.Fatalf("failed to decode dependency_links %s: %v", body["dependency_links"], err)
+		}
+		if len(deps.Blockers) != 1 || deps.Blockers[0].ID != "a-2" {
+			t.Errorf("expected a-2 as the only blocker, got %+v", deps.Blockers)
+		}
+		if len(deps.BlockedBy) != 0 {
+			t.Errorf("expected nothing blocked by a-1, got %+v", deps.BlockedBy)
+		}
+	})
+
+	t.Run("convoy is skipped for non-convoys", func(t *testing.T) {
+		body := decode(t, get("a-1", "convoy"))
+		if _, ok := body["convoy"]; ok {
+			t.Errorf("expected no convoy for a task, got %s", body["convoy"])
+		}
+	})
+
+	t.Run("convoy", func(t *testing.T) {
+		body := decode(t, get("c-1", "convoy"))
+		var convoy types.ConvoyInfo
+		if err := json.Unmarshal(body["convoy"], &convoy); err != nil {
+			t.Fatalf("failed to decode convoy %s: %v", body["convoy"], err)
+		}
+		want := types.ConvoyProgress{Completed: 1, Total: 2, Percentage: 50}
+		if convoy.ID != "c-1" || convoy.Progress != want {
+			t.Errorf("expected c-1 at %+v, got %+v", want, convoy)
+		}
+	})
+
+	t.Run("telemetry", func(t *testing.T) {
+		body := decode(t, get("a-1", "telemetry"))
+		var bt telemetry.BeadTelemetry
+		if err := json.Unmarshal(body["telemetry"], &bt); err != nil {
+			t.Fatalf("failed to decode telemetry %s: %v", body["telemetry"], err)
+		}
+		if bt.BeadID != "a-1" || len(bt.TokenUsage) != 1 {
+			t.Errorf("expected a-1's one token usage record, got %+v", bt)
+		}
+	})
+
+	t.Run("several parts with blanks", func(t *testing.T) {
+		body := decode(t, get("a-1", "dependencies,,%20telemetry"))
+		for _, key := range []string{"dependency_links", "telemetry"} {
+			if _, ok := body[key]; !ok {
+				t.Errorf("expected %s with both parts expanded", key)
+			}
+		}
+	})
+
+	t.Run("unknown part", func(t *testing.T) {
+		rec := get("a-1", "dependencies,comments")
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400, got %d: %s", rec.Code, rec.Body.String())
+		}
+		assertErrorCode(t, rec, ErrCodeValidationFailed)
+	})
+
+	t.Run("unknown issue", func(t *testing.T) {
+		rec := get("a-9", "dependencies")
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("expected 404, got %d: %s", rec.Code, rec.Body.String())
+		}
+		assertErrorCode(t, rec, ErrCodeIssueNotFound)
+	})
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// errBDUnavailable is returned by runBD when the startup preflight found no usable bd binary.
+var errBDUnavailable = errors.New("bd is not available")
+
+// ToolStatus describes whether an external CLI the server shells out to is usable.
+type ToolStatus struct {
+	Name      string `json:"name"`
+	Path      string `json:"path"`
+	Available bool   `json:"available"`
+	Version   string `json:"version,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ToolAvailability records the result of the startup tooling preflight.
+type ToolAvailability struct {
+	BD ToolStatus `json:"bd"`
+	GT ToolStatus `json:"gt"`
+}
+
+// ReadyStatus is the response body for GET /readyz.
+type ReadyStatus struct {
+	Ready    bool             `json:"ready"`
+	ReadOnly bool             `json:"readonly"` // Writes rejected for maintenance; reads still served
+	Tools    ToolAvailability `json:"tools"`
+}
+
+// toolPath returns the binary path for a tool, honouring its *_PATH environment override.
+func toolPath(envVar, fallback string) string {
+	if p := os.Getenv(envVar); p != "" {
+		return p
+	}
+	return fallback
+}
+
+// checkTool runs "<path> --version" and reports whether the tool is usable.
+func checkTool(name, path string) ToolStatus {
+	status := ToolStatus{Name: name, Path: path}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, path, "--version")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		status.Error = err.Error()
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			status.Error += ": " + msg
+		}
+		return status
+	}
+
+	status.Available = true
+	status.Version = strings.TrimSpace(stdout.String())
+	return status
+}
+
+// Preflight checks that bd and gt are runnable (respecting BD_PATH/GT_PATH),
+// logs a warning for each missing tool, and records the result for /readyz
+// and for write endpoints that depend on bd.
+func (h *Handlers) Preflight() ToolAvailability {
+	tools := ToolAvailability{
+		BD: checkTool("bd", h.bdPath),
+		GT: checkTool("gt", toolPath("GT_PATH", "gt")),
+	}
+
+	for _, t := range []ToolStatus{tools.BD, tools.GT} {
+		if t.Available {
+			slog.Info("Found tool", "tool", t.Name, "path", t.Path, "version", t.Version)
+		} else {
+			slog.Warn("Tool not available, dependent endpoints will fail", "tool", t.Name, "path", t.Path, "error", t.Error)
+		}
+	}
+
+	h.tools = &tools
+	return tools
+}
+
+// bdAvailable reports whether bd can be used. Before the preflight has run, bd is assumed present.
+func (h *Handlers) bdAvailable() bool {
+	return h.tools == nil || h.tools.BD.Available
+}
+
+// Readyz handles GET /readyz
+func (h *Handlers) Readyz(w http.ResponseWriter, r *http.Request) {
+	status := ReadyStatus{Ready: true, ReadOnly: h.ReadOnly()}
+	if h.tools != nil {
+		status.Tools = *h.tools
+		status.Ready = h.tools.BD.Available && h.tools.GT.Available
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !status.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	writeJSON(w, status)
+}
+
+// writeBDError writes the error response for a failed bd write operation:
+// 503 when bd is unavailable, 500 otherwise.
+func writeBDError(w http.ResponseWriter, err error, message string) {
+	if errors.Is(err, errBDUnavailable) {
+		writeError(w, http.StatusServiceUnavailable, ErrCodeBDUnavailable, "bd is not available on this server")
+		return
+	}
+	writeError(w, http.StatusInternalServerError, ErrCodeBDCommandFailed, message)
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPreflight(t *testing.T) {
+	tests := []struct {
+		name       string
+		bd, gt     bool // whether each tool is installed
+		wantStatus int  // from /readyz
+	}{
+		{"both tools present", true, true, http.StatusOK},
+		{"bd missing", false, true, http.StatusServiceUnavailable},
+		{"gt missing", true, false, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, tool := range []struct {
+				name, env string
+				present   bool
+			}{{"bd", "BD_PATH", tt.bd}, {"gt", "GT_PATH", tt.gt}} {
+				path := filepath.Join(dir, tool.name)
+				if tool.present {
+					if err := os.WriteFile(path, []byte("#!/bin/sh\necho \""+tool.name+" 1.2.3\"\n"), 0755); err != nil {
+						t.Fatalf("failed to write %s stub: %v", tool.name, err)
+					}
+				}
+				t.Setenv(tool.env, path)
+			}
+			townRoot := t.TempDir()
+			addTestRig(t, townRoot, "rig-a")
+			h := New(newTestManager(t, townRoot), nil, nil, nil, nil, townRoot)
+
+			tools := h.Preflight()
+			if tools.BD.Available != tt.bd || tools.GT.Available != tt.gt {
+				t.Fatalf("expected bd %v and gt %v, got %+v", tt.bd, tt.gt, tools)
+			}
+			if tt.bd && tools.BD.Version != "bd 1.2.3" {
+				t.Errorf("expected bd's version, got %q", tools.BD.Version)
+			}
+			if !tt.bd && tools.BD.Error == "" {
+				t.Error("expected an error for the missing bd")
+			}
+
+			rec := httptest.NewRecorder()
+			h.Readyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected readyz %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			var ready ReadyStatus
+			if err := json.NewDecoder(rec.Body).Decode(&ready); err != nil {
+				t.Fatalf("failed to decode readyz: %v", err)
+			}
+			if ready.Ready != (tt.wantStatus == http.StatusOK) || ready.Tools != tools {
+				t.Errorf("expected readyz to report the preflight, got %+v", ready)
+			}
+
+			// Writes that need bd answer 503 without it
+			req := httptest.NewRequest(http.MethodPost, "/api/rigs/rig-a/issues", strings.NewReader(`{"title":"New"}`))
+			req.SetPathValue("rigId", "rig-a")
+			rec = httptest.NewRecorder()
+			h.CreateIssue(rec, req)
+			if tt.bd {
+				if rec.Code == http.StatusServiceUnavailable {
+					t.Errorf("expected bd to be tried, got %s", rec.Body.String())
+				}
+				return
+			}
+			if rec.Code != http.StatusServiceUnavailable {
+				t.Fatalf("expected 503 without bd, got %d: %s", rec.Code, rec.Body.String())
+			}
+			assertErrorCode(t, rec, ErrCodeBDUnavailable)
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+// readOnlyExempt lists non-GET routes still served in read-only mode: the mode
+// toggle itself and routes that only touch in-memory state while it is on.
+// Heartbeats skip recording token usage to telemetry in read-only mode, and
+// rediscovery only adds rigs and agents (it never removes a rig's history).
+var readOnlyExempt = map[string]bool{
+	"/api/admin/readonly":   true,
+	"/api/agents/heartbeat": true,
+	"/api/rigs/rediscover":  true,
+}
+
+// ReadOnlyMode is the request and response body for the read-only toggle.
+type ReadOnlyMode struct {
+	ReadOnly bool `json:"readonly"`
+}
+
+// SetReadOnly turns read-only maintenance mode on or off. While on, mutating
+// requests are rejected with 503 so the beads and telemetry databases can be
+// snapshotted without racing writers; reads are served as usual.
+func (h *Handlers) SetReadOnly(readOnly bool) {
+	h.readOnly.Store(readOnly)
+}
+
+// ReadOnly reports whether read-only maintenance mode is on.
+func (h *Handlers) ReadOnly() bool {
+	return h.readOnly.Load()
+}
+
+// ReadOnlyMiddleware rejects mutating requests with 503 while read-only mode
+// is on. GET, HEAD and OPTIONS requests and readOnlyExempt routes pass through.
+func (h *Handlers) ReadOnlyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			if h.ReadOnly() && !readOnlyExempt[r.URL.Path] {
+				writeError(w, http.StatusServiceUnavailable, ErrCodeReadOnly, "Server is in read-only maintenance mode; writes are disabled")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// GetReadOnly handles GET /api/admin/readonly
+func (h *Handlers) GetReadOnly(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, ReadOnlyMode{ReadOnly: h.ReadOnly()})
+}
+
+// SetReadOnlyMode handles PUT /api/admin/readonly
+// Toggles read-only maintenance mode at runtime. Requires the write token.
+func (h *Handlers) SetReadOnlyMode(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeWrite(w, r) {
+		return
+	}
+
+	var req ReadOnlyMode
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeValidationFailed, "Invalid request body")
+		return
+	}
+
+	h.SetReadOnly(req.ReadOnly)
+	slog.Warn("Read-only mode changed", "readonly", req.ReadOnly)
+
+	writeJSON(w, ReadOnlyMode{ReadOnly: h.ReadOnly()})
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReadOnlyMiddleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		readOnly bool
+		method   string
+		path     string
+		wantCode int
+	}{
+		{"write allowed when off", false, http.MethodPost, "/api/rigs/rig-a/issues", http.StatusOK},
+		{"write blocked", true, http.MethodPost, "/api/rigs/rig-a/issues", http.StatusServiceUnavailable},
+		{"delete blocked", true, http.MethodDelete, "/api/rigs/rig-a", http.StatusServiceUnavailable},
+		{"patch blocked", true, http.MethodPatch, "/api/rigs/rig-a/issues/a-1", http.StatusServiceUnavailable},
+		{"read served", true, http.MethodGet, "/api/rigs/rig-a/issues", http.StatusOK},
+		{"head served", true, http.MethodHead, "/api/rigs", http.StatusOK},
+		{"options served", true, http.MethodOptions, "/api/rigs/rig-a/issues", http.StatusOK},
+		{"toggle exempt", true, http.MethodPut, "/api/admin/readonly", http.StatusOK},
+		{"heartbeat exempt", true, http.MethodPost, "/api/agents/heartbeat", http.StatusOK},
+		{"rediscover exempt", true, http.MethodPost, "/api/rigs/rediscover", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New(nil, nil, nil, nil, nil, t.TempDir())
+			h.SetReadOnly(tt.readOnly)
+			served := false
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				served = true
+			})
+
+			rec := httptest.NewRecorder()
+			h.ReadOnlyMiddleware(next).ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("expected %d, got %d: %s", tt.wantCode, rec.Code, rec.Body.String())
+			}
+			if served != (tt.wantCode == http.StatusOK) {
+				t.Errorf("handler served = %v", served)
+			}
+			if tt.wantCode == http.StatusServiceUnavailable {
+				assertErrorCode(t, rec, ErrCodeReadOnly)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gastown/townview/internal/types"
+)
+
+// templateExt is the file extension of issue templates; the name is the file
+// name without it.
+const templateExt = ".md"
+
+// templateNamePattern keeps template names to plain file names.
+var templateNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// templateVarPattern matches a {{variable}} placeholder in a template.
+var templateVarPattern = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_]+)\s*\}\}`)
+
+// errTemplateNotFound is returned by loadTemplate for an unknown template.
+var errTemplateNotFound = errors.New("template not found")
+
+// SetTemplatesDir sets the directory issue templates are read from. An empty
+// dir disables templates. Call before serving requests.
+func (h *Handlers) SetTemplatesDir(dir string) {
+	h.templatesDir = dir
+}
+
+// loadTemplate reads a template by name along with the variables it uses.
+func (h *Handlers) loadTemplate(name string) (types.IssueTemplate, error) {
+	if h.templatesDir == "" || !templateNamePattern.MatchString(name) {
+		return types.IssueTemplate{}, errTemplateNotFound
+	}
+
+	data, err := os.ReadFile(filepath.Join(h.templatesDir, name+templateExt))
+	if errors.Is(err, os.ErrNotExist) {
+		return types.IssueTemplate{}, errTemplateNotFound
+	}
+	if err != nil {
+		return types.IssueTemplate{}, err
+	}
+
+	body := string(data)
+	return types.IssueTemplate{
+		Name:      name,
+		Variables: templateVariables(body),
+		Body:      body,
+	}, nil
+}
+
+// templateVariables lists the distinct variables a template body uses, in
+// order of first appearance.
+func templateVariables(body string) []string {
+	vars := []string{}
+	seen := map[string]bool{}
+	for _, m := range templateVarPattern.FindAllStringSubmatch(body, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			vars = append(vars, m[1])
+		}
+	}
+	return vars
+}
+
+// renderTemplate substitutes vars into a template body. Every variable the
+// template uses must be provided; extra ones are ignored.
+func renderTemplate(tmpl types.IssueTemplate, vars map[string]string) (string, error) {
+	var missing []string
+	for _, v := range tmpl.Variables {
+		if _, ok := vars[v]; !ok {
+			missing = append(missing, v)
+		}
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("template %q is missing variables: %s", tmpl.Name, strings.Join(missing, ", "))
+	}
+
+	return templateVarPattern.ReplaceAllStringFunc(tmpl.Body, func(match string) string {
+		return vars[templateVarPattern.FindStringSubmatch(match)[1]]
+	}), nil
+}
+
+// ListTemplates handles GET /api/templates
+// Returns the available issue templates and the variables each one expects.
+func (h *Handlers) ListTemplates(w http.ResponseWriter, r *http.Request) {
+	templates := []types.IssueTemplate{}
+	if h.templatesDir == "" {
+		writeJSON(w, templates)
+		return
+	}
+
+	entries, err := os.ReadDir(h.templatesDir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.Error("Failed to read templates directory", "dir", h.templatesDir, "error", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to list templates")
+		return
+	}
+
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), templateExt)
+		if entry.IsDir() || !ok {
+			continue
+		}
+		tmpl, err := h.loadTemplate(name)
+		if err != nil {
+			slog.Warn("Skipping unreadable template", "name", entry.Name(), "error", err)
+			continue
+		}
+		templates = append(templates, tmpl)
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].Name < templates[j].Name
+	})
+
+	writeJSON(w, templates)
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gastown/townview/internal/types"
+)
+
+func TestTemplateVariables(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"none", "Plain description", []string{}},
+		{"single", "Fix {{component}}", []string{"component"}},
+		{"first appearance order", "{{b}} then {{a}} then {{b}}", []string{"b", "a"}},
+		{"inner spaces", "{{ owner }} and {{owner}}", []string{"owner"}},
+		{"not a variable", "{{two words}} and {single}", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := templateVariables(tt.body); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("templateVariables(%q) = %v, want %v", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		vars    map[string]string
+		want    string
+		wantErr bool
+	}{
+		{"substitutes", "Fix {{component}} for {{ owner }}", map[string]string{"component": "api", "owner": "jeremy"}, "Fix api for jeremy", false},
+		{"repeated", "{{x}}-{{x}}", map[string]string{"x": "1"}, "1-1", false},
+		{"extra variables ignored", "Fix {{component}}", map[string]string{"component": "api", "unused": "x"}, "Fix api", false},
+		{"empty value", "Fix {{component}}", map[string]string{"component": ""}, "Fix ", false},
+		{"no variables", "Plain", nil, "Plain", false},
+		{"missing variable", "Fix {{component}} for {{owner}}", map[string]string{"component": "api"}, "", true},
+		{"unknown variable only", "Fix {{component}}", map[string]string{"other": "x"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl := types.IssueTemplate{Name: "bug", Variables: templateVariables(tt.body), Body: tt.body}
+			got, err := renderTemplate(tmpl, tt.vars)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("renderTemplate error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("renderTemplate = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateIssue_Templates(t *testing.T) {
+	townRoot := t.TempDir()
+	addTestRig(t, townRoot, "rig-a", `INSERT INTO issues (id, title) VALUES ('a-1', 'Created')`)
+	// A template-shaped file outside the templates dir must stay unreachable
+	dataDir := t.TempDir()
+	templatesDir := filepath.Join(dataDir, "templates")
+	if err := os.MkdirAll(templatesDir, 0755); err != nil {
+		t.Fatalf("failed to create templates dir: %v", err)
+	}
+	for path, body := range map[string]string{
+		filepath.Join(templatesDir, "bug.md"): "Fix {{component}} for {{owner}}",
+		filepath.Join(dataDir, "secret.md"):   "Not a template",
+	} {
+		if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		template   string
+		body       string
+		wantStatus int
+		wantCode   string
+		wantArgs   string // expected in the bd create call, if one is made
+	}{
+		{
+			name:       "renders the description",
+			template:   "bug",
+			body:       `{"title":"Broken","variables":{"component":"api","owner":"jeremy"}}`,
+			wantStatus: http.StatusCreated,
+			wantArgs:   "--description Fix api for jeremy",
+		},
+		{name: "unknown template", template: "nope", body: `{"title":"Broken"}`, wantStatus: http.StatusNotFound, wantCode: ErrCodeNotFound},
+		{name: "missing variable", template: "bug", body: `{"title":"Broken","variables":{"component":"api"}}`, wantStatus: http.StatusBadRequest, wantCode: ErrCodeValidationFailed},
+		{name: "path traversal", template: "../secret", body: `{"title":"Broken"}`, wantStatus: http.StatusNotFound, wantCode: ErrCodeNotFound},
+		{name: "absolute path", template: filepath.Join(dataDir, "secret"), body: `{"title":"Broken"}`, wantStatus: http.StatusNotFound, wantCode: ErrCodeNotFound},
+		{name: "with a description", template: "bug", body: `{"title":"Broken","description":"mine"}`, wantStatus: http.StatusBadRequest, wantCode: ErrCodeValidationFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logPath := stubBD(t, `echo '{"id":"a-1"}'`)
+			h := New(newTestManager(t, townRoot), nil, nil, nil, nil, townRoot)
+			h.SetTemplatesDir(templatesDir)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/rigs/rig-a/issues?template="+url.QueryEscape(tt.template), strings.NewReader(tt.body))
+			req.SetPathValue("rigId", "rig-a")
+			rec := httptest.NewRecorder()
+
+			h.CreateIssue(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatus, rec.Code, rec.Body.String())
+			}
+			calls := bdCalls(t, logPath)
+			if tt.wantCode != "" {
+				assertErrorCode(t, rec, tt.wantCode)
+				if calls[0] != "" {
+					t.Errorf("expected no bd call, got %v", calls)
+				}
+				return
+			}
+			if len(calls) != 1 || !strings.Contains(calls[0], tt.wantArgs) {
+				t.Errorf("expected one bd create with %q, got %v", tt.wantArgs, calls)
+			}
+		})
+	}
+}
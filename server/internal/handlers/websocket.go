@@ -1,10 +1,13 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 	"log/slog"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/gastown/townview/internal/events"
 	"github.com/gastown/townview/internal/mail"
@@ -36,6 +39,13 @@ type Snapshot struct {
 	CacheStats query.CacheStats      `json:"cache_stats"`
 }
 
+// BeadsChanged is the payload of a beads_changed message, sent after any bead
+// event in a rig so boards can update their column counts without a refetch.
+type BeadsChanged struct {
+	Rig          string         `json:"rig"`
+	StatusCounts map[string]int `json:"status_counts"`
+}
+
 // WebSocketHandler handles WebSocket connections.
 type WebSocketHandler struct {
 	hub           *websocket.Hub
@@ -43,8 +53,19 @@ type WebSocketHandler struct {
 	eventStore    *events.Store
 	agentRegistry *registry.Registry
 	mailClient    *mail.Client
+	upgrader      gorillaws.Upgrader
+
+	// beads_changed is published at most once per rig per beadsChangedWindow;
+	// countIssues defaults to the rig manager's uncached GROUP BY
+	countIssues        func(rigID string) (map[string]int, error)
+	beadsChangedMu     sync.Mutex
+	beadsChangedQueued map[string]bool
 }
 
+// beadsChangedWindow is how long bead events for a rig are gathered before one
+// beads_changed message is published for all of them.
+const beadsChangedWindow = 250 * time.Millisecond
+
 // NewWebSocketHandler creates a new WebSocketHandler.
 func NewWebSocketHandler(rigManager *rigmanager.Manager, eventStore *events.Store, agentRegistry *registry.Registry, mailClient *mail.Client) *WebSocketHandler {
 	h := &WebSocketHandler{
@@ -52,19 +73,42 @@ func NewWebSocketHandler(rigManager *rigmanager.Manager, eventStore *events.Stor
 		eventStore:    eventStore,
 		agentRegistry: agentRegistry,
 		mailClient:    mailClient,
+		upgrader:      upgrader,
+
+		beadsChangedQueued: make(map[string]bool),
+	}
+	if rigManager != nil {
+		h.countIssues = rigManager.CountIssuesByStatus
 	}
 	h.hub = websocket.NewHub(h.buildSnapshot)
+	h.hub.SetInitialProvider(h.buildAgentSnapshot)
+	h.hub.SetRigValidator(h.rigExists)
 	return h
 }
 
+// rigExists reports whether the rig manager knows a rig.
+func (h *WebSocketHandler) rigExists(rig string) bool {
+	if h.rigManager == nil {
+		return true
+	}
+	_, err := h.rigManager.GetRig(rig)
+	return err == nil
+}
+
 // Hub returns the WebSocket hub.
 func (h *WebSocketHandler) Hub() *websocket.Hub {
 	return h.hub
 }
 
+// SetCompression toggles permessage-deflate negotiation for new connections.
+// Compression is only used with clients that offer the extension.
+func (h *WebSocketHandler) SetCompression(enabled bool) {
+	h.upgrader.EnableCompression = enabled
+}
+
 // ServeHTTP handles WebSocket upgrade requests.
 func (h *WebSocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+	conn, err := h.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		slog.Error("Failed to upgrade WebSocket connection", "error", err)
 		return
@@ -78,6 +122,105 @@ func (h *WebSocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	go client.ReadPump()
 }
 
+// StartEventBridge forwards every event from the event store to WebSocket
+// clients as a message tagged with the event's rig, so clients subscribed to
+// specific rigs only see theirs. Call the returned function to stop.
+func (h *WebSocketHandler) StartEventBridge() (stop func()) {
+	if h.eventStore == nil {
+		return func() {}
+	}
+
+	ch := h.eventStore.Subscribe(events.EventFilter{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for event := range ch {
+			message, err := json.Marshal(types.WSMessage{
+				Type:    event.Type,
+				Rig:     event.Rig,
+				Payload: event,
+			})
+			if err != nil {
+				slog.Error("Failed to encode event for WebSocket", "type", event.Type, "error", err)
+				continue
+			}
+			h.hub.Publish(event.Rig, message)
+
+			if strings.HasPrefix(event.Type, "bead.") && event.Rig != "" {
+				h.queueBeadsChanged(event.Rig)
+			}
+		}
+	}()
+
+	return func() {
+		h.eventStore.Unsubscribe(ch)
+		<-done
+	}
+}
+
+// queueBeadsChanged schedules a beads_changed message for a rig unless one is
+// already pending, so a burst of bead events costs one count, run off the
+// event bridge goroutine.
+func (h *WebSocketHandler) queueBeadsChanged(rigID string) {
+	if h.countIssues == nil {
+		return
+	}
+
+	h.beadsChangedMu.Lock()
+	defer h.beadsChangedMu.Unlock()
+	if h.beadsChangedQueued[rigID] {
+		return
+	}
+	h.beadsChangedQueued[rigID] = true
+
+	time.AfterFunc(beadsChangedWindow, func() {
+		// Dequeue first so events arriving during the count schedule another
+		h.beadsChangedMu.Lock()
+		delete(h.beadsChangedQueued, rigID)
+		h.beadsChangedMu.Unlock()
+		h.publishBeadsChanged(rigID)
+	})
+}
+
+// publishBeadsChanged sends a rig's current per-status issue counts to the
+// clients subscribed to it.
+func (h *WebSocketHandler) publishBeadsChanged(rigID string) {
+	counts, err := h.countIssues(rigID)
+	if err != nil {
+		slog.Debug("Failed to count issues for beads_changed", "rig", rigID, "error", err)
+		return
+	}
+
+	message, err := json.Marshal(types.WSMessage{
+		Type:    "beads_changed",
+		Rig:     rigID,
+		Payload: BeadsChanged{Rig: rigID, StatusCounts: counts},
+	})
+	if err != nil {
+		slog.Error("Failed to encode beads_changed for WebSocket", "rig", rigID, "error", err)
+		return
+	}
+	h.hub.Publish(rigID, message)
+}
+
+// buildAgentSnapshot creates the first frame sent to a new client: the current
+// agent states, so the client has a starting point before any update arrives.
+func (h *WebSocketHandler) buildAgentSnapshot() ([]byte, error) {
+	return json.Marshal(types.WSMessage{
+		Type:    "agent_snapshot",
+		Payload: h.listAgents(),
+	})
+}
+
+// listAgents converts the registry's agents to their API representation.
+func (h *WebSocketHandler) listAgents() []types.Agent {
+	agents := []types.Agent{}
+	for _, agent := range h.agentRegistry.ListAgents(nil) {
+		agents = append(agents, toAPIAgent(agent))
+	}
+	return agents
+}
+
 // buildSnapshot creates a full data snapshot for broadcasting.
 func (h *WebSocketHandler) buildSnapshot() ([]byte, error) {
 	// Get cache stats from townview rig's query service
@@ -100,7 +243,7 @@ func (h *WebSocketHandler) buildSnapshot() ([]byte, error) {
 	snapshot.Rigs = h.rigManager.ListRigs()
 
 	// Get all issues from all rigs
-	issues := h.rigManager.ListAllIssues(query.IssueFilter{})
+	issues := h.rigManager.ListAllIssues(snapshotIssueFilter())
 
 	// Enrich convoy-type issues with progress data and dependencies
 	for i, issue := range issues {
@@ -125,21 +268,7 @@ func (h *WebSocketHandler) buildSnapshot() ([]byte, error) {
 	snapshot.Issues = issues
 
 	// Get all agents from Agent Registry
-	agents := h.agentRegistry.ListAgents(nil)
-	for _, agent := range agents {
-		a := types.Agent{
-			ID:        agent.ID,
-			Name:      agent.Name,
-			RoleType:  string(agent.Role),
-			Rig:       agent.Rig,
-			State:     string(agent.Status),
-			UpdatedAt: agent.LastHeartbeat,
-		}
-		if agent.CurrentBead != nil {
-			a.HookBead = *agent.CurrentBead
-		}
-		snapshot.Agents = append(snapshot.Agents, a)
-	}
+	snapshot.Agents = h.listAgents()
 
 	// Get recent activity from Event Store
 	activityEvents, err := h.eventStore.Query(events.EventFilter{
@@ -149,29 +278,13 @@ func (h *WebSocketHandler) buildSnapshot() ([]byte, error) {
 		slog.Debug("Failed to get activity for snapshot", "error", err)
 	} else {
 		for _, evt := range activityEvents {
-			// Parse payload for additional fields
-			var payload map[string]interface{}
-			if len(evt.Payload) > 0 {
-				json.Unmarshal(evt.Payload, &payload)
-			}
-
-			issueID, _ := payload["issue_id"].(string)
-			title, _ := payload["title"].(string)
-
-			snapshot.Activity = append(snapshot.Activity, types.ActivityEvent{
-				ID:        fmt.Sprintf("%d", evt.ID),
-				IssueID:   issueID,
-				EventType: evt.Type,
-				Title:     title,
-				Actor:     evt.Source,
-				Timestamp: evt.Timestamp,
-			})
+			snapshot.Activity = append(snapshot.Activity, toActivityEvent(evt))
 		}
 	}
 
-	// Get mail (town-level)
+	// Get mail (town-level); snapshots aren't tied to a request
 	opts := mail.ListMailOptions{Limit: 20}
-	messages, err := h.mailClient.ListMail("", opts)
+	messages, err := h.mailClient.ListMail(context.Background(), "", opts)
 	if err != nil {
 		slog.Debug("Failed to get mail for snapshot", "error", err)
 	} else {
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gastown/townview/internal/events"
+	"github.com/gastown/townview/internal/mail"
+	"github.com/gastown/townview/internal/registry"
+	gorillaws "github.com/gorilla/websocket"
+)
+
+func TestWebSocketHandler_QueueBeadsChanged_CoalescesPerRig(t *testing.T) {
+	h := NewWebSocketHandler(nil, nil, nil, nil)
+
+	var mu sync.Mutex
+	counted := map[string]int{}
+	h.countIssues = func(rigID string) (map[string]int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		counted[rigID]++
+		return map[string]int{"open": 1}, nil
+	}
+
+	// A burst of bead events in two rigs costs one count per rig
+	for i := 0; i < 100; i++ {
+		h.queueBeadsChanged("rig-a")
+		h.queueBeadsChanged("rig-b")
+	}
+	time.Sleep(3 * beadsChangedWindow)
+
+	mu.Lock()
+	if counted["rig-a"] != 1 || counted["rig-b"] != 1 {
+		t.Errorf("expected one count per rig, got %v", counted)
+	}
+	mu.Unlock()
+
+	// Once published, the next event schedules another
+	h.queueBeadsChanged("rig-a")
+	time.Sleep(3 * beadsChangedWindow)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if counted["rig-a"] != 2 {
+		t.Errorf("expected a second count for rig-a, got %d", counted["rig-a"])
+	}
+}
+
+func TestWebSocketHandler_RigExists(t *testing.T) {
+	h := NewWebSocketHandler(newTestManager(t, newTestTown(t)), nil, nil, nil)
+
+	if !h.rigExists("rig-a") {
+		t.Error("expected rig-a to be subscribable")
+	}
+	if h.rigExists("rig-x") {
+		t.Error("expected an unknown rig to be rejected")
+	}
+}
+
+func TestWebSocketHandler_EventBridgeTagsRig(t *testing.T) {
+	townRoot := t.TempDir()
+	addTestRig(t, townRoot, "rig-a", `INSERT INTO issues (id, title) VALUES ('rig-a-1', 'Updated')`)
+	addTestRig(t, townRoot, "rig-b")
+	gtPath := filepath.Join(t.TempDir(), "gt")
+	if err := os.WriteFile(gtPath, []byte("#!/bin/sh\necho '[]'\n"), 0755); err != nil {
+		t.Fatalf("failed to write gt stub: %v", err)
+	}
+	t.Setenv("GT_PATH", gtPath)
+	store, err := events.NewStore(events.DefaultConfig())
+	if err != nil {
+		t.Fatalf("failed to create event store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	reg := registry.NewWithDefaults()
+	t.Cleanup(reg.Stop)
+
+	h := NewWebSocketHandler(newTestManager(t, townRoot), store, reg, mail.NewClient(townRoot))
+	go h.Hub().Run()
+	stop := h.StartEventBridge()
+	t.Cleanup(stop)
+
+	server := httptest.NewServer(h)
+	t.Cleanup(server.Close)
+	conn, _, err := gorillaws.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	// The write pump batches queued messages into one frame, newline-separated
+	type message struct {
+		Type    string          `json:"type"`
+		Rig     string          `json:"rig"`
+		Payload json.RawMessage `json:"payload"`
+	}
+	var pending []message
+	next := func(t *testing.T, skip ...string) message {
+		t.Helper()
+		for {
+			for len(pending) > 0 {
+				msg := pending[0]
+				pending = pending[1:]
+				skipped := false
+				for _, typ := range skip {
+					skipped = skipped || msg.Type == typ
+				}
+				if !skipped {
+					return msg
+				}
+			}
+			conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				t.Fatalf("failed to read message: %v", err)
+			}
+			for _, line := range strings.Split(string(data), "\n") {
+				var msg message
+				if err := json.Unmarshal([]byte(line), &msg); err != nil {
+					t.Fatalf("failed to decode message %q: %v", line, err)
+				}
+				pending = append(pending, msg)
+			}
+		}
+	}
+
+	if err := conn.WriteMessage(gorillaws.TextMessage, []byte(`{"type":"subscribe","rig":"rig-a"}`)); err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	if msg := next(t, "agent_snapshot", "snapshot"); msg.Type != "subscribed" {
+		t.Fatalf("expected a subscribe ack, got %+v", msg)
+	}
+
+	for _, rig := range []string{"rig-b", "rig-a"} {
+		if err := store.Emit("bead.updated", "test", rig, map[string]string{"issue_id": rig + "-1"}); err != nil {
+			t.Fatalf("Emit failed: %v", err)
+		}
+	}
+
+	// rig-b's event and its beads_changed are filtered out by the subscription
+	msg := next(t, "snapshot")
+	if msg.Type != "bead.updated" || msg.Rig != "rig-a" {
+		t.Fatalf("expected rig-a's bead.updated, got %+v", msg)
+	}
+	var event events.Event
+	if err := json.Unmarshal(msg.Payload, &event); err != nil {
+		t.Fatalf("failed to decode event payload %s: %v", msg.Payload, err)
+	}
+	if event.Rig != "rig-a" || !strings.Contains(string(event.Payload), "rig-a-1") {
+		t.Errorf("expected rig-a's event as the payload, got %+v", event)
+	}
+
+	msg = next(t, "snapshot")
+	var changed BeadsChanged
+	if err := json.Unmarshal(msg.Payload, &changed); err != nil {
+		t.Fatalf("failed to decode beads_changed payload %s: %v", msg.Payload, err)
+	}
+	if msg.Type != "beads_changed" || msg.Rig != "rig-a" || changed.StatusCounts["open"] != 1 {
+		t.Errorf("expected rig-a's beads_changed with one open issue, got %+v", msg)
+	}
+}
@@ -3,6 +3,7 @@ package mail
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"log/slog"
@@ -10,6 +11,7 @@ import (
 	"os/exec"
 	"path/filepath"
 
+	"github.com/gastown/townview/internal/execlimit"
 	"github.com/gastown/townview/internal/types"
 )
 
@@ -17,6 +19,7 @@ import (
 type Client struct {
 	townRoot string
 	gtPath   string
+	limiter  *execlimit.Limiter // nil runs gt without a concurrency cap
 }
 
 // NewClient creates a new mail client.
@@ -31,6 +34,12 @@ func NewClient(townRoot string) *Client {
 	}
 }
 
+// SetExecLimiter bounds gt invocations by a limiter shared with the rest of
+// the server. Call before serving requests.
+func (c *Client) SetExecLimiter(limiter *execlimit.Limiter) {
+	c.limiter = limiter
+}
+
 // ListMailOptions configures mail listing.
 type ListMailOptions struct {
 	Limit      int
@@ -40,7 +49,20 @@ type ListMailOptions struct {
 }
 
 // ListMail returns mail messages from an inbox.
-func (c *Client) ListMail(rigPath string, opts ListMailOptions) ([]types.Mail, error) {
+func (c *Client) ListMail(ctx context.Context, rigPath string, opts ListMailOptions) ([]types.Mail, error) {
+	messages, _, err := c.ListMailPage(ctx, rigPath, opts)
+	return messages, err
+}
+
+// CountMail returns the total number of messages in an inbox, ignoring limit and offset.
+func (c *Client) CountMail(ctx context.Context, rigPath string, opts ListMailOptions) (int, error) {
+	_, total, err := c.ListMailPage(ctx, rigPath, opts)
+	return total, err
+}
+
+// ListMailPage returns one page of mail messages along with the total number of
+// messages in the inbox before limit and offset were applied.
+func (c *Client) ListMailPage(ctx context.Context, rigPath string, opts ListMailOptions) ([]types.Mail, int, error) {
 	args := []string{"mail", "inbox", "--json"}
 
 	if opts.UnreadOnly {
@@ -51,25 +73,26 @@ func (c *Client) ListMail(rigPath string, opts ListMailOptions) ([]types.Mail, e
 		args = append(args, opts.Address)
 	}
 
-	output, err := c.runGT(rigPath, args...)
+	output, err := c.runGT(ctx, rigPath, args...)
 	if err != nil {
-		return nil, fmt.Errorf("gt mail inbox failed: %w", err)
+		return nil, 0, fmt.Errorf("gt mail inbox failed: %w", err)
 	}
 
 	// Handle null response (empty inbox)
 	if string(bytes.TrimSpace(output)) == "null" {
-		return []types.Mail{}, nil
+		return []types.Mail{}, 0, nil
 	}
 
 	var messages []types.Mail
 	if err := json.Unmarshal(output, &messages); err != nil {
-		return nil, fmt.Errorf("failed to parse mail: %w", err)
+		return nil, 0, fmt.Errorf("failed to parse mail: %w", err)
 	}
+	total := len(messages)
 
 	// Apply offset and limit
 	if opts.Offset > 0 {
 		if opts.Offset >= len(messages) {
-			return []types.Mail{}, nil
+			return []types.Mail{}, total, nil
 		}
 		messages = messages[opts.Offset:]
 	}
@@ -78,14 +101,14 @@ func (c *Client) ListMail(rigPath string, opts ListMailOptions) ([]types.Mail, e
 		messages = messages[:opts.Limit]
 	}
 
-	return messages, nil
+	return messages, total, nil
 }
 
 // GetMail returns a single mail message by ID.
-func (c *Client) GetMail(rigPath, mailID string) (*types.Mail, error) {
+func (c *Client) GetMail(ctx context.Context, rigPath, mailID string) (*types.Mail, error) {
 	args := []string{"mail", "read", mailID, "--json"}
 
-	output, err := c.runGT(rigPath, args...)
+	output, err := c.runGT(ctx, rigPath, args...)
 	if err != nil {
 		return nil, fmt.Errorf("gt mail read failed: %w", err)
 	}
@@ -98,9 +121,10 @@ func (c *Client) GetMail(rigPath, mailID string) (*types.Mail, error) {
 	return &message, nil
 }
 
-// runGT executes a gt command in the given rig path.
-func (c *Client) runGT(rigPath string, args ...string) ([]byte, error) {
-	cmd := exec.Command(c.gtPath, args...)
+// runGT executes a gt command in the given rig path. Cancelling ctx, e.g. when
+// the request it serves goes away, stops waiting for a limiter slot and kills gt.
+func (c *Client) runGT(ctx context.Context, rigPath string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, c.gtPath, args...)
 	if rigPath != "" {
 		cmd.Dir = filepath.Join(c.townRoot, rigPath)
 	} else {
@@ -113,7 +137,7 @@ func (c *Client) runGT(rigPath string, args ...string) ([]byte, error) {
 
 	slog.Debug("Running gt command", "args", args, "dir", cmd.Dir)
 
-	if err := cmd.Run(); err != nil {
+	if err := c.limiter.Run(ctx, cmd); err != nil {
 		slog.Error("gt command failed", "args", args, "stderr", stderr.String(), "error", err)
 		return nil, fmt.Errorf("%s: %s", err, stderr.String())
 	}
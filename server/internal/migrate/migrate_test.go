@@ -0,0 +1,113 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "migrate.db"))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestRun_AppliesPendingMigrationsOnce(t *testing.T) {
+	db := openTestDB(t)
+
+	var calls []int
+	step := func(version int, script string) Migration {
+		return Migration{Version: version, Name: "step", Up: func(tx *sql.Tx) error {
+			calls = append(calls, version)
+			_, err := tx.Exec(script)
+			return err
+		}}
+	}
+
+	// Deliberately out of order; Run sorts by version
+	migrations := []Migration{
+		step(2, "ALTER TABLE widgets ADD COLUMN color TEXT"),
+		step(1, "CREATE TABLE widgets (id INTEGER PRIMARY KEY)"),
+	}
+	if err := Run(db, migrations); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(calls) != 2 || calls[0] != 1 || calls[1] != 2 {
+		t.Fatalf("expected migrations 1 then 2, got %v", calls)
+	}
+
+	exists, err := ColumnExists(db, "widgets", "color")
+	if err != nil || !exists {
+		t.Errorf("expected widgets.color to exist, got %v (err %v)", exists, err)
+	}
+
+	// Re-running applies only the new migration
+	calls = nil
+	migrations = append(migrations, step(3, "ALTER TABLE widgets ADD COLUMN size INTEGER"))
+	if err := Run(db, migrations); err != nil {
+		t.Fatalf("second Run failed: %v", err)
+	}
+	if len(calls) != 1 || calls[0] != 3 {
+		t.Errorf("expected only migration 3 to run, got %v", calls)
+	}
+
+	version, err := Version(db)
+	if err != nil {
+		t.Fatalf("Version failed: %v", err)
+	}
+	if version != 3 {
+		t.Errorf("expected version 3, got %d", version)
+	}
+}
+
+func TestRun_FailedMigrationRollsBack(t *testing.T) {
+	db := openTestDB(t)
+
+	migrations := []Migration{
+		{Version: 1, Name: "create", Up: func(tx *sql.Tx) error {
+			_, err := tx.Exec("CREATE TABLE widgets (id INTEGER PRIMARY KEY)")
+			return err
+		}},
+		{Version: 2, Name: "broken", Up: func(tx *sql.Tx) error {
+			if _, err := tx.Exec("ALTER TABLE widgets ADD COLUMN color TEXT"); err != nil {
+				return err
+			}
+			return errors.New("boom")
+		}},
+	}
+	if err := Run(db, migrations); err == nil {
+		t.Fatal("expected Run to fail")
+	}
+
+	version, err := Version(db)
+	if err != nil {
+		t.Fatalf("Version failed: %v", err)
+	}
+	if version != 1 {
+		t.Errorf("expected version 1 after failed migration, got %d", version)
+	}
+	exists, err := ColumnExists(db, "widgets", "color")
+	if err != nil {
+		t.Fatalf("ColumnExists failed: %v", err)
+	}
+	if exists {
+		t.Error("expected failed migration's column change to be rolled back")
+	}
+}
+
+func TestRun_RejectsDuplicateVersions(t *testing.T) {
+	db := openTestDB(t)
+
+	noop := func(tx *sql.Tx) error { return nil }
+	err := Run(db, []Migration{{Version: 1, Name: "a", Up: noop}, {Version: 1, Name: "b", Up: noop}})
+	if err == nil {
+		t.Fatal("expected duplicate versions to be rejected")
+	}
+}
@@ -0,0 +1,54 @@
+package query
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// busyTimeoutMS is how long SQLite itself waits on a lock before returning
+// SQLITE_BUSY; busyRetries and busyBackoff cover what slips past it.
+const (
+	busyTimeoutMS = 2000
+	busyRetries   = 3
+	busyBackoff   = 50 * time.Millisecond
+)
+
+// isBusyError reports whether err is a transient SQLITE_BUSY or SQLITE_LOCKED
+// error, as happens while a bd writer holds the database.
+func isBusyError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "database is locked") || strings.Contains(msg, "database table is locked")
+}
+
+// withBusyRetry runs fn, retrying with linear backoff while it fails with a
+// busy/locked error. fn must be safe to run again from the start.
+func withBusyRetry(fn func() error) error {
+	return withStreamRetry(func(*bool) error { return fn() })
+}
+
+// withStreamRetry is withBusyRetry for a stream that hands out results as it
+// reads them. stream sets *started before handing out its first result; a busy
+// error after that is returned as is, since a restart would repeat results.
+func withStreamRetry(stream func(started *bool) error) error {
+	started := false
+	var err error
+	for attempt := 0; attempt <= busyRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * busyBackoff)
+		}
+		if err = stream(&started); started || !isBusyError(err) {
+			return err
+		}
+	}
+	return err
+}
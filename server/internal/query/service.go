@@ -4,8 +4,10 @@ package query
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log/slog"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -25,17 +27,24 @@ type CacheConfig struct {
 	IssuesTTL         time.Duration
 	DependenciesTTL   time.Duration
 	ActivityTTL       time.Duration
+
+	// InvalidationWindow coalesces event-driven cache clears: the first event
+	// clears at once, later ones within the window share a single clear at its
+	// end. Zero clears on every event.
+	InvalidationWindow time.Duration
 }
 
 // DefaultCacheConfig returns the default cache configuration per ADR-013.
 func DefaultCacheConfig() CacheConfig {
 	return CacheConfig{
-		RigsTTL:           60 * time.Second,  // 1 minute
-		AgentsTTL:         5 * time.Second,   // 5 seconds (live data)
-		ConvoyProgressTTL: 10 * time.Second,  // 10 seconds
-		IssuesTTL:         30 * time.Second,  // 30 seconds
-		DependenciesTTL:   60 * time.Second,  // 1 minute
-		ActivityTTL:       5 * time.Minute,   // 5 minutes
+		RigsTTL:           60 * time.Second, // 1 minute
+		AgentsTTL:         5 * time.Second,  // 5 seconds (live data)
+		ConvoyProgressTTL: 10 * time.Second, // 10 seconds
+		IssuesTTL:         30 * time.Second, // 30 seconds
+		DependenciesTTL:   60 * time.Second, // 1 minute
+		ActivityTTL:       5 * time.Minute,  // 5 minutes
+
+		InvalidationWindow: 100 * time.Millisecond,
 	}
 }
 
@@ -43,8 +52,19 @@ func DefaultCacheConfig() CacheConfig {
 type Config struct {
 	DBPath      string      // Path to beads SQLite database
 	CacheConfig CacheConfig // Cache TTL settings
+
+	// ServeStaleOnError returns the last good result (with an ErrStale-wrapped
+	// error) when a database query fails, instead of failing outright.
+	ServeStaleOnError bool
 }
 
+// ErrStale is wrapped by errors returned alongside stale data when
+// ServeStaleOnError is enabled. The accompanying value is usable.
+var ErrStale = errors.New("serving stale cached data")
+
+// ErrIssueNotFound is wrapped by errors for a missing issue.
+var ErrIssueNotFound = errors.New("issue not found")
+
 // DefaultConfig returns a default service configuration.
 func DefaultConfig() Config {
 	return Config{
@@ -65,10 +85,18 @@ type IssueFilter struct {
 	Status   []string // Filter by status (any match)
 	Type     []string // Filter by type (any match)
 	Assignee string   // Filter by assignee
+	Owner    string   // Filter by owner (who is accountable)
 	Parent   string   // Filter by parent ID
 	Convoy   string   // Filter by convoy ID
+	Blocked  *bool    // Filter by computed blocked state
 	Limit    int      // Maximum results (0 for no limit)
 	Offset   int      // Skip first N results
+
+	IncludeDescription bool // Return descriptions; left empty otherwise to keep lists small
+
+	ClosedSince   *time.Time // Only issues closed at or after this time
+	ClosedUntil   *time.Time // Only issues closed before this time
+	OrderByClosed bool       // Order by closed_at, most recent first
 }
 
 // ConvoyFilter defines query parameters for filtering convoys.
@@ -90,9 +118,42 @@ type ActivityFilter struct {
 
 // DependencyNode represents a node in a dependency graph.
 type DependencyNode struct {
-	Issue    types.Issue      `json:"issue"`
-	Children []DependencyNode `json:"children,omitempty"`
-	Depth    int              `json:"depth"`
+	Issue        types.Issue      `json:"issue"`
+	Children     []DependencyNode `json:"children,omitempty"`
+	Depth        int              `json:"depth"`
+	MoreChildren int              `json:"more_children,omitempty"` // Children left out by MaxChildren
+}
+
+// GraphOptions bounds the size of a dependency graph.
+type GraphOptions struct {
+	MaxDepth    int // Levels below the root to expand
+	MaxChildren int // Children expanded per node; 0 for no limit
+}
+
+// DefaultGraphOptions returns the default dependency graph bounds.
+func DefaultGraphOptions() GraphOptions {
+	return GraphOptions{
+		MaxDepth:    10,
+		MaxChildren: 100,
+	}
+}
+
+// Server-side caps on caller-supplied graph bounds.
+const (
+	MaxGraphDepth    = 50
+	MaxGraphChildren = 1000
+)
+
+// Clamped returns the options capped to MaxGraphDepth and MaxGraphChildren,
+// with an unlimited MaxChildren (0) capped too.
+func (o GraphOptions) Clamped() GraphOptions {
+	if o.MaxDepth > MaxGraphDepth {
+		o.MaxDepth = MaxGraphDepth
+	}
+	if o.MaxChildren <= 0 || o.MaxChildren > MaxGraphChildren {
+		o.MaxChildren = MaxGraphChildren
+	}
+	return o
 }
 
 // DependencyGraph represents a full dependency graph from a root.
@@ -103,12 +164,12 @@ type DependencyGraph struct {
 
 // RigSummary provides aggregate statistics for a rig.
 type RigSummary struct {
-	Rig         types.Rig              `json:"rig"`
-	IssueCount  int                    `json:"issue_count"`
-	OpenCount   int                    `json:"open_count"`
-	ByStatus    map[string]int         `json:"by_status"`
-	ByType      map[string]int         `json:"by_type"`
-	AgentStates []registry.AgentState  `json:"agent_states"`
+	Rig         types.Rig             `json:"rig"`
+	IssueCount  int                   `json:"issue_count"`
+	OpenCount   int                   `json:"open_count"`
+	ByStatus    map[string]int        `json:"by_status"`
+	ByType      map[string]int        `json:"by_type"`
+	AgentStates []registry.AgentState `json:"agent_states"`
 }
 
 // SystemHealth provides overall system health information.
@@ -122,16 +183,16 @@ type SystemHealth struct {
 
 // CacheStats provides cache performance statistics.
 type CacheStats struct {
-	IssueEntries           int       `json:"issue_entries"`
-	IssueListEntries       int       `json:"issue_list_entries"`
-	DependencyEntries      int       `json:"dependency_entries"`
-	ConvoyProgressEntries  int       `json:"convoy_progress_entries"`
-	HitCount               uint64    `json:"hit_count"`
-	MissCount              uint64    `json:"miss_count"`
-	LastInvalidation       time.Time `json:"last_invalidation"`
-	IssuesTTL              int       `json:"issues_ttl_seconds"`
-	ConvoyProgressTTL      int       `json:"convoy_progress_ttl_seconds"`
-	DependenciesTTL        int       `json:"dependencies_ttl_seconds"`
+	IssueEntries          int       `json:"issue_entries"`
+	IssueListEntries      int       `json:"issue_list_entries"`
+	DependencyEntries     int       `json:"dependency_entries"`
+	ConvoyProgressEntries int       `json:"convoy_progress_entries"`
+	HitCount              uint64    `json:"hit_count"`
+	MissCount             uint64    `json:"miss_count"`
+	LastInvalidation      time.Time `json:"last_invalidation"`
+	IssuesTTL             int       `json:"issues_ttl_seconds"`
+	ConvoyProgressTTL     int       `json:"convoy_progress_ttl_seconds"`
+	DependenciesTTL       int       `json:"dependencies_ttl_seconds"`
 }
 
 // Service provides fast, cached access to beads data via direct SQLite queries.
@@ -142,9 +203,9 @@ type Service struct {
 	eventStore    *events.Store
 
 	// Caches with type-safe entries
-	issueCache         map[string]cacheEntry[types.Issue]
-	issueListCache     map[string]cacheEntry[[]types.Issue]
-	dependencyCache    map[string]cacheEntry[[]types.Dependency]
+	issueCache          map[string]cacheEntry[types.Issue]
+	issueListCache      map[string]cacheEntry[[]types.Issue]
+	dependencyCache     map[string]cacheEntry[[]types.Dependency]
 	convoyProgressCache map[string]cacheEntry[types.ConvoyProgress]
 
 	// Mutex for cache access
@@ -155,10 +216,22 @@ type Service struct {
 	missCount        uint64
 	lastInvalidation time.Time
 
+	// Last good results, kept across invalidations for ServeStaleOnError.
+	// Issue lists are keyed by filter, so they are capped at maxStaleIssueLists
+	// with the least recently used evicted first.
+	staleIssues     map[string]types.Issue
+	staleIssueLists map[string]staleIssueList
+	staleSeq        uint64
+
 	// Event subscription for cache invalidation
-	eventCh    <-chan events.Event
-	stopCh     chan struct{}
-	stoppedCh  chan struct{}
+	eventCh   <-chan events.Event
+	stopCh    chan struct{}
+	stoppedCh chan struct{}
+
+	// Issue-list filters re-run in the background after each invalidation
+	warmFilters []IssueFilter
+	warming     atomic.Bool
+	warmPending atomic.Bool
 }
 
 // New creates a new Query Service.
@@ -167,7 +240,7 @@ func New(config Config, agentRegistry *registry.Registry, eventStore *events.Sto
 		return nil, fmt.Errorf("database path is required")
 	}
 
-	db, err := sql.Open("sqlite3", config.DBPath+"?mode=ro")
+	db, err := sql.Open("sqlite3", fmt.Sprintf("%s?mode=ro&_busy_timeout=%d", config.DBPath, busyTimeoutMS))
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -187,6 +260,8 @@ func New(config Config, agentRegistry *registry.Registry, eventStore *events.Sto
 		issueListCache:      make(map[string]cacheEntry[[]types.Issue]),
 		dependencyCache:     make(map[string]cacheEntry[[]types.Dependency]),
 		convoyProgressCache: make(map[string]cacheEntry[types.ConvoyProgress]),
+		staleIssues:         make(map[string]types.Issue),
+		staleIssueLists:     make(map[string]staleIssueList),
 		stopCh:              make(chan struct{}),
 		stoppedCh:           make(chan struct{}),
 	}
@@ -215,10 +290,42 @@ func (s *Service) Close() error {
 	return s.db.Close()
 }
 
-// eventLoop processes events for cache invalidation.
+// invalidation is a set of caches to clear in response to events.
+type invalidation int
+
+const (
+	invalidateIssues  invalidation = 1 << iota // issue, list, dependency and convoy caches
+	invalidateConvoys                          // convoy progress cache only
+)
+
+// invalidationFor returns the caches an event type makes stale.
+func invalidationFor(eventType string) invalidation {
+	switch {
+	case strings.HasPrefix(eventType, "bead."):
+		return invalidateIssues
+	case strings.HasPrefix(eventType, "convoy."):
+		return invalidateConvoys
+	}
+	return 0
+}
+
+// eventLoop processes events for cache invalidation. Bursts of events (bulk
+// updates, molecule construction) are coalesced per InvalidationWindow so the
+// caches are cleared once at the start and once after the last event, rather
+// than once per event.
 func (s *Service) eventLoop() {
 	defer close(s.stoppedCh)
 
+	window := s.config.CacheConfig.InvalidationWindow
+	var pending invalidation
+	var timer *time.Timer
+	var windowEnd <-chan time.Time
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
 	for {
 		select {
 		case <-s.stopCh:
@@ -227,34 +334,60 @@ func (s *Service) eventLoop() {
 			if !ok {
 				return
 			}
-			s.handleEvent(event)
+			kind := invalidationFor(event.Type)
+			if kind == 0 {
+				continue
+			}
+			if window <= 0 {
+				s.invalidate(kind)
+				continue
+			}
+			if windowEnd != nil {
+				pending |= kind
+				continue
+			}
+			s.invalidate(kind)
+			if timer == nil {
+				timer = time.NewTimer(window)
+			} else {
+				timer.Reset(window)
+			}
+			windowEnd = timer.C
+		case <-windowEnd:
+			if pending == 0 {
+				windowEnd = nil
+				continue
+			}
+			s.invalidate(pending)
+			pending = 0
+			timer.Reset(window)
 		}
 	}
 }
 
-// handleEvent invalidates caches based on event type.
-func (s *Service) handleEvent(event events.Event) {
+// invalidate clears the given caches.
+func (s *Service) invalidate(kind invalidation) {
+	if kind&invalidateIssues != 0 {
+		defer s.warm()
+	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	switch {
-	case strings.HasPrefix(event.Type, "bead."):
-		// Invalidate issue caches
+	if kind&invalidateIssues != 0 {
 		s.issueCache = make(map[string]cacheEntry[types.Issue])
 		s.issueListCache = make(map[string]cacheEntry[[]types.Issue])
 		s.dependencyCache = make(map[string]cacheEntry[[]types.Dependency])
 		s.convoyProgressCache = make(map[string]cacheEntry[types.ConvoyProgress])
-		slog.Debug("Invalidated issue caches on bead event", "type", event.Type)
-
-	case strings.HasPrefix(event.Type, "convoy."):
-		// Invalidate convoy caches
+		slog.Debug("Invalidated issue caches on bead event")
+	} else if kind&invalidateConvoys != 0 {
 		s.convoyProgressCache = make(map[string]cacheEntry[types.ConvoyProgress])
-		slog.Debug("Invalidated convoy cache on convoy event", "type", event.Type)
+		slog.Debug("Invalidated convoy cache on convoy event")
 	}
 }
 
 // InvalidateCache clears all caches. Useful for testing.
 func (s *Service) InvalidateCache() {
+	defer s.warm()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -265,6 +398,61 @@ func (s *Service) InvalidateCache() {
 	s.lastInvalidation = time.Now()
 }
 
+// SetWarmFilters sets the issue-list filters to keep warm: they are queried in
+// the background now and again after every invalidation of the issue caches,
+// so the first request for them after startup or a change is a cache hit.
+// Nil turns warming off.
+func (s *Service) SetWarmFilters(filters []IssueFilter) {
+	s.mu.Lock()
+	s.warmFilters = filters
+	s.mu.Unlock()
+
+	s.warm()
+}
+
+// warm repopulates the issue-list cache for the warm filters in the
+// background. A call while a warm-up runs makes it go around once more, so
+// the cache ends up holding results from after the latest invalidation.
+func (s *Service) warm() {
+	s.mu.RLock()
+	enabled := len(s.warmFilters) > 0
+	s.mu.RUnlock()
+	if !enabled {
+		return
+	}
+
+	s.warmPending.Store(true)
+	if !s.warming.CompareAndSwap(false, true) {
+		return
+	}
+
+	go func() {
+		for s.warmPending.Swap(false) {
+			s.mu.RLock()
+			filters := s.warmFilters
+			s.mu.RUnlock()
+
+			for _, filter := range filters {
+				select {
+				case <-s.stopCh:
+					s.warming.Store(false)
+					return
+				default:
+				}
+				if _, err := s.ListIssues(filter); err != nil {
+					slog.Debug("Failed to warm issue cache", "db", s.config.DBPath, "error", err)
+				}
+			}
+		}
+		s.warming.Store(false)
+
+		// A call that lost the race with the Store above still needs its warm-up
+		if s.warmPending.Load() {
+			s.warm()
+		}
+	}()
+}
+
 // GetCacheStats returns current cache statistics.
 func (s *Service) GetCacheStats() CacheStats {
 	s.mu.RLock()
@@ -287,9 +475,15 @@ func (s *Service) GetCacheStats() CacheStats {
 // ListIssues returns issues matching the filter.
 func (s *Service) ListIssues(filter IssueFilter) ([]types.Issue, error) {
 	// Generate cache key
-	cacheKey := fmt.Sprintf("list:%s:%v:%v:%s:%s:%s:%d:%d",
-		filter.Rig, filter.Status, filter.Type, filter.Assignee,
-		filter.Parent, filter.Convoy, filter.Limit, filter.Offset)
+	blocked := ""
+	if filter.Blocked != nil {
+		blocked = strconv.FormatBool(*filter.Blocked)
+	}
+	cacheKey := fmt.Sprintf("list:%s:%v:%v:%s:%s:%s:%s:%s:%d:%d:%s:%s:%v:%v",
+		filter.Rig, filter.Status, filter.Type, filter.Assignee, filter.Owner,
+		filter.Parent, filter.Convoy, blocked, filter.Limit, filter.Offset,
+		formatFilterTime(filter.ClosedSince), formatFilterTime(filter.ClosedUntil), filter.OrderByClosed,
+		filter.IncludeDescription)
 
 	// Check cache
 	s.mu.RLock()
@@ -306,6 +500,20 @@ func (s *Service) ListIssues(filter IssueFilter) ([]types.Issue, error) {
 	// Query database
 	issues, err := s.queryIssues(filter)
 	if err != nil {
+		if s.config.ServeStaleOnError {
+			s.mu.Lock()
+			stale, ok := s.staleIssueLists[cacheKey]
+			if ok {
+				s.staleSeq++
+				stale.used = s.staleSeq
+				s.staleIssueLists[cacheKey] = stale
+			}
+			s.mu.Unlock()
+			if ok {
+				slog.Warn("Serving stale issue list after query failure", "error", err)
+				return stale.issues, fmt.Errorf("%w: %v", ErrStale, err)
+			}
+		}
 		return nil, err
 	}
 
@@ -315,22 +523,154 @@ func (s *Service) ListIssues(filter IssueFilter) ([]types.Issue, error) {
 		value:     issues,
 		expiresAt: time.Now().Add(s.config.CacheConfig.IssuesTTL),
 	}
+	if s.config.ServeStaleOnError {
+		s.storeStaleIssueList(cacheKey, issues)
+	}
 	s.mu.Unlock()
 
 	return issues, nil
 }
 
+// maxStaleIssueLists caps the stale issue lists kept for ServeStaleOnError.
+const maxStaleIssueLists = 256
+
+// staleIssueList is a last good issue list and when it was last used.
+type staleIssueList struct {
+	issues []types.Issue
+	used   uint64 // staleSeq at last store or serve
+}
+
+// storeStaleIssueList records a last good issue list, evicting the least
+// recently used list when over maxStaleIssueLists. Must hold s.mu.
+func (s *Service) storeStaleIssueList(key string, issues []types.Issue) {
+	s.staleSeq++
+	s.staleIssueLists[key] = staleIssueList{issues: issues, used: s.staleSeq}
+	if len(s.staleIssueLists) <= maxStaleIssueLists {
+		return
+	}
+	oldestKey, oldest := "", uint64(0)
+	for k, v := range s.staleIssueLists {
+		if oldestKey == "" || v.used < oldest {
+			oldestKey, oldest = k, v.used
+		}
+	}
+	delete(s.staleIssueLists, oldestKey)
+}
+
+// formatFilterTime formats an optional filter time for SQL comparison and cache keys.
+func formatFilterTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
 // queryIssues executes the SQLite query for issues.
 func (s *Service) queryIssues(filter IssueFilter) ([]types.Issue, error) {
+	issues := []types.Issue{}
+	err := s.StreamIssues(filter, func(issue types.Issue) error {
+		issues = append(issues, issue)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return issues, nil
+}
+
+// StreamIssues calls fn for each issue matching the filter as rows are scanned,
+// without building the full list or touching the cache. Returning an error from
+// fn stops the scan and is returned. Busy errors, including ones SQLite reports
+// while stepping rows, are retried until the first issue reaches fn; after that
+// they are returned, since restarting the scan would repeat issues.
+func (s *Service) StreamIssues(filter IssueFilter, fn func(types.Issue) error) error {
+	query, args := buildIssueQuery(filter)
+
+	return withStreamRetry(func(started *bool) error {
+		rows, err := s.db.Query(query, args...)
+		if err != nil {
+			return fmt.Errorf("failed to query issues: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var issue types.Issue
+			var closedAt sql.NullTime
+			var closeReason sql.NullString
+			var owner, assignee, createdBy sql.NullString
+
+			if err := rows.Scan(
+				&issue.ID, &issue.Title, &issue.Description,
+				&issue.Status, &issue.Priority, &issue.IssueType,
+				&owner, &assignee, &issue.CreatedAt, &createdBy,
+				&issue.UpdatedAt, &closedAt, &closeReason, &issue.Blocked,
+			); err != nil {
+				return fmt.Errorf("failed to scan issue: %w", err)
+			}
+
+			if closedAt.Valid {
+				issue.ClosedAt = &closedAt.Time
+			}
+			if closeReason.Valid {
+				issue.CloseReason = closeReason.String
+			}
+			if owner.Valid {
+				issue.Owner = owner.String
+			}
+			if assignee.Valid {
+				issue.Assignee = assignee.String
+			}
+			if createdBy.Valid {
+				issue.CreatedBy = createdBy.String
+			}
+
+			*started = true
+			if err := fn(issue); err != nil {
+				return err
+			}
+		}
+
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("error iterating issues: %w", err)
+		}
+		return nil
+	})
+}
+
+// blockedExpr is true for an issue with a 'blocks' dependency on an issue that
+// is not yet closed. Dependencies on unknown (external) issues don't count.
+const blockedExpr = `EXISTS (
+		SELECT 1 FROM dependencies bd
+		INNER JOIN issues bi ON bi.id = bd.depends_on_id
+		WHERE bd.issue_id = issues.id AND bd.type = 'blocks'
+		  AND bi.deleted_at IS NULL AND bi.status NOT IN ('closed', 'tombstone')
+	)`
+
+// buildIssueQuery builds the SQL and arguments for an issue filter.
+func buildIssueQuery(filter IssueFilter) (string, []interface{}) {
+	// Selecting an empty literal keeps the scan order the same either way.
+	description := "'' AS description"
+	if filter.IncludeDescription {
+		description = "description"
+	}
+
 	query := `
-		SELECT id, title, description, status, priority, issue_type,
+		SELECT id, title, ` + description + `, status, priority, issue_type,
 		       owner, assignee, created_at, created_by, updated_at,
-		       closed_at, close_reason
+		       closed_at, close_reason, ` + blockedExpr + ` AS blocked
 		FROM issues
 		WHERE deleted_at IS NULL AND status != 'tombstone'
 	`
 	args := []interface{}{}
 
+	if filter.Blocked != nil {
+		if *filter.Blocked {
+			query += " AND " + blockedExpr
+		} else {
+			query += " AND NOT " + blockedExpr
+		}
+	}
+
 	if filter.Rig != "" {
 		query += " AND source_repo = ?"
 		args = append(args, filter.Rig)
@@ -359,6 +699,11 @@ func (s *Service) queryIssues(filter IssueFilter) ([]types.Issue, error) {
 		args = append(args, filter.Assignee)
 	}
 
+	if filter.Owner != "" {
+		query += " AND owner = ?"
+		args = append(args, filter.Owner)
+	}
+
 	if filter.Parent != "" {
 		// Parent is tracked via dependencies with type 'parent'
 		query += ` AND id IN (
@@ -368,69 +713,48 @@ func (s *Service) queryIssues(filter IssueFilter) ([]types.Issue, error) {
 		args = append(args, filter.Parent)
 	}
 
-	query += " ORDER BY priority ASC, updated_at DESC"
-
-	if filter.Limit > 0 {
-		query += " LIMIT ?"
-		args = append(args, filter.Limit)
+	if filter.Convoy != "" {
+		// Convoy membership is tracked via 'tracks' dependencies in either direction
+		query += ` AND (id IN (
+			SELECT issue_id FROM dependencies
+			WHERE depends_on_id = ? AND type = 'tracks'
+		) OR id IN (
+			SELECT depends_on_id FROM dependencies
+			WHERE issue_id = ? AND type = 'tracks'
+		))`
+		args = append(args, filter.Convoy, filter.Convoy)
 	}
 
-	if filter.Offset > 0 {
-		query += " OFFSET ?"
-		args = append(args, filter.Offset)
+	if filter.ClosedSince != nil {
+		query += " AND datetime(closed_at) >= datetime(?)"
+		args = append(args, formatFilterTime(filter.ClosedSince))
 	}
 
-	rows, err := s.db.Query(query, args...)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query issues: %w", err)
+	if filter.ClosedUntil != nil {
+		query += " AND datetime(closed_at) < datetime(?)"
+		args = append(args, formatFilterTime(filter.ClosedUntil))
 	}
-	defer rows.Close()
-
-	var issues []types.Issue
-	for rows.Next() {
-		var issue types.Issue
-		var closedAt sql.NullTime
-		var closeReason sql.NullString
-		var owner, assignee, createdBy sql.NullString
-
-		if err := rows.Scan(
-			&issue.ID, &issue.Title, &issue.Description,
-			&issue.Status, &issue.Priority, &issue.IssueType,
-			&owner, &assignee, &issue.CreatedAt, &createdBy,
-			&issue.UpdatedAt, &closedAt, &closeReason,
-		); err != nil {
-			return nil, fmt.Errorf("failed to scan issue: %w", err)
-		}
 
-		if closedAt.Valid {
-			issue.ClosedAt = &closedAt.Time
-		}
-		if closeReason.Valid {
-			issue.CloseReason = closeReason.String
-		}
-		if owner.Valid {
-			issue.Owner = owner.String
-		}
-		if assignee.Valid {
-			issue.Assignee = assignee.String
-		}
-		if createdBy.Valid {
-			issue.CreatedBy = createdBy.String
-		}
-
-		issues = append(issues, issue)
+	if filter.OrderByClosed {
+		query += " ORDER BY closed_at DESC, id ASC"
+	} else {
+		query += " ORDER BY priority ASC, updated_at DESC"
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating issues: %w", err)
+	if filter.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	} else if filter.Offset > 0 {
+		// SQLite requires a LIMIT clause before OFFSET
+		query += " LIMIT -1"
 	}
 
-	// Ensure non-nil slice
-	if issues == nil {
-		issues = []types.Issue{}
+	if filter.Offset > 0 {
+		query += " OFFSET ?"
+		args = append(args, filter.Offset)
 	}
 
-	return issues, nil
+	return query, args
 }
 
 // GetIssue returns a single issue by ID.
@@ -452,7 +776,7 @@ func (s *Service) GetIssue(issueID string) (*types.Issue, error) {
 	query := `
 		SELECT id, title, description, status, priority, issue_type,
 		       owner, assignee, created_at, created_by, updated_at,
-		       closed_at, close_reason
+		       closed_at, close_reason, ` + blockedExpr + ` AS blocked
 		FROM issues
 		WHERE id = ? AND deleted_at IS NULL
 	`
@@ -462,16 +786,27 @@ func (s *Service) GetIssue(issueID string) (*types.Issue, error) {
 	var closeReason sql.NullString
 	var owner, assignee, createdBy sql.NullString
 
-	err := s.db.QueryRow(query, issueID).Scan(
-		&issue.ID, &issue.Title, &issue.Description,
-		&issue.Status, &issue.Priority, &issue.IssueType,
-		&owner, &assignee, &issue.CreatedAt, &createdBy,
-		&issue.UpdatedAt, &closedAt, &closeReason,
-	)
+	err := withBusyRetry(func() error {
+		return s.db.QueryRow(query, issueID).Scan(
+			&issue.ID, &issue.Title, &issue.Description,
+			&issue.Status, &issue.Priority, &issue.IssueType,
+			&owner, &assignee, &issue.CreatedAt, &createdBy,
+			&issue.UpdatedAt, &closedAt, &closeReason, &issue.Blocked,
+		)
+	})
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
+		if s.config.ServeStaleOnError {
+			s.mu.RLock()
+			stale, ok := s.staleIssues[issueID]
+			s.mu.RUnlock()
+			if ok {
+				slog.Warn("Serving stale issue after query failure", "issueId", issueID, "error", err)
+				return &stale, fmt.Errorf("%w: failed to get issue: %v", ErrStale, err)
+			}
+		}
 		return nil, fmt.Errorf("failed to get issue: %w", err)
 	}
 
@@ -497,6 +832,9 @@ func (s *Service) GetIssue(issueID string) (*types.Issue, error) {
 		value:     issue,
 		expiresAt: time.Now().Add(s.config.CacheConfig.IssuesTTL),
 	}
+	if s.config.ServeStaleOnError {
+		s.staleIssues[issueID] = issue
+	}
 	s.mu.Unlock()
 
 	return &issue, nil
@@ -560,18 +898,74 @@ func (s *Service) GetDependencies(issueID string) (*types.IssueDependencies, err
 	return result, nil
 }
 
-// GetDependencyGraph returns a full dependency graph from a root issue.
+// GetUnblockedBy returns the open issues whose only unfinished blocker is
+// issueID, i.e. the issues that closing it would make actionable.
+func (s *Service) GetUnblockedBy(issueID string) ([]types.Issue, error) {
+	issue, err := s.GetIssue(issueID)
+	if err != nil {
+		return nil, err
+	}
+	if issue == nil {
+		return nil, fmt.Errorf("%w: %s", ErrIssueNotFound, issueID)
+	}
+
+	query := `
+		SELECT i.id, i.title, i.description, i.status, i.priority, i.issue_type,
+		       i.owner, i.assignee, i.created_at, i.created_by, i.updated_at,
+		       i.closed_at, i.close_reason
+		FROM issues i
+		INNER JOIN dependencies d ON i.id = d.issue_id
+		WHERE d.depends_on_id = ? AND d.type = 'blocks'
+		  AND i.deleted_at IS NULL AND i.status NOT IN ('closed', 'tombstone')
+		  AND NOT EXISTS (
+			SELECT 1 FROM dependencies od
+			INNER JOIN issues oi ON oi.id = od.depends_on_id
+			WHERE od.issue_id = i.id AND od.type = 'blocks' AND od.depends_on_id != ?
+			  AND oi.deleted_at IS NULL AND oi.status NOT IN ('closed', 'tombstone')
+		  )
+		ORDER BY i.priority, i.id
+	`
+
+	rows, err := s.db.Query(query, issueID, issueID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query unblocked issues: %w", err)
+	}
+	defer rows.Close()
+
+	unblocked := []types.Issue{}
+	for rows.Next() {
+		issue, err := scanIssue(rows)
+		if err != nil {
+			return nil, err
+		}
+		unblocked = append(unblocked, *issue)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating unblocked issues: %w", err)
+	}
+
+	return unblocked, nil
+}
+
+// GetDependencyGraph returns a dependency graph from a root issue using the default bounds.
 func (s *Service) GetDependencyGraph(rootID string) (*DependencyGraph, error) {
+	return s.GetDependencyGraphWithOptions(rootID, DefaultGraphOptions())
+}
+
+// GetDependencyGraphWithOptions returns a dependency graph from a root issue,
+// bounded in depth and in children per node.
+func (s *Service) GetDependencyGraphWithOptions(rootID string, opts GraphOptions) (*DependencyGraph, error) {
 	rootIssue, err := s.GetIssue(rootID)
 	if err != nil {
 		return nil, err
 	}
 	if rootIssue == nil {
-		return nil, fmt.Errorf("issue not found: %s", rootID)
+		return nil, fmt.Errorf("%w: %s", ErrIssueNotFound, rootID)
 	}
 
 	visited := make(map[string]bool)
-	rootNode := s.buildDependencyNode(rootID, visited, 0, 10) // Max depth 10
+	rootNode := s.buildDependencyNode(rootID, visited, 0, opts)
 
 	return &DependencyGraph{
 		Root:  rootNode,
@@ -580,8 +974,8 @@ func (s *Service) GetDependencyGraph(rootID string) (*DependencyGraph, error) {
 }
 
 // buildDependencyNode recursively builds the dependency tree.
-func (s *Service) buildDependencyNode(issueID string, visited map[string]bool, depth, maxDepth int) DependencyNode {
-	if visited[issueID] || depth >= maxDepth {
+func (s *Service) buildDependencyNode(issueID string, visited map[string]bool, depth int, opts GraphOptions) DependencyNode {
+	if visited[issueID] || depth >= opts.MaxDepth {
 		issue, _ := s.GetIssue(issueID)
 		if issue == nil {
 			return DependencyNode{Depth: depth}
@@ -609,24 +1003,132 @@ func (s *Service) buildDependencyNode(issueID string, visited map[string]bool, d
 	}
 
 	for _, blocked := range deps.BlockedBy {
-		if !visited[blocked.ID] {
-			child := s.buildDependencyNode(blocked.ID, visited, depth+1, maxDepth)
-			node.Children = append(node.Children, child)
+		if visited[blocked.ID] {
+			continue
 		}
+		if opts.MaxChildren > 0 && len(node.Children) >= opts.MaxChildren {
+			node.MoreChildren++
+			continue
+		}
+		child := s.buildDependencyNode(blocked.ID, visited, depth+1, opts)
+		node.Children = append(node.Children, child)
 	}
 
 	return node
 }
 
-// GetRawDependencies returns the raw dependency entries for an issue.
-// This is used for convoy-type issues to get their "tracks" dependencies.
-func (s *Service) GetRawDependencies(issueID string) ([]types.IssueDependency, error) {
+// ListDependencyEdges returns every dependency edge between live issues in the database,
+// in a single query. Edges to external references are included as-is.
+func (s *Service) ListDependencyEdges() ([]types.Dependency, error) {
+	const cacheKey = "edges:all"
+
+	// Check cache
+	s.mu.RLock()
+	if entry, ok := s.dependencyCache[cacheKey]; ok && time.Now().Before(entry.expiresAt) {
+		s.mu.RUnlock()
+		atomic.AddUint64(&s.hitCount, 1)
+		return entry.value, nil
+	}
+	s.mu.RUnlock()
+
+	// Cache miss
+	atomic.AddUint64(&s.missCount, 1)
+
 	query := `
+		SELECT d.issue_id, d.depends_on_id, d.type
+		FROM dependencies d
+		INNER JOIN issues i ON i.id = d.issue_id
+		WHERE i.deleted_at IS NULL AND i.status != 'tombstone'
+		ORDER BY d.issue_id, d.depends_on_id
+	`
+
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dependency edges: %w", err)
+	}
+	defer rows.Close()
+
+	edges := []types.Dependency{}
+	for rows.Next() {
+		var dep types.Dependency
+		if err := rows.Scan(&dep.FromID, &dep.ToID, &dep.Type); err != nil {
+			return nil, fmt.Errorf("failed to scan dependency edge: %w", err)
+		}
+		edges = append(edges, dep)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating dependency edges: %w", err)
+	}
+
+	// Update cache
+	s.mu.Lock()
+	s.dependencyCache[cacheKey] = cacheEntry[[]types.Dependency]{
+		value:     edges,
+		expiresAt: time.Now().Add(s.config.CacheConfig.DependenciesTTL),
+	}
+	s.mu.Unlock()
+
+	return edges, nil
+}
+
+// CountIssuesByStatus returns the number of live issues in each status. It
+// bypasses the cache so counts reflect a change as soon as it is written.
+func (s *Service) CountIssuesByStatus() (map[string]int, error) {
+	query := `
+		SELECT status, COUNT(*)
+		FROM issues
+		WHERE deleted_at IS NULL AND status != 'tombstone'
+		GROUP BY status
+	`
+
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count issues by status: %w", err)
+	}
+	defer rows.Close()
+
+	counts := map[string]int{}
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan status count: %w", err)
+		}
+		counts[status] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating status counts: %w", err)
+	}
+
+	return counts, nil
+}
+
+// GetRawDependencies returns the raw dependency entries for an issue, ordered
+// by target and type. This is used for convoy-type issues to get their
+// "tracks" dependencies.
+func (s *Service) GetRawDependencies(issueID string) ([]types.IssueDependency, error) {
+	return s.queryRawDependencies(`
 		SELECT issue_id, depends_on_id, type, created_at, created_by
 		FROM dependencies
 		WHERE issue_id = ?
-	`
+		ORDER BY depends_on_id, type
+	`, issueID)
+}
 
+// GetRawDependents returns the raw dependency entries of every type that
+// point at an issue, i.e. the issues that depend on it.
+func (s *Service) GetRawDependents(issueID string) ([]types.IssueDependency, error) {
+	return s.queryRawDependencies(`
+		SELECT issue_id, depends_on_id, type, created_at, created_by
+		FROM dependencies
+		WHERE depends_on_id = ?
+	`, issueID)
+}
+
+// queryRawDependencies runs a dependency query and scans its rows.
+func (s *Service) queryRawDependencies(query string, issueID string) ([]types.IssueDependency, error) {
 	rows, err := s.db.Query(query, issueID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query dependencies: %w", err)
@@ -679,9 +1181,40 @@ func (s *Service) GetConvoyProgress(convoyID string) (*types.ConvoyProgress, err
 	// Cache miss
 	atomic.AddUint64(&s.missCount, 1)
 
-	total := 0
-	completed := 0
+	var completed, total int
+	err := withBusyRetry(func() error {
+		var err error
+		completed, total, err = s.countConvoyProgress(convoyID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var percentage float64
+	if total > 0 {
+		percentage = float64(completed) / float64(total) * 100
+	}
+
+	progress := types.ConvoyProgress{
+		Completed:  completed,
+		Total:      total,
+		Percentage: percentage,
+	}
+
+	// Update cache
+	s.mu.Lock()
+	s.convoyProgressCache[convoyID] = cacheEntry[types.ConvoyProgress]{
+		value:     progress,
+		expiresAt: time.Now().Add(s.config.CacheConfig.ConvoyProgressTTL),
+	}
+	s.mu.Unlock()
+
+	return &progress, nil
+}
 
+// countConvoyProgress counts a convoy's tracked issues and how many are done.
+func (s *Service) countConvoyProgress(convoyID string) (completed, total int, err error) {
 	// Method 1: Query for issues that track this convoy (child -> convoy direction)
 	// This handles the case where children have dependencies pointing to the convoy
 	query1 := `
@@ -692,14 +1225,14 @@ func (s *Service) GetConvoyProgress(convoyID string) (*types.ConvoyProgress, err
 	`
 	rows1, err := s.db.Query(query1, convoyID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query convoy issues: %w", err)
+		return 0, 0, fmt.Errorf("failed to query convoy issues: %w", err)
 	}
 	defer rows1.Close()
 
 	for rows1.Next() {
 		var status string
 		if err := rows1.Scan(&status); err != nil {
-			return nil, fmt.Errorf("failed to scan status: %w", err)
+			return 0, 0, fmt.Errorf("failed to scan status: %w", err)
 		}
 		total++
 		if status == "closed" || status == "tombstone" {
@@ -718,14 +1251,14 @@ func (s *Service) GetConvoyProgress(convoyID string) (*types.ConvoyProgress, err
 	`
 	rows2, err := s.db.Query(query2, convoyID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query convoy dependencies: %w", err)
+		return 0, 0, fmt.Errorf("failed to query convoy dependencies: %w", err)
 	}
 	defer rows2.Close()
 
 	for rows2.Next() {
 		var dependsOnID, status string
 		if err := rows2.Scan(&dependsOnID, &status); err != nil {
-			return nil, fmt.Errorf("failed to scan dependency: %w", err)
+			return 0, 0, fmt.Errorf("failed to scan dependency: %w", err)
 		}
 		total++
 		// For external references (status will be empty), we count as open
@@ -735,26 +1268,7 @@ func (s *Service) GetConvoyProgress(convoyID string) (*types.ConvoyProgress, err
 		}
 	}
 
-	var percentage float64
-	if total > 0 {
-		percentage = float64(completed) / float64(total) * 100
-	}
-
-	progress := types.ConvoyProgress{
-		Completed:  completed,
-		Total:      total,
-		Percentage: percentage,
-	}
-
-	// Update cache
-	s.mu.Lock()
-	s.convoyProgressCache[convoyID] = cacheEntry[types.ConvoyProgress]{
-		value:     progress,
-		expiresAt: time.Now().Add(s.config.CacheConfig.ConvoyProgressTTL),
-	}
-	s.mu.Unlock()
-
-	return &progress, nil
+	return completed, total, nil
 }
 
 // ListAgents returns agents from the registry, optionally filtered by rig.
@@ -2,13 +2,16 @@ package query
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
 	"os"
 	"testing"
 	"time"
 
 	"github.com/gastown/townview/internal/events"
 	"github.com/gastown/townview/internal/registry"
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/gastown/townview/internal/types"
+	"github.com/mattn/go-sqlite3"
 )
 
 // testDB creates a temporary SQLite database with test data.
@@ -374,6 +377,111 @@ func TestQueryService_CacheInvalidation_OnEvent(t *testing.T) {
 	}
 }
 
+// TestQueryService_CacheInvalidation_Coalesced verifies events in a burst share one trailing clear.
+func TestQueryService_CacheInvalidation_Coalesced(t *testing.T) {
+	dbPath, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	insertTestIssue(t, dbPath, "burst-001", "Burst Issue", "open", "task", 1)
+
+	eventStore, err := events.NewStore(events.DefaultConfig())
+	if err != nil {
+		t.Fatalf("failed to create event store: %v", err)
+	}
+	defer eventStore.Close()
+
+	config := DefaultConfig()
+	config.DBPath = dbPath
+	config.CacheConfig.IssuesTTL = 1 * time.Hour
+	config.CacheConfig.InvalidationWindow = 300 * time.Millisecond
+	svc, err := New(config, nil, eventStore)
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	defer svc.Close()
+
+	// The first event in a burst clears immediately
+	if err := eventStore.Emit("bead.updated", "test", "townview", nil); err != nil {
+		t.Fatalf("failed to emit event: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, err := svc.ListIssues(IssueFilter{}); err != nil {
+		t.Fatalf("ListIssues failed: %v", err)
+	}
+
+	// Later events in the window are held back...
+	insertTestIssue(t, dbPath, "burst-002", "Burst Issue 2", "open", "task", 1)
+	for i := 0; i < 10; i++ {
+		if err := eventStore.Emit("bead.updated", "test", "townview", nil); err != nil {
+			t.Fatalf("failed to emit event: %v", err)
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	issues, err := svc.ListIssues(IssueFilter{})
+	if err != nil {
+		t.Fatalf("ListIssues failed: %v", err)
+	}
+	if len(issues) != 1 {
+		t.Errorf("expected cached result during the window, got %d issues", len(issues))
+	}
+
+	// ...and cleared together once it ends
+	time.Sleep(500 * time.Millisecond)
+	issues, err = svc.ListIssues(IssueFilter{})
+	if err != nil {
+		t.Fatalf("ListIssues failed: %v", err)
+	}
+	if len(issues) != 2 {
+		t.Errorf("expected 2 issues after the window closed, got %d", len(issues))
+	}
+}
+
+// TestQueryService_WarmFilters verifies warm filters are cached at startup and again after invalidation.
+func TestQueryService_WarmFilters(t *testing.T) {
+	dbPath, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	insertTestIssue(t, dbPath, "warm-001", "Open", "open", "task", 1)
+	insertTestIssue(t, dbPath, "warm-002", "Closed", "closed", "task", 2)
+
+	config := DefaultConfig()
+	config.DBPath = dbPath
+	svc, err := New(config, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	defer svc.Close()
+
+	waitForListEntries := func(want int) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for svc.GetCacheStats().IssueListEntries != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected %d cached issue lists, got %d", want, svc.GetCacheStats().IssueListEntries)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	svc.SetWarmFilters([]IssueFilter{{}, {Status: []string{"open"}}})
+	waitForListEntries(2)
+
+	svc.InvalidateCache()
+	waitForListEntries(2)
+
+	hitsBefore := svc.GetCacheStats().HitCount
+	issues, err := svc.ListIssues(IssueFilter{Status: []string{"open"}})
+	if err != nil {
+		t.Fatalf("ListIssues failed: %v", err)
+	}
+	if len(issues) != 1 {
+		t.Errorf("expected 1 open issue, got %d", len(issues))
+	}
+	if svc.GetCacheStats().HitCount != hitsBefore+1 {
+		t.Error("expected the warmed filter to be a cache hit")
+	}
+}
+
 // TestQueryService_ConvoyProgress_Computed verifies AC-4: Convoy progress computed correctly.
 func TestQueryService_ConvoyProgress_Computed(t *testing.T) {
 	dbPath, cleanup := setupTestDB(t)
@@ -435,6 +543,432 @@ func TestQueryService_ConvoyProgress_Computed(t *testing.T) {
 	}
 }
 
+// TestQueryService_ListIssues_ConvoyFilter verifies issues can be listed by convoy membership.
+func TestQueryService_ListIssues_ConvoyFilter(t *testing.T) {
+	dbPath, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	insertTestIssue(t, dbPath, "member-convoy", "Test Convoy", "open", "convoy", 1)
+	insertTestIssue(t, dbPath, "member-001", "Child tracks convoy", "open", "task", 1)
+	insertTestIssue(t, dbPath, "member-002", "Convoy tracks child", "open", "task", 2)
+	insertTestIssue(t, dbPath, "member-003", "Unrelated", "open", "task", 1)
+
+	insertTestDependency(t, dbPath, "member-001", "member-convoy", "tracks")
+	insertTestDependency(t, dbPath, "member-convoy", "member-002", "tracks")
+	insertTestDependency(t, dbPath, "member-003", "member-convoy", "blocks")
+
+	config := DefaultConfig()
+	config.DBPath = dbPath
+	svc, err := New(config, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	defer svc.Close()
+
+	issues, err := svc.ListIssues(IssueFilter{Convoy: "member-convoy"})
+	if err != nil {
+		t.Fatalf("ListIssues with convoy filter failed: %v", err)
+	}
+	if len(issues) != 2 {
+		t.Fatalf("expected 2 convoy members, got %d", len(issues))
+	}
+	if issues[0].ID != "member-001" || issues[1].ID != "member-002" {
+		t.Errorf("expected member-001 and member-002, got %s and %s", issues[0].ID, issues[1].ID)
+	}
+}
+
+// TestQueryService_StreamIssues verifies streaming yields the same issues as ListIssues and stops on error.
+func TestQueryService_StreamIssues(t *testing.T) {
+	dbPath, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	insertTestIssue(t, dbPath, "stream-001", "First", "open", "task", 1)
+	insertTestIssue(t, dbPath, "stream-002", "Second", "open", "task", 2)
+	insertTestIssue(t, dbPath, "stream-003", "Third", "closed", "task", 3)
+
+	config := DefaultConfig()
+	config.DBPath = dbPath
+	svc, err := New(config, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	defer svc.Close()
+
+	filter := IssueFilter{Status: []string{"open"}}
+	listed, err := svc.ListIssues(filter)
+	if err != nil {
+		t.Fatalf("ListIssues failed: %v", err)
+	}
+
+	var streamed []string
+	if err := svc.StreamIssues(filter, func(issue types.Issue) error {
+		streamed = append(streamed, issue.ID)
+		return nil
+	}); err != nil {
+		t.Fatalf("StreamIssues failed: %v", err)
+	}
+	if len(streamed) != len(listed) {
+		t.Fatalf("expected %d streamed issues, got %d", len(listed), len(streamed))
+	}
+	for i := range listed {
+		if streamed[i] != listed[i].ID {
+			t.Errorf("issue %d: expected %s, got %s", i, listed[i].ID, streamed[i])
+		}
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err = svc.StreamIssues(IssueFilter{}, func(types.Issue) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("expected stream to stop after first callback error, got err=%v calls=%d", err, calls)
+	}
+}
+
+// TestQueryService_BlockedStatus verifies blocked is computed from open blocks dependencies and filterable.
+func TestQueryService_BlockedStatus(t *testing.T) {
+	dbPath, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	insertTestIssue(t, dbPath, "blk-open", "Open blocker", "open", "task", 1)
+	insertTestIssue(t, dbPath, "blk-done", "Closed blocker", "closed", "task", 1)
+	insertTestIssue(t, dbPath, "blk-001", "Blocked by open", "open", "task", 2)
+	insertTestIssue(t, dbPath, "blk-002", "Blocked by closed", "open", "task", 2)
+	insertTestIssue(t, dbPath, "blk-003", "Tracks open", "open", "task", 2)
+
+	insertTestDependency(t, dbPath, "blk-001", "blk-open", "blocks")
+	insertTestDependency(t, dbPath, "blk-002", "blk-done", "blocks")
+	insertTestDependency(t, dbPath, "blk-003", "blk-open", "tracks")
+
+	config := DefaultConfig()
+	config.DBPath = dbPath
+	svc, err := New(config, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	defer svc.Close()
+
+	blocked := true
+	issues, err := svc.ListIssues(IssueFilter{Blocked: &blocked})
+	if err != nil {
+		t.Fatalf("ListIssues failed: %v", err)
+	}
+	if len(issues) != 1 || issues[0].ID != "blk-001" || !issues[0].Blocked {
+		t.Fatalf("expected only blk-001 blocked, got %+v", issues)
+	}
+
+	unblocked := false
+	issues, err = svc.ListIssues(IssueFilter{Blocked: &unblocked})
+	if err != nil {
+		t.Fatalf("ListIssues failed: %v", err)
+	}
+	if len(issues) != 4 {
+		t.Errorf("expected 4 unblocked issues, got %d", len(issues))
+	}
+
+	issue, err := svc.GetIssue("blk-001")
+	if err != nil {
+		t.Fatalf("GetIssue failed: %v", err)
+	}
+	if !issue.Blocked {
+		t.Error("expected GetIssue to report blk-001 blocked")
+	}
+}
+
+// TestQueryService_ListIssues_IncludeDescription verifies lists omit descriptions unless asked.
+func TestQueryService_ListIssues_IncludeDescription(t *testing.T) {
+	dbPath, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	insertTestIssue(t, dbPath, "desc-001", "Verbose issue", "open", "task", 1)
+
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if _, err := db.Exec("UPDATE issues SET description = 'long body' WHERE id = 'desc-001'"); err != nil {
+		t.Fatalf("failed to set description: %v", err)
+	}
+	db.Close()
+
+	config := DefaultConfig()
+	config.DBPath = dbPath
+	svc, err := New(config, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	defer svc.Close()
+
+	issues, err := svc.ListIssues(IssueFilter{})
+	if err != nil {
+		t.Fatalf("ListIssues failed: %v", err)
+	}
+	if len(issues) != 1 || issues[0].Description != "" {
+		t.Fatalf("expected description omitted by default, got %+v", issues)
+	}
+
+	issues, err = svc.ListIssues(IssueFilter{IncludeDescription: true})
+	if err != nil {
+		t.Fatalf("ListIssues failed: %v", err)
+	}
+	if len(issues) != 1 || issues[0].Description != "long body" {
+		t.Fatalf("expected description included on request, got %+v", issues)
+	}
+
+	issue, err := svc.GetIssue("desc-001")
+	if err != nil {
+		t.Fatalf("GetIssue failed: %v", err)
+	}
+	if issue.Description != "long body" {
+		t.Errorf("expected GetIssue to return the description, got %q", issue.Description)
+	}
+}
+
+// TestQueryService_ListIssues_ClosedWindow verifies closed-at window filtering and ordering.
+func TestQueryService_ListIssues_ClosedWindow(t *testing.T) {
+	dbPath, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	insertTestIssue(t, dbPath, "closed-001", "Closed last week", "closed", "task", 1)
+	insertTestIssue(t, dbPath, "closed-002", "Closed yesterday", "closed", "task", 1)
+	insertTestIssue(t, dbPath, "closed-003", "Closed today", "closed", "task", 1)
+	insertTestIssue(t, dbPath, "open-001", "Still open", "open", "task", 1)
+
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	now := time.Now().UTC()
+	for id, closedAt := range map[string]time.Time{
+		"closed-001": now.Add(-7 * 24 * time.Hour),
+		"closed-002": now.Add(-24 * time.Hour),
+		"closed-003": now.Add(-time.Hour),
+	} {
+		if _, err := db.Exec("UPDATE issues SET closed_at = ?, close_reason = 'done' WHERE id = ?", closedAt.Format(time.RFC3339), id); err != nil {
+			t.Fatalf("failed to set closed_at: %v", err)
+		}
+	}
+	db.Close()
+
+	config := DefaultConfig()
+	config.DBPath = dbPath
+	svc, err := New(config, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	defer svc.Close()
+
+	since := now.Add(-48 * time.Hour)
+	issues, err := svc.ListIssues(IssueFilter{Status: []string{"closed"}, ClosedSince: &since, OrderByClosed: true})
+	if err != nil {
+		t.Fatalf("ListIssues with closed window failed: %v", err)
+	}
+	if len(issues) != 2 {
+		t.Fatalf("expected 2 recently closed issues, got %d", len(issues))
+	}
+	if issues[0].ID != "closed-003" || issues[1].ID != "closed-002" {
+		t.Errorf("expected closed-003 then closed-002, got %s and %s", issues[0].ID, issues[1].ID)
+	}
+	if issues[0].CloseReason != "done" {
+		t.Errorf("expected close reason 'done', got %q", issues[0].CloseReason)
+	}
+}
+
+// TestQueryService_ListDependencyEdges verifies all edges are returned with their types.
+func TestQueryService_ListDependencyEdges(t *testing.T) {
+	dbPath, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	insertTestIssue(t, dbPath, "edge-convoy", "Convoy", "open", "convoy", 1)
+	insertTestIssue(t, dbPath, "edge-001", "Task 1", "open", "task", 1)
+	insertTestIssue(t, dbPath, "edge-002", "Task 2", "open", "task", 2)
+
+	insertTestDependency(t, dbPath, "edge-002", "edge-001", "blocks")
+	insertTestDependency(t, dbPath, "edge-convoy", "edge-001", "tracks")
+
+	config := DefaultConfig()
+	config.DBPath = dbPath
+	svc, err := New(config, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	defer svc.Close()
+
+	edges, err := svc.ListDependencyEdges()
+	if err != nil {
+		t.Fatalf("ListDependencyEdges failed: %v", err)
+	}
+	if len(edges) != 2 {
+		t.Fatalf("expected 2 edges, got %d", len(edges))
+	}
+	if edges[0].FromID != "edge-002" || edges[0].ToID != "edge-001" || edges[0].Type != "blocks" {
+		t.Errorf("unexpected first edge: %+v", edges[0])
+	}
+	if edges[1].FromID != "edge-convoy" || edges[1].Type != "tracks" {
+		t.Errorf("unexpected second edge: %+v", edges[1])
+	}
+}
+
+// TestQueryService_CountIssuesByStatus verifies live issues are counted per status.
+func TestQueryService_CountIssuesByStatus(t *testing.T) {
+	dbPath, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	insertTestIssue(t, dbPath, "count-001", "Open 1", "open", "task", 1)
+	insertTestIssue(t, dbPath, "count-002", "Open 2", "open", "task", 2)
+	insertTestIssue(t, dbPath, "count-003", "Working", "in_progress", "task", 2)
+	insertTestIssue(t, dbPath, "count-004", "Gone", "tombstone", "task", 2)
+
+	config := DefaultConfig()
+	config.DBPath = dbPath
+	svc, err := New(config, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	defer svc.Close()
+
+	counts, err := svc.CountIssuesByStatus()
+	if err != nil {
+		t.Fatalf("CountIssuesByStatus failed: %v", err)
+	}
+	if counts["open"] != 2 || counts["in_progress"] != 1 {
+		t.Errorf("unexpected counts: %v", counts)
+	}
+	if _, ok := counts["tombstone"]; ok {
+		t.Errorf("tombstoned issues should not be counted: %v", counts)
+	}
+}
+
+// TestQueryService_GetUnblockedBy verifies only issues with no other open blocker are returned.
+func TestQueryService_GetUnblockedBy(t *testing.T) {
+	dbPath, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	insertTestIssue(t, dbPath, "unb-root", "Blocker", "open", "task", 1)
+	insertTestIssue(t, dbPath, "unb-other", "Other blocker", "open", "task", 1)
+	insertTestIssue(t, dbPath, "unb-done", "Done blocker", "closed", "task", 1)
+	insertTestIssue(t, dbPath, "unb-001", "Only root", "open", "task", 2)
+	insertTestIssue(t, dbPath, "unb-002", "Root and other", "open", "task", 2)
+	insertTestIssue(t, dbPath, "unb-003", "Root and done", "open", "task", 2)
+	insertTestIssue(t, dbPath, "unb-004", "Already closed", "closed", "task", 2)
+
+	insertTestDependency(t, dbPath, "unb-001", "unb-root", "blocks")
+	insertTestDependency(t, dbPath, "unb-002", "unb-root", "blocks")
+	insertTestDependency(t, dbPath, "unb-002", "unb-other", "blocks")
+	insertTestDependency(t, dbPath, "unb-003", "unb-root", "blocks")
+	insertTestDependency(t, dbPath, "unb-003", "unb-done", "blocks")
+	insertTestDependency(t, dbPath, "unb-004", "unb-root", "blocks")
+
+	config := DefaultConfig()
+	config.DBPath = dbPath
+	svc, err := New(config, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	defer svc.Close()
+
+	issues, err := svc.GetUnblockedBy("unb-root")
+	if err != nil {
+		t.Fatalf("GetUnblockedBy failed: %v", err)
+	}
+	if len(issues) != 2 || issues[0].ID != "unb-001" || issues[1].ID != "unb-003" {
+		t.Errorf("expected unb-001 and unb-003, got %+v", issues)
+	}
+
+	if _, err := svc.GetUnblockedBy("unb-missing"); !errors.Is(err, ErrIssueNotFound) {
+		t.Errorf("expected ErrIssueNotFound, got %v", err)
+	}
+}
+
+// TestQueryService_ServeStaleOnError verifies the last good result is served when the database fails.
+func TestQueryService_ServeStaleOnError(t *testing.T) {
+	dbPath, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	insertTestIssue(t, dbPath, "stale-001", "Cached Issue", "open", "task", 1)
+
+	config := DefaultConfig()
+	config.DBPath = dbPath
+	config.ServeStaleOnError = true
+	svc, err := New(config, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	defer svc.Close()
+
+	if _, err := svc.ListIssues(IssueFilter{}); err != nil {
+		t.Fatalf("initial ListIssues failed: %v", err)
+	}
+	if _, err := svc.GetIssue("stale-001"); err != nil {
+		t.Fatalf("initial GetIssue failed: %v", err)
+	}
+
+	// Simulate a backend failure after the cache is invalidated
+	svc.InvalidateCache()
+	svc.db.Close()
+
+	issues, err := svc.ListIssues(IssueFilter{})
+	if !errors.Is(err, ErrStale) {
+		t.Fatalf("expected ErrStale, got %v", err)
+	}
+	if len(issues) != 1 || issues[0].ID != "stale-001" {
+		t.Errorf("expected stale issue list, got %+v", issues)
+	}
+
+	issue, err := svc.GetIssue("stale-001")
+	if !errors.Is(err, ErrStale) {
+		t.Fatalf("expected ErrStale from GetIssue, got %v", err)
+	}
+	if issue == nil || issue.Title != "Cached Issue" {
+		t.Errorf("expected stale issue, got %+v", issue)
+	}
+}
+
+func TestQueryService_ServeStaleOnError_CapsIssueLists(t *testing.T) {
+	dbPath, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	insertTestIssue(t, dbPath, "stale-001", "Cached Issue", "open", "task", 1)
+
+	config := DefaultConfig()
+	config.DBPath = dbPath
+	config.ServeStaleOnError = true
+	svc, err := New(config, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	defer svc.Close()
+
+	// Each distinct filter, e.g. a moving closed_since, is its own stale entry
+	base := time.Now()
+	for i := 0; i < maxStaleIssueLists+10; i++ {
+		since := base.Add(-time.Duration(i) * time.Second)
+		if _, err := svc.ListIssues(IssueFilter{ClosedSince: &since}); err != nil {
+			t.Fatalf("ListIssues failed: %v", err)
+		}
+	}
+
+	svc.mu.RLock()
+	n := len(svc.staleIssueLists)
+	svc.mu.RUnlock()
+	if n != maxStaleIssueLists {
+		t.Errorf("expected %d stale issue lists, got %d", maxStaleIssueLists, n)
+	}
+
+	// The newest filter survives eviction; the oldest does not
+	svc.InvalidateCache()
+	svc.db.Close()
+	newest := base.Add(-time.Duration(maxStaleIssueLists+9) * time.Second)
+	if _, err := svc.ListIssues(IssueFilter{ClosedSince: &newest}); !errors.Is(err, ErrStale) {
+		t.Errorf("expected ErrStale for the newest filter, got %v", err)
+	}
+	if _, err := svc.ListIssues(IssueFilter{ClosedSince: &base}); err == nil || errors.Is(err, ErrStale) {
+		t.Errorf("expected the oldest filter to be evicted, got %v", err)
+	}
+}
+
 // TestQueryService_DependencyGraph_Traversal verifies AC-5: Dependency graph traverses correctly.
 func TestQueryService_DependencyGraph_Traversal(t *testing.T) {
 	dbPath, cleanup := setupTestDB(t)
@@ -528,6 +1062,58 @@ func TestQueryService_DependencyGraph_Traversal(t *testing.T) {
 	}
 }
 
+// TestQueryService_DependencyGraph_MaxChildren verifies breadth truncation is reported per node.
+func TestQueryService_DependencyGraph_MaxChildren(t *testing.T) {
+	dbPath, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	insertTestIssue(t, dbPath, "hub-001", "Everything depends on this", "open", "epic", 1)
+	for _, id := range []string{"spoke-001", "spoke-002", "spoke-003", "spoke-004"} {
+		insertTestIssue(t, dbPath, id, "Spoke "+id, "open", "task", 2)
+		insertTestDependency(t, dbPath, id, "hub-001", "blocks")
+	}
+
+	config := DefaultConfig()
+	config.DBPath = dbPath
+	svc, err := New(config, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	defer svc.Close()
+
+	graph, err := svc.GetDependencyGraphWithOptions("hub-001", GraphOptions{MaxDepth: 10, MaxChildren: 3})
+	if err != nil {
+		t.Fatalf("GetDependencyGraphWithOptions failed: %v", err)
+	}
+	if len(graph.Root.Children) != 3 || graph.Root.MoreChildren != 1 {
+		t.Errorf("expected 3 children and 1 more, got %d and %d", len(graph.Root.Children), graph.Root.MoreChildren)
+	}
+
+	if _, err := svc.GetDependencyGraph("missing-001"); !errors.Is(err, ErrIssueNotFound) {
+		t.Errorf("expected ErrIssueNotFound for missing root, got %v", err)
+	}
+}
+
+func TestGraphOptions_Clamped(t *testing.T) {
+	tests := []struct {
+		name string
+		in   GraphOptions
+		want GraphOptions
+	}{
+		{"within bounds", GraphOptions{MaxDepth: 5, MaxChildren: 20}, GraphOptions{MaxDepth: 5, MaxChildren: 20}},
+		{"depth over cap", GraphOptions{MaxDepth: MaxGraphDepth + 1, MaxChildren: 20}, GraphOptions{MaxDepth: MaxGraphDepth, MaxChildren: 20}},
+		{"children over cap", GraphOptions{MaxDepth: 5, MaxChildren: MaxGraphChildren + 1}, GraphOptions{MaxDepth: 5, MaxChildren: MaxGraphChildren}},
+		{"unlimited children", GraphOptions{MaxDepth: 5, MaxChildren: 0}, GraphOptions{MaxDepth: 5, MaxChildren: MaxGraphChildren}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.in.Clamped(); got != tt.want {
+				t.Errorf("Clamped() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
 // TestQueryService_AgentIntegration tests agent registry integration.
 func TestQueryService_AgentIntegration(t *testing.T) {
 	dbPath, cleanup := setupTestDB(t)
@@ -769,3 +1355,114 @@ func TestQueryService_CacheStats(t *testing.T) {
 			stats.HitCount, stats.MissCount)
 	}
 }
+
+// TestQueryService_BusyRetry verifies busy/locked errors are retried and other errors are not.
+func TestQueryService_BusyRetry(t *testing.T) {
+	busy := sqlite3.Error{Code: sqlite3.ErrBusy}
+
+	calls := 0
+	err := withBusyRetry(func() error {
+		calls++
+		if calls < 3 {
+			return busy
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("expected success on third attempt, got err=%v calls=%d", err, calls)
+	}
+
+	calls = 0
+	err = withBusyRetry(func() error {
+		calls++
+		return fmt.Errorf("failed to query issues: %w", busy)
+	})
+	if !isBusyError(err) || calls != busyRetries+1 {
+		t.Errorf("expected busy error after %d attempts, got err=%v calls=%d", busyRetries+1, err, calls)
+	}
+
+	calls = 0
+	err = withBusyRetry(func() error {
+		calls++
+		return errors.New("no such table: issues")
+	})
+	if err == nil || calls != 1 {
+		t.Errorf("expected non-busy error without retry, got err=%v calls=%d", err, calls)
+	}
+}
+
+// TestQueryService_StreamRetry verifies busy errors are retried only until a
+// stream has handed out results.
+func TestQueryService_StreamRetry(t *testing.T) {
+	busy := sqlite3.Error{Code: sqlite3.ErrBusy}
+
+	// Busy while stepping the first row: nothing handed out, so retry
+	calls := 0
+	err := withStreamRetry(func(started *bool) error {
+		calls++
+		if calls < 2 {
+			return fmt.Errorf("error iterating issues: %w", busy)
+		}
+		*started = true
+		return nil
+	})
+	if err != nil || calls != 2 {
+		t.Errorf("expected success on second attempt, got err=%v calls=%d", err, calls)
+	}
+
+	// Busy after results were handed out: returned without a restart
+	calls = 0
+	err = withStreamRetry(func(started *bool) error {
+		calls++
+		*started = true
+		return fmt.Errorf("error iterating issues: %w", busy)
+	})
+	if !isBusyError(err) || calls != 1 {
+		t.Errorf("expected busy error without retry, got err=%v calls=%d", err, calls)
+	}
+}
+
+// TestQueryService_GetRawDependents verifies dependents of every type are returned.
+func TestQueryService_GetRawDependents(t *testing.T) {
+	dbPath, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	insertTestIssue(t, dbPath, "base-001", "Depended on", "open", "task", 2)
+	insertTestIssue(t, dbPath, "child-001", "Blocked", "open", "task", 2)
+	insertTestIssue(t, dbPath, "convoy-001", "Tracker", "open", "convoy", 2)
+	insertTestIssue(t, dbPath, "other-001", "Unrelated", "open", "task", 2)
+	insertTestDependency(t, dbPath, "child-001", "base-001", "blocks")
+	insertTestDependency(t, dbPath, "convoy-001", "base-001", "tracks")
+	insertTestDependency(t, dbPath, "base-001", "other-001", "blocks")
+
+	config := DefaultConfig()
+	config.DBPath = dbPath
+	svc, err := New(config, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	defer svc.Close()
+
+	dependents, err := svc.GetRawDependents("base-001")
+	if err != nil {
+		t.Fatalf("GetRawDependents failed: %v", err)
+	}
+	got := map[string]string{}
+	for _, dep := range dependents {
+		if dep.DependsOnID != "base-001" {
+			t.Errorf("expected dependents of base-001, got edge %+v", dep)
+		}
+		got[dep.IssueID] = dep.Type
+	}
+	if len(got) != 2 || got["child-001"] != "blocks" || got["convoy-001"] != "tracks" {
+		t.Errorf("expected child-001 (blocks) and convoy-001 (tracks), got %v", got)
+	}
+
+	none, err := svc.GetRawDependents("child-001")
+	if err != nil {
+		t.Fatalf("GetRawDependents failed: %v", err)
+	}
+	if len(none) != 0 {
+		t.Errorf("expected no dependents, got %+v", none)
+	}
+}
@@ -3,6 +3,7 @@
 package registry
 
 import (
+	"log/slog"
 	"sync"
 	"time"
 )
@@ -39,7 +40,9 @@ type AgentState struct {
 	Role AgentRole `json:"role"` // e.g., "polecat"
 	Name string    `json:"name"` // e.g., "obsidian"
 
-	Status AgentStatus `json:"status"` // current agent status
+	Status          AgentStatus `json:"status"`                 // current agent status
+	StatusChangedAt time.Time   `json:"status_changed_at"`      // When the status last changed
+	StuckReason     *string     `json:"stuck_reason,omitempty"` // Why the agent was marked stuck
 
 	// Work tracking
 	CurrentBead        *string    `json:"current_bead,omitempty"`         // Bead ID being worked on
@@ -49,6 +52,7 @@ type AgentState struct {
 	LastHeartbeat       time.Time `json:"last_heartbeat"`        // Last heartbeat time
 	HeartbeatIntervalMs int       `json:"heartbeat_interval_ms"` // Expected interval
 	MissedHeartbeats    int       `json:"missed_heartbeats"`     // Count of missed beats
+	Degraded            bool      `json:"degraded"`              // Missing beats but not yet dead
 
 	// Session info
 	SessionID *string   `json:"session_id,omitempty"` // tmux session name
@@ -57,18 +61,34 @@ type AgentState struct {
 	// Telemetry pointers
 	TokensUsed *int    `json:"tokens_used,omitempty"` // Cumulative token count
 	LastCommit *string `json:"last_commit,omitempty"` // Last git commit SHA
+
+	// Free-form metadata, e.g. {"model": "opus", "owner": "jeremy"}
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Pinned agents are marked stopped instead of auto-deregistered when dead
+	Pinned bool `json:"pinned,omitempty"`
+
+	// HasHeartbeated is set once the agent reports its own heartbeat; until
+	// then its status and current bead are inferred from discovery
+	HasHeartbeated bool `json:"has_heartbeated"`
+
+	// When the agent last reported its own heartbeat. Unlike LastHeartbeat,
+	// discovery refreshes leave it alone.
+	LastSelfHeartbeat *time.Time `json:"last_self_heartbeat,omitempty"`
 }
 
 // AgentRegistration contains the information needed to register an agent.
 type AgentRegistration struct {
-	ID                  string      `json:"id"`
-	Rig                 string      `json:"rig"`
-	Role                AgentRole   `json:"role"`
-	Name                string      `json:"name"`
-	SessionID           *string     `json:"session_id,omitempty"`
-	HeartbeatIntervalMs int         `json:"heartbeat_interval_ms"`
-	Status              AgentStatus `json:"status,omitempty"`
-	CurrentBead         *string     `json:"current_bead,omitempty"` // Bead ID being worked on
+	ID                  string            `json:"id"`
+	Rig                 string            `json:"rig"`
+	Role                AgentRole         `json:"role"`
+	Name                string            `json:"name"`
+	SessionID           *string           `json:"session_id,omitempty"`
+	HeartbeatIntervalMs int               `json:"heartbeat_interval_ms"`
+	Status              AgentStatus       `json:"status,omitempty"`
+	CurrentBead         *string           `json:"current_bead,omitempty"` // Bead ID being worked on
+	Labels              map[string]string `json:"labels,omitempty"`
+	Pinned              bool              `json:"pinned,omitempty"` // Never auto-deregister, e.g. expected singleton roles
 }
 
 // Heartbeat contains the information sent in a heartbeat.
@@ -78,13 +98,24 @@ type Heartbeat struct {
 	Status          AgentStatus `json:"status"`
 	CurrentBead     *string     `json:"current_bead,omitempty"`
 	TokensSinceLast *int        `json:"tokens_since_last,omitempty"`
+	Model           string      `json:"model,omitempty"` // Model that consumed TokensSinceLast
+
+	// Labels, when set, replace the agent's labels
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Inferred marks a refresh from discovery rather than the agent itself. It
+	// refreshes liveness and revives stopped agents but keeps the reported
+	// status, and only sets the bead for agents that have never heartbeated.
+	Inferred bool `json:"-"`
 }
 
 // AgentFilter specifies criteria for filtering agents.
 type AgentFilter struct {
-	Rig    *string      `json:"rig,omitempty"`
-	Role   *AgentRole   `json:"role,omitempty"`
-	Status *AgentStatus `json:"status,omitempty"`
+	Rig         *string           `json:"rig,omitempty"`
+	Role        *AgentRole        `json:"role,omitempty"`
+	Status      *AgentStatus      `json:"status,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"` // Agent must carry every key=value pair
+	CurrentBead *string           `json:"current_bead,omitempty"`
 }
 
 // EventType represents the type of agent change event.
@@ -94,6 +125,7 @@ const (
 	EventRegistered   EventType = "registered"
 	EventUpdated      EventType = "updated"
 	EventDeregistered EventType = "deregistered"
+	EventDegraded     EventType = "degraded"
 )
 
 // AgentEvent represents a change event for an agent.
@@ -112,8 +144,18 @@ type Config struct {
 	StuckThreshold      time.Duration // Duration after which agent is stuck (default: 15 minutes)
 	DeadThreshold       int           // Missed heartbeats before dead (default: 3)
 	DeregisterAfter     time.Duration // Time after which dead agents auto-deregister (default: 5 minutes)
+
+	// Duration an agent may stay starting/stopping before it is stuck (default: 10 minutes)
+	TransitionStuckThreshold time.Duration
 }
 
+// Stuck reasons recorded in AgentState.StuckReason.
+const (
+	StuckReasonWorking  = "stuck_working"
+	StuckReasonStarting = "stuck_starting"
+	StuckReasonStopping = "stuck_stopping"
+)
+
 // DefaultConfig returns the default configuration.
 func DefaultConfig() Config {
 	return Config{
@@ -121,6 +163,8 @@ func DefaultConfig() Config {
 		StuckThreshold:      15 * time.Minute,
 		DeadThreshold:       3,
 		DeregisterAfter:     5 * time.Minute,
+
+		TransitionStuckThreshold: 10 * time.Minute,
 	}
 }
 
@@ -128,7 +172,7 @@ func DefaultConfig() Config {
 type Registry struct {
 	config      Config
 	agents      map[string]*AgentState
-	subscribers []chan AgentEvent
+	subscribers []*subscriber
 	mu          sync.RWMutex
 	subMu       sync.RWMutex
 
@@ -142,7 +186,7 @@ func New(config Config) *Registry {
 	r := &Registry{
 		config:      config,
 		agents:      make(map[string]*AgentState),
-		subscribers: make([]chan AgentEvent, 0),
+		subscribers: make([]*subscriber, 0),
 		stopMonitor: make(chan struct{}),
 	}
 	return r
@@ -185,25 +229,33 @@ func (r *Registry) Register(reg AgentRegistration) AgentState {
 		Role:                reg.Role,
 		Name:                reg.Name,
 		Status:              status,
+		StatusChangedAt:     now,
 		CurrentBead:         reg.CurrentBead,
 		LastHeartbeat:       now,
 		HeartbeatIntervalMs: intervalMs,
 		MissedHeartbeats:    0,
 		SessionID:           reg.SessionID,
 		StartedAt:           now,
+		Labels:              copyLabels(reg.Labels),
+		Pinned:              reg.Pinned,
 	}
 
 	r.mu.Lock()
+	// Re-registration without labels, e.g. from discovery, keeps the old ones
+	if existing, ok := r.agents[reg.ID]; ok && reg.Labels == nil {
+		state.Labels = copyLabels(existing.Labels)
+	}
 	r.agents[reg.ID] = &state
+	result := state.snapshot()
 	r.mu.Unlock()
 
 	r.emit(AgentEvent{
-		Agent:     state,
+		Agent:     result,
 		EventType: EventRegistered,
 		Timestamp: now,
 	})
 
-	return state
+	return result
 }
 
 // Deregister removes an agent from the registry.
@@ -214,7 +266,7 @@ func (r *Registry) Deregister(agentID string) {
 		r.mu.Unlock()
 		return
 	}
-	agentCopy := *agent
+	agentCopy := agent.snapshot()
 	delete(r.agents, agentID)
 	r.mu.Unlock()
 
@@ -225,7 +277,8 @@ func (r *Registry) Deregister(agentID string) {
 	})
 }
 
-// Heartbeat processes a heartbeat from an agent and returns the updated state.
+// Heartbeat processes a heartbeat from an agent and returns a copy of the
+// updated state, or nil if the agent is not registered.
 func (r *Registry) Heartbeat(beat Heartbeat) *AgentState {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -236,26 +289,34 @@ func (r *Registry) Heartbeat(beat Heartbeat) *AgentState {
 	}
 
 	oldStatus := agent.Status
-	oldBead := agent.CurrentBead
 
 	// Update heartbeat time and reset missed count
+	wasDegraded := agent.Degraded
 	agent.LastHeartbeat = beat.Timestamp
 	agent.MissedHeartbeats = 0
-	agent.Status = beat.Status
-
-	// Track bead changes
-	if beat.CurrentBead != nil {
-		if oldBead == nil || *oldBead != *beat.CurrentBead {
-			// New bead started
-			now := beat.Timestamp
-			agent.CurrentBead = beat.CurrentBead
-			agent.CurrentBeadStarted = &now
+	agent.Degraded = false
+
+	// Discovery only knows the session is alive: it refreshes liveness, revives
+	// stopped agents, and fills in the bead until the agent reports its own
+	if beat.Inferred {
+		if agent.Status == StatusStopped && beat.Status != StatusStopped {
+			setStatus(agent, beat.Status, beat.Timestamp)
 		}
-	} else {
-		agent.CurrentBead = nil
-		agent.CurrentBeadStarted = nil
+		if !agent.HasHeartbeated {
+			trackBead(agent, beat.CurrentBead, beat.Timestamp)
+		}
+		return r.finishHeartbeat(agent, oldStatus, wasDegraded, beat.Timestamp)
+	}
+	agent.HasHeartbeated = true
+	selfBeat := beat.Timestamp
+	agent.LastSelfHeartbeat = &selfBeat
+	if beat.Labels != nil {
+		agent.Labels = copyLabels(beat.Labels)
 	}
 
+	setStatus(agent, beat.Status, beat.Timestamp)
+	trackBead(agent, beat.CurrentBead, beat.Timestamp)
+
 	// Update token count
 	if beat.TokensSinceLast != nil && agent.TokensUsed != nil {
 		newTotal := *agent.TokensUsed + *beat.TokensSinceLast
@@ -264,16 +325,53 @@ func (r *Registry) Heartbeat(beat Heartbeat) *AgentState {
 		agent.TokensUsed = beat.TokensSinceLast
 	}
 
-	// Emit event if status changed
-	if oldStatus != agent.Status {
+	return r.finishHeartbeat(agent, oldStatus, wasDegraded, beat.Timestamp)
+}
+
+// setStatus changes an agent's status, clearing any stuck reason on a change.
+func setStatus(agent *AgentState, status AgentStatus, at time.Time) {
+	if agent.Status == status {
+		return
+	}
+	agent.Status = status
+	agent.StatusChangedAt = at
+	agent.StuckReason = nil
+}
+
+// trackBead sets an agent's current bead, restarting its timer when it changes.
+func trackBead(agent *AgentState, bead *string, at time.Time) {
+	if bead == nil {
+		agent.CurrentBead = nil
+		agent.CurrentBeadStarted = nil
+		return
+	}
+	if agent.CurrentBead == nil || *agent.CurrentBead != *bead {
+		// New bead started
+		agent.CurrentBead = bead
+		agent.CurrentBeadStarted = &at
+	}
+}
+
+// finishHeartbeat emits an update if a heartbeat changed the agent's status or
+// recovered it from degraded, and returns a copy of the agent. Must hold r.mu.
+func (r *Registry) finishHeartbeat(agent *AgentState, oldStatus AgentStatus, wasDegraded bool, at time.Time) *AgentState {
+	if oldStatus != agent.Status || wasDegraded {
 		r.emitWithLock(AgentEvent{
-			Agent:     *agent,
+			Agent:     agent.snapshot(),
 			EventType: EventUpdated,
-			Timestamp: beat.Timestamp,
+			Timestamp: at,
 		})
 	}
+	copy := agent.snapshot()
+	return &copy
+}
 
-	return agent
+// snapshot returns a copy of the agent that shares no labels map with the
+// registry. Must hold r.mu.
+func (a *AgentState) snapshot() AgentState {
+	copy := *a
+	copy.Labels = copyLabels(a.Labels)
+	return copy
 }
 
 // GetAgent returns an agent by ID, or nil if not found.
@@ -286,7 +384,7 @@ func (r *Registry) GetAgent(agentID string) *AgentState {
 		return nil
 	}
 	// Return a copy to prevent external modification
-	copy := *agent
+	copy := agent.snapshot()
 	return &copy
 }
 
@@ -307,42 +405,133 @@ func (r *Registry) ListAgents(filter *AgentFilter) []AgentState {
 			if filter.Status != nil && agent.Status != *filter.Status {
 				continue
 			}
+			if !hasLabels(agent.Labels, filter.Labels) {
+				continue
+			}
+			if filter.CurrentBead != nil && (agent.CurrentBead == nil || *agent.CurrentBead != *filter.CurrentBead) {
+				continue
+			}
 		}
-		result = append(result, *agent)
+		result = append(result, agent.snapshot())
 	}
 	return result
 }
 
+// GetAgentByBead returns the agent currently working on a bead, or nil if none is.
+// If several agents claim the bead, the one that started on it most recently wins.
+func (r *Registry) GetAgentByBead(beadID string) *AgentState {
+	var found *AgentState
+	for _, agent := range r.ListAgents(&AgentFilter{CurrentBead: &beadID}) {
+		if found == nil || startedAfter(agent.CurrentBeadStarted, found.CurrentBeadStarted) {
+			a := agent
+			found = &a
+		}
+	}
+	return found
+}
+
+// startedAfter reports whether bead start time a is later than b; unknown times sort first.
+func startedAfter(a, b *time.Time) bool {
+	if a == nil {
+		return false
+	}
+	return b == nil || a.After(*b)
+}
+
+// hasLabels reports whether labels contains every key=value pair in want.
+func hasLabels(labels, want map[string]string) bool {
+	for k, v := range want {
+		if got, ok := labels[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
+// copyLabels returns a copy of labels so callers can't mutate registry state.
+func copyLabels(labels map[string]string) map[string]string {
+	if len(labels) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(labels))
+	for k, v := range labels {
+		out[k] = v
+	}
+	return out
+}
+
 // GetAgentsByRig returns all agents for a specific rig.
 func (r *Registry) GetAgentsByRig(rigID string) []AgentState {
 	return r.ListAgents(&AgentFilter{Rig: &rigID})
 }
 
-// OnAgentChange subscribes to agent change events.
+// OnAgentChange subscribes to agent change events. Events are dropped while
+// 64 are already waiting on the callback.
 // Returns an unsubscribe function.
 func (r *Registry) OnAgentChange(callback func(AgentEvent)) UnsubscribeFunc {
-	ch := make(chan AgentEvent, 64)
+	sub := newSubscriber(64)
 
 	r.subMu.Lock()
-	r.subscribers = append(r.subscribers, ch)
+	r.subscribers = append(r.subscribers, sub)
 	r.subMu.Unlock()
 
-	// Start goroutine to forward events to callback
-	go func() {
-		for event := range ch {
-			callback(event)
-		}
-	}()
+	go sub.run(callback)
 
-	// Return unsubscribe function
+	return r.unsubscribeFunc(sub)
+}
+
+// snapshotSubscriberLimit caps the live events queued for a SubscribeWithSnapshot
+// callback. It is far above what a slow snapshot or a burst of heartbeats
+// queues, so only a stalled callback reaches it.
+const snapshotSubscriberLimit = 1024
+
+// SubscribeWithSnapshot subscribes to agent change events after first delivering
+// a synthetic EventRegistered for every current agent.
+// The snapshot is taken and the subscription registered under the lock, so no
+// change can slip between them; live events queue until the snapshot has been
+// delivered. Snapshot delivery happens synchronously before returning.
+// If the callback falls snapshotSubscriberLimit events behind, the oldest
+// queued events are dropped and a warning logged, keeping the latest changes.
+// Returns an unsubscribe function.
+func (r *Registry) SubscribeWithSnapshot(callback func(AgentEvent)) UnsubscribeFunc {
+	sub := newSubscriber(snapshotSubscriberLimit)
+	sub.dropOldest = true
+
+	r.mu.RLock()
+	now := time.Now()
+	snapshot := make([]AgentEvent, 0, len(r.agents))
+	for _, agent := range r.agents {
+		snapshot = append(snapshot, AgentEvent{
+			Agent:     agent.snapshot(),
+			EventType: EventRegistered,
+			Timestamp: now,
+		})
+	}
+	r.subMu.Lock()
+	r.subscribers = append(r.subscribers, sub)
+	r.subMu.Unlock()
+	r.mu.RUnlock()
+
+	for _, event := range snapshot {
+		callback(event)
+	}
+
+	// Forward live events, including any queued during the snapshot
+	go sub.run(callback)
+
+	return r.unsubscribeFunc(sub)
+}
+
+// unsubscribeFunc returns a function that removes and stops the given subscriber.
+func (r *Registry) unsubscribeFunc(sub *subscriber) UnsubscribeFunc {
 	return func() {
 		r.subMu.Lock()
 		defer r.subMu.Unlock()
 
-		for i, sub := range r.subscribers {
-			if sub == ch {
+		for i, s := range r.subscribers {
+			if s == sub {
 				r.subscribers = append(r.subscribers[:i], r.subscribers[i+1:]...)
-				close(ch)
+				close(sub.done)
 				return
 			}
 		}
@@ -354,12 +543,8 @@ func (r *Registry) emit(event AgentEvent) {
 	r.subMu.RLock()
 	defer r.subMu.RUnlock()
 
-	for _, ch := range r.subscribers {
-		select {
-		case ch <- event:
-		default:
-			// Channel full, skip
-		}
+	for _, sub := range r.subscribers {
+		sub.push(event)
 	}
 }
 
@@ -368,11 +553,73 @@ func (r *Registry) emitWithLock(event AgentEvent) {
 	r.subMu.RLock()
 	defer r.subMu.RUnlock()
 
-	for _, ch := range r.subscribers {
+	for _, sub := range r.subscribers {
+		sub.push(event)
+	}
+}
+
+// subscriber queues events for one callback. Pushing never blocks, so events
+// can be emitted while holding the registry lock.
+type subscriber struct {
+	mu         sync.Mutex
+	pending    []AgentEvent
+	limit      int           // Pending events beyond this are dropped; 0 for no limit
+	dropOldest bool          // At the limit, drop the oldest pending event rather than the new one
+	dropped    int           // Events dropped since the last delivered batch
+	wake       chan struct{} // Signals run that events are pending
+	done       chan struct{} // Closed on unsubscribe
+}
+
+func newSubscriber(limit int) *subscriber {
+	return &subscriber{
+		limit: limit,
+		wake:  make(chan struct{}, 1),
+		done:  make(chan struct{}),
+	}
+}
+
+// push queues an event. At the subscriber's limit it drops the new event, or
+// the oldest pending one for dropOldest subscribers.
+func (s *subscriber) push(event AgentEvent) {
+	s.mu.Lock()
+	if s.limit > 0 && len(s.pending) >= s.limit {
+		if !s.dropOldest {
+			s.mu.Unlock()
+			return // Queue full, skip
+		}
+		s.pending = s.pending[1:]
+		s.dropped++
+	}
+	s.pending = append(s.pending, event)
+	s.mu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run delivers queued events to callback in order until unsubscribed.
+func (s *subscriber) run(callback func(AgentEvent)) {
+	for {
 		select {
-		case ch <- event:
-		default:
-			// Channel full, skip
+		case <-s.done:
+			return
+		case <-s.wake:
+		}
+
+		s.mu.Lock()
+		batch := s.pending
+		s.pending = nil
+		dropped := s.dropped
+		s.dropped = 0
+		s.mu.Unlock()
+
+		if dropped > 0 {
+			slog.Warn("Agent event subscriber fell behind, dropped oldest events", "dropped", dropped)
+		}
+		for _, event := range batch {
+			callback(event)
 		}
 	}
 }
@@ -401,6 +648,11 @@ func (r *Registry) checkAgentHealth() {
 	var toDeregister []string
 	var events []AgentEvent
 
+	transitionThreshold := r.config.TransitionStuckThreshold
+	if transitionThreshold == 0 {
+		transitionThreshold = DefaultConfig().TransitionStuckThreshold
+	}
+
 	r.mu.Lock()
 	for id, agent := range r.agents {
 		// Calculate expected heartbeat interval
@@ -415,30 +667,67 @@ func (r *Registry) checkAgentHealth() {
 
 				// Check if dead
 				if agent.MissedHeartbeats > r.config.DeadThreshold {
-					// Check if should auto-deregister
+					// Check if should auto-deregister; pinned agents stay listed as stopped
 					if timeSinceHeartbeat > r.config.DeregisterAfter {
-						toDeregister = append(toDeregister, id)
+						if !agent.Pinned {
+							toDeregister = append(toDeregister, id)
+							continue
+						}
+						if agent.Status != StatusStopped {
+							agent.Status = StatusStopped
+							agent.StatusChangedAt = now
+							agent.StuckReason = nil
+							events = append(events, AgentEvent{
+								Agent:     agent.snapshot(),
+								EventType: EventUpdated,
+								Timestamp: now,
+							})
+						}
 						continue
 					}
 				}
+
+				// More than one missed beat is an early warning sign
+				if agent.MissedHeartbeats > 1 && !agent.Degraded {
+					agent.Degraded = true
+					events = append(events, AgentEvent{
+						Agent:     agent.snapshot(),
+						EventType: EventDegraded,
+						Timestamp: now,
+					})
+				}
 			}
 		}
 
 		// Check for stuck status (working + same bead > threshold)
+		var stuckReason string
 		if agent.Status == StatusWorking && agent.CurrentBeadStarted != nil {
-			beadDuration := now.Sub(*agent.CurrentBeadStarted)
-			if beadDuration > r.config.StuckThreshold && agent.Status != StatusStuck {
-				oldStatus := agent.Status
-				agent.Status = StatusStuck
-				if oldStatus != agent.Status {
-					events = append(events, AgentEvent{
-						Agent:     *agent,
-						EventType: EventUpdated,
-						Timestamp: now,
-					})
+			if now.Sub(*agent.CurrentBeadStarted) > r.config.StuckThreshold {
+				stuckReason = StuckReasonWorking
+			}
+		}
+
+		// Check for agents wedged while starting or stopping
+		if agent.Status == StatusStarting || agent.Status == StatusStopping {
+			if now.Sub(agent.StatusChangedAt) > transitionThreshold {
+				if agent.Status == StatusStarting {
+					stuckReason = StuckReasonStarting
+				} else {
+					stuckReason = StuckReasonStopping
 				}
 			}
 		}
+
+		if stuckReason != "" {
+			agent.Status = StatusStuck
+			agent.StatusChangedAt = now
+			agent.StuckReason = &stuckReason
+			events = append(events, AgentEvent{
+				Agent:     agent.snapshot(),
+				EventType: EventUpdated,
+				Timestamp: now,
+			})
+		}
 	}
 	r.mu.Unlock()
 
@@ -1,6 +1,7 @@
 package registry
 
 import (
+	"fmt"
 	"sync"
 	"testing"
 	"time"
@@ -128,6 +129,121 @@ func TestAgentRegistry_Heartbeat_UpdatesState(t *testing.T) {
 	}
 }
 
+// TestAgentRegistry_HasHeartbeated tests that only self-reported heartbeats
+// mark an agent as having heartbeated.
+func TestAgentRegistry_HasHeartbeated(t *testing.T) {
+	r := NewWithDefaults()
+
+	reg := AgentRegistration{
+		ID:   "townview/polecats/obsidian",
+		Rig:  "townview",
+		Role: RolePolecat,
+		Name: "obsidian",
+	}
+	if state := r.Register(reg); state.HasHeartbeated {
+		t.Error("Expected a newly registered agent to have no heartbeat")
+	}
+
+	// Discovery refreshes do not count
+	r.Heartbeat(Heartbeat{AgentID: reg.ID, Timestamp: time.Now(), Status: StatusIdle, Inferred: true})
+	if r.GetAgent(reg.ID).HasHeartbeated {
+		t.Error("Expected an inferred heartbeat not to set HasHeartbeated")
+	}
+
+	r.Heartbeat(Heartbeat{AgentID: reg.ID, Timestamp: time.Now(), Status: StatusWorking})
+	if !r.GetAgent(reg.ID).HasHeartbeated {
+		t.Error("Expected a real heartbeat to set HasHeartbeated")
+	}
+
+	selfBeat := r.GetAgent(reg.ID).LastSelfHeartbeat
+	if selfBeat == nil {
+		t.Fatal("Expected a real heartbeat to set LastSelfHeartbeat")
+	}
+
+	// Later inferred refreshes keep the flag and the self-reported time
+	r.Heartbeat(Heartbeat{AgentID: reg.ID, Timestamp: selfBeat.Add(time.Minute), Status: StatusIdle, Inferred: true})
+	agent := r.GetAgent(reg.ID)
+	if !agent.HasHeartbeated {
+		t.Error("Expected HasHeartbeated to stay set after an inferred heartbeat")
+	}
+	if agent.LastSelfHeartbeat == nil || !agent.LastSelfHeartbeat.Equal(*selfBeat) {
+		t.Errorf("Expected LastSelfHeartbeat to stay %v, got %v", selfBeat, agent.LastSelfHeartbeat)
+	}
+}
+
+// TestAgentRegistry_InferredHeartbeat_OnlyRefreshesLiveness tests that a
+// discovery refresh keeps a stuck status but revives a stopped agent.
+func TestAgentRegistry_InferredHeartbeat_OnlyRefreshesLiveness(t *testing.T) {
+	r := NewWithDefaults()
+
+	reg := AgentRegistration{
+		ID:     "townview/witness",
+		Rig:    "townview",
+		Role:   RoleWitness,
+		Name:   "witness",
+		Status: StatusStopped,
+	}
+	r.Register(reg)
+
+	r.Heartbeat(Heartbeat{AgentID: reg.ID, Timestamp: time.Now(), Status: StatusRunning, Inferred: true})
+	if agent := r.GetAgent(reg.ID); agent.Status != StatusRunning {
+		t.Fatalf("Expected a stopped agent to be revived as running, got %s", agent.Status)
+	}
+
+	// Stuck detection's verdict survives discovery
+	reason := StuckReasonWorking
+	r.mu.Lock()
+	r.agents[reg.ID].Status = StatusStuck
+	r.agents[reg.ID].StuckReason = &reason
+	r.mu.Unlock()
+	r.Heartbeat(Heartbeat{AgentID: reg.ID, Timestamp: time.Now(), Status: StatusRunning, Inferred: true})
+	agent := r.GetAgent(reg.ID)
+	if agent.Status != StatusStuck || agent.StuckReason == nil {
+		t.Errorf("Expected the agent to stay stuck after an inferred heartbeat, got %s (%v)", agent.Status, agent.StuckReason)
+	}
+	if agent.MissedHeartbeats != 0 {
+		t.Errorf("Expected liveness to be refreshed, got %d missed", agent.MissedHeartbeats)
+	}
+}
+
+// TestAgentRegistry_InferredHeartbeat_KeepsSelfReportedState tests that a
+// discovery refresh does not overwrite state the agent reported itself.
+func TestAgentRegistry_InferredHeartbeat_KeepsSelfReportedState(t *testing.T) {
+	r := NewWithDefaults()
+
+	reg := AgentRegistration{
+		ID:   "townview/polecats/obsidian",
+		Rig:  "townview",
+		Role: RolePolecat,
+		Name: "obsidian",
+	}
+	r.Register(reg)
+
+	// Before any real heartbeat, discovery's guess is the only state there is
+	guessed := "to-guess"
+	r.Heartbeat(Heartbeat{AgentID: reg.ID, Timestamp: time.Now(), Status: StatusRunning, CurrentBead: &guessed, Inferred: true})
+	if agent := r.GetAgent(reg.ID); agent.CurrentBead == nil || *agent.CurrentBead != guessed {
+		t.Errorf("Expected inferred bead %s before any heartbeat, got %v", guessed, agent.CurrentBead)
+	}
+
+	reported := "to-2e0s.2"
+	r.Heartbeat(Heartbeat{AgentID: reg.ID, Timestamp: time.Now(), Status: StatusWorking, CurrentBead: &reported})
+
+	refreshedAt := time.Now().Add(time.Second)
+	r.Heartbeat(Heartbeat{AgentID: reg.ID, Timestamp: refreshedAt, Status: StatusRunning, CurrentBead: &guessed, Inferred: true})
+
+	agent := r.GetAgent(reg.ID)
+	if agent.CurrentBead == nil || *agent.CurrentBead != reported {
+		t.Errorf("Expected self-reported bead %s to survive, got %v", reported, agent.CurrentBead)
+	}
+	if agent.Status != StatusWorking {
+		t.Errorf("Expected self-reported status %s to survive, got %s", StatusWorking, agent.Status)
+	}
+	if !agent.LastHeartbeat.Equal(refreshedAt) {
+		t.Errorf("Expected the inferred heartbeat to refresh liveness, got %v", agent.LastHeartbeat)
+	}
+}
+
 // TestAgentRegistry_MissedHeartbeat_IncrementsCounter tests AC-3: Missing heartbeats increment counter.
 func TestAgentRegistry_MissedHeartbeat_IncrementsCounter(t *testing.T) {
 	// Use short intervals for testing
@@ -220,6 +336,39 @@ func TestAgentRegistry_DeadAgent_AutoDeregisters(t *testing.T) {
 	}
 }
 
+// TestAgentRegistry_DeadAgent_PinnedStaysStopped verifies pinned agents are kept as stopped instead of deregistered.
+func TestAgentRegistry_DeadAgent_PinnedStaysStopped(t *testing.T) {
+	config := Config{
+		HeartbeatIntervalMs: 50,
+		StuckThreshold:      15 * time.Minute,
+		DeadThreshold:       3,
+		DeregisterAfter:     200 * time.Millisecond,
+	}
+	r := New(config)
+
+	reg := AgentRegistration{
+		ID:                  "townview/witness",
+		Rig:                 "townview",
+		Role:                RoleWitness,
+		Name:                "witness",
+		HeartbeatIntervalMs: 50,
+		Status:              StatusIdle,
+		Pinned:              true,
+	}
+	r.Register(reg)
+
+	time.Sleep(300 * time.Millisecond)
+	r.checkAgentHealth()
+
+	agent := r.GetAgent(reg.ID)
+	if agent == nil {
+		t.Fatal("Expected pinned agent to stay registered")
+	}
+	if agent.Status != StatusStopped {
+		t.Errorf("Expected pinned agent to be stopped, got %s", agent.Status)
+	}
+}
+
 // TestAgentRegistry_StatusChange_EmitsEvent tests AC-5: Status changes emit events.
 func TestAgentRegistry_StatusChange_EmitsEvent(t *testing.T) {
 	r := NewWithDefaults()
@@ -368,6 +517,100 @@ func TestAgentRegistry_StuckDetection(t *testing.T) {
 	}
 }
 
+// TestAgentRegistry_StuckStarting tests that agents lingering in starting are marked stuck with a reason.
+func TestAgentRegistry_StuckStarting(t *testing.T) {
+	config := DefaultConfig()
+	config.TransitionStuckThreshold = 100 * time.Millisecond // 100ms for testing
+	r := New(config)
+
+	reg := AgentRegistration{
+		ID:   "townview/polecats/obsidian",
+		Rig:  "townview",
+		Role: RolePolecat,
+		Name: "obsidian",
+	}
+	r.Register(reg)
+
+	// Not stuck before the threshold
+	r.checkAgentHealth()
+	if agent := r.GetAgent(reg.ID); agent.Status != StatusStarting {
+		t.Fatalf("Expected status %s, got %s", StatusStarting, agent.Status)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	r.checkAgentHealth()
+
+	agent := r.GetAgent(reg.ID)
+	if agent.Status != StatusStuck {
+		t.Errorf("Expected status %s, got %s", StatusStuck, agent.Status)
+	}
+	if agent.StuckReason == nil || *agent.StuckReason != StuckReasonStarting {
+		t.Errorf("Expected stuck reason %s, got %v", StuckReasonStarting, agent.StuckReason)
+	}
+
+	// A heartbeat with a new status clears the reason
+	r.Heartbeat(Heartbeat{AgentID: reg.ID, Timestamp: time.Now(), Status: StatusIdle})
+	if agent := r.GetAgent(reg.ID); agent.StuckReason != nil {
+		t.Errorf("Expected stuck reason cleared, got %s", *agent.StuckReason)
+	}
+}
+
+// TestAgentRegistry_Degraded tests that agents missing several beats are flagged degraded before dying.
+func TestAgentRegistry_Degraded(t *testing.T) {
+	config := DefaultConfig()
+	config.HeartbeatIntervalMs = 100
+	r := New(config)
+
+	var events []AgentEvent
+	var mu sync.Mutex
+	unsubscribe := r.OnAgentChange(func(event AgentEvent) {
+		mu.Lock()
+		events = append(events, event)
+		mu.Unlock()
+	})
+	defer unsubscribe()
+
+	reg := AgentRegistration{
+		ID:                  "townview/polecats/obsidian",
+		Rig:                 "townview",
+		Role:                RolePolecat,
+		Name:                "obsidian",
+		HeartbeatIntervalMs: 100,
+	}
+	r.Register(reg)
+
+	time.Sleep(250 * time.Millisecond)
+	r.checkAgentHealth()
+	r.checkAgentHealth()
+
+	if agent := r.GetAgent(reg.ID); agent == nil || !agent.Degraded {
+		t.Fatalf("Expected agent to be degraded, got %+v", agent)
+	}
+
+	// A heartbeat clears the flag
+	state := r.Heartbeat(Heartbeat{AgentID: reg.ID, Timestamp: time.Now(), Status: StatusStarting})
+	if state.Degraded {
+		t.Error("Expected degraded cleared after heartbeat")
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	degradedEvents := 0
+	for _, e := range events {
+		if e.EventType == EventDegraded {
+			degradedEvents++
+		}
+	}
+	if degradedEvents != 1 {
+		t.Errorf("Expected 1 degraded event, got %d", degradedEvents)
+	}
+	if last := events[len(events)-1]; last.EventType != EventUpdated || last.Agent.Degraded {
+		t.Errorf("Expected recovery update event, got %s (degraded=%v)", last.EventType, last.Agent.Degraded)
+	}
+}
+
 // TestAgentRegistry_FilterByRole tests filtering agents by role.
 func TestAgentRegistry_FilterByRole(t *testing.T) {
 	r := NewWithDefaults()
@@ -414,6 +657,91 @@ func TestAgentRegistry_FilterByStatus(t *testing.T) {
 	}
 }
 
+// TestAgentRegistry_FilterByLabels tests label matching and that labels survive heartbeats.
+func TestAgentRegistry_FilterByLabels(t *testing.T) {
+	r := NewWithDefaults()
+
+	r.Register(AgentRegistration{ID: "a1", Rig: "r1", Role: RolePolecat, Name: "a1", Labels: map[string]string{"model": "opus", "owner": "jeremy"}})
+	r.Register(AgentRegistration{ID: "a2", Rig: "r1", Role: RolePolecat, Name: "a2", Labels: map[string]string{"model": "sonnet"}})
+	r.Register(AgentRegistration{ID: "a3", Rig: "r1", Role: RolePolecat, Name: "a3"})
+
+	r.Heartbeat(Heartbeat{AgentID: "a1", Timestamp: time.Now(), Status: StatusWorking})
+
+	opus := r.ListAgents(&AgentFilter{Labels: map[string]string{"model": "opus"}})
+	if len(opus) != 1 || opus[0].ID != "a1" {
+		t.Fatalf("Expected only a1 to match model=opus, got %+v", opus)
+	}
+	if opus[0].Labels["owner"] != "jeremy" {
+		t.Errorf("Expected labels to survive heartbeat, got %v", opus[0].Labels)
+	}
+
+	both := r.ListAgents(&AgentFilter{Labels: map[string]string{"model": "opus", "owner": "someone-else"}})
+	if len(both) != 0 {
+		t.Errorf("Expected all pairs to be required, got %d agents", len(both))
+	}
+
+	all := r.ListAgents(&AgentFilter{})
+	if len(all) != 3 {
+		t.Errorf("Expected 3 agents without a label filter, got %d", len(all))
+	}
+}
+
+// TestAgentRegistry_Labels_CarriedForwardAndCopied tests that heartbeats set
+// labels, re-registration keeps them, and callers get their own copy.
+func TestAgentRegistry_Labels_CarriedForwardAndCopied(t *testing.T) {
+	r := NewWithDefaults()
+
+	r.Register(AgentRegistration{ID: "a1", Rig: "r1", Role: RolePolecat, Name: "a1"})
+	r.Heartbeat(Heartbeat{AgentID: "a1", Timestamp: time.Now(), Status: StatusWorking, Labels: map[string]string{"model": "opus"}})
+
+	if got := r.ListAgents(&AgentFilter{Labels: map[string]string{"model": "opus"}}); len(got) != 1 {
+		t.Fatalf("Expected heartbeat labels to be filterable, got %+v", got)
+	}
+
+	// Discovery re-registers without labels on a new session
+	session := "session-2"
+	r.Register(AgentRegistration{ID: "a1", Rig: "r1", Role: RolePolecat, Name: "a1", SessionID: &session})
+	agent := r.GetAgent("a1")
+	if agent == nil || agent.Labels["model"] != "opus" {
+		t.Fatalf("Expected labels to carry over re-registration, got %+v", agent)
+	}
+
+	agent.Labels["model"] = "mutated"
+	r.ListAgents(nil)[0].Labels["model"] = "mutated"
+	if got := r.GetAgent("a1").Labels["model"]; got != "opus" {
+		t.Errorf("Expected returned labels to be copies, registry has %q", got)
+	}
+}
+
+// TestAgentRegistry_GetAgentByBead tests finding the agent working on a bead.
+func TestAgentRegistry_GetAgentByBead(t *testing.T) {
+	r := NewWithDefaults()
+
+	r.Register(AgentRegistration{ID: "a1", Rig: "r1", Role: RolePolecat, Name: "a1"})
+	r.Register(AgentRegistration{ID: "a2", Rig: "r1", Role: RolePolecat, Name: "a2"})
+
+	bead := "to-abc"
+	r.Heartbeat(Heartbeat{AgentID: "a1", Timestamp: time.Now(), Status: StatusWorking, CurrentBead: &bead})
+
+	if agent := r.GetAgentByBead("to-abc"); agent == nil || agent.ID != "a1" {
+		t.Fatalf("Expected a1 to be working on to-abc, got %+v", agent)
+	}
+	if agent := r.GetAgentByBead("to-none"); agent != nil {
+		t.Errorf("Expected no agent for an unclaimed bead, got %s", agent.ID)
+	}
+
+	// A later claim by another agent wins
+	r.Heartbeat(Heartbeat{AgentID: "a2", Timestamp: time.Now().Add(time.Minute), Status: StatusWorking, CurrentBead: &bead})
+	if agent := r.GetAgentByBead("to-abc"); agent == nil || agent.ID != "a2" {
+		t.Errorf("Expected most recent claimant a2, got %+v", agent)
+	}
+
+	working := r.ListAgents(&AgentFilter{CurrentBead: &bead})
+	if len(working) != 2 {
+		t.Errorf("Expected 2 agents on to-abc, got %d", len(working))
+	}
+}
+
 // TestAgentRegistry_Unsubscribe tests that unsubscribe stops events.
 func TestAgentRegistry_Unsubscribe(t *testing.T) {
 	r := NewWithDefaults()
@@ -450,3 +778,157 @@ func TestAgentRegistry_Unsubscribe(t *testing.T) {
 	}
 	mu.Unlock()
 }
+
+// TestAgentRegistry_SubscribeWithSnapshot tests that existing agents are replayed before live events.
+func TestAgentRegistry_SubscribeWithSnapshot(t *testing.T) {
+	r := NewWithDefaults()
+
+	r.Register(AgentRegistration{ID: "a1", Rig: "r1", Role: RolePolecat, Name: "a1"})
+	r.Register(AgentRegistration{ID: "a2", Rig: "r1", Role: RoleCrew, Name: "a2"})
+
+	var received []AgentEvent
+	var mu sync.Mutex
+
+	unsubscribe := r.SubscribeWithSnapshot(func(event AgentEvent) {
+		mu.Lock()
+		received = append(received, event)
+		mu.Unlock()
+	})
+	defer unsubscribe()
+
+	// Snapshot is delivered synchronously
+	mu.Lock()
+	if len(received) != 2 {
+		t.Fatalf("Expected 2 snapshot events, got %d", len(received))
+	}
+	for _, e := range received {
+		if e.EventType != EventRegistered {
+			t.Errorf("Expected snapshot event type %s, got %s", EventRegistered, e.EventType)
+		}
+	}
+	mu.Unlock()
+
+	// Live events follow the snapshot
+	r.Register(AgentRegistration{ID: "a3", Rig: "r1", Role: RolePolecat, Name: "a3"})
+	time.Sleep(50 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 3 {
+		t.Fatalf("Expected 3 events after live registration, got %d", len(received))
+	}
+	if received[2].Agent.ID != "a3" {
+		t.Errorf("Expected live event for a3, got %s", received[2].Agent.ID)
+	}
+}
+
+// TestAgentRegistry_SubscribeWithSnapshot_KeepsLiveEventsDuringSlowSnapshot tests
+// that live events emitted while the snapshot is delivered are queued, not dropped.
+func TestAgentRegistry_SubscribeWithSnapshot_KeepsLiveEventsDuringSlowSnapshot(t *testing.T) {
+	r := NewWithDefaults()
+	r.Register(AgentRegistration{ID: "existing", Rig: "r1", Role: RolePolecat, Name: "existing"})
+
+	const live = 200
+	var mu sync.Mutex
+	var received []string
+	emitted := make(chan struct{})
+
+	unsubscribe := r.SubscribeWithSnapshot(func(event AgentEvent) {
+		if event.Agent.ID == "existing" {
+			// Hold up snapshot delivery until far more than any fixed buffer is emitted
+			go func() {
+				for i := 0; i < live; i++ {
+					id := fmt.Sprintf("live-%d", i)
+					r.Register(AgentRegistration{ID: id, Rig: "r1", Role: RolePolecat, Name: id})
+				}
+				close(emitted)
+			}()
+			<-emitted
+		}
+		mu.Lock()
+		received = append(received, event.Agent.ID)
+		mu.Unlock()
+	})
+	defer unsubscribe()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		n := len(received)
+		mu.Unlock()
+		if n == live+1 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != live+1 {
+		t.Fatalf("Expected snapshot plus %d live events, got %d", live, len(received))
+	}
+	if received[0] != "existing" {
+		t.Errorf("Expected snapshot first, got %s", received[0])
+	}
+	for i := 0; i < live; i++ {
+		if want := fmt.Sprintf("live-%d", i); received[i+1] != want {
+			t.Fatalf("Expected live events in order, got %s at %d", received[i+1], i+1)
+		}
+	}
+}
+
+// TestAgentRegistry_SubscribeWithSnapshot_DropsOldestWhenStalled tests that a
+// stalled callback's queue stays capped and keeps the newest events.
+func TestAgentRegistry_SubscribeWithSnapshot_DropsOldestWhenStalled(t *testing.T) {
+	r := NewWithDefaults()
+
+	var mu sync.Mutex
+	var received []string
+	stalled := make(chan struct{})
+	release := make(chan struct{})
+	unsubscribe := r.SubscribeWithSnapshot(func(event AgentEvent) {
+		if event.Agent.ID == "first" {
+			close(stalled)
+			<-release
+		}
+		mu.Lock()
+		received = append(received, event.Agent.ID)
+		mu.Unlock()
+	})
+	defer unsubscribe()
+
+	r.Register(AgentRegistration{ID: "first", Rig: "r1", Role: RolePolecat, Name: "first"})
+	<-stalled
+
+	// Overflow the queue while the callback is stuck on "first"
+	const queued = snapshotSubscriberLimit + 100
+	for i := 0; i < queued; i++ {
+		id := fmt.Sprintf("live-%d", i)
+		r.Register(AgentRegistration{ID: id, Rig: "r1", Role: RolePolecat, Name: id})
+	}
+	close(release)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		n := len(received)
+		mu.Unlock()
+		if n == snapshotSubscriberLimit+1 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != snapshotSubscriberLimit+1 {
+		t.Fatalf("Expected %d events with the queue capped, got %d", snapshotSubscriberLimit+1, len(received))
+	}
+	if want := fmt.Sprintf("live-%d", queued-snapshotSubscriberLimit); received[1] != want {
+		t.Errorf("Expected the oldest events dropped, first kept is %s, want %s", received[1], want)
+	}
+	if want := fmt.Sprintf("live-%d", queued-1); received[len(received)-1] != want {
+		t.Errorf("Expected the newest event kept, got %s, want %s", received[len(received)-1], want)
+	}
+}
@@ -3,6 +3,9 @@
 package rigmanager
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
@@ -13,8 +16,11 @@ import (
 	"time"
 
 	"github.com/gastown/townview/internal/events"
+	"github.com/gastown/townview/internal/execlimit"
 	"github.com/gastown/townview/internal/query"
 	"github.com/gastown/townview/internal/registry"
+	"github.com/gastown/townview/internal/session"
+	"github.com/gastown/townview/internal/telemetry"
 	"github.com/gastown/townview/internal/types"
 )
 
@@ -23,13 +29,66 @@ type Rig struct {
 	ID           string         `json:"id"`
 	Name         string         `json:"name"`
 	Prefix       string         `json:"prefix"`
-	Path         string         `json:"path"`         // Relative path from town root
-	AbsPath      string         `json:"abs_path"`     // Absolute path
-	BeadsPath    string         `json:"beads_path"`   // Path to .beads directory
-	DBPath       string         `json:"db_path"`      // Path to beads.db
-	QueryService *query.Service `json:"-"`            // Query service for this rig
+	Path         string         `json:"path"`       // Relative path from town root
+	AbsPath      string         `json:"abs_path"`   // Absolute path
+	BeadsPath    string         `json:"beads_path"` // Path to .beads directory
+	DBPath       string         `json:"db_path"`    // Path to beads.db
+	QueryService *query.Service `json:"-"`          // Query service for this rig
+
+	// Outcome of the last query against the rig's database
+	healthMu         sync.Mutex
+	lastQueryFailed  bool
+	lastQueryError   string
+	lastQueryErrorAt time.Time
 }
 
+// recordQuery notes the outcome of a query against the rig's database, logging
+// when its db health changes from ok to error and back. A stale result counts
+// as a failure: the database didn't answer.
+func (r *Rig) recordQuery(err error) {
+	r.healthMu.Lock()
+	defer r.healthMu.Unlock()
+
+	if err == nil {
+		if r.lastQueryFailed {
+			slog.Info("Rig database health changed from error to ok after a successful query", "rig", r.ID)
+		}
+		r.lastQueryFailed = false
+		return
+	}
+	if !r.lastQueryFailed {
+		slog.Warn("Rig database health changed from ok to error after a failed query", "rig", r.ID, "error", err)
+	}
+	r.lastQueryFailed = true
+	r.lastQueryError = err.Error()
+	r.lastQueryErrorAt = time.Now()
+}
+
+// dbHealth reports the rig's database health. The last error is kept after
+// the database recovers so a transient outage stays visible.
+func (r *Rig) dbHealth() types.RigDBHealth {
+	if r.QueryService == nil {
+		return types.RigDBHealth{Status: types.RigDBUnavailable}
+	}
+
+	r.healthMu.Lock()
+	defer r.healthMu.Unlock()
+
+	health := types.RigDBHealth{Status: types.RigDBOK}
+	if r.lastQueryFailed {
+		health.Status = types.RigDBError
+	}
+	if r.lastQueryError != "" {
+		at := r.lastQueryErrorAt
+		health.LastError = r.lastQueryError
+		health.LastErrorAt = &at
+	}
+	return health
+}
+
+// ErrRigNotFound is wrapped by errors for a rig the manager does not track.
+var ErrRigNotFound = errors.New("rig not found")
+
 // Manager manages multiple rigs and their services.
 type Manager struct {
 	townRoot      string
@@ -37,13 +96,100 @@ type Manager struct {
 	eventStore    *events.Store
 	agentRegistry *registry.Registry
 	mu            sync.RWMutex
+
+	// Agent-bead enrichment cache, invalidated on bead.* events
+	agentBeadsTTL     time.Duration
+	agentBeads        map[string]query.AgentBead
+	agentBeadsExpires time.Time
+	agentBeadsMu      sync.Mutex
+	eventCh           <-chan events.Event
+
+	serveStaleOnError bool
+
+	degradedAfterMissed  int
+	unhealthyAfterMissed int
+
+	// Optional sink for convoy progress history
+	progressRecorder ProgressRecorder
+
+	// Reports read-only maintenance mode; background writes pause while true
+	readOnly func() bool
+
+	execLimiter *execlimit.Limiter
+
+	// Issue-list filters every rig keeps warm in its cache
+	warmFilters []query.IssueFilter
+
+	// Recent event counts per rig, refreshed at most every rigActivityTTL
+	rigActivity        map[string]int
+	rigActivityExpires time.Time
+	rigActivityMu      sync.Mutex
+}
+
+// Rig activity is the number of events in the last rigActivityWindow.
+const (
+	rigActivityWindow = time.Hour
+	rigActivityTTL    = 30 * time.Second
+)
+
+// ProgressRecorder persists convoy progress snapshots for trend reporting.
+type ProgressRecorder interface {
+	RecordConvoyProgress(snapshot telemetry.ProgressSnapshot) error
 }
 
 // Config holds configuration for the RigManager.
 type Config struct {
-	TownRoot string
+	TownRoot      string
+	AgentBeadsTTL time.Duration // How long agent beads are cached for discovery (default: 2 minutes)
+
+	// Serve the last good cached result when a rig's database query fails
+	ServeStaleOnError bool
+
+	// Missed heartbeats before a live agent's role shows as degraded
+	// (default: DefaultDegradedAfterMissed) or unhealthy (default: DefaultUnhealthyAfterMissed).
+	// Degraded must come before unhealthy; New rejects anything else.
+	DegradedAfterMissed  int
+	UnhealthyAfterMissed int
+
+	// Shared cap on concurrent subprocesses; nil runs tmux without one
+	ExecLimiter *execlimit.Limiter
 }
 
+// Default heartbeat thresholds for the rig health roll-up.
+const (
+	DefaultDegradedAfterMissed  = 2
+	DefaultUnhealthyAfterMissed = 3
+)
+
+// healthThresholds returns the configured health thresholds with defaults
+// filled in for zero values. Negative thresholds, or a degraded threshold not
+// below the unhealthy one, are rejected.
+func healthThresholds(config Config) (degraded, unhealthy int, err error) {
+	if config.DegradedAfterMissed < 0 || config.UnhealthyAfterMissed < 0 {
+		return 0, 0, fmt.Errorf("health thresholds must not be negative (degraded %d, unhealthy %d)",
+			config.DegradedAfterMissed, config.UnhealthyAfterMissed)
+	}
+
+	degraded = config.DegradedAfterMissed
+	if degraded == 0 {
+		degraded = DefaultDegradedAfterMissed
+	}
+	unhealthy = config.UnhealthyAfterMissed
+	if unhealthy == 0 {
+		unhealthy = DefaultUnhealthyAfterMissed
+	}
+	if degraded >= unhealthy {
+		return 0, 0, fmt.Errorf("degraded threshold (%d missed heartbeats) must be below unhealthy threshold (%d)", degraded, unhealthy)
+	}
+	return degraded, unhealthy, nil
+}
+
+// Role statuses reported by ComputeAgentHealth when an agent has stopped heartbeating.
+const (
+	HealthDegraded  = "degraded"
+	HealthUnhealthy = "unhealthy"
+)
+
 // New creates a new RigManager.
 func New(config Config, eventStore *events.Store, agentRegistry *registry.Registry) (*Manager, error) {
 	if config.TownRoot == "" {
@@ -55,11 +201,35 @@ func New(config Config, eventStore *events.Store, agentRegistry *registry.Regist
 		return nil, fmt.Errorf("town root does not exist: %s", config.TownRoot)
 	}
 
+	agentBeadsTTL := config.AgentBeadsTTL
+	if agentBeadsTTL == 0 {
+		agentBeadsTTL = 2 * time.Minute
+	}
+
+	degradedAfter, unhealthyAfter, err := healthThresholds(config)
+	if err != nil {
+		return nil, err
+	}
+
 	m := &Manager{
 		townRoot:      config.TownRoot,
 		rigs:          make(map[string]*Rig),
 		eventStore:    eventStore,
 		agentRegistry: agentRegistry,
+		agentBeadsTTL: agentBeadsTTL,
+
+		serveStaleOnError: config.ServeStaleOnError,
+
+		degradedAfterMissed:  degradedAfter,
+		unhealthyAfterMissed: unhealthyAfter,
+
+		execLimiter: config.ExecLimiter,
+	}
+
+	// Invalidate cached agent beads whenever beads change
+	if eventStore != nil {
+		m.eventCh = eventStore.Subscribe(events.EventFilter{})
+		go m.eventLoop()
 	}
 
 	// Discover rigs
@@ -77,7 +247,8 @@ func New(config Config, eventStore *events.Store, agentRegistry *registry.Regist
 	return m, nil
 }
 
-// discoverRigs finds all rigs in the town.
+// discoverRigs finds all rigs in the town. Rigs missing from a scan stay
+// tracked: a miss may be transient, and only RemoveRig drops a rig's history.
 func (m *Manager) discoverRigs() error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -122,6 +293,39 @@ func (m *Manager) discoverRigs() error {
 	return nil
 }
 
+// RemoveRig stops tracking a rig, closes its QueryService and purges its
+// events and issue snapshots so a deleted rig's history does not outlive it.
+// Discovery re-adds the rig if its beads directory is still present. Returns
+// an error wrapping ErrRigNotFound for an untracked rig.
+func (m *Manager) RemoveRig(rigID string) error {
+	m.mu.Lock()
+	rig, ok := m.rigs[rigID]
+	delete(m.rigs, rigID)
+	m.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrRigNotFound, rigID)
+	}
+
+	if rig.QueryService != nil {
+		if err := rig.QueryService.Close(); err != nil {
+			slog.Error("Failed to close QueryService", "rig", rigID, "error", err)
+		}
+	}
+
+	if m.eventStore != nil {
+		purged, err := m.eventStore.PurgeRigEvents(rigID)
+		if err != nil {
+			return err
+		}
+		snapshots, err := m.eventStore.PurgeRigSnapshots(rigID)
+		if err != nil {
+			return err
+		}
+		slog.Info("Removed rig", "id", rigID, "events_purged", purged, "snapshots_purged", snapshots)
+	}
+	return nil
+}
+
 // addRig adds a rig to the manager and initializes its QueryService.
 // Idempotent: skips rigs already tracked.
 func (m *Manager) addRig(id, name, prefix, relPath, beadsPath string) {
@@ -149,8 +353,9 @@ func (m *Manager) addRig(id, name, prefix, relPath, beadsPath string) {
 
 	// Initialize QueryService for this rig
 	queryConfig := query.Config{
-		DBPath:      dbPath,
-		CacheConfig: query.DefaultCacheConfig(),
+		DBPath:            dbPath,
+		CacheConfig:       query.DefaultCacheConfig(),
+		ServeStaleOnError: m.serveStaleOnError,
 	}
 
 	qs, err := query.New(queryConfig, m.agentRegistry, m.eventStore)
@@ -159,6 +364,10 @@ func (m *Manager) addRig(id, name, prefix, relPath, beadsPath string) {
 		return
 	}
 
+	if len(m.warmFilters) > 0 {
+		qs.SetWarmFilters(m.warmFilters)
+	}
+
 	rig.QueryService = qs
 	m.rigs[id] = rig
 }
@@ -215,6 +424,10 @@ func (m *Manager) inferPrefix(name, beadsPath string) string {
 
 // Close shuts down all QueryServices.
 func (m *Manager) Close() error {
+	if m.eventCh != nil {
+		m.eventStore.Unsubscribe(m.eventCh)
+	}
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -232,6 +445,8 @@ func (m *Manager) Close() error {
 
 // ListRigs returns all discovered rigs.
 func (m *Manager) ListRigs() []types.Rig {
+	activity := m.getRigActivity()
+
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
@@ -247,7 +462,8 @@ func (m *Manager) ListRigs() []types.Rig {
 
 		// Get counts from QueryService
 		if rig.QueryService != nil {
-			issues, _ := rig.QueryService.ListIssues(query.IssueFilter{})
+			issues, err := rig.QueryService.ListIssues(query.IssueFilter{})
+			rig.recordQuery(err)
 			r.IssueCount = len(issues)
 
 			openCount := 0
@@ -264,22 +480,31 @@ func (m *Manager) ListRigs() []types.Rig {
 			rigID := rig.ID
 			agents := m.agentRegistry.ListAgents(&registry.AgentFilter{Rig: &rigID})
 			r.AgentCount = len(agents)
-			health := m.computeAgentHealth(agents)
+			health := m.ComputeAgentHealth(agents)
 			r.AgentHealth = &health
+			r.RoleCounts = CountAgentRoles(agents)
 		}
 
+		r.RecentEvents = activity[rig.ID]
+
+		health := rig.dbHealth()
+		r.DBHealth = &health
+
 		result = append(result, r)
 	}
 
 	return result
 }
 
-// computeAgentHealth computes health status for each role.
-func (m *Manager) computeAgentHealth(agents []registry.AgentState) types.AgentHealth {
+// ComputeAgentHealth computes health status for each role. An agent that has
+// missed heartbeats is reported as degraded or unhealthy rather than by its
+// last-known status, so a silent agent doesn't look healthy.
+func (m *Manager) ComputeAgentHealth(agents []registry.AgentState) types.AgentHealth {
 	health := types.AgentHealth{}
+	now := time.Now()
 
 	for _, agent := range agents {
-		status := string(agent.Status)
+		status := m.roleStatus(agent, now)
 		switch agent.Role {
 		case registry.RoleWitness:
 			health.Witness = &status
@@ -293,6 +518,40 @@ func (m *Manager) computeAgentHealth(agents []registry.AgentState) types.AgentHe
 	return health
 }
 
+// roleStatus returns the status shown for an agent in the health roll-up.
+// Missed beats are counted from the last heartbeat's age as well as the
+// registry's count, which only updates on its periodic health check.
+// Stuck and stopped agents keep their status; it already says what's wrong.
+func (m *Manager) roleStatus(agent registry.AgentState, now time.Time) string {
+	if agent.Status == registry.StatusStuck || agent.Status == registry.StatusStopped {
+		return string(agent.Status)
+	}
+
+	missed := agent.MissedHeartbeats
+	if interval := time.Duration(agent.HeartbeatIntervalMs) * time.Millisecond; interval > 0 && !agent.LastHeartbeat.IsZero() {
+		if n := int(now.Sub(agent.LastHeartbeat) / interval); n > missed {
+			missed = n
+		}
+	}
+
+	switch {
+	case missed >= m.unhealthyAfterMissed:
+		return HealthUnhealthy
+	case missed >= m.degradedAfterMissed || agent.Degraded:
+		return HealthDegraded
+	}
+	return string(agent.Status)
+}
+
+// CountAgentRoles returns the number of agents in each role.
+func CountAgentRoles(agents []registry.AgentState) map[registry.AgentRole]int {
+	counts := make(map[registry.AgentRole]int)
+	for _, agent := range agents {
+		counts[agent.Role]++
+	}
+	return counts
+}
+
 // GetRig returns a specific rig by ID.
 func (m *Manager) GetRig(rigID string) (*Rig, error) {
 	m.mu.RLock()
@@ -300,7 +559,7 @@ func (m *Manager) GetRig(rigID string) (*Rig, error) {
 
 	rig, ok := m.rigs[rigID]
 	if !ok {
-		return nil, fmt.Errorf("rig not found: %s", rigID)
+		return nil, fmt.Errorf("%w: %s", ErrRigNotFound, rigID)
 	}
 	return rig, nil
 }
@@ -315,14 +574,43 @@ func (m *Manager) ListIssues(rigID string, filter query.IssueFilter) ([]types.Is
 		return nil, fmt.Errorf("rig %s has no query service", rigID)
 	}
 	issues, err := rig.QueryService.ListIssues(filter)
-	if err != nil {
+	rig.recordQuery(err)
+	if err != nil && !errors.Is(err, query.ErrStale) {
 		return nil, err
 	}
 	// Set RigID on each issue for frontend grouping
 	for i := range issues {
 		issues[i].RigID = rigID
 	}
-	return issues, nil
+	return issues, err
+}
+
+// StreamIssues calls fn for each issue in a rig matching the filter, as rows
+// are read. Unlike ListIssues it bypasses the cache.
+func (m *Manager) StreamIssues(rigID string, filter query.IssueFilter, fn func(types.Issue) error) error {
+	rig, err := m.GetRig(rigID)
+	if err != nil {
+		return err
+	}
+	if rig.QueryService == nil {
+		return fmt.Errorf("rig %s has no query service", rigID)
+	}
+	return rig.QueryService.StreamIssues(filter, func(issue types.Issue) error {
+		issue.RigID = rigID
+		return fn(issue)
+	})
+}
+
+// GetDependencyGraph returns the bounded dependency graph below an issue in a rig.
+func (m *Manager) GetDependencyGraph(rigID, issueID string, opts query.GraphOptions) (*query.DependencyGraph, error) {
+	rig, err := m.GetRig(rigID)
+	if err != nil {
+		return nil, err
+	}
+	if rig.QueryService == nil {
+		return nil, fmt.Errorf("rig %s has no query service", rigID)
+	}
+	return rig.QueryService.GetDependencyGraphWithOptions(issueID, opts)
 }
 
 // GetIssue returns a specific issue from a rig.
@@ -346,7 +634,8 @@ func (m *Manager) ListAllIssues(filter query.IssueFilter) []types.Issue {
 	for _, rig := range m.rigs {
 		if rig.QueryService != nil {
 			issues, err := rig.QueryService.ListIssues(filter)
-			if err != nil {
+			rig.recordQuery(err)
+			if err != nil && !errors.Is(err, query.ErrStale) {
 				slog.Debug("Failed to list issues for rig", "rig", rig.ID, "error", err)
 				continue
 			}
@@ -372,6 +661,41 @@ func (m *Manager) GetDependencies(rigID, issueID string) (*types.IssueDependenci
 	return rig.QueryService.GetDependencies(issueID)
 }
 
+// GetUnblockedBy returns the issues in a rig that closing issueID would unblock.
+func (m *Manager) GetUnblockedBy(rigID, issueID string) ([]types.Issue, error) {
+	rig, err := m.GetRig(rigID)
+	if err != nil {
+		return nil, err
+	}
+	if rig.QueryService == nil {
+		return nil, fmt.Errorf("rig %s has no query service", rigID)
+	}
+	issues, err := rig.QueryService.GetUnblockedBy(issueID)
+	for i := range issues {
+		issues[i].RigID = rigID
+	}
+	return issues, err
+}
+
+// SetProgressRecorder records a snapshot every time convoy progress is computed.
+// Call before serving requests.
+func (m *Manager) SetProgressRecorder(recorder ProgressRecorder) {
+	m.progressRecorder = recorder
+}
+
+// SetReadOnlyCheck installs the predicate reporting read-only maintenance
+// mode. While it returns true the manager skips its own background writes:
+// convoy progress recording, issue snapshots and agent status events. Call
+// before serving requests.
+func (m *Manager) SetReadOnlyCheck(readOnly func() bool) {
+	m.readOnly = readOnly
+}
+
+// writesPaused reports whether read-only maintenance mode is on.
+func (m *Manager) writesPaused() bool {
+	return m.readOnly != nil && m.readOnly()
+}
+
 // GetConvoyProgress returns progress for a convoy/molecule with cross-rig resolution.
 // This handles external references (external:rig:issue-id) by querying the target rig.
 func (m *Manager) GetConvoyProgress(rigID, issueID string) (*types.ConvoyProgress, error) {
@@ -428,6 +752,18 @@ func (m *Manager) GetConvoyProgress(rigID, issueID string) (*types.ConvoyProgres
 		percentage = float64(completed) / float64(total) * 100
 	}
 
+	if m.progressRecorder != nil && !m.writesPaused() {
+		snapshot := telemetry.ProgressSnapshot{
+			ConvoyID:  issueID,
+			Rig:       rigID,
+			Completed: completed,
+			Total:     total,
+		}
+		if err := m.progressRecorder.RecordConvoyProgress(snapshot); err != nil {
+			slog.Warn("Failed to record convoy progress", "rigId", rigID, "convoyId", issueID, "error", err)
+		}
+	}
+
 	return &types.ConvoyProgress{
 		Completed:  completed,
 		Total:      total,
@@ -453,6 +789,30 @@ func (m *Manager) resolveIssueStatus(rigID, issueID string) string {
 	return issue.Status
 }
 
+// ListDependencyEdges returns all dependency edges in a rig.
+func (m *Manager) ListDependencyEdges(rigID string) ([]types.Dependency, error) {
+	rig, err := m.GetRig(rigID)
+	if err != nil {
+		return nil, err
+	}
+	if rig.QueryService == nil {
+		return nil, fmt.Errorf("rig %s has no query service", rigID)
+	}
+	return rig.QueryService.ListDependencyEdges()
+}
+
+// CountIssuesByStatus returns the number of live issues per status in a rig.
+func (m *Manager) CountIssuesByStatus(rigID string) (map[string]int, error) {
+	rig, err := m.GetRig(rigID)
+	if err != nil {
+		return nil, err
+	}
+	if rig.QueryService == nil {
+		return nil, fmt.Errorf("rig %s has no query service", rigID)
+	}
+	return rig.QueryService.CountIssuesByStatus()
+}
+
 // GetRawDependencies returns raw dependency entries for an issue.
 func (m *Manager) GetRawDependencies(rigID, issueID string) ([]types.IssueDependency, error) {
 	rig, err := m.GetRig(rigID)
@@ -465,6 +825,18 @@ func (m *Manager) GetRawDependencies(rigID, issueID string) ([]types.IssueDepend
 	return rig.QueryService.GetRawDependencies(issueID)
 }
 
+// GetRawDependents returns the raw dependency entries pointing at an issue.
+func (m *Manager) GetRawDependents(rigID, issueID string) ([]types.IssueDependency, error) {
+	rig, err := m.GetRig(rigID)
+	if err != nil {
+		return nil, err
+	}
+	if rig.QueryService == nil {
+		return nil, fmt.Errorf("rig %s has no query service", rigID)
+	}
+	return rig.QueryService.GetRawDependents(issueID)
+}
+
 // GetAllAgentBeads returns agent beads from all rigs.
 func (m *Manager) GetAllAgentBeads() map[string]query.AgentBead {
 	m.mu.RLock()
@@ -474,6 +846,7 @@ func (m *Manager) GetAllAgentBeads() map[string]query.AgentBead {
 	for _, rig := range m.rigs {
 		if rig.QueryService != nil {
 			beads, err := rig.QueryService.GetAgentBeads()
+			rig.recordQuery(err)
 			if err != nil {
 				slog.Debug("Failed to get agent beads for rig", "rig", rig.ID, "error", err)
 				continue
@@ -486,6 +859,62 @@ func (m *Manager) GetAllAgentBeads() map[string]query.AgentBead {
 	return result
 }
 
+// getRigActivity returns recent event counts per rig, cached for rigActivityTTL
+// so listing rigs doesn't query the event store every time.
+func (m *Manager) getRigActivity() map[string]int {
+	if m.eventStore == nil {
+		return nil
+	}
+
+	m.rigActivityMu.Lock()
+	defer m.rigActivityMu.Unlock()
+
+	if m.rigActivity != nil && time.Now().Before(m.rigActivityExpires) {
+		return m.rigActivity
+	}
+
+	since := time.Now().Add(-rigActivityWindow)
+	counts, err := m.eventStore.CountByRig(events.EventFilter{StartTime: &since})
+	if err != nil {
+		slog.Debug("Failed to count recent rig events", "error", err)
+		return m.rigActivity
+	}
+	m.rigActivity = counts
+	m.rigActivityExpires = time.Now().Add(rigActivityTTL)
+	return m.rigActivity
+}
+
+// getAgentBeads returns the cached agent-bead map, reloading it from all rigs
+// when the cache has expired or been invalidated.
+func (m *Manager) getAgentBeads() map[string]query.AgentBead {
+	m.agentBeadsMu.Lock()
+	defer m.agentBeadsMu.Unlock()
+
+	if m.agentBeads != nil && time.Now().Before(m.agentBeadsExpires) {
+		return m.agentBeads
+	}
+
+	m.agentBeads = m.GetAllAgentBeads()
+	m.agentBeadsExpires = time.Now().Add(m.agentBeadsTTL)
+	return m.agentBeads
+}
+
+// invalidateAgentBeads drops the cached agent-bead map.
+func (m *Manager) invalidateAgentBeads() {
+	m.agentBeadsMu.Lock()
+	m.agentBeads = nil
+	m.agentBeadsMu.Unlock()
+}
+
+// eventLoop invalidates the agent-bead cache on bead events.
+func (m *Manager) eventLoop() {
+	for event := range m.eventCh {
+		if strings.HasPrefix(event.Type, "bead.") {
+			m.invalidateAgentBeads()
+		}
+	}
+}
+
 // RefreshRig forces a refresh of rig data (clears cache).
 func (m *Manager) RefreshRig(rigID string) error {
 	rig, err := m.GetRig(rigID)
@@ -498,6 +927,47 @@ func (m *Manager) RefreshRig(rigID string) error {
 	return nil
 }
 
+// SetWarmFilters has every rig, including rigs discovered later, keep the
+// given issue-list filters warm in its cache. See query.Service.SetWarmFilters.
+func (m *Manager) SetWarmFilters(filters []query.IssueFilter) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.warmFilters = filters
+	for _, rig := range m.rigs {
+		if rig.QueryService != nil {
+			rig.QueryService.SetWarmFilters(filters)
+		}
+	}
+}
+
+// GetCacheStats returns query cache statistics for a rig.
+func (m *Manager) GetCacheStats(rigID string) (*query.CacheStats, error) {
+	rig, err := m.GetRig(rigID)
+	if err != nil {
+		return nil, err
+	}
+	if rig.QueryService == nil {
+		return nil, fmt.Errorf("rig %s has no query service", rigID)
+	}
+	stats := rig.QueryService.GetCacheStats()
+	return &stats, nil
+}
+
+// GetAllCacheStats returns query cache statistics keyed by rig ID.
+func (m *Manager) GetAllCacheStats() map[string]query.CacheStats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make(map[string]query.CacheStats, len(m.rigs))
+	for id, rig := range m.rigs {
+		if rig.QueryService != nil {
+			result[id] = rig.QueryService.GetCacheStats()
+		}
+	}
+	return result
+}
+
 // RefreshAll forces a refresh of all rigs.
 func (m *Manager) RefreshAll() {
 	m.mu.RLock()
@@ -510,6 +980,29 @@ func (m *Manager) RefreshAll() {
 	}
 }
 
+// DiscoveryResult reports topology counts after a rediscovery.
+type DiscoveryResult struct {
+	Rigs   int `json:"rigs"`
+	Agents int `json:"agents"`
+}
+
+// Rediscover rescans the town for rigs and tmux for agents immediately,
+// without waiting for the background loops.
+func (m *Manager) Rediscover() (DiscoveryResult, error) {
+	if err := m.discoverRigs(); err != nil {
+		return DiscoveryResult{}, err
+	}
+	m.discoverAgents()
+
+	m.mu.RLock()
+	result := DiscoveryResult{Rigs: len(m.rigs)}
+	m.mu.RUnlock()
+	if m.agentRegistry != nil {
+		result.Agents = len(m.agentRegistry.ListAgents(nil))
+	}
+	return result, nil
+}
+
 // rigDiscoveryLoop periodically scans for new rigs in the town root.
 func (m *Manager) rigDiscoveryLoop() {
 	ticker := time.NewTicker(60 * time.Second)
@@ -522,6 +1015,116 @@ func (m *Manager) rigDiscoveryLoop() {
 	}
 }
 
+// StartSnapshots snapshots every rig's changed issues into the event store
+// now and then every interval, for history diffs. Call once, after
+// SetReadOnlyCheck, so a server started read-only never writes a snapshot.
+func (m *Manager) StartSnapshots(interval time.Duration) {
+	if interval <= 0 || m.eventStore == nil {
+		return
+	}
+	go m.snapshotLoop(interval)
+}
+
+// StartAgentStatusEvents records an agent.status_changed event in the event
+// store each time a registered agent's status changes, skipped in read-only
+// mode. Call the returned function to stop.
+func (m *Manager) StartAgentStatusEvents() (stop func()) {
+	if m.agentRegistry == nil || m.eventStore == nil {
+		return func() {}
+	}
+
+	// Only touched by the subscription's callback, which runs one event at a time
+	statuses := make(map[string]registry.AgentStatus)
+	return m.agentRegistry.SubscribeWithSnapshot(func(event registry.AgentEvent) {
+		agent := event.Agent
+		if event.EventType == registry.EventDeregistered {
+			delete(statuses, agent.ID)
+			return
+		}
+		old, known := statuses[agent.ID]
+		statuses[agent.ID] = agent.Status
+		if !known || old == agent.Status || m.writesPaused() {
+			return
+		}
+
+		if err := m.eventStore.Emit(events.TypeAgentStatusChanged, "townview/server", agent.Rig, events.AgentStatusChangedPayload{
+			AgentID:  agent.ID,
+			Rig:      agent.Rig,
+			OldValue: string(old),
+			NewValue: string(agent.Status),
+		}); err != nil {
+			slog.Warn("Failed to record agent status change", "agentId", agent.ID, "error", err)
+		}
+	})
+}
+
+// snapshotLoop snapshots every rig's issues at startup and then every interval.
+func (m *Manager) snapshotLoop(interval time.Duration) {
+	m.SnapshotAllIssues()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		m.SnapshotAllIssues()
+	}
+}
+
+// SnapshotAllIssues snapshots the issues of every rig, logging rigs that fail.
+func (m *Manager) SnapshotAllIssues() {
+	m.mu.RLock()
+	rigIDs := make([]string, 0, len(m.rigs))
+	for id := range m.rigs {
+		rigIDs = append(rigIDs, id)
+	}
+	m.mu.RUnlock()
+
+	for _, rigID := range rigIDs {
+		if _, err := m.SnapshotIssues(rigID); err != nil {
+			slog.Warn("Failed to snapshot issues", "rig", rigID, "error", err)
+		}
+	}
+}
+
+// SnapshotIssues records the current state of each issue in a rig whose state
+// changed since its last snapshot, plus a deletion snapshot for each issue that
+// has disappeared, reading the rig's database directly rather than the cache.
+// Returns how many snapshots were stored; nothing is stored in
+// read-only mode.
+func (m *Manager) SnapshotIssues(rigID string) (int, error) {
+	if m.eventStore == nil || m.writesPaused() {
+		return 0, nil
+	}
+
+	now := time.Now()
+	states := make(map[string][]byte)
+	err := m.StreamIssues(rigID, query.IssueFilter{IncludeDescription: true}, func(issue types.Issue) error {
+		state, err := json.Marshal(issue)
+		if err != nil {
+			return fmt.Errorf("marshal issue %s: %w", issue.ID, err)
+		}
+		states[issue.ID] = state
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	stored, err := m.eventStore.RecordIssueSnapshots(rigID, now, states)
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := m.eventStore.RecordDeletedIssues(rigID, now, states)
+	if err != nil {
+		return 0, err
+	}
+	stored += deleted
+	if stored > 0 {
+		slog.Debug("Snapshotted changed issues", "rig", rigID, "count", stored)
+	}
+	return stored, nil
+}
+
 // agentDiscoveryLoop periodically discovers agents from tmux sessions.
 func (m *Manager) agentDiscoveryLoop() {
 	ticker := time.NewTicker(30 * time.Second)
@@ -532,23 +1135,49 @@ func (m *Manager) agentDiscoveryLoop() {
 	}
 }
 
+// discoveredAgent is one agent found during discovery, before registration.
+type discoveredAgent struct {
+	rig, role, name string
+	sessionID       *string
+	status          registry.AgentStatus
+}
+
 // discoverAgents discovers agents from tmux sessions and registers them.
-// Sessions are expected to follow the pattern: gt-{rig}-{role} or gt-{rig}-{role}-{name}
+// Session names are parsed with session.Parse (gt-{rig}-{role}, gt-{rig}-{role}-{name}, hq-{role}).
 // Always registers expected singleton roles (witness, refinery) for each rig, even if stopped.
+// Agents are merged by canonical ID (rig+role+name) so each is registered once per pass.
 func (m *Manager) discoverAgents() {
 	if m.agentRegistry == nil {
 		return
 	}
 
-	// Get agent beads from all rigs for hook_bead enrichment
-	agentBeads := m.GetAllAgentBeads()
+	// Get agent beads from all rigs for hook_bead enrichment (cached)
+	agentBeads := m.getAgentBeads()
+
+	found := make(map[string]*discoveredAgent)
+	var order []string
+	add := func(a discoveredAgent) {
+		id := session.AgentID(a.rig, a.role, a.name)
+		if existing, ok := found[id]; ok {
+			// A live session beats the stopped placeholder
+			if a.status == registry.StatusRunning {
+				existing.status = a.status
+			}
+			if existing.sessionID == nil {
+				existing.sessionID = a.sessionID
+			}
+			return
+		}
+		found[id] = &a
+		order = append(order, id)
+	}
 
 	// Known singleton roles that should always be shown
 	// Note: mayor and deacon are HQ-only, registered separately
 	expectedRoles := []string{"witness", "refinery"}
 
-	// First, register expected singleton roles as "stopped" for all rigs
-	// These will be updated to "running" if we find their tmux sessions
+	// Expected singleton roles start as "stopped" for all rigs
+	// They are upgraded to "running" if we find their tmux sessions
 	m.mu.RLock()
 	rigIDs := make([]string, 0, len(m.rigs))
 	for rigID := range m.rigs {
@@ -558,99 +1187,59 @@ func (m *Manager) discoverAgents() {
 
 	for _, rigID := range rigIDs {
 		for _, role := range expectedRoles {
-			m.registerAgentWithStatus(rigID, role, role, nil, registry.StatusStopped, agentBeads)
+			add(discoveredAgent{rig: rigID, role: role, name: role, status: registry.StatusStopped})
 		}
 	}
 
-	// Register HQ-only roles (mayor, deacon) as stopped by default
-	// They'll be updated to running if tmux sessions are found
-	m.registerAgentWithStatus("hq", "mayor", "mayor", nil, registry.StatusStopped, agentBeads)
-	m.registerAgentWithStatus("hq", "deacon", "deacon", nil, registry.StatusStopped, agentBeads)
+	// HQ-only roles (mayor, deacon) are stopped by default
+	add(discoveredAgent{rig: "hq", role: "mayor", name: "mayor", status: registry.StatusStopped})
+	add(discoveredAgent{rig: "hq", role: "deacon", name: "deacon", status: registry.StatusStopped})
 
 	// Run tmux list-sessions to get all sessions
 	cmd := exec.Command("tmux", "list-sessions", "-F", "#{session_name}")
-	output, err := cmd.Output()
+	output, err := m.execLimiter.Output(context.Background(), cmd)
 	if err != nil {
 		slog.Debug("Failed to list tmux sessions", "error", err)
-		return
+		output = nil
 	}
 
-	// Known singleton roles that don't have additional names
-	singletonRoles := map[string]bool{
-		"witness":  true,
-		"refinery": true,
-		"mayor":    true,
-		"deacon":   true,
-	}
-
-	// Parse sessions and register running agents
+	// Parse sessions into running agents
 	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
 	discovered := 0
 
-	for _, session := range lines {
-		session = strings.TrimSpace(session)
-		if session == "" {
-			continue
-		}
-
-		// Handle hq-mayor pattern (before gt- prefix check)
-		if strings.HasPrefix(session, "hq-mayor") {
-			m.registerAgentWithBeads("hq", "mayor", "mayor", &session, registry.StatusRunning, agentBeads)
-			discovered++
+	for _, sessionName := range lines {
+		sessionName = strings.TrimSpace(sessionName)
+		if sessionName == "" {
 			continue
 		}
 
-		// Skip non-gt- sessions
-		if !strings.HasPrefix(session, "gt-") {
+		// Skip sessions that don't follow a Gas Town naming pattern
+		id, ok := session.Parse(sessionName)
+		if !ok {
 			continue
 		}
 
-		// Parse gt-{rig}-{rest} pattern
-		parts := strings.SplitN(session[3:], "-", 2) // Skip "gt-"
-		if len(parts) < 2 {
-			continue
-		}
-
-		rigName := parts[0]
-		rest := parts[1]
-
-		// Check if this rig exists
-		m.mu.RLock()
-		_, rigExists := m.rigs[rigName]
-		m.mu.RUnlock()
+		// Check if this rig exists (HQ agents have no rig)
+		if id.Rig != "hq" {
+			m.mu.RLock()
+			_, rigExists := m.rigs[id.Rig]
+			m.mu.RUnlock()
 
-		if !rigExists {
-			slog.Debug("Skipping agent from unknown rig", "session", session, "rig", rigName)
-			continue
-		}
-
-		// Parse the role and name
-		var role, name string
-		restParts := strings.SplitN(rest, "-", 2)
-		rolePart := restParts[0]
-
-		if singletonRoles[rolePart] {
-			// Singleton role: gt-townview-witness
-			role = rolePart
-			name = rolePart
-		} else if rolePart == "crew" && len(restParts) > 1 {
-			// Crew: gt-townview-crew-jeremy
-			role = "crew"
-			name = restParts[1]
-		} else if rolePart == "polecats" && len(restParts) > 1 {
-			// Polecat: gt-townview-polecats-obsidian (less common pattern)
-			role = "polecat"
-			name = restParts[1]
-		} else {
-			// Polecat with direct name: gt-townview-obsidian
-			role = "polecat"
-			name = rest
+			if !rigExists {
+				slog.Debug("Skipping agent from unknown rig", "session", sessionName, "rig", id.Rig)
+				continue
+			}
 		}
 
-		m.registerAgentWithBeads(rigName, role, name, &session, registry.StatusRunning, agentBeads)
+		add(discoveredAgent{rig: id.Rig, role: id.Role, name: id.Name, sessionID: &sessionName, status: registry.StatusRunning})
 		discovered++
 	}
 
+	for _, id := range order {
+		a := found[id]
+		m.registerAgentWithBeads(a.rig, a.role, a.name, a.sessionID, a.status, agentBeads)
+	}
+
 	if discovered > 0 {
 		slog.Debug("Discovered agents from tmux", "count", discovered)
 	}
@@ -665,15 +1254,7 @@ func (m *Manager) registerAgentWithStatus(rig, role, name string, sessionID *str
 // registerAgentWithBeads registers an agent with the registry, enriching with hook_bead from agent beads.
 func (m *Manager) registerAgentWithBeads(rig, role, name string, sessionID *string, status registry.AgentStatus, agentBeads map[string]query.AgentBead) {
 	// Build agent ID (for registry)
-	var id string
-	switch role {
-	case "witness", "refinery", "mayor", "deacon":
-		id = fmt.Sprintf("%s/%s", rig, role)
-	case "crew":
-		id = fmt.Sprintf("%s/crew/%s", rig, name)
-	default:
-		id = fmt.Sprintf("%s/polecats/%s", rig, name)
-	}
+	id := session.AgentID(rig, role, name)
 
 	// Lookup hook_bead from agent beads
 	// Agent bead ID format: {prefix}{rig}-{role}-{name} e.g., "to-townview-polecat-obsidian"
@@ -726,6 +1307,19 @@ func (m *Manager) registerAgentWithBeads(rig, role, name string, sessionID *stri
 		agentRole = registry.RolePolecat
 	}
 
+	// Already registered under the same session: refresh in place so runtime
+	// state (start time, tokens, bead timing) is kept and no churn events fire
+	if existing := m.agentRegistry.GetAgent(id); existing != nil && sameSession(existing.SessionID, sessionID) {
+		m.agentRegistry.Heartbeat(registry.Heartbeat{
+			AgentID:     id,
+			Timestamp:   time.Now(),
+			Status:      status,
+			CurrentBead: currentBead,
+			Inferred:    true,
+		})
+		return
+	}
+
 	reg := registry.AgentRegistration{
 		ID:                  id,
 		Rig:                 rig,
@@ -735,7 +1329,16 @@ func (m *Manager) registerAgentWithBeads(rig, role, name string, sessionID *stri
 		HeartbeatIntervalMs: 30000, // 30 second heartbeat expected
 		Status:              status,
 		CurrentBead:         currentBead,
+		Pinned:              session.IsSingletonRole(role), // Expected roles stay visible while stopped
 	}
 
 	m.agentRegistry.Register(reg)
 }
+
+// sameSession reports whether two optional session IDs are equal.
+func sameSession(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
@@ -0,0 +1,616 @@
+package rigmanager
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gastown/townview/internal/events"
+	"github.com/gastown/townview/internal/query"
+	"github.com/gastown/townview/internal/registry"
+	"github.com/gastown/townview/internal/telemetry"
+	"github.com/gastown/townview/internal/types"
+)
+
+// newTestManager returns a Manager over townRoot without the background
+// discovery loops, so tests drive discovery directly.
+func newTestManager(t *testing.T, townRoot string, store *events.Store) *Manager {
+	t.Helper()
+	m := &Manager{
+		townRoot:   townRoot,
+		rigs:       make(map[string]*Rig),
+		eventStore: store,
+	}
+	t.Cleanup(func() { m.Close() })
+	return m
+}
+
+// createTestRig creates <townRoot>/<name>/.beads/beads.db as an empty database.
+func createTestRig(t *testing.T, townRoot, name string) {
+	t.Helper()
+	beadsPath := filepath.Join(townRoot, name, ".beads")
+	if err := os.MkdirAll(beadsPath, 0755); err != nil {
+		t.Fatalf("Failed to create beads dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(beadsPath, "beads.db"), nil, 0644); err != nil {
+		t.Fatalf("Failed to create beads db: %v", err)
+	}
+}
+
+// createTestRigWithSchema creates a rig whose beads.db has the issues and
+// dependencies tables, runs the given statements against it, and returns the
+// database path.
+func createTestRigWithSchema(t *testing.T, townRoot, name string, stmts ...string) string {
+	t.Helper()
+	createTestRig(t, townRoot, name)
+	dbPath := filepath.Join(townRoot, name, ".beads", "beads.db")
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open beads db: %v", err)
+	}
+	defer db.Close()
+
+	schema := []string{`
+		CREATE TABLE issues (
+			id TEXT PRIMARY KEY,
+			title TEXT NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			status TEXT NOT NULL DEFAULT 'open',
+			priority INTEGER NOT NULL DEFAULT 2,
+			issue_type TEXT NOT NULL DEFAULT 'task',
+			owner TEXT,
+			assignee TEXT,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			created_by TEXT DEFAULT '',
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			closed_at DATETIME,
+			close_reason TEXT DEFAULT '',
+			deleted_at DATETIME,
+			source_repo TEXT DEFAULT '.'
+		)`, `
+		CREATE TABLE dependencies (
+			issue_id TEXT NOT NULL,
+			depends_on_id TEXT NOT NULL,
+			type TEXT NOT NULL DEFAULT 'blocks',
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			created_by TEXT NOT NULL DEFAULT '',
+			PRIMARY KEY (issue_id, depends_on_id, type)
+		)`}
+	for _, stmt := range append(schema, stmts...) {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("Failed to run %q: %v", stmt, err)
+		}
+	}
+	return dbPath
+}
+
+func newTestEventStore(t *testing.T) *events.Store {
+	t.Helper()
+	store, err := events.NewStore(events.DefaultConfig())
+	if err != nil {
+		t.Fatalf("Failed to create event store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestManager_DiscoverRigs_KeepsMissingRigs(t *testing.T) {
+	townRoot := t.TempDir()
+	createTestRig(t, townRoot, "rig-a")
+	store := newTestEventStore(t)
+	m := newTestManager(t, townRoot, store)
+
+	if err := m.discoverRigs(); err != nil {
+		t.Fatalf("discoverRigs failed: %v", err)
+	}
+	if _, err := m.GetRig("rig-a"); err != nil {
+		t.Fatalf("Expected rig-a to be discovered: %v", err)
+	}
+	if err := store.Emit("bead.updated", "test-source", "rig-a", nil); err != nil {
+		t.Fatalf("Failed to emit event: %v", err)
+	}
+
+	// A rig missing from one scan stays tracked and keeps its history
+	if err := os.RemoveAll(filepath.Join(townRoot, "rig-a")); err != nil {
+		t.Fatalf("Failed to remove rig dir: %v", err)
+	}
+	if err := m.discoverRigs(); err != nil {
+		t.Fatalf("discoverRigs failed: %v", err)
+	}
+	if _, err := m.GetRig("rig-a"); err != nil {
+		t.Errorf("Expected rig-a to stay tracked after a missed scan: %v", err)
+	}
+
+	since := time.Now().Add(-time.Minute)
+	counts, err := store.CountByRig(events.EventFilter{StartTime: &since})
+	if err != nil {
+		t.Fatalf("CountByRig failed: %v", err)
+	}
+	if counts["rig-a"] != 1 {
+		t.Errorf("Expected rig-a's event to survive discovery, got %v", counts)
+	}
+}
+
+func TestManager_RemoveRig_PurgesHistory(t *testing.T) {
+	townRoot := t.TempDir()
+	createTestRig(t, townRoot, "rig-a")
+	createTestRig(t, townRoot, "rig-b")
+	store := newTestEventStore(t)
+	m := newTestManager(t, townRoot, store)
+
+	if err := m.discoverRigs(); err != nil {
+		t.Fatalf("discoverRigs failed: %v", err)
+	}
+	for _, rig := range []string{"rig-a", "rig-a", "rig-b"} {
+		if err := store.Emit("bead.updated", "test-source", rig, nil); err != nil {
+			t.Fatalf("Failed to emit event: %v", err)
+		}
+	}
+	at := time.Now()
+	if _, err := store.RecordIssueSnapshots("rig-a", at, map[string][]byte{"a-1": []byte(`{"id":"a-1"}`)}); err != nil {
+		t.Fatalf("RecordIssueSnapshots failed: %v", err)
+	}
+	if _, err := store.RecordIssueSnapshots("rig-b", at, map[string][]byte{"b-1": []byte(`{"id":"b-1"}`)}); err != nil {
+		t.Fatalf("RecordIssueSnapshots failed: %v", err)
+	}
+
+	if err := m.RemoveRig("rig-a"); err != nil {
+		t.Fatalf("RemoveRig failed: %v", err)
+	}
+	if _, err := m.GetRig("rig-a"); err == nil {
+		t.Error("Expected rig-a to be untracked")
+	}
+	if _, err := m.GetRig("rig-b"); err != nil {
+		t.Errorf("Expected rig-b to stay tracked: %v", err)
+	}
+
+	since := time.Now().Add(-time.Minute)
+	counts, err := store.CountByRig(events.EventFilter{StartTime: &since})
+	if err != nil {
+		t.Fatalf("CountByRig failed: %v", err)
+	}
+	if counts["rig-a"] != 0 || counts["rig-b"] != 1 {
+		t.Errorf("Expected only rig-b's event to remain, got %v", counts)
+	}
+
+	if snap, err := store.GetIssueSnapshot("rig-a", "a-1", at); err != nil || snap != nil {
+		t.Errorf("Expected rig-a's snapshot to be purged, got %v (err %v)", snap, err)
+	}
+	if snap, err := store.GetIssueSnapshot("rig-b", "b-1", at); err != nil || snap == nil {
+		t.Errorf("Expected rig-b's snapshot to remain, got %v (err %v)", snap, err)
+	}
+
+	if err := m.RemoveRig("rig-a"); err == nil {
+		t.Error("Expected removing an untracked rig to fail")
+	}
+}
+
+func TestHealthThresholds(t *testing.T) {
+	tests := []struct {
+		name          string
+		degraded      int
+		unhealthy     int
+		wantDegraded  int
+		wantUnhealthy int
+		wantErr       bool
+	}{
+		{name: "defaults", wantDegraded: DefaultDegradedAfterMissed, wantUnhealthy: DefaultUnhealthyAfterMissed},
+		{name: "custom", degraded: 4, unhealthy: 10, wantDegraded: 4, wantUnhealthy: 10},
+		{name: "default degraded below custom unhealthy", unhealthy: 5, wantDegraded: DefaultDegradedAfterMissed, wantUnhealthy: 5},
+		{name: "degraded above unhealthy", degraded: 5, unhealthy: 3, wantErr: true},
+		{name: "degraded equal to unhealthy", degraded: 3, unhealthy: 3, wantErr: true},
+		{name: "custom degraded past default unhealthy", degraded: 6, wantErr: true},
+		{name: "negative degraded", degraded: -1, unhealthy: 3, wantErr: true},
+		{name: "negative unhealthy", degraded: 2, unhealthy: -1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			degraded, unhealthy, err := healthThresholds(Config{DegradedAfterMissed: tt.degraded, UnhealthyAfterMissed: tt.unhealthy})
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected an error, got degraded=%d unhealthy=%d", degraded, unhealthy)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if degraded != tt.wantDegraded || unhealthy != tt.wantUnhealthy {
+				t.Errorf("got degraded=%d unhealthy=%d, want %d and %d", degraded, unhealthy, tt.wantDegraded, tt.wantUnhealthy)
+			}
+		})
+	}
+}
+
+func TestManager_RoleStatus(t *testing.T) {
+	m := newTestManager(t, t.TempDir(), nil)
+	m.degradedAfterMissed = 2
+	m.unhealthyAfterMissed = 4
+	now := time.Now()
+	agent := func(status registry.AgentStatus, age time.Duration) registry.AgentState {
+		return registry.AgentState{Status: status, HeartbeatIntervalMs: 10000, LastHeartbeat: now.Add(-age)}
+	}
+
+	tests := []struct {
+		name  string
+		agent registry.AgentState
+		want  string
+	}{
+		{"fresh beat keeps status", agent(registry.StatusWorking, time.Second), "working"},
+		{"one missed beat keeps status", agent(registry.StatusIdle, 15*time.Second), "idle"},
+		{"stale beat degrades", agent(registry.StatusWorking, 25*time.Second), HealthDegraded},
+		{"long-stale beat is unhealthy", agent(registry.StatusWorking, 45*time.Second), HealthUnhealthy},
+		{"registry's missed count", func() registry.AgentState {
+			a := agent(registry.StatusWorking, time.Second)
+			a.MissedHeartbeats = 4
+			return a
+		}(), HealthUnhealthy},
+		{"registry's degraded flag", func() registry.AgentState {
+			a := agent(registry.StatusWorking, time.Second)
+			a.Degraded = true
+			return a
+		}(), HealthDegraded},
+		{"stuck keeps its status", agent(registry.StatusStuck, time.Hour), "stuck"},
+		{"stopped keeps its status", agent(registry.StatusStopped, time.Hour), "stopped"},
+		{"no interval", registry.AgentState{Status: registry.StatusWorking, LastHeartbeat: now.Add(-time.Hour)}, "working"},
+		{"never heartbeated", registry.AgentState{Status: registry.StatusStarting, HeartbeatIntervalMs: 10000}, "starting"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := m.roleStatus(tt.agent, now); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// The roll-up reports each role under its own field
+	witness := agent(registry.StatusWorking, 45*time.Second)
+	witness.Role = registry.RoleWitness
+	refinery := agent(registry.StatusIdle, time.Second)
+	refinery.Role = registry.RoleRefinery
+	health := m.ComputeAgentHealth([]registry.AgentState{witness, refinery})
+	if health.Witness == nil || *health.Witness != HealthUnhealthy {
+		t.Errorf("expected an unhealthy witness, got %v", health.Witness)
+	}
+	if health.Refinery == nil || *health.Refinery != "idle" {
+		t.Errorf("expected an idle refinery, got %v", health.Refinery)
+	}
+	if health.Crew != nil {
+		t.Errorf("expected no crew status without crew, got %q", *health.Crew)
+	}
+}
+
+func TestCountAgentRoles(t *testing.T) {
+	counts := CountAgentRoles([]registry.AgentState{
+		{ID: "p1", Role: registry.RolePolecat},
+		{ID: "p2", Role: registry.RolePolecat},
+		{ID: "c1", Role: registry.RoleCrew},
+	})
+	want := map[registry.AgentRole]int{registry.RolePolecat: 2, registry.RoleCrew: 1}
+	if !reflect.DeepEqual(counts, want) {
+		t.Errorf("Expected %v, got %v", want, counts)
+	}
+
+	// Typed keys still encode as the role names
+	data, err := json.Marshal(types.Rig{RoleCounts: counts})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if !strings.Contains(string(data), `"role_counts":{"crew":1,"polecat":2}`) {
+		t.Errorf("Expected role names as keys, got %s", data)
+	}
+}
+
+func TestManager_AgentBeadsCache_InvalidatedByBeadEvents(t *testing.T) {
+	store := newTestEventStore(t)
+	m := newTestManager(t, t.TempDir(), store)
+	m.agentBeadsTTL = time.Hour
+	m.eventCh = store.Subscribe(events.EventFilter{})
+	go m.eventLoop()
+
+	cached := func() bool {
+		m.agentBeadsMu.Lock()
+		defer m.agentBeadsMu.Unlock()
+		return m.agentBeads != nil
+	}
+
+	// A fresh cache is served without reloading
+	m.agentBeadsMu.Lock()
+	m.agentBeads = map[string]query.AgentBead{"gt-agent-1": {ID: "gt-agent-1"}}
+	m.agentBeadsExpires = time.Now().Add(time.Hour)
+	m.agentBeadsMu.Unlock()
+	if _, ok := m.getAgentBeads()["gt-agent-1"]; !ok {
+		t.Fatal("Expected the cached agent beads to be served")
+	}
+
+	// Non-bead events leave the cache alone
+	if err := store.Emit("agent.registered", "test-source", "rig-a", nil); err != nil {
+		t.Fatalf("Failed to emit event: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if !cached() {
+		t.Fatal("Expected a non-bead event to keep the cache")
+	}
+
+	// Bead events drop it, so the next lookup reloads from the rigs
+	if err := store.Emit("bead.updated", "test-source", "rig-a", nil); err != nil {
+		t.Fatalf("Failed to emit event: %v", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for cached() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if cached() {
+		t.Fatal("Expected a bead event to invalidate the cache")
+	}
+	if beads := m.getAgentBeads(); len(beads) != 0 {
+		t.Errorf("Expected a reload with no rigs to find no agent beads, got %v", beads)
+	}
+
+	// An expired cache is reloaded too
+	m.agentBeadsMu.Lock()
+	m.agentBeads = map[string]query.AgentBead{"gt-agent-1": {ID: "gt-agent-1"}}
+	m.agentBeadsExpires = time.Now().Add(-time.Second)
+	m.agentBeadsMu.Unlock()
+	if _, ok := m.getAgentBeads()["gt-agent-1"]; ok {
+		t.Error("Expected an expired cache to be reloaded")
+	}
+}
+
+func TestManager_StartAgentStatusEvents(t *testing.T) {
+	store := newTestEventStore(t)
+	m := newTestManager(t, t.TempDir(), store)
+	reg := registry.NewWithDefaults()
+	m.agentRegistry = reg
+	var readOnly atomic.Bool
+	m.SetReadOnlyCheck(readOnly.Load)
+
+	beat := func(id string, status registry.AgentStatus) {
+		reg.Heartbeat(registry.Heartbeat{AgentID: id, Timestamp: time.Now(), Status: status})
+	}
+	changes := func() []string {
+		t.Helper()
+		recorded, err := store.Query(events.EventFilter{Type: events.TypeAgentStatusChanged})
+		if err != nil {
+			t.Fatalf("Query failed: %v", err)
+		}
+		sort.Slice(recorded, func(i, j int) bool { return recorded[i].ID < recorded[j].ID })
+		got := []string{}
+		for _, e := range recorded {
+			p, err := events.UnmarshalPayload[events.AgentStatusChangedPayload](e)
+			if err != nil {
+				t.Fatalf("Bad payload: %v", err)
+			}
+			if e.Rig != p.Rig {
+				t.Errorf("Expected the event under rig %s, got %s", p.Rig, e.Rig)
+			}
+			got = append(got, p.AgentID+" "+p.OldValue+"->"+p.NewValue)
+		}
+		return got
+	}
+	// waitFor polls until n changes are recorded; the callback runs in order,
+	// so the nth arriving means every earlier one has been handled
+	waitFor := func(n int) []string {
+		t.Helper()
+		got := changes()
+		for deadline := time.Now().Add(time.Second); len(got) < n && time.Now().Before(deadline); got = changes() {
+			time.Sleep(10 * time.Millisecond)
+		}
+		return got
+	}
+
+	// Registered before the subscription; the snapshot supplies its old status
+	reg.Register(registry.AgentRegistration{ID: "rig-a/polecats/a1", Rig: "rig-a", Role: registry.RolePolecat, Status: registry.StatusWorking})
+
+	stop := m.StartAgentStatusEvents()
+	defer stop()
+
+	beat("rig-a/polecats/a1", registry.StatusIdle)
+	beat("rig-a/polecats/a1", registry.StatusIdle) // unchanged
+	reg.Register(registry.AgentRegistration{ID: "rig-b/polecats/b1", Rig: "rig-b", Role: registry.RolePolecat})
+	beat("rig-b/polecats/b1", registry.StatusWorking)
+	// Re-registering after deregistration starts the agent afresh
+	reg.Deregister("rig-b/polecats/b1")
+	reg.Register(registry.AgentRegistration{ID: "rig-b/polecats/b1", Rig: "rig-b", Role: registry.RolePolecat, Status: registry.StatusIdle})
+	beat("rig-b/polecats/b1", registry.StatusWorking)
+
+	want := []string{
+		"rig-a/polecats/a1 working->idle",
+		"rig-b/polecats/b1 starting->working",
+		"rig-b/polecats/b1 idle->working",
+	}
+	if got := waitFor(len(want)); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected status changes %q, got %q", want, got)
+	}
+
+	// Read-only mode records nothing
+	readOnly.Store(true)
+	beat("rig-a/polecats/a1", registry.StatusWorking)
+	time.Sleep(50 * time.Millisecond)
+	if got := changes(); len(got) != len(want) {
+		t.Errorf("Expected no events in read-only mode, got %q", got[len(want):])
+	}
+}
+
+// recordedProgress counts convoy progress snapshots.
+type recordedProgress struct {
+	snapshots []telemetry.ProgressSnapshot
+}
+
+func (r *recordedProgress) RecordConvoyProgress(snapshot telemetry.ProgressSnapshot) error {
+	r.snapshots = append(r.snapshots, snapshot)
+	return nil
+}
+
+func TestManager_ReadOnly_PausesBackgroundWrites(t *testing.T) {
+	townRoot := t.TempDir()
+	createTestRigWithSchema(t, townRoot, "rig-a",
+		`INSERT INTO issues (id, title, issue_type) VALUES ('a-1', 'Convoy', 'convoy'), ('a-2', 'Task', 'task')`,
+		`INSERT INTO dependencies (issue_id, depends_on_id, type) VALUES ('a-1', 'a-2', 'tracks')`,
+	)
+	store := newTestEventStore(t)
+	m := newTestManager(t, townRoot, store)
+	if err := m.discoverRigs(); err != nil {
+		t.Fatalf("discoverRigs failed: %v", err)
+	}
+	recorder := &recordedProgress{}
+	m.SetProgressRecorder(recorder)
+	readOnly := true
+	m.SetReadOnlyCheck(func() bool { return readOnly })
+
+	if _, err := m.GetConvoyProgress("rig-a", "a-1"); err != nil {
+		t.Fatalf("GetConvoyProgress failed: %v", err)
+	}
+	if stored, err := m.SnapshotIssues("rig-a"); err != nil || stored != 0 {
+		t.Fatalf("Expected no snapshots in read-only mode, got %d (err %v)", stored, err)
+	}
+	if len(recorder.snapshots) != 0 {
+		t.Fatalf("Expected no convoy progress in read-only mode, got %v", recorder.snapshots)
+	}
+	if snap, err := store.GetIssueSnapshot("rig-a", "a-1", time.Now()); err != nil || snap != nil {
+		t.Fatalf("Expected no stored snapshot, got %v (err %v)", snap, err)
+	}
+
+	readOnly = false
+	if _, err := m.GetConvoyProgress("rig-a", "a-1"); err != nil {
+		t.Fatalf("GetConvoyProgress failed: %v", err)
+	}
+	if stored, err := m.SnapshotIssues("rig-a"); err != nil || stored != 2 {
+		t.Fatalf("Expected 2 snapshots once writable, got %d (err %v)", stored, err)
+	}
+	if len(recorder.snapshots) != 1 {
+		t.Errorf("Expected one convoy progress snapshot once writable, got %v", recorder.snapshots)
+	}
+}
+
+func TestManager_SnapshotIssues_RecordsDeletions(t *testing.T) {
+	townRoot := t.TempDir()
+	dbPath := createTestRigWithSchema(t, townRoot, "rig-a",
+		`INSERT INTO issues (id, title) VALUES ('a-1', 'First'), ('a-2', 'Second')`,
+	)
+	store := newTestEventStore(t)
+	m := newTestManager(t, townRoot, store)
+	if err := m.discoverRigs(); err != nil {
+		t.Fatalf("discoverRigs failed: %v", err)
+	}
+
+	if stored, err := m.SnapshotIssues("rig-a"); err != nil || stored != 2 {
+		t.Fatalf("Expected 2 snapshots, got %d (err %v)", stored, err)
+	}
+
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open beads db: %v", err)
+	}
+	if _, err := db.Exec(`DELETE FROM issues WHERE id = 'a-1'`); err != nil {
+		t.Fatalf("Failed to delete issue: %v", err)
+	}
+	db.Close()
+
+	if stored, err := m.SnapshotIssues("rig-a"); err != nil || stored != 1 {
+		t.Fatalf("Expected 1 deletion snapshot, got %d (err %v)", stored, err)
+	}
+	snap, err := store.GetIssueSnapshot("rig-a", "a-1", time.Now())
+	if err != nil || snap == nil || !snap.Deleted {
+		t.Fatalf("Expected a-1 to be recorded as deleted, got %+v (err %v)", snap, err)
+	}
+	if snap, err := store.GetIssueSnapshot("rig-a", "a-2", time.Now()); err != nil || snap == nil || snap.Deleted {
+		t.Errorf("Expected a-2 to stay live, got %+v (err %v)", snap, err)
+	}
+}
+
+func TestRig_RecordQuery_LogsHealthTransitions(t *testing.T) {
+	var logs bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	rig := &Rig{ID: "rig-a", QueryService: &query.Service{}}
+	steps := []struct {
+		err        error
+		wantStatus string
+		wantError  string
+		wantLog    string // new log line expected, if any
+	}{
+		{nil, "ok", "", ""},
+		{errors.New("database is locked"), "error", "database is locked", "changed from ok to error"},
+		{errors.New("disk I/O error"), "error", "disk I/O error", ""}, // still failing: no new transition
+		{nil, "ok", "disk I/O error", "changed from error to ok"},     // recovered; last error stays visible
+		{nil, "ok", "disk I/O error", ""},
+	}
+	for i, step := range steps {
+		logs.Reset()
+		rig.recordQuery(step.err)
+
+		health := rig.dbHealth()
+		if health.Status != step.wantStatus || health.LastError != step.wantError {
+			t.Errorf("step %d: expected %s with last error %q, got %+v", i, step.wantStatus, step.wantError, health)
+		}
+		if (health.LastErrorAt != nil) != (step.wantError != "") {
+			t.Errorf("step %d: expected last_error_at alongside last_error, got %+v", i, health)
+		}
+		got := logs.String()
+		if step.wantLog == "" && got != "" {
+			t.Errorf("step %d: expected no log, got %q", i, got)
+		}
+		if step.wantLog != "" && !strings.Contains(got, step.wantLog) {
+			t.Errorf("step %d: expected a log containing %q, got %q", i, step.wantLog, got)
+		}
+	}
+}
+
+func TestManager_ListRigs_ReportsDBHealth(t *testing.T) {
+	townRoot := t.TempDir()
+	dbPath := createTestRigWithSchema(t, townRoot, "rig-a")
+	m := newTestManager(t, townRoot, nil)
+	if err := m.discoverRigs(); err != nil {
+		t.Fatalf("discoverRigs failed: %v", err)
+	}
+	rig, err := m.GetRig("rig-a")
+	if err != nil {
+		t.Fatalf("GetRig failed: %v", err)
+	}
+	dbHealth := func() *types.RigDBHealth {
+		t.Helper()
+		rig.QueryService.InvalidateCache()
+		rigs := m.ListRigs()
+		if len(rigs) != 1 {
+			t.Fatalf("Expected one rig, got %+v", rigs)
+		}
+		return rigs[0].DBHealth
+	}
+
+	if h := dbHealth(); h == nil || h.Status != types.RigDBOK || h.LastError != "" {
+		t.Fatalf("Expected a healthy rig, got %+v", h)
+	}
+
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open beads db: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`ALTER TABLE issues RENAME TO issues_moved`); err != nil {
+		t.Fatalf("Failed to break the rig database: %v", err)
+	}
+	h := dbHealth()
+	if h == nil || h.Status != types.RigDBError || h.LastError == "" || h.LastErrorAt == nil {
+		t.Fatalf("Expected the failed query in the rig payload, got %+v", h)
+	}
+
+	if _, err := db.Exec(`ALTER TABLE issues_moved RENAME TO issues`); err != nil {
+		t.Fatalf("Failed to restore the rig database: %v", err)
+	}
+	if recovered := dbHealth(); recovered == nil || recovered.Status != types.RigDBOK || recovered.LastError != h.LastError {
+		t.Errorf("Expected ok with the last error kept, got %+v", recovered)
+	}
+}
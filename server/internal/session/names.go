@@ -0,0 +1,101 @@
+// Package session maps Gas Town agents to and from their tmux session names.
+package session
+
+import "strings"
+
+// Identity identifies the agent that owns a tmux session.
+type Identity struct {
+	Rig  string
+	Role string // "witness", "refinery", "crew", "polecat", "mayor", "deacon"
+	Name string
+}
+
+// singletonRoles have exactly one agent per rig (or per town for mayor/deacon),
+// named after the role itself.
+var singletonRoles = map[string]bool{
+	"witness":  true,
+	"refinery": true,
+	"mayor":    true,
+	"deacon":   true,
+}
+
+// IsSingletonRole reports whether role has a single agent named after the role.
+func IsSingletonRole(role string) bool {
+	return singletonRoles[role]
+}
+
+// AgentID returns the canonical registry ID for an agent:
+// {rig}/{role} for singletons, {rig}/crew/{name} for crew, {rig}/polecats/{name} otherwise.
+func AgentID(rig, role, name string) string {
+	switch {
+	case singletonRoles[role]:
+		return rig + "/" + role
+	case role == "crew":
+		return rig + "/crew/" + name
+	default:
+		return rig + "/polecats/" + name
+	}
+}
+
+// SessionNames returns the candidate tmux session names for an agent, most
+// likely first. Patterns:
+//
+//	mayor, deacon:       hq-{role}
+//	witness, refinery:   gt-{rig}-{role}
+//	crew:                gt-{rig}-crew-{name}
+//	polecat:             gt-{rig}-{name}, gt-{rig}-polecats-{name}
+func SessionNames(rig, role, name string) []string {
+	switch role {
+	case "mayor", "deacon":
+		return []string{"hq-" + role}
+	case "witness", "refinery":
+		return []string{"gt-" + rig + "-" + role}
+	case "crew":
+		return []string{"gt-" + rig + "-crew-" + name}
+	default:
+		return []string{
+			"gt-" + rig + "-" + name,
+			"gt-" + rig + "-polecats-" + name,
+		}
+	}
+}
+
+// Parse returns the agent identity for a tmux session name, or false if the
+// session does not follow a Gas Town naming pattern. It is the inverse of SessionNames.
+func Parse(name string) (Identity, bool) {
+	// HQ-only roles: hq-mayor, hq-deacon
+	if rest, ok := strings.CutPrefix(name, "hq-"); ok {
+		for _, role := range []string{"mayor", "deacon"} {
+			if strings.HasPrefix(rest, role) {
+				return Identity{Rig: "hq", Role: role, Name: role}, true
+			}
+		}
+		return Identity{}, false
+	}
+
+	rest, ok := strings.CutPrefix(name, "gt-")
+	if !ok {
+		return Identity{}, false
+	}
+
+	// gt-{rig}-{rest}
+	parts := strings.SplitN(rest, "-", 2)
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return Identity{}, false
+	}
+	rig, rest := parts[0], parts[1]
+
+	restParts := strings.SplitN(rest, "-", 2)
+	rolePart := restParts[0]
+
+	switch {
+	case singletonRoles[rolePart]:
+		return Identity{Rig: rig, Role: rolePart, Name: rolePart}, true
+	case rolePart == "crew" && len(restParts) > 1:
+		return Identity{Rig: rig, Role: "crew", Name: restParts[1]}, true
+	case rolePart == "polecats" && len(restParts) > 1:
+		return Identity{Rig: rig, Role: "polecat", Name: restParts[1]}, true
+	default:
+		return Identity{Rig: rig, Role: "polecat", Name: rest}, true
+	}
+}
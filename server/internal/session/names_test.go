@@ -0,0 +1,55 @@
+package session
+
+import "testing"
+
+func TestSessionNames_RoundTrip(t *testing.T) {
+	tests := []struct {
+		rig, role, name string
+	}{
+		{"townview", "witness", "witness"},
+		{"townview", "refinery", "refinery"},
+		{"townview", "crew", "jeremy"},
+		{"townview", "polecat", "obsidian"},
+		{"hq", "mayor", "mayor"},
+		{"hq", "deacon", "deacon"},
+	}
+
+	for _, tt := range tests {
+		names := SessionNames(tt.rig, tt.role, tt.name)
+		if len(names) == 0 {
+			t.Fatalf("SessionNames(%q, %q, %q) returned no candidates", tt.rig, tt.role, tt.name)
+		}
+		for _, n := range names {
+			id, ok := Parse(n)
+			if !ok {
+				t.Errorf("Parse(%q) failed", n)
+				continue
+			}
+			want := Identity{Rig: tt.rig, Role: tt.role, Name: tt.name}
+			if id != want {
+				t.Errorf("Parse(%q) = %+v, want %+v", n, id, want)
+			}
+		}
+	}
+
+	if _, ok := Parse("scratch"); ok {
+		t.Error("expected non-Gas Town session to be rejected")
+	}
+}
+
+func TestAgentID(t *testing.T) {
+	tests := []struct {
+		rig, role, name, want string
+	}{
+		{"townview", "witness", "witness", "townview/witness"},
+		{"townview", "crew", "jeremy", "townview/crew/jeremy"},
+		{"townview", "polecat", "obsidian", "townview/polecats/obsidian"},
+		{"hq", "mayor", "mayor", "hq/mayor"},
+	}
+
+	for _, tt := range tests {
+		if got := AgentID(tt.rig, tt.role, tt.name); got != tt.want {
+			t.Errorf("AgentID(%q, %q, %q) = %q, want %q", tt.rig, tt.role, tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package telemetry
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DefaultAnomalyMultiplier is how many times its expected usage an agent must
+// burn in a window before GetTokenAnomalies flags it.
+const DefaultAnomalyMultiplier = 3.0
+
+// defaultAnomalyWindow is the window GetTokenAnomalies inspects when since is empty.
+const defaultAnomalyWindow = 24 * time.Hour
+
+// Baselines an agent's window usage is compared against.
+const (
+	AnomalyBaselineHistory    = "agent_history" // the agent's own usage before the window
+	AnomalyBaselineTownMedian = "town_median"   // the median agent's usage in the window
+)
+
+// TokenAnomaly is an agent whose token usage in a window is well above expected.
+type TokenAnomaly struct {
+	AgentID        string   `json:"agent_id"`
+	ObservedTokens int      `json:"observed_tokens"`
+	ExpectedTokens float64  `json:"expected_tokens"`
+	Ratio          float64  `json:"ratio"`    // observed / expected
+	Baseline       string   `json:"baseline"` // agent_history or town_median
+	Beads          []string `json:"beads"`    // beads worked in the window, heaviest first
+}
+
+// SetAnomalyMultiplier sets how far above expected usage counts as an anomaly.
+// Values <= 0 restore DefaultAnomalyMultiplier. Call before serving requests.
+func (c *SQLiteCollector) SetAnomalyMultiplier(m float64) {
+	c.anomalyMultiplier = m
+}
+
+// GetTokenAnomalies flags agents whose token usage since the given time is
+// more than the anomaly multiplier times what is expected of them. An agent
+// with at least a window's worth of earlier history is compared against its
+// own average rate over that history; newer agents are compared against the
+// town median for the window.
+func (c *SQLiteCollector) GetTokenAnomalies(since string) ([]TokenAnomaly, error) {
+	now := time.Now().UTC()
+	start := now.Add(-defaultAnomalyWindow)
+	if since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return nil, fmt.Errorf("parse since: %w", err)
+		}
+		start = t.UTC()
+	}
+	window := now.Sub(start)
+	if window <= 0 {
+		return []TokenAnomaly{}, nil
+	}
+	startStr := start.Format(time.RFC3339)
+
+	multiplier := c.anomalyMultiplier
+	if multiplier <= 0 {
+		multiplier = DefaultAnomalyMultiplier
+	}
+
+	observed, err := c.windowTokensByAgent(startStr)
+	if err != nil {
+		return nil, fmt.Errorf("query window usage: %w", err)
+	}
+	if len(observed) == 0 {
+		return []TokenAnomaly{}, nil
+	}
+
+	type agentHistory struct {
+		tokens int
+		first  time.Time
+	}
+	history := make(map[string]agentHistory)
+	rows, err := c.db.Query(`
+		SELECT agent_id, SUM(input_tokens + output_tokens), MIN(timestamp)
+		FROM token_usage
+		WHERE timestamp < ?
+		GROUP BY agent_id`, startStr)
+	if err != nil {
+		return nil, fmt.Errorf("query historical usage: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var agentID, first string
+		var tokens int
+		if err := rows.Scan(&agentID, &tokens, &first); err != nil {
+			return nil, fmt.Errorf("scan historical usage: %w", err)
+		}
+		firstAt, err := time.Parse(time.RFC3339, first)
+		if err != nil {
+			continue
+		}
+		history[agentID] = agentHistory{tokens: tokens, first: firstAt}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate historical usage: %w", err)
+	}
+
+	totals := make([]int, 0, len(observed))
+	for _, tokens := range observed {
+		totals = append(totals, tokens)
+	}
+	median := medianInt(totals)
+
+	anomalies := []TokenAnomaly{}
+	for agentID, tokens := range observed {
+		expected := median
+		baseline := AnomalyBaselineTownMedian
+		if h, ok := history[agentID]; ok {
+			if span := start.Sub(h.first); span >= window {
+				expected = float64(h.tokens) * window.Seconds() / span.Seconds()
+				baseline = AnomalyBaselineHistory
+			}
+		}
+		if expected <= 0 || float64(tokens) <= expected*multiplier {
+			continue
+		}
+
+		beads, err := c.agentBeadsSince(agentID, startStr)
+		if err != nil {
+			return nil, err
+		}
+		anomalies = append(anomalies, TokenAnomaly{
+			AgentID:        agentID,
+			ObservedTokens: tokens,
+			ExpectedTokens: expected,
+			Ratio:          float64(tokens) / expected,
+			Baseline:       baseline,
+			Beads:          beads,
+		})
+	}
+
+	sort.Slice(anomalies, func(i, j int) bool {
+		return anomalies[i].Ratio > anomalies[j].Ratio
+	})
+	return anomalies, nil
+}
+
+// windowTokensByAgent sums input and output tokens per agent since a timestamp.
+func (c *SQLiteCollector) windowTokensByAgent(since string) (map[string]int, error) {
+	rows, err := c.db.Query(`
+		SELECT agent_id, SUM(input_tokens + output_tokens)
+		FROM token_usage
+		WHERE timestamp >= ?
+		GROUP BY agent_id`, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := make(map[string]int)
+	for rows.Next() {
+		var agentID string
+		var tokens int
+		if err := rows.Scan(&agentID, &tokens); err != nil {
+			return nil, err
+		}
+		totals[agentID] = tokens
+	}
+	return totals, rows.Err()
+}
+
+// agentBeadsSince lists the beads an agent spent tokens on since a timestamp,
+// heaviest first.
+func (c *SQLiteCollector) agentBeadsSince(agentID, since string) ([]string, error) {
+	rows, err := c.db.Query(`
+		SELECT bead_id
+		FROM token_usage
+		WHERE agent_id = ? AND timestamp >= ? AND bead_id IS NOT NULL AND bead_id != ''
+		GROUP BY bead_id
+		ORDER BY SUM(input_tokens + output_tokens) DESC, bead_id`, agentID, since)
+	if err != nil {
+		return nil, fmt.Errorf("query anomaly beads: %w", err)
+	}
+	defer rows.Close()
+
+	beads := []string{}
+	for rows.Next() {
+		var beadID string
+		if err := rows.Scan(&beadID); err != nil {
+			return nil, fmt.Errorf("scan anomaly bead: %w", err)
+		}
+		beads = append(beads, beadID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate anomaly beads: %w", err)
+	}
+	return beads, nil
+}
+
+// medianInt returns the median of values, or 0 for none. values is reordered.
+func medianInt(values []int) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sort.Ints(values)
+	mid := len(values) / 2
+	if len(values)%2 == 0 {
+		return float64(values[mid-1]+values[mid]) / 2
+	}
+	return float64(values[mid])
+}
@@ -3,10 +3,14 @@
 package telemetry
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"log/slog"
+	"strings"
 	"time"
 
+	"github.com/gastown/townview/internal/migrate"
 	_ "modernc.org/sqlite"
 )
 
@@ -14,6 +18,7 @@ import (
 type TokenUsage struct {
 	AgentID      string `json:"agent_id"`
 	BeadID       string `json:"bead_id,omitempty"`
+	Rig          string `json:"rig,omitempty"`
 	Timestamp    string `json:"timestamp"`
 	InputTokens  int    `json:"input_tokens"`
 	OutputTokens int    `json:"output_tokens"`
@@ -25,6 +30,7 @@ type TokenUsage struct {
 type GitChange struct {
 	AgentID      string `json:"agent_id"`
 	BeadID       string `json:"bead_id,omitempty"`
+	Rig          string `json:"rig,omitempty"`
 	Timestamp    string `json:"timestamp"`
 	CommitSHA    string `json:"commit_sha"`
 	Branch       string `json:"branch"`
@@ -39,6 +45,7 @@ type GitChange struct {
 type TestResult struct {
 	AgentID      string `json:"agent_id"`
 	BeadID       string `json:"bead_id,omitempty"`
+	Rig          string `json:"rig,omitempty"`
 	Timestamp    string `json:"timestamp"`
 	CommitSHA    string `json:"commit_sha,omitempty"`
 	TestFile     string `json:"test_file"`
@@ -53,6 +60,7 @@ type TestResult struct {
 type TestRun struct {
 	AgentID    string       `json:"agent_id"`
 	BeadID     string       `json:"bead_id,omitempty"`
+	Rig        string       `json:"rig,omitempty"`
 	Timestamp  string       `json:"timestamp"`
 	CommitSHA  string       `json:"commit_sha,omitempty"`
 	Branch     string       `json:"branch,omitempty"`
@@ -61,7 +69,9 @@ type TestRun struct {
 	Passed     int          `json:"passed"`
 	Failed     int          `json:"failed"`
 	Skipped    int          `json:"skipped"`
+	Errored    int          `json:"errored"` // Panics, timeouts and other non-assertion failures
 	DurationMS int          `json:"duration_ms"`
+	Model      string       `json:"model,omitempty"` // Model the agent ran on, for cost attribution
 	Results    []TestResult `json:"results"`
 }
 
@@ -73,15 +83,18 @@ type TelemetryFilter struct {
 	Since   string `json:"since,omitempty"`
 	Until   string `json:"until,omitempty"`
 	Limit   int    `json:"limit,omitempty"`
+
+	ExcludeResults bool   `json:"-"`                    // GetTestRuns: skip loading each run's per-test results
+	CommitSHA      string `json:"commit_sha,omitempty"` // Git changes and test runs only
 }
 
 // TokenSummary aggregates token usage statistics.
 type TokenSummary struct {
-	TotalInput    int                          `json:"total_input"`
-	TotalOutput   int                          `json:"total_output"`
-	TotalCostUSD  float64                      `json:"total_cost_usd,omitempty"`
-	ByModel       map[string]TokenModelSummary `json:"by_model"`
-	ByAgent       map[string]TokenModelSummary `json:"by_agent"`
+	TotalInput   int                          `json:"total_input"`
+	TotalOutput  int                          `json:"total_output"`
+	TotalCostUSD float64                      `json:"total_cost_usd,omitempty"`
+	ByModel      map[string]TokenModelSummary `json:"by_model"`
+	ByAgent      map[string]TokenModelSummary `json:"by_agent"`
 }
 
 // TokenModelSummary contains input/output token counts.
@@ -92,21 +105,22 @@ type TokenModelSummary struct {
 
 // GitSummary aggregates git change statistics.
 type GitSummary struct {
-	TotalCommits     int            `json:"total_commits"`
-	TotalFilesChanged int           `json:"total_files_changed"`
-	TotalInsertions  int            `json:"total_insertions"`
-	TotalDeletions   int            `json:"total_deletions"`
-	ByAgent          map[string]int `json:"by_agent"` // commit count per agent
+	TotalCommits      int            `json:"total_commits"`
+	TotalFilesChanged int            `json:"total_files_changed"`
+	TotalInsertions   int            `json:"total_insertions"`
+	TotalDeletions    int            `json:"total_deletions"`
+	ByAgent           map[string]int `json:"by_agent"` // commit count per agent
 }
 
 // TestSummary aggregates test result statistics.
 type TestSummary struct {
-	TotalRuns   int            `json:"total_runs"`
-	TotalTests  int            `json:"total_tests"`
-	TotalPassed int            `json:"total_passed"`
-	TotalFailed int            `json:"total_failed"`
-	TotalSkipped int           `json:"total_skipped"`
-	ByAgent     map[string]int `json:"by_agent"` // run count per agent
+	TotalRuns    int            `json:"total_runs"`
+	TotalTests   int            `json:"total_tests"`
+	TotalPassed  int            `json:"total_passed"`
+	TotalFailed  int            `json:"total_failed"`
+	TotalSkipped int            `json:"total_skipped"`
+	TotalErrored int            `json:"total_errored"`
+	ByAgent      map[string]int `json:"by_agent"` // run count per agent
 }
 
 // TestHistoryEntry represents a single test result in history.
@@ -121,27 +135,87 @@ type TestHistoryEntry struct {
 
 // TestRegression represents a test that regressed (was passing, now failing).
 type TestRegression struct {
-	TestName        string `json:"test_name"`
-	TestFile        string `json:"test_file"`
-	LastPassedAt    string `json:"last_passed_at"`
-	LastPassedCommit string `json:"last_passed_commit,omitempty"`
-	FirstFailedAt   string `json:"first_failed_at"`
+	TestName          string `json:"test_name"`
+	TestFile          string `json:"test_file"`
+	LastPassedAt      string `json:"last_passed_at"`
+	LastPassedCommit  string `json:"last_passed_commit,omitempty"`
+	FirstFailedAt     string `json:"first_failed_at"`
 	FirstFailedCommit string `json:"first_failed_commit,omitempty"`
-	ErrorMessage    string `json:"error_message,omitempty"`
+	ErrorMessage      string `json:"error_message,omitempty"`
+	StackTrace        string `json:"stack_trace,omitempty"`
+	Status            string `json:"status"` // Current status: failed or error
+	Owner             string `json:"owner,omitempty"`
+}
+
+// RegressionOptions controls GetRegressionsWithOptions.
+type RegressionOptions struct {
+	Since         string // Only failures at or after this timestamp
+	SuppressFlaky bool   // Drop tests the flaky heuristic currently flags
 }
 
 // TestStatus represents the current status of a test with last_passed info.
 type TestStatus struct {
-	TestName       string `json:"test_name"`
-	TestFile       string `json:"test_file"`
-	CurrentStatus  string `json:"current_status"`
-	LastRunAt      string `json:"last_run_at"`
-	LastPassedAt   string `json:"last_passed_at,omitempty"`
+	TestName         string `json:"test_name"`
+	TestFile         string `json:"test_file"`
+	CurrentStatus    string `json:"current_status"` // passed, failed, error, skipped or missing
+	LastRunAt        string `json:"last_run_at"`
+	LastPassedAt     string `json:"last_passed_at,omitempty"`
 	LastPassedCommit string `json:"last_passed_commit,omitempty"`
-	FailCount      int    `json:"fail_count"`       // consecutive failures
-	TotalRuns      int    `json:"total_runs"`
+	FailCount        int    `json:"fail_count"`  // consecutive failures
+	ErrorCount       int    `json:"error_count"` // consecutive failures that were errors
+	TotalRuns        int    `json:"total_runs"`
+	Flaky            bool   `json:"flaky"` // flipped between pass and fail recently
+	Owner            string `json:"owner,omitempty"`
 }
 
+// StatusFilter selects which tests GetTestSuiteStatus returns.
+type StatusFilter string
+
+const (
+	StatusFilterAll     StatusFilter = "all"
+	StatusFilterFailing StatusFilter = "failing" // currently failed or errored
+	StatusFilterPassing StatusFilter = "passing"
+	StatusFilterFlaky   StatusFilter = "flaky"
+	StatusFilterMissing StatusFilter = "missing" // absent from the latest run of its command
+)
+
+// Valid reports whether f is a known filter. The empty filter means all.
+func (f StatusFilter) Valid() bool {
+	switch f {
+	case "", StatusFilterAll, StatusFilterFailing, StatusFilterPassing, StatusFilterFlaky, StatusFilterMissing:
+		return true
+	}
+	return false
+}
+
+// matches reports whether a test status passes the filter.
+func (f StatusFilter) matches(s TestStatus) bool {
+	switch f {
+	case StatusFilterFailing:
+		return s.CurrentStatus == "failed" || s.CurrentStatus == "error"
+	case StatusFilterPassing:
+		return s.CurrentStatus == "passed"
+	case StatusFilterFlaky:
+		return s.Flaky
+	case StatusFilterMissing:
+		return s.CurrentStatus == TestStatusMissing
+	default:
+		return true
+	}
+}
+
+// TestStatusMissing is the current status of a test that reported in an earlier
+// run but not in a later run of the same command, e.g. it was deleted or is
+// accidentally no longer being run.
+const TestStatusMissing = "missing"
+
+// Flaky detection: a test is flaky when its last flakyWindow non-skipped results
+// switch between passing and not passing at least flakyMinFlips times.
+const (
+	flakyWindow   = 10
+	flakyMinFlips = 2
+)
+
 // BeadTelemetry aggregates all telemetry for a single bead.
 type BeadTelemetry struct {
 	BeadID       string       `json:"bead_id"`
@@ -151,6 +225,12 @@ type BeadTelemetry struct {
 	TokenSummary TokenSummary `json:"token_summary"`
 	GitSummary   GitSummary   `json:"git_summary"`
 	TestSummary  TestSummary  `json:"test_summary"`
+
+	// Daily spend across the bead's lifecycle, annotated with its commits
+	CostTimeline CostTimeline `json:"cost_timeline"`
+
+	// Regressions that first failed at one of the bead's commits
+	Regressions []TestRegression `json:"regressions"`
 }
 
 // AgentTelemetry aggregates all telemetry for a single agent.
@@ -164,6 +244,112 @@ type AgentTelemetry struct {
 	TestSummary  TestSummary  `json:"test_summary"`
 }
 
+// FailedTestCount is how often one test failed in an agent's runs.
+type FailedTestCount struct {
+	TestName string `json:"test_name"`
+	TestFile string `json:"test_file"`
+	Failures int    `json:"failures"` // failed or errored results
+}
+
+// AgentTestHealth rolls up an agent's test results: how often they pass,
+// which tests fail most, and how many regressions began at the agent's commits.
+type AgentTestHealth struct {
+	AgentID         string            `json:"agent_id"`
+	TotalResults    int               `json:"total_results"`
+	Passed          int               `json:"passed"`
+	Failed          int               `json:"failed"`    // failed or errored
+	PassRate        float64           `json:"pass_rate"` // passed / (passed + failed), 0 when nothing ran
+	TopFailedTests  []FailedTestCount `json:"top_failed_tests"`
+	RegressionCount int               `json:"regression_count"`
+}
+
+// agentTopFailedLimit caps the tests listed in AgentTestHealth.TopFailedTests.
+const agentTopFailedLimit = 10
+
+// BeadBudget is a spending limit configured for a single bead.
+type BeadBudget struct {
+	BeadID            string  `json:"bead_id"`
+	BudgetUSD         float64 `json:"budget_usd"`
+	AlertThresholdPct float64 `json:"alert_threshold_pct"` // Percent consumed that triggers an alert (default 80)
+	UpdatedAt         string  `json:"updated_at,omitempty"`
+	AlertedAt         string  `json:"alerted_at,omitempty"`  // When spend first reached the alert threshold
+	ExceededAt        string  `json:"exceeded_at,omitempty"` // When the budget was first exceeded
+}
+
+// BudgetStatus compares a bead's accumulated token cost against its budget.
+type BudgetStatus struct {
+	BeadID          string  `json:"bead_id"`
+	BudgetUSD       float64 `json:"budget_usd"`
+	SpentUSD        float64 `json:"spent_usd"`
+	PercentConsumed float64 `json:"percent_consumed"`
+	OverBudget      bool    `json:"over_budget"`
+	AlertTriggered  bool    `json:"alert_triggered"`
+}
+
+// CommitTestStatus summarizes the latest result of each test recorded at one commit.
+type CommitTestStatus struct {
+	CommitSHA string   `json:"commit_sha"`
+	Total     int      `json:"total"`
+	Passed    int      `json:"passed"`
+	Failed    int      `json:"failed"`
+	Skipped   int      `json:"skipped"`
+	Errored   int      `json:"errored"`
+	Failing   []string `json:"failing,omitempty"` // Tests that failed or errored
+}
+
+// CommitActivity correlates a commit's code change with its test outcome.
+type CommitActivity struct {
+	CommitSHA  string           `json:"commit_sha"`
+	GitChanges []GitChange      `json:"git_changes"`
+	TestRuns   []TestRun        `json:"test_runs"`
+	Tests      CommitTestStatus `json:"tests"` // Latest result per test at the commit
+}
+
+// CoverageGap is a bead commit with no test run recorded at that commit.
+type CoverageGap struct {
+	BeadID       string   `json:"bead_id"`
+	CommitSHA    string   `json:"commit_sha"`
+	AgentID      string   `json:"agent_id"`
+	Timestamp    string   `json:"timestamp"`
+	Message      string   `json:"message"`
+	FilesChanged int      `json:"files_changed"`
+	Files        []string `json:"files"` // Paths from the diff summary; empty when none was recorded
+}
+
+// CommitGate is a merge-gate verdict for a commit.
+type CommitGate struct {
+	CommitSHA   string           `json:"commit_sha"`
+	Pass        bool             `json:"pass"`
+	Reasons     []string         `json:"reasons,omitempty"` // Why the gate failed
+	Tests       CommitTestStatus `json:"tests"`
+	Regressions []TestRegression `json:"regressions"`
+	CostUSD     float64          `json:"cost_usd"`
+	Budget      *BudgetStatus    `json:"budget,omitempty"` // Set when the commit's bead has a budget
+}
+
+// ProgressSnapshot records a convoy's completion at a point in time.
+type ProgressSnapshot struct {
+	ConvoyID   string  `json:"convoy_id"`
+	Rig        string  `json:"rig,omitempty"`
+	Timestamp  string  `json:"timestamp"`
+	Completed  int     `json:"completed"`
+	Total      int     `json:"total"`
+	Percentage float64 `json:"percentage"`
+}
+
+// ConvoyETA is a projected completion time for a convoy, extrapolated from
+// the completion rate across its recent progress snapshots.
+type ConvoyETA struct {
+	ConvoyID            string  `json:"convoy_id"`
+	EstimatedCompletion string  `json:"estimated_completion"` // RFC3339
+	RatePerHour         float64 `json:"rate_per_hour"`        // items completed per hour
+	Remaining           int     `json:"remaining"`
+	Samples             int     `json:"samples"` // snapshots used for the fit
+}
+
+// convoyETASamples is how many recent snapshots EstimateConvoyCompletion fits.
+const convoyETASamples = 10
+
 // Collector defines the interface for telemetry collection.
 type Collector interface {
 	// Ingest
@@ -174,6 +360,7 @@ type Collector interface {
 	// Query - Token Usage
 	GetTokenUsage(filter TelemetryFilter) ([]TokenUsage, error)
 	GetTokenSummary(filter TelemetryFilter) (TokenSummary, error)
+	GetTokenAnomalies(since string) ([]TokenAnomaly, error)
 
 	// Query - Git Changes
 	GetGitChanges(filter TelemetryFilter) ([]GitChange, error)
@@ -187,11 +374,35 @@ type Collector interface {
 	GetTestHistory(testName string, limit int) ([]TestHistoryEntry, error)
 	GetLastPassedCommit(testName string) (string, error)
 	GetRegressions(since string) ([]TestRegression, error)
-	GetTestSuiteStatus() ([]TestStatus, error)
+	GetRegressionsByCommit(since string) (map[string][]TestRegression, error)
+	GetRegressionsWithOptions(opts RegressionOptions) (regressions []TestRegression, suppressed int, err error)
+	GetTestSuiteStatus(filter StatusFilter) ([]TestStatus, error)
+	GetTestStatusAtCommit(commitSHA string) (CommitTestStatus, error)
+	GetCommitActivity(commitSHA string) (CommitActivity, error)
+	GetCoverageGaps(filter TelemetryFilter) ([]CoverageGap, error)
+	GetRegressionsAtCommit(commitSHA string) ([]TestRegression, error)
+	GetBeadRegressions(beadID string) ([]TestRegression, error)
 
 	// Aggregates
 	GetBeadTelemetry(beadID string) (BeadTelemetry, error)
 	GetAgentTelemetry(agentID string) (AgentTelemetry, error)
+	GetAgentTestHealth(agentID string) (AgentTestHealth, error)
+
+	// Budgets
+	SetBeadBudget(budget BeadBudget) error
+	GetBeadBudget(beadID string) (*BeadBudget, error)
+	CheckBeadBudget(beadID string, budgetUSD float64) (BudgetStatus, error)
+	MarkBudgetAlerted(beadID string) (bool, error)
+	MarkBudgetExceeded(beadID string) (bool, error)
+
+	// Merge gating
+	GetCommitCost(commitSHA string) (float64, error)
+	GetCommitGate(commitSHA string) (CommitGate, error)
+
+	// Convoy progress history
+	RecordConvoyProgress(snapshot ProgressSnapshot) error
+	GetConvoyProgressHistory(rig, convoyID string) ([]ProgressSnapshot, error)
+	EstimateConvoyCompletion(rig, convoyID string) (*ConvoyETA, error)
 
 	// Lifecycle
 	Close() error
@@ -199,12 +410,16 @@ type Collector interface {
 
 // SQLiteCollector implements Collector using SQLite storage.
 type SQLiteCollector struct {
-	db *sql.DB
+	db     *sql.DB
+	owners Owners
+
+	anomalyMultiplier float64       // see SetAnomalyMultiplier
+	coalesceWindow    time.Duration // see SetTokenCoalesceWindow
 }
 
 // NewSQLiteCollector creates a new SQLite-backed telemetry collector.
 func NewSQLiteCollector(dbPath string) (*SQLiteCollector, error) {
-	db, err := sql.Open("sqlite", dbPath)
+	db, err := sql.Open("sqlite", withPragmas(dbPath))
 	if err != nil {
 		return nil, fmt.Errorf("open database: %w", err)
 	}
@@ -215,12 +430,124 @@ func NewSQLiteCollector(dbPath string) (*SQLiteCollector, error) {
 		return nil, fmt.Errorf("init schema: %w", err)
 	}
 
+	c.checkIntegrity()
+
 	return c, nil
 }
 
-// initSchema creates the required tables and indexes.
+// SetOwners sets the test ownership rules used to fill Owner on test status
+// and regression results. Call before serving requests.
+func (c *SQLiteCollector) SetOwners(owners Owners) {
+	c.owners = owners
+}
+
+// withPragmas adds the foreign_keys and busy_timeout pragmas to the DSN so
+// every pooled connection, not just the first, enforces foreign keys and waits
+// out another writer's lock instead of failing with SQLITE_BUSY.
+func withPragmas(dbPath string) string {
+	sep := "?"
+	if strings.Contains(dbPath, "?") {
+		sep = "&"
+	}
+	return dbPath + sep + "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+}
+
+// checkIntegrity checkpoints any leftover WAL and verifies the database after startup.
+// A failed check is logged and a REINDEX is attempted; startup is never blocked.
+func (c *SQLiteCollector) checkIntegrity() {
+	if _, err := c.db.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		slog.Warn("Telemetry WAL checkpoint failed", "error", err)
+	}
+
+	if removed, err := c.CheckOrphans(); err != nil {
+		slog.Warn("Telemetry orphan check failed", "error", err)
+	} else if removed > 0 {
+		slog.Warn("Removed orphaned telemetry test results", "count", removed)
+	}
+
+	result, err := c.integrityCheck()
+	if err != nil {
+		slog.Warn("Telemetry integrity check failed to run", "error", err)
+		return
+	}
+	if result == "ok" {
+		return
+	}
+
+	slog.Warn("Telemetry database integrity check failed, attempting recovery", "result", result)
+	if _, err := c.db.Exec("REINDEX"); err != nil {
+		slog.Error("Telemetry database recovery failed", "error", err)
+		return
+	}
+
+	result, err = c.integrityCheck()
+	if err != nil || result != "ok" {
+		slog.Error("Telemetry database still corrupt after recovery", "result", result, "error", err)
+		return
+	}
+	slog.Info("Telemetry database recovered")
+}
+
+// integrityCheck runs PRAGMA integrity_check and returns its first result row.
+func (c *SQLiteCollector) integrityCheck() (string, error) {
+	var result string
+	if err := c.db.QueryRow("PRAGMA integrity_check").Scan(&result); err != nil {
+		return "", err
+	}
+	return result, nil
+}
+
+// CheckOrphans deletes test_results rows whose parent test run no longer exists
+// and returns how many were removed. Such rows predate foreign key enforcement.
+func (c *SQLiteCollector) CheckOrphans() (int, error) {
+	result, err := c.db.Exec(`
+		DELETE FROM test_results
+		WHERE NOT EXISTS (SELECT 1 FROM test_runs WHERE test_runs.id = test_results.run_id)`)
+	if err != nil {
+		return 0, fmt.Errorf("delete orphaned test results: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("count orphaned test results: %w", err)
+	}
+	return int(n), nil
+}
+
+// Vacuum rebuilds the database file to reclaim space. Intended for periodic maintenance.
+func (c *SQLiteCollector) Vacuum() error {
+	if _, err := c.db.Exec("VACUUM"); err != nil {
+		return fmt.Errorf("vacuum: %w", err)
+	}
+	return nil
+}
+
+// initSchema brings the database up to the latest schema version.
 func (c *SQLiteCollector) initSchema() error {
-	schema := `
+	return migrate.Run(c.db, migrations)
+}
+
+// migrations is the ordered telemetry schema history. Early migrations are
+// idempotent so databases created before versioning upgrade cleanly.
+var migrations = []migrate.Migration{
+	{Version: 1, Name: "initial schema", Up: execMigration(initialSchema)},
+	{Version: 2, Name: "add rig columns", Up: addRigColumns},
+	{Version: 3, Name: "add test_runs.errored", Up: addErroredColumn},
+	{Version: 4, Name: "backfill rig from agent id", Up: backfillRigs},
+	{Version: 5, Name: "add convoy_progress", Up: execMigration(convoyProgressSchema)},
+	{Version: 6, Name: "add test_runs.model", Up: execMigration("ALTER TABLE test_runs ADD COLUMN model TEXT")},
+	{Version: 7, Name: "add bead_budgets.alerted_at", Up: execMigration("ALTER TABLE bead_budgets ADD COLUMN alerted_at TEXT")},
+}
+
+// execMigration returns a migration step that executes a fixed SQL script.
+func execMigration(script string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(script)
+		return err
+	}
+}
+
+const (
+	initialSchema = `
 	CREATE TABLE IF NOT EXISTS token_usage (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		agent_id TEXT NOT NULL,
@@ -292,9 +619,79 @@ func (c *SQLiteCollector) initSchema() error {
 	CREATE INDEX IF NOT EXISTS idx_test_results_commit_sha ON test_results(commit_sha);
 	CREATE INDEX IF NOT EXISTS idx_test_results_test_name_timestamp ON test_results(test_name, timestamp);
 	CREATE INDEX IF NOT EXISTS idx_test_results_test_name_status_timestamp ON test_results(test_name, status, timestamp);
+
+	CREATE TABLE IF NOT EXISTS bead_budgets (
+		bead_id TEXT PRIMARY KEY,
+		budget_usd REAL NOT NULL,
+		alert_threshold_pct REAL NOT NULL,
+		updated_at TEXT NOT NULL,
+		exceeded_at TEXT
+	);
 	`
-	_, err := c.db.Exec(schema)
-	return err
+
+	convoyProgressSchema = `
+	CREATE TABLE IF NOT EXISTS convoy_progress (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		convoy_id TEXT NOT NULL,
+		rig TEXT,
+		timestamp TEXT NOT NULL,
+		completed INTEGER NOT NULL,
+		total INTEGER NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_convoy_progress_convoy_id_timestamp ON convoy_progress(convoy_id, timestamp);
+	`
+)
+
+// rigTables lists the telemetry tables that carry a rig column.
+var rigTables = []string{"token_usage", "git_changes", "test_runs", "test_results"}
+
+// addRigColumns adds the rig column (and its index) to tables created before it existed.
+func addRigColumns(tx *sql.Tx) error {
+	for _, table := range rigTables {
+		exists, err := migrate.ColumnExists(tx, table, "rig")
+		if err != nil {
+			return fmt.Errorf("inspect %s: %w", table, err)
+		}
+		if !exists {
+			if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN rig TEXT", table)); err != nil {
+				return fmt.Errorf("add rig column to %s: %w", table, err)
+			}
+		}
+		if _, err := tx.Exec(fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_rig ON %s(rig)", table, table)); err != nil {
+			return fmt.Errorf("create rig index on %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// addErroredColumn adds test_runs.errored, counting panics and timeouts apart from failures.
+func addErroredColumn(tx *sql.Tx) error {
+	exists, err := migrate.ColumnExists(tx, "test_runs", "errored")
+	if err != nil {
+		return fmt.Errorf("inspect test_runs: %w", err)
+	}
+	if exists {
+		return nil
+	}
+	if _, err := tx.Exec("ALTER TABLE test_runs ADD COLUMN errored INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return fmt.Errorf("add errored column to test_runs: %w", err)
+	}
+	return nil
+}
+
+// backfillRigs infers the rig of rows recorded before the rig column existed from
+// their agent ID prefix (e.g. "townview/crew/jeremy" -> "townview"). Agent IDs
+// without a rig prefix are left NULL.
+func backfillRigs(tx *sql.Tx) error {
+	for _, table := range rigTables {
+		_, err := tx.Exec(fmt.Sprintf(`
+			UPDATE %s SET rig = substr(agent_id, 1, instr(agent_id, '/') - 1)
+			WHERE rig IS NULL AND instr(agent_id, '/') > 1`, table))
+		if err != nil {
+			return fmt.Errorf("backfill rig on %s: %w", table, err)
+		}
+	}
+	return nil
 }
 
 // Close closes the database connection.
@@ -302,12 +699,89 @@ func (c *SQLiteCollector) Close() error {
 	return c.db.Close()
 }
 
-// RecordTokenUsage stores a token usage record.
+// SetTokenCoalesceWindow turns on aggregation at ingest: token usage for the
+// same agent, bead, rig, model and request type recorded within window of an
+// existing row is added to that row instead of inserted. Totals are unchanged;
+// individual calls are no longer distinguishable. 0 disables it (the default).
+// Call before serving requests.
+func (c *SQLiteCollector) SetTokenCoalesceWindow(window time.Duration) {
+	c.coalesceWindow = window
+}
+
+// RecordTokenUsage stores a token usage record, or folds it into a running
+// row when a coalesce window is set.
 func (c *SQLiteCollector) RecordTokenUsage(usage TokenUsage) error {
-	_, err := c.db.Exec(`
-		INSERT INTO token_usage (agent_id, bead_id, timestamp, input_tokens, output_tokens, model, request_type)
-		VALUES (?, ?, ?, ?, ?, ?, ?)`,
-		usage.AgentID, nullString(usage.BeadID), usage.Timestamp,
+	if ts, err := time.Parse(time.RFC3339, usage.Timestamp); err == nil {
+		// Stored in UTC so timestamps from agents in other zones order correctly
+		usage.Timestamp = ts.UTC().Format(time.RFC3339)
+		if c.coalesceWindow > 0 {
+			return c.coalesceTokenUsage(usage, ts.Add(-c.coalesceWindow).UTC().Format(time.RFC3339))
+		}
+	}
+	return c.insertTokenUsage(context.Background(), c.db, usage)
+}
+
+// execer is the ExecContext method shared by *sql.DB, *sql.Tx and *sql.Conn.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// coalesceTokenUsage adds usage to the newest matching row whose window began
+// after windowStart and no later than the usage, or inserts a new row. The
+// lookup and write run under BEGIN IMMEDIATE so concurrent heartbeats for the
+// same agent cannot both miss the row and insert duplicates. Timestamps are
+// compared with julianday() so rows stored with a zone offset still match.
+func (c *SQLiteCollector) coalesceTokenUsage(usage TokenUsage, windowStart string) error {
+	ctx := context.Background()
+	conn, err := c.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("begin token usage: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "BEGIN IMMEDIATE"); err != nil {
+		return fmt.Errorf("begin token usage: %w", err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			conn.ExecContext(ctx, "ROLLBACK")
+		}
+	}()
+
+	var id int64
+	err = conn.QueryRowContext(ctx, `
+		SELECT id FROM token_usage
+		WHERE agent_id = ? AND COALESCE(bead_id, '') = ? AND COALESCE(rig, '') = ?
+		  AND model = ? AND request_type = ?
+		  AND julianday(timestamp) > julianday(?) AND julianday(timestamp) <= julianday(?)
+		ORDER BY julianday(timestamp) DESC LIMIT 1`,
+		usage.AgentID, usage.BeadID, usage.Rig, usage.Model, usage.RequestType,
+		windowStart, usage.Timestamp).Scan(&id)
+	switch {
+	case err == sql.ErrNoRows:
+		err = c.insertTokenUsage(ctx, conn, usage)
+	case err == nil:
+		_, err = conn.ExecContext(ctx, `
+			UPDATE token_usage SET input_tokens = input_tokens + ?, output_tokens = output_tokens + ?
+			WHERE id = ?`, usage.InputTokens, usage.OutputTokens, id)
+	}
+	if err != nil {
+		return fmt.Errorf("coalesce token usage: %w", err)
+	}
+	if _, err := conn.ExecContext(ctx, "COMMIT"); err != nil {
+		return fmt.Errorf("commit token usage: %w", err)
+	}
+	committed = true
+	return nil
+}
+
+// insertTokenUsage inserts usage as a new row.
+func (c *SQLiteCollector) insertTokenUsage(ctx context.Context, db execer, usage TokenUsage) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO token_usage (agent_id, bead_id, rig, timestamp, input_tokens, output_tokens, model, request_type)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		usage.AgentID, nullString(usage.BeadID), nullString(usage.Rig), usage.Timestamp,
 		usage.InputTokens, usage.OutputTokens, usage.Model, usage.RequestType)
 	return err
 }
@@ -315,9 +789,9 @@ func (c *SQLiteCollector) RecordTokenUsage(usage TokenUsage) error {
 // RecordGitChange stores a git change record.
 func (c *SQLiteCollector) RecordGitChange(change GitChange) error {
 	_, err := c.db.Exec(`
-		INSERT INTO git_changes (agent_id, bead_id, timestamp, commit_sha, branch, files_changed, insertions, deletions, message, diff_summary)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		change.AgentID, nullString(change.BeadID), change.Timestamp,
+		INSERT INTO git_changes (agent_id, bead_id, rig, timestamp, commit_sha, branch, files_changed, insertions, deletions, message, diff_summary)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		change.AgentID, nullString(change.BeadID), nullString(change.Rig), change.Timestamp,
 		change.CommitSHA, change.Branch, change.FilesChanged,
 		change.Insertions, change.Deletions, change.Message, nullString(change.DiffSummary))
 	return err
@@ -342,17 +816,19 @@ func (c *SQLiteCollector) RecordTestRun(run TestRun) error {
 				run.Failed++
 			case "skipped":
 				run.Skipped++
+			case "error":
+				run.Errored++
 			}
 			run.DurationMS += r.DurationMS
 		}
 	}
 
 	result, err := tx.Exec(`
-		INSERT INTO test_runs (agent_id, bead_id, timestamp, commit_sha, branch, command, total, passed, failed, skipped, duration_ms)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		run.AgentID, nullString(run.BeadID), run.Timestamp,
+		INSERT INTO test_runs (agent_id, bead_id, rig, timestamp, commit_sha, branch, command, total, passed, failed, skipped, errored, duration_ms, model)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		run.AgentID, nullString(run.BeadID), nullString(run.Rig), run.Timestamp,
 		nullString(run.CommitSHA), nullString(run.Branch),
-		run.Command, run.Total, run.Passed, run.Failed, run.Skipped, run.DurationMS)
+		run.Command, run.Total, run.Passed, run.Failed, run.Skipped, run.Errored, run.DurationMS, nullString(run.Model))
 	if err != nil {
 		return fmt.Errorf("insert test run: %w", err)
 	}
@@ -364,9 +840,9 @@ func (c *SQLiteCollector) RecordTestRun(run TestRun) error {
 
 	for _, r := range run.Results {
 		_, err := tx.Exec(`
-			INSERT INTO test_results (run_id, agent_id, bead_id, timestamp, commit_sha, test_file, test_name, status, duration_ms, error_message, stack_trace)
-			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-			runID, run.AgentID, nullString(run.BeadID), run.Timestamp,
+			INSERT INTO test_results (run_id, agent_id, bead_id, rig, timestamp, commit_sha, test_file, test_name, status, duration_ms, error_message, stack_trace)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			runID, run.AgentID, nullString(run.BeadID), nullString(run.Rig), run.Timestamp,
 			nullString(run.CommitSHA),
 			r.TestFile, r.TestName, r.Status, r.DurationMS,
 			nullString(r.ErrorMessage), nullString(r.StackTrace))
@@ -380,7 +856,7 @@ func (c *SQLiteCollector) RecordTestRun(run TestRun) error {
 
 // GetTokenUsage retrieves token usage records matching the filter.
 func (c *SQLiteCollector) GetTokenUsage(filter TelemetryFilter) ([]TokenUsage, error) {
-	query := `SELECT agent_id, COALESCE(bead_id, ''), timestamp, input_tokens, output_tokens, model, request_type FROM token_usage WHERE 1=1`
+	query := `SELECT agent_id, COALESCE(bead_id, ''), COALESCE(rig, ''), timestamp, input_tokens, output_tokens, model, request_type FROM token_usage WHERE 1=1`
 	args := []interface{}{}
 
 	query, args = applyFilter(query, args, filter)
@@ -398,7 +874,7 @@ func (c *SQLiteCollector) GetTokenUsage(filter TelemetryFilter) ([]TokenUsage, e
 	var results []TokenUsage
 	for rows.Next() {
 		var u TokenUsage
-		if err := rows.Scan(&u.AgentID, &u.BeadID, &u.Timestamp, &u.InputTokens, &u.OutputTokens, &u.Model, &u.RequestType); err != nil {
+		if err := rows.Scan(&u.AgentID, &u.BeadID, &u.Rig, &u.Timestamp, &u.InputTokens, &u.OutputTokens, &u.Model, &u.RequestType); err != nil {
 			return nil, err
 		}
 		results = append(results, u)
@@ -408,20 +884,26 @@ func (c *SQLiteCollector) GetTokenUsage(filter TelemetryFilter) ([]TokenUsage, e
 
 // GetTokenSummary aggregates token usage statistics for the given filter.
 func (c *SQLiteCollector) GetTokenSummary(filter TelemetryFilter) (TokenSummary, error) {
+	usage, err := c.GetTokenUsage(filter)
+	if err != nil {
+		return summarizeTokenUsage(nil), err
+	}
+	return summarizeTokenUsage(usage), nil
+}
+
+// summarizeTokenUsage totals token usage records overall, by model and by agent.
+func summarizeTokenUsage(usage []TokenUsage) TokenSummary {
 	summary := TokenSummary{
 		ByModel: make(map[string]TokenModelSummary),
 		ByAgent: make(map[string]TokenModelSummary),
 	}
 
-	usage, err := c.GetTokenUsage(filter)
-	if err != nil {
-		return summary, err
-	}
-
 	for _, u := range usage {
 		summary.TotalInput += u.InputTokens
 		summary.TotalOutput += u.OutputTokens
 
+		summary.TotalCostUSD += EstimateCostUSD(u.Model, u.InputTokens, u.OutputTokens)
+
 		// Aggregate by model
 		m := summary.ByModel[u.Model]
 		m.Input += u.InputTokens
@@ -435,15 +917,16 @@ func (c *SQLiteCollector) GetTokenSummary(filter TelemetryFilter) (TokenSummary,
 		summary.ByAgent[u.AgentID] = a
 	}
 
-	return summary, nil
+	return summary
 }
 
 // GetGitChanges retrieves git change records matching the filter.
 func (c *SQLiteCollector) GetGitChanges(filter TelemetryFilter) ([]GitChange, error) {
-	query := `SELECT agent_id, COALESCE(bead_id, ''), timestamp, commit_sha, branch, files_changed, insertions, deletions, message, COALESCE(diff_summary, '') FROM git_changes WHERE 1=1`
+	query := `SELECT agent_id, COALESCE(bead_id, ''), COALESCE(rig, ''), timestamp, commit_sha, branch, files_changed, insertions, deletions, message, COALESCE(diff_summary, '') FROM git_changes WHERE 1=1`
 	args := []interface{}{}
 
 	query, args = applyFilter(query, args, filter)
+	query, args = applyCommitFilter(query, args, filter)
 	query += " ORDER BY timestamp DESC"
 	if filter.Limit > 0 {
 		query += fmt.Sprintf(" LIMIT %d", filter.Limit)
@@ -458,7 +941,7 @@ func (c *SQLiteCollector) GetGitChanges(filter TelemetryFilter) ([]GitChange, er
 	var results []GitChange
 	for rows.Next() {
 		var g GitChange
-		if err := rows.Scan(&g.AgentID, &g.BeadID, &g.Timestamp, &g.CommitSHA, &g.Branch, &g.FilesChanged, &g.Insertions, &g.Deletions, &g.Message, &g.DiffSummary); err != nil {
+		if err := rows.Scan(&g.AgentID, &g.BeadID, &g.Rig, &g.Timestamp, &g.CommitSHA, &g.Branch, &g.FilesChanged, &g.Insertions, &g.Deletions, &g.Message, &g.DiffSummary); err != nil {
 			return nil, err
 		}
 		results = append(results, g)
@@ -468,13 +951,17 @@ func (c *SQLiteCollector) GetGitChanges(filter TelemetryFilter) ([]GitChange, er
 
 // GetGitSummary aggregates git change statistics for the given filter.
 func (c *SQLiteCollector) GetGitSummary(filter TelemetryFilter) (GitSummary, error) {
-	summary := GitSummary{
-		ByAgent: make(map[string]int),
-	}
-
 	changes, err := c.GetGitChanges(filter)
 	if err != nil {
-		return summary, err
+		return summarizeGitChanges(nil), err
+	}
+	return summarizeGitChanges(changes), nil
+}
+
+// summarizeGitChanges totals git change records overall and by agent.
+func summarizeGitChanges(changes []GitChange) GitSummary {
+	summary := GitSummary{
+		ByAgent: make(map[string]int),
 	}
 
 	for _, g := range changes {
@@ -485,15 +972,16 @@ func (c *SQLiteCollector) GetGitSummary(filter TelemetryFilter) (GitSummary, err
 		summary.ByAgent[g.AgentID]++
 	}
 
-	return summary, nil
+	return summary
 }
 
 // GetTestRuns retrieves test run records matching the filter.
 func (c *SQLiteCollector) GetTestRuns(filter TelemetryFilter) ([]TestRun, error) {
-	query := `SELECT id, agent_id, COALESCE(bead_id, ''), timestamp, COALESCE(commit_sha, ''), COALESCE(branch, ''), command, total, passed, failed, skipped, duration_ms FROM test_runs WHERE 1=1`
+	query := `SELECT id, agent_id, COALESCE(bead_id, ''), COALESCE(rig, ''), timestamp, COALESCE(commit_sha, ''), COALESCE(branch, ''), command, total, passed, failed, skipped, errored, duration_ms, COALESCE(model, '') FROM test_runs WHERE 1=1`
 	args := []interface{}{}
 
 	query, args = applyFilter(query, args, filter)
+	query, args = applyCommitFilter(query, args, filter)
 	query += " ORDER BY timestamp DESC"
 	if filter.Limit > 0 {
 		query += fmt.Sprintf(" LIMIT %d", filter.Limit)
@@ -509,13 +997,18 @@ func (c *SQLiteCollector) GetTestRuns(filter TelemetryFilter) ([]TestRun, error)
 	for rows.Next() {
 		var runID int64
 		var r TestRun
-		if err := rows.Scan(&runID, &r.AgentID, &r.BeadID, &r.Timestamp, &r.CommitSHA, &r.Branch, &r.Command, &r.Total, &r.Passed, &r.Failed, &r.Skipped, &r.DurationMS); err != nil {
+		if err := rows.Scan(&runID, &r.AgentID, &r.BeadID, &r.Rig, &r.Timestamp, &r.CommitSHA, &r.Branch, &r.Command, &r.Total, &r.Passed, &r.Failed, &r.Skipped, &r.Errored, &r.DurationMS, &r.Model); err != nil {
 			return nil, err
 		}
 
+		if filter.ExcludeResults {
+			results = append(results, r)
+			continue
+		}
+
 		// Load individual results for this run
 		resultRows, err := c.db.Query(`
-			SELECT agent_id, COALESCE(bead_id, ''), timestamp, COALESCE(commit_sha, ''), test_file, test_name, status, duration_ms, COALESCE(error_message, ''), COALESCE(stack_trace, '')
+			SELECT agent_id, COALESCE(bead_id, ''), COALESCE(rig, ''), timestamp, COALESCE(commit_sha, ''), test_file, test_name, status, duration_ms, COALESCE(error_message, ''), COALESCE(stack_trace, '')
 			FROM test_results WHERE run_id = ?`, runID)
 		if err != nil {
 			return nil, err
@@ -523,7 +1016,7 @@ func (c *SQLiteCollector) GetTestRuns(filter TelemetryFilter) ([]TestRun, error)
 
 		for resultRows.Next() {
 			var tr TestResult
-			if err := resultRows.Scan(&tr.AgentID, &tr.BeadID, &tr.Timestamp, &tr.CommitSHA, &tr.TestFile, &tr.TestName, &tr.Status, &tr.DurationMS, &tr.ErrorMessage, &tr.StackTrace); err != nil {
+			if err := resultRows.Scan(&tr.AgentID, &tr.BeadID, &tr.Rig, &tr.Timestamp, &tr.CommitSHA, &tr.TestFile, &tr.TestName, &tr.Status, &tr.DurationMS, &tr.ErrorMessage, &tr.StackTrace); err != nil {
 				resultRows.Close()
 				return nil, err
 			}
@@ -538,13 +1031,19 @@ func (c *SQLiteCollector) GetTestRuns(filter TelemetryFilter) ([]TestRun, error)
 
 // GetTestSummary aggregates test result statistics for the given filter.
 func (c *SQLiteCollector) GetTestSummary(filter TelemetryFilter) (TestSummary, error) {
-	summary := TestSummary{
-		ByAgent: make(map[string]int),
-	}
-
+	// Totals come from the run rows; per-test results aren't needed
+	filter.ExcludeResults = true
 	runs, err := c.GetTestRuns(filter)
 	if err != nil {
-		return summary, err
+		return summarizeTestRuns(nil), err
+	}
+	return summarizeTestRuns(runs), nil
+}
+
+// summarizeTestRuns totals test run records overall and by agent.
+func summarizeTestRuns(runs []TestRun) TestSummary {
+	summary := TestSummary{
+		ByAgent: make(map[string]int),
 	}
 
 	for _, r := range runs {
@@ -553,10 +1052,11 @@ func (c *SQLiteCollector) GetTestSummary(filter TelemetryFilter) (TestSummary, e
 		summary.TotalPassed += r.Passed
 		summary.TotalFailed += r.Failed
 		summary.TotalSkipped += r.Skipped
+		summary.TotalErrored += r.Errored
 		summary.ByAgent[r.AgentID]++
 	}
 
-	return summary, nil
+	return summary
 }
 
 // GetTestHistory returns chronological test results for a specific test.
@@ -624,9 +1124,26 @@ func (c *SQLiteCollector) GetLastPassedCommit(testName string) (string, error) {
 
 // GetRegressions returns tests that were passing but now fail since the given timestamp.
 func (c *SQLiteCollector) GetRegressions(since string) ([]TestRegression, error) {
+	return c.queryRegressions(since, nil)
+}
+
+// regressionsFirstFailingAt returns the regressions, over all time, whose
+// first failing commit is one of commits.
+func (c *SQLiteCollector) regressionsFirstFailingAt(commits []string) ([]TestRegression, error) {
+	if len(commits) == 0 {
+		return []TestRegression{}, nil
+	}
+	return c.queryRegressions("", commits)
+}
+
+// queryRegressions runs the regression query for failures since the given
+// timestamp, keeping only regressions first failing at one of commits when
+// commits is non-nil.
+func (c *SQLiteCollector) queryRegressions(since string, commits []string) ([]TestRegression, error) {
 	// Find tests that have both a passing result before and a failing result after the 'since' time,
 	// where the most recent result is a failure.
 	// A regression requires: (1) test currently failing, (2) test had a prior pass, (3) first failure since 'since' is after the last pass
+	// Errors (panics, timeouts) count as failures here.
 	query := `
 		WITH latest_results AS (
 			SELECT
@@ -655,13 +1172,16 @@ func (c *SQLiteCollector) GetRegressions(since string) ([]TestRegression, error)
 				test_name,
 				MIN(timestamp) as first_failed_at,
 				(SELECT commit_sha FROM test_results t2
-				 WHERE t2.test_name = test_results.test_name AND t2.status = 'failed' AND t2.timestamp >= ?
+				 WHERE t2.test_name = test_results.test_name AND t2.status IN ('failed', 'error') AND t2.timestamp >= ?
 				 ORDER BY t2.timestamp ASC LIMIT 1) as first_failed_commit,
 				(SELECT error_message FROM test_results t2
-				 WHERE t2.test_name = test_results.test_name AND t2.status = 'failed' AND t2.timestamp >= ?
-				 ORDER BY t2.timestamp ASC LIMIT 1) as error_message
+				 WHERE t2.test_name = test_results.test_name AND t2.status IN ('failed', 'error') AND t2.timestamp >= ?
+				 ORDER BY t2.timestamp ASC LIMIT 1) as error_message,
+				(SELECT stack_trace FROM test_results t2
+				 WHERE t2.test_name = test_results.test_name AND t2.status IN ('failed', 'error') AND t2.timestamp >= ?
+				 ORDER BY t2.timestamp ASC LIMIT 1) as stack_trace
 			FROM test_results
-			WHERE status = 'failed' AND timestamp >= ?
+			WHERE status IN ('failed', 'error') AND timestamp >= ?
 			GROUP BY test_name
 		)
 		SELECT
@@ -671,16 +1191,25 @@ func (c *SQLiteCollector) GetRegressions(since string) ([]TestRegression, error)
 			COALESCE(lp.last_passed_commit, '') as last_passed_commit,
 			ff.first_failed_at,
 			COALESCE(ff.first_failed_commit, '') as first_failed_commit,
-			COALESCE(ff.error_message, '') as error_message
+			COALESCE(ff.error_message, '') as error_message,
+			COALESCE(ff.stack_trace, '') as stack_trace,
+			lr.status
 		FROM latest_results lr
 		JOIN first_failed_since ff ON lr.test_name = ff.test_name
 		JOIN last_passed lp ON lr.test_name = lp.test_name
-		WHERE lr.rn = 1 AND lr.status = 'failed'
+		WHERE lr.rn = 1 AND lr.status IN ('failed', 'error')
 		  AND lp.last_passed_at < ff.first_failed_at
-		ORDER BY ff.first_failed_at DESC
 	`
+	args := []interface{}{since, since, since, since}
+	if commits != nil {
+		query += " AND ff.first_failed_commit IN (?" + strings.Repeat(", ?", len(commits)-1) + ")"
+		for _, sha := range commits {
+			args = append(args, sha)
+		}
+	}
+	query += " ORDER BY ff.first_failed_at DESC"
 
-	rows, err := c.db.Query(query, since, since, since)
+	rows, err := c.db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("query regressions: %w", err)
 	}
@@ -690,9 +1219,10 @@ func (c *SQLiteCollector) GetRegressions(since string) ([]TestRegression, error)
 	for rows.Next() {
 		var r TestRegression
 		if err := rows.Scan(&r.TestName, &r.TestFile, &r.LastPassedAt, &r.LastPassedCommit,
-			&r.FirstFailedAt, &r.FirstFailedCommit, &r.ErrorMessage); err != nil {
+			&r.FirstFailedAt, &r.FirstFailedCommit, &r.ErrorMessage, &r.StackTrace, &r.Status); err != nil {
 			return nil, fmt.Errorf("scan regression: %w", err)
 		}
+		r.Owner = c.owners.OwnerOf(r.TestFile)
 		results = append(results, r)
 	}
 
@@ -707,8 +1237,62 @@ func (c *SQLiteCollector) GetRegressions(since string) ([]TestRegression, error)
 	return results, nil
 }
 
-// GetTestSuiteStatus returns the status of all tests with their last_passed info.
-func (c *SQLiteCollector) GetTestSuiteStatus() ([]TestStatus, error) {
+// GetRegressionsByCommit returns regressions since the given timestamp grouped by
+// the commit where each test first failed. Regressions without a known commit are
+// grouped under the empty string.
+func (c *SQLiteCollector) GetRegressionsByCommit(since string) (map[string][]TestRegression, error) {
+	regressions, err := c.GetRegressions(since)
+	if err != nil {
+		return nil, err
+	}
+
+	return GroupRegressionsByCommit(regressions), nil
+}
+
+// GroupRegressionsByCommit groups regressions by their first-failed commit SHA.
+func GroupRegressionsByCommit(regressions []TestRegression) map[string][]TestRegression {
+	grouped := make(map[string][]TestRegression)
+	for _, r := range regressions {
+		grouped[r.FirstFailedCommit] = append(grouped[r.FirstFailedCommit], r)
+	}
+	return grouped
+}
+
+// GetRegressionsWithOptions returns regressions like GetRegressions, optionally
+// leaving out flaky tests, which are often red at query time without a real
+// breakage. suppressed is how many regressions were left out.
+func (c *SQLiteCollector) GetRegressionsWithOptions(opts RegressionOptions) ([]TestRegression, int, error) {
+	regressions, err := c.GetRegressions(opts.Since)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !opts.SuppressFlaky || len(regressions) == 0 {
+		return regressions, 0, nil
+	}
+
+	flaky, err := c.GetTestSuiteStatus(StatusFilterFlaky)
+	if err != nil {
+		return nil, 0, fmt.Errorf("get flaky tests: %w", err)
+	}
+	if len(flaky) == 0 {
+		return regressions, 0, nil
+	}
+	isFlaky := make(map[string]bool, len(flaky))
+	for _, s := range flaky {
+		isFlaky[s.TestName] = true
+	}
+
+	kept := make([]TestRegression, 0, len(regressions))
+	for _, r := range regressions {
+		if !isFlaky[r.TestName] {
+			kept = append(kept, r)
+		}
+	}
+	return kept, len(regressions) - len(kept), nil
+}
+
+// GetTestSuiteStatus returns the status of tests matching the filter with their last_passed info.
+func (c *SQLiteCollector) GetTestSuiteStatus(filter StatusFilter) ([]TestStatus, error) {
 	query := `
 		WITH latest_results AS (
 			SELECT
@@ -717,6 +1301,7 @@ func (c *SQLiteCollector) GetTestSuiteStatus() ([]TestStatus, error) {
 				status,
 				timestamp,
 				commit_sha,
+				run_id,
 				ROW_NUMBER() OVER (PARTITION BY test_name ORDER BY timestamp DESC) as rn
 			FROM test_results
 		),
@@ -734,7 +1319,8 @@ func (c *SQLiteCollector) GetTestSuiteStatus() ([]TestStatus, error) {
 		fail_counts AS (
 			SELECT
 				test_name,
-				COUNT(*) as consecutive_fails
+				COUNT(*) as consecutive_fails,
+				SUM(CASE WHEN status = 'error' THEN 1 ELSE 0 END) as consecutive_errors
 			FROM (
 				SELECT
 					test_name,
@@ -744,7 +1330,7 @@ func (c *SQLiteCollector) GetTestSuiteStatus() ([]TestStatus, error) {
 					 WHERE t2.test_name = test_results.test_name AND t2.status = 'passed'
 					 AND t2.timestamp > test_results.timestamp) as next_pass
 				FROM test_results
-				WHERE status = 'failed'
+				WHERE status IN ('failed', 'error')
 			) t
 			WHERE next_pass IS NULL
 			GROUP BY test_name
@@ -753,6 +1339,25 @@ func (c *SQLiteCollector) GetTestSuiteStatus() ([]TestStatus, error) {
 			SELECT test_name, COUNT(*) as total_runs
 			FROM test_results
 			GROUP BY test_name
+		),
+		recent AS (
+			SELECT
+				test_name,
+				status,
+				ROW_NUMBER() OVER (PARTITION BY test_name ORDER BY timestamp DESC) as rn
+			FROM test_results
+			WHERE status != 'skipped'
+		),
+		flips AS (
+			SELECT
+				test_name,
+				SUM(CASE WHEN prev IS NOT NULL AND (status = 'passed') != (prev = 'passed') THEN 1 ELSE 0 END) as flips
+			FROM (
+				SELECT test_name, status, LAG(status) OVER (PARTITION BY test_name ORDER BY rn) as prev
+				FROM recent
+				WHERE rn <= ?
+			) t
+			GROUP BY test_name
 		)
 		SELECT
 			lr.test_name,
@@ -762,16 +1367,24 @@ func (c *SQLiteCollector) GetTestSuiteStatus() ([]TestStatus, error) {
 			COALESCE(lp.last_passed_at, '') as last_passed_at,
 			COALESCE(lp.last_passed_commit, '') as last_passed_commit,
 			COALESCE(fc.consecutive_fails, 0) as fail_count,
-			COALESCE(tr.total_runs, 0) as total_runs
+			COALESCE(fc.consecutive_errors, 0) as error_count,
+			COALESCE(tr.total_runs, 0) as total_runs,
+			COALESCE(fl.flips, 0) >= ? as flaky,
+			EXISTS (
+				SELECT 1 FROM test_runs cur
+				JOIN test_runs later ON later.command = cur.command AND later.timestamp > cur.timestamp
+				WHERE cur.id = lr.run_id
+			) as missing
 		FROM latest_results lr
 		LEFT JOIN last_passed lp ON lr.test_name = lp.test_name
 		LEFT JOIN fail_counts fc ON lr.test_name = fc.test_name
 		LEFT JOIN total_runs tr ON lr.test_name = tr.test_name
+		LEFT JOIN flips fl ON lr.test_name = fl.test_name
 		WHERE lr.rn = 1
 		ORDER BY lr.test_name
 	`
 
-	rows, err := c.db.Query(query)
+	rows, err := c.db.Query(query, flakyWindow, flakyMinFlips)
 	if err != nil {
 		return nil, fmt.Errorf("query test suite status: %w", err)
 	}
@@ -780,10 +1393,18 @@ func (c *SQLiteCollector) GetTestSuiteStatus() ([]TestStatus, error) {
 	var results []TestStatus
 	for rows.Next() {
 		var s TestStatus
+		var missing bool
 		if err := rows.Scan(&s.TestName, &s.TestFile, &s.CurrentStatus, &s.LastRunAt,
-			&s.LastPassedAt, &s.LastPassedCommit, &s.FailCount, &s.TotalRuns); err != nil {
+			&s.LastPassedAt, &s.LastPassedCommit, &s.FailCount, &s.ErrorCount, &s.TotalRuns, &s.Flaky, &missing); err != nil {
 			return nil, fmt.Errorf("scan test status: %w", err)
 		}
+		if missing {
+			s.CurrentStatus = TestStatusMissing
+		}
+		s.Owner = c.owners.OwnerOf(s.TestFile)
+		if !filter.matches(s) {
+			continue
+		}
 		results = append(results, s)
 	}
 
@@ -798,6 +1419,210 @@ func (c *SQLiteCollector) GetTestSuiteStatus() ([]TestStatus, error) {
 	return results, nil
 }
 
+// GetTestStatusAtCommit summarizes test results recorded at a commit.
+// When a test ran more than once at the commit, its latest result wins.
+func (c *SQLiteCollector) GetTestStatusAtCommit(commitSHA string) (CommitTestStatus, error) {
+	status := CommitTestStatus{CommitSHA: commitSHA}
+
+	rows, err := c.db.Query(`
+		SELECT test_name, status FROM (
+			SELECT test_name, status,
+				ROW_NUMBER() OVER (PARTITION BY test_name ORDER BY timestamp DESC, id DESC) as rn
+			FROM test_results
+			WHERE commit_sha = ?
+		)
+		WHERE rn = 1
+		ORDER BY test_name`, commitSHA)
+	if err != nil {
+		return status, fmt.Errorf("query commit test status: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name, result string
+		if err := rows.Scan(&name, &result); err != nil {
+			return status, fmt.Errorf("scan commit test status: %w", err)
+		}
+		status.Total++
+		switch result {
+		case "passed":
+			status.Passed++
+		case "failed":
+			status.Failed++
+			status.Failing = append(status.Failing, name)
+		case "skipped":
+			status.Skipped++
+		case "error":
+			status.Errored++
+			status.Failing = append(status.Failing, name)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return status, fmt.Errorf("iterate commit test status: %w", err)
+	}
+	return status, nil
+}
+
+// GetCommitActivity returns the git changes and test runs recorded for a
+// commit, with the test outcome at that commit.
+func (c *SQLiteCollector) GetCommitActivity(commitSHA string) (CommitActivity, error) {
+	activity := CommitActivity{CommitSHA: commitSHA}
+	filter := TelemetryFilter{CommitSHA: commitSHA}
+
+	var err error
+	activity.GitChanges, err = c.GetGitChanges(filter)
+	if err != nil {
+		return activity, fmt.Errorf("get git changes: %w", err)
+	}
+	activity.TestRuns, err = c.GetTestRuns(filter)
+	if err != nil {
+		return activity, fmt.Errorf("get test runs: %w", err)
+	}
+	activity.Tests, err = c.GetTestStatusAtCommit(commitSHA)
+	if err != nil {
+		return activity, err
+	}
+
+	if activity.GitChanges == nil {
+		activity.GitChanges = []GitChange{}
+	}
+	if activity.TestRuns == nil {
+		activity.TestRuns = []TestRun{}
+	}
+	return activity, nil
+}
+
+// GetCoverageGaps returns commits made for a bead that have no test run at the
+// same commit, newest first: code that shipped without being tested.
+func (c *SQLiteCollector) GetCoverageGaps(filter TelemetryFilter) ([]CoverageGap, error) {
+	query := `SELECT bead_id, commit_sha, agent_id, timestamp, message, files_changed, COALESCE(diff_summary, '')
+		FROM git_changes g
+		WHERE COALESCE(bead_id, '') != ''
+		  AND NOT EXISTS (SELECT 1 FROM test_runs t WHERE t.commit_sha = g.commit_sha)`
+	args := []interface{}{}
+
+	query, args = applyFilter(query, args, filter)
+	query += " ORDER BY timestamp DESC"
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", filter.Limit)
+	}
+
+	rows, err := c.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query coverage gaps: %w", err)
+	}
+	defer rows.Close()
+
+	gaps := []CoverageGap{}
+	for rows.Next() {
+		var g CoverageGap
+		var diffSummary string
+		if err := rows.Scan(&g.BeadID, &g.CommitSHA, &g.AgentID, &g.Timestamp, &g.Message, &g.FilesChanged, &diffSummary); err != nil {
+			return nil, fmt.Errorf("scan coverage gap: %w", err)
+		}
+		g.Files = DiffFiles(diffSummary)
+		gaps = append(gaps, g)
+	}
+	return gaps, rows.Err()
+}
+
+// GetRegressionsAtCommit returns tests failing at the commit that last passed
+// at an earlier commit.
+func (c *SQLiteCollector) GetRegressionsAtCommit(commitSHA string) ([]TestRegression, error) {
+	query := `
+		WITH at_commit AS (
+			SELECT
+				test_name,
+				test_file,
+				status,
+				timestamp,
+				error_message,
+				stack_trace,
+				ROW_NUMBER() OVER (PARTITION BY test_name ORDER BY timestamp DESC, id DESC) as rn
+			FROM test_results
+			WHERE commit_sha = ?
+		),
+		commit_start AS (
+			SELECT MIN(timestamp) as started_at FROM test_results WHERE commit_sha = ?
+		),
+		last_passed AS (
+			SELECT
+				t.test_name,
+				MAX(t.timestamp) as last_passed_at
+			FROM test_results t, commit_start cs
+			WHERE t.status = 'passed' AND t.timestamp < cs.started_at
+			  AND COALESCE(t.commit_sha, '') != ?
+			GROUP BY t.test_name
+		)
+		SELECT
+			ac.test_name,
+			ac.test_file,
+			lp.last_passed_at,
+			COALESCE((SELECT t2.commit_sha FROM test_results t2
+			          WHERE t2.test_name = ac.test_name AND t2.status = 'passed' AND t2.timestamp = lp.last_passed_at
+			          LIMIT 1), '') as last_passed_commit,
+			ac.timestamp,
+			COALESCE(ac.error_message, ''),
+			COALESCE(ac.stack_trace, ''),
+			ac.status
+		FROM at_commit ac
+		JOIN last_passed lp ON ac.test_name = lp.test_name
+		WHERE ac.rn = 1 AND ac.status IN ('failed', 'error')
+		ORDER BY ac.test_name
+	`
+
+	rows, err := c.db.Query(query, commitSHA, commitSHA, commitSHA)
+	if err != nil {
+		return nil, fmt.Errorf("query commit regressions: %w", err)
+	}
+	defer rows.Close()
+
+	results := []TestRegression{}
+	for rows.Next() {
+		r := TestRegression{FirstFailedCommit: commitSHA}
+		if err := rows.Scan(&r.TestName, &r.TestFile, &r.LastPassedAt, &r.LastPassedCommit,
+			&r.FirstFailedAt, &r.ErrorMessage, &r.StackTrace, &r.Status); err != nil {
+			return nil, fmt.Errorf("scan commit regression: %w", err)
+		}
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate commit regressions: %w", err)
+	}
+	return results, nil
+}
+
+// GetBeadRegressions returns the regressions whose first failing commit is one
+// of the commits recorded for the bead: tests the bead's work broke.
+func (c *SQLiteCollector) GetBeadRegressions(beadID string) ([]TestRegression, error) {
+	changes, err := c.GetGitChanges(TelemetryFilter{BeadID: beadID})
+	if err != nil {
+		return nil, fmt.Errorf("get git changes: %w", err)
+	}
+	if len(changes) == 0 {
+		return []TestRegression{}, nil
+	}
+
+	regressions, err := c.regressionsFirstFailingAt(changeCommits(changes))
+	if err != nil {
+		return nil, fmt.Errorf("get regressions: %w", err)
+	}
+	return regressions, nil
+}
+
+// changeCommits lists the distinct non-empty commit SHAs of changes.
+func changeCommits(changes []GitChange) []string {
+	seen := make(map[string]bool, len(changes))
+	commits := []string{}
+	for _, change := range changes {
+		if change.CommitSHA != "" && !seen[change.CommitSHA] {
+			seen[change.CommitSHA] = true
+			commits = append(commits, change.CommitSHA)
+		}
+	}
+	return commits
+}
+
 // GetBeadTelemetry retrieves all telemetry data for a specific bead.
 func (c *SQLiteCollector) GetBeadTelemetry(beadID string) (BeadTelemetry, error) {
 	filter := TelemetryFilter{BeadID: beadID}
@@ -835,6 +1660,13 @@ func (c *SQLiteCollector) GetBeadTelemetry(beadID string) (BeadTelemetry, error)
 		return bt, fmt.Errorf("get test summary: %w", err)
 	}
 
+	bt.CostTimeline = buildCostTimeline(bt.TokenUsage, bt.GitChanges)
+
+	bt.Regressions, err = c.GetBeadRegressions(beadID)
+	if err != nil {
+		return bt, fmt.Errorf("get bead regressions: %w", err)
+	}
+
 	return bt, nil
 }
 
@@ -878,6 +1710,276 @@ func (c *SQLiteCollector) GetAgentTelemetry(agentID string) (AgentTelemetry, err
 	return at, nil
 }
 
+// GetAgentTestHealth summarises the test results recorded by an agent.
+// A regression counts against the agent when its first failing commit is one
+// of the agent's recorded git changes.
+func (c *SQLiteCollector) GetAgentTestHealth(agentID string) (AgentTestHealth, error) {
+	health := AgentTestHealth{AgentID: agentID, TopFailedTests: []FailedTestCount{}}
+
+	err := c.db.QueryRow(`
+		SELECT
+			COUNT(*),
+			COALESCE(SUM(CASE WHEN status = 'passed' THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN status IN ('failed', 'error') THEN 1 ELSE 0 END), 0)
+		FROM test_results
+		WHERE agent_id = ?
+	`, agentID).Scan(&health.TotalResults, &health.Passed, &health.Failed)
+	if err != nil {
+		return health, fmt.Errorf("query agent test counts: %w", err)
+	}
+	if ran := health.Passed + health.Failed; ran > 0 {
+		health.PassRate = float64(health.Passed) / float64(ran)
+	}
+
+	rows, err := c.db.Query(`
+		SELECT test_name, test_file, COUNT(*) AS failures
+		FROM test_results
+		WHERE agent_id = ? AND status IN ('failed', 'error')
+		GROUP BY test_name, test_file
+		ORDER BY failures DESC, test_name
+		LIMIT ?
+	`, agentID, agentTopFailedLimit)
+	if err != nil {
+		return health, fmt.Errorf("query agent failed tests: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var f FailedTestCount
+		if err := rows.Scan(&f.TestName, &f.TestFile, &f.Failures); err != nil {
+			return health, fmt.Errorf("scan agent failed test: %w", err)
+		}
+		health.TopFailedTests = append(health.TopFailedTests, f)
+	}
+	if err := rows.Err(); err != nil {
+		return health, fmt.Errorf("iterate agent failed tests: %w", err)
+	}
+
+	changes, err := c.GetGitChanges(TelemetryFilter{AgentID: agentID})
+	if err != nil {
+		return health, fmt.Errorf("get git changes: %w", err)
+	}
+	if len(changes) == 0 {
+		return health, nil
+	}
+
+	regressions, err := c.regressionsFirstFailingAt(changeCommits(changes))
+	if err != nil {
+		return health, fmt.Errorf("get regressions: %w", err)
+	}
+	health.RegressionCount = len(regressions)
+
+	return health, nil
+}
+
+// SetBeadBudget creates or replaces the budget for a bead.
+// Replacing a budget clears any previous alert and exceeded markers so both can fire again.
+func (c *SQLiteCollector) SetBeadBudget(budget BeadBudget) error {
+	if budget.AlertThresholdPct <= 0 {
+		budget.AlertThresholdPct = 80
+	}
+	if budget.UpdatedAt == "" {
+		budget.UpdatedAt = Now()
+	}
+
+	_, err := c.db.Exec(`
+		INSERT INTO bead_budgets (bead_id, budget_usd, alert_threshold_pct, updated_at, alerted_at, exceeded_at)
+		VALUES (?, ?, ?, ?, NULL, NULL)
+		ON CONFLICT(bead_id) DO UPDATE SET
+			budget_usd = excluded.budget_usd,
+			alert_threshold_pct = excluded.alert_threshold_pct,
+			updated_at = excluded.updated_at,
+			alerted_at = NULL,
+			exceeded_at = NULL`,
+		budget.BeadID, budget.BudgetUSD, budget.AlertThresholdPct, budget.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("set bead budget: %w", err)
+	}
+	return nil
+}
+
+// GetBeadBudget returns the budget configured for a bead, or nil if none is set.
+func (c *SQLiteCollector) GetBeadBudget(beadID string) (*BeadBudget, error) {
+	var b BeadBudget
+	err := c.db.QueryRow(`
+		SELECT bead_id, budget_usd, alert_threshold_pct, updated_at, COALESCE(alerted_at, ''), COALESCE(exceeded_at, '')
+		FROM bead_budgets WHERE bead_id = ?`, beadID).Scan(
+		&b.BeadID, &b.BudgetUSD, &b.AlertThresholdPct, &b.UpdatedAt, &b.AlertedAt, &b.ExceededAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get bead budget: %w", err)
+	}
+	return &b, nil
+}
+
+// CheckBeadBudget compares the accumulated token cost for a bead against budgetUSD.
+// The alert threshold comes from the stored budget when one exists (default 80%).
+func (c *SQLiteCollector) CheckBeadBudget(beadID string, budgetUSD float64) (BudgetStatus, error) {
+	status := BudgetStatus{BeadID: beadID, BudgetUSD: budgetUSD}
+
+	summary, err := c.GetTokenSummary(TelemetryFilter{BeadID: beadID})
+	if err != nil {
+		return status, fmt.Errorf("get token summary: %w", err)
+	}
+
+	budget, err := c.GetBeadBudget(beadID)
+	if err != nil {
+		return status, err
+	}
+	return budgetStatus(beadID, budgetUSD, summary.TotalCostUSD, budget), nil
+}
+
+// budgetStatus compares spentUSD against budgetUSD, alerting at the stored
+// budget's threshold or 80% when budget is nil.
+func budgetStatus(beadID string, budgetUSD, spentUSD float64, budget *BeadBudget) BudgetStatus {
+	status := BudgetStatus{BeadID: beadID, BudgetUSD: budgetUSD, SpentUSD: spentUSD}
+
+	threshold := 80.0
+	if budget != nil {
+		threshold = budget.AlertThresholdPct
+	}
+
+	if budgetUSD > 0 {
+		status.PercentConsumed = status.SpentUSD / budgetUSD * 100
+	}
+	status.OverBudget = status.SpentUSD > budgetUSD
+	status.AlertTriggered = status.OverBudget || status.PercentConsumed >= threshold
+
+	return status
+}
+
+// MarkBudgetAlerted records that a bead's spend reached its alert threshold.
+// Returns true only the first time, so callers can alert once per crossing.
+func (c *SQLiteCollector) MarkBudgetAlerted(beadID string) (bool, error) {
+	result, err := c.db.Exec(`
+		UPDATE bead_budgets SET alerted_at = ?
+		WHERE bead_id = ? AND alerted_at IS NULL`, Now(), beadID)
+	if err != nil {
+		return false, fmt.Errorf("mark budget alerted: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("mark budget alerted: %w", err)
+	}
+	return n > 0, nil
+}
+
+// MarkBudgetExceeded records that a bead's budget was exceeded.
+// Returns true only the first time, so callers can alert once per crossing.
+func (c *SQLiteCollector) MarkBudgetExceeded(beadID string) (bool, error) {
+	result, err := c.db.Exec(`
+		UPDATE bead_budgets SET exceeded_at = ?
+		WHERE bead_id = ? AND exceeded_at IS NULL`, Now(), beadID)
+	if err != nil {
+		return false, fmt.Errorf("mark budget exceeded: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("mark budget exceeded: %w", err)
+	}
+	return n > 0, nil
+}
+
+// commitChange returns the agent, bead and timestamp of the git change recording a commit.
+// Returns sql.ErrNoRows when the commit was never recorded.
+func (c *SQLiteCollector) commitChange(commitSHA string) (agentID, beadID, timestamp string, err error) {
+	err = c.db.QueryRow(`
+		SELECT agent_id, COALESCE(bead_id, ''), timestamp FROM git_changes
+		WHERE commit_sha = ?
+		ORDER BY timestamp ASC LIMIT 1`, commitSHA).Scan(&agentID, &beadID, &timestamp)
+	return agentID, beadID, timestamp, err
+}
+
+// GetCommitCost estimates the token cost of producing a commit: the committing
+// agent's usage since its previous recorded commit. Unrecorded commits cost 0.
+func (c *SQLiteCollector) GetCommitCost(commitSHA string) (float64, error) {
+	agentID, _, timestamp, err := c.commitChange(commitSHA)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("query commit: %w", err)
+	}
+
+	var prev sql.NullString
+	if err := c.db.QueryRow(`
+		SELECT MAX(timestamp) FROM git_changes
+		WHERE agent_id = ? AND timestamp < ?`, agentID, timestamp).Scan(&prev); err != nil {
+		return 0, fmt.Errorf("query previous commit: %w", err)
+	}
+
+	filter := TelemetryFilter{AgentID: agentID, Until: timestamp}
+	if prev.Valid {
+		filter.Since = prev.String
+	}
+	summary, err := c.GetTokenSummary(filter)
+	if err != nil {
+		return 0, fmt.Errorf("get token summary: %w", err)
+	}
+	return summary.TotalCostUSD, nil
+}
+
+// GetCommitGate decides whether a commit is mergeable: tests were recorded and
+// all pass, nothing regressed, and the commit's bead (if budgeted) is within budget.
+func (c *SQLiteCollector) GetCommitGate(commitSHA string) (CommitGate, error) {
+	gate := CommitGate{CommitSHA: commitSHA}
+
+	var err error
+	gate.Tests, err = c.GetTestStatusAtCommit(commitSHA)
+	if err != nil {
+		return gate, err
+	}
+	gate.Regressions, err = c.GetRegressionsAtCommit(commitSHA)
+	if err != nil {
+		return gate, err
+	}
+	gate.CostUSD, err = c.GetCommitCost(commitSHA)
+	if err != nil {
+		return gate, err
+	}
+
+	_, beadID, _, err := c.commitChange(commitSHA)
+	if err != nil && err != sql.ErrNoRows {
+		return gate, fmt.Errorf("query commit: %w", err)
+	}
+	if beadID != "" {
+		budget, err := c.GetBeadBudget(beadID)
+		if err != nil {
+			return gate, err
+		}
+		if budget != nil {
+			status, err := c.CheckBeadBudget(beadID, budget.BudgetUSD)
+			if err != nil {
+				return gate, err
+			}
+			gate.Budget = &status
+		}
+	}
+
+	judgeCommitGate(&gate)
+	return gate, nil
+}
+
+// judgeCommitGate fills in the gate's failure reasons and verdict from its
+// tests, regressions and budget.
+func judgeCommitGate(gate *CommitGate) {
+	if gate.Tests.Total == 0 {
+		gate.Reasons = append(gate.Reasons, "no test results recorded for commit")
+	}
+	if n := gate.Tests.Failed + gate.Tests.Errored; n > 0 {
+		gate.Reasons = append(gate.Reasons, fmt.Sprintf("%d tests failing", n))
+	}
+	if n := len(gate.Regressions); n > 0 {
+		gate.Reasons = append(gate.Reasons, fmt.Sprintf("%d tests regressed", n))
+	}
+	if gate.Budget != nil && gate.Budget.OverBudget {
+		gate.Reasons = append(gate.Reasons, "bead is over budget")
+	}
+	gate.Pass = len(gate.Reasons) == 0
+}
+
 // applyFilter adds WHERE clauses based on the filter.
 func applyFilter(query string, args []interface{}, filter TelemetryFilter) (string, []interface{}) {
 	if filter.AgentID != "" {
@@ -888,6 +1990,10 @@ func applyFilter(query string, args []interface{}, filter TelemetryFilter) (stri
 		query += " AND bead_id = ?"
 		args = append(args, filter.BeadID)
 	}
+	if filter.Rig != "" {
+		query += " AND rig = ?"
+		args = append(args, filter.Rig)
+	}
 	if filter.Since != "" {
 		query += " AND timestamp >= ?"
 		args = append(args, filter.Since)
@@ -899,6 +2005,138 @@ func applyFilter(query string, args []interface{}, filter TelemetryFilter) (stri
 	return query, args
 }
 
+// applyCommitFilter adds the commit filter for tables with a commit_sha column.
+func applyCommitFilter(query string, args []interface{}, filter TelemetryFilter) (string, []interface{}) {
+	if filter.CommitSHA != "" {
+		query += " AND commit_sha = ?"
+		args = append(args, filter.CommitSHA)
+	}
+	return query, args
+}
+
+// RecordConvoyProgress stores a convoy progress snapshot. Snapshots identical to the
+// convoy's most recent one in the same rig are skipped, so history only grows when
+// progress changes.
+func (c *SQLiteCollector) RecordConvoyProgress(snapshot ProgressSnapshot) error {
+	var completed, total int
+	err := c.db.QueryRow(`
+		SELECT completed, total FROM convoy_progress
+		WHERE convoy_id = ? AND COALESCE(rig, '') = ?
+		ORDER BY timestamp DESC, id DESC LIMIT 1`, snapshot.ConvoyID, snapshot.Rig).Scan(&completed, &total)
+	if err == nil && completed == snapshot.Completed && total == snapshot.Total {
+		return nil
+	}
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("query last convoy progress: %w", err)
+	}
+
+	if snapshot.Timestamp == "" {
+		snapshot.Timestamp = Now()
+	}
+	_, err = c.db.Exec(`
+		INSERT INTO convoy_progress (convoy_id, rig, timestamp, completed, total)
+		VALUES (?, ?, ?, ?, ?)`,
+		snapshot.ConvoyID, nullString(snapshot.Rig), snapshot.Timestamp, snapshot.Completed, snapshot.Total)
+	if err != nil {
+		return fmt.Errorf("insert convoy progress: %w", err)
+	}
+	return nil
+}
+
+// GetConvoyProgressHistory returns a convoy's progress snapshots in a rig,
+// oldest first. Convoy IDs are only unique within a rig.
+func (c *SQLiteCollector) GetConvoyProgressHistory(rig, convoyID string) ([]ProgressSnapshot, error) {
+	rows, err := c.db.Query(`
+		SELECT convoy_id, COALESCE(rig, ''), timestamp, completed, total
+		FROM convoy_progress
+		WHERE convoy_id = ? AND COALESCE(rig, '') = ?
+		ORDER BY timestamp ASC, id ASC`, convoyID, rig)
+	if err != nil {
+		return nil, fmt.Errorf("query convoy progress: %w", err)
+	}
+	defer rows.Close()
+
+	history := []ProgressSnapshot{}
+	for rows.Next() {
+		var p ProgressSnapshot
+		if err := rows.Scan(&p.ConvoyID, &p.Rig, &p.Timestamp, &p.Completed, &p.Total); err != nil {
+			return nil, fmt.Errorf("scan convoy progress: %w", err)
+		}
+		if p.Total > 0 {
+			p.Percentage = float64(p.Completed) / float64(p.Total) * 100
+		}
+		history = append(history, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate convoy progress: %w", err)
+	}
+	return history, nil
+}
+
+// EstimateConvoyCompletion projects when a convoy will finish by fitting a
+// least-squares line to completed count over time for its last few snapshots.
+// Returns nil when there are fewer than two usable snapshots or the convoy is
+// not making progress.
+func (c *SQLiteCollector) EstimateConvoyCompletion(rig, convoyID string) (*ConvoyETA, error) {
+	history, err := c.GetConvoyProgressHistory(rig, convoyID)
+	if err != nil {
+		return nil, err
+	}
+	if len(history) > convoyETASamples {
+		history = history[len(history)-convoyETASamples:]
+	}
+
+	var xs, ys []float64
+	var start, last time.Time
+	for _, p := range history {
+		ts, err := time.Parse(time.RFC3339, p.Timestamp)
+		if err != nil {
+			continue
+		}
+		if start.IsZero() {
+			start = ts
+		}
+		last = ts
+		xs = append(xs, ts.Sub(start).Seconds())
+		ys = append(ys, float64(p.Completed))
+	}
+	if len(xs) < 2 {
+		return nil, nil
+	}
+
+	n := float64(len(xs))
+	var sumX, sumY, sumXY, sumXX float64
+	for i := range xs {
+		sumX += xs[i]
+		sumY += ys[i]
+		sumXY += xs[i] * ys[i]
+		sumXX += xs[i] * xs[i]
+	}
+	denom := n*sumXX - sumX*sumX
+	if denom == 0 {
+		return nil, nil
+	}
+	perSecond := (n*sumXY - sumX*sumY) / denom
+	if perSecond <= 0 {
+		return nil, nil
+	}
+
+	latest := history[len(history)-1]
+	remaining := latest.Total - latest.Completed
+	if remaining < 0 {
+		remaining = 0
+	}
+	finish := last.Add(time.Duration(float64(remaining) / perSecond * float64(time.Second)))
+
+	return &ConvoyETA{
+		ConvoyID:            convoyID,
+		EstimatedCompletion: finish.UTC().Format(time.RFC3339),
+		RatePerHour:         perSecond * 3600,
+		Remaining:           remaining,
+		Samples:             len(xs),
+	}, nil
+}
+
 // nullString returns sql.NullString for optional string fields.
 func nullString(s string) interface{} {
 	if s == "" {
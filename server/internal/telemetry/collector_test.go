@@ -1,7 +1,13 @@
 package telemetry
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
 	"testing"
 	"time"
 )
@@ -195,6 +201,15 @@ func TestTelemetry_RecordTestRun_AggregatesResults(t *testing.T) {
 	if len(r.Results) != 4 {
 		t.Errorf("expected 4 individual results, got %d", len(r.Results))
 	}
+
+	// Lightweight listing keeps totals but skips per-test results
+	light, err := collector.GetTestRuns(TelemetryFilter{BeadID: "bead-789", ExcludeResults: true})
+	if err != nil {
+		t.Fatalf("GetTestRuns failed: %v", err)
+	}
+	if len(light) != 1 || light[0].Total != 4 || len(light[0].Results) != 0 {
+		t.Errorf("expected totals without results, got %+v", light)
+	}
 }
 
 // TestTelemetry_GetSummary_AggregatesTimeRange verifies summaries aggregate across time ranges.
@@ -393,6 +408,90 @@ func TestTelemetry_GetBeadTelemetry_CombinesAllTypes(t *testing.T) {
 	}
 }
 
+// TestTelemetry_GetBeadTelemetry_CostTimeline verifies bead spend is bucketed by day with commits annotated.
+func TestTelemetry_GetBeadTelemetry_CostTimeline(t *testing.T) {
+	collector, cleanup := createTestCollector(t)
+	defer cleanup()
+
+	beadID := "bead-timeline"
+	usage := []TokenUsage{
+		{AgentID: "agent-1", BeadID: beadID, Timestamp: "2026-01-20T09:00:00Z", InputTokens: 1000, OutputTokens: 100, Model: "claude-opus-4-5-20251101", RequestType: "chat"},
+		{AgentID: "agent-1", BeadID: beadID, Timestamp: "2026-01-20T15:00:00Z", InputTokens: 2000, OutputTokens: 200, Model: "claude-opus-4-5-20251101", RequestType: "chat"},
+		{AgentID: "agent-1", BeadID: beadID, Timestamp: "2026-01-22T10:00:00Z", InputTokens: 500, OutputTokens: 50, Model: "claude-opus-4-5-20251101", RequestType: "chat"},
+	}
+	for _, u := range usage {
+		if err := collector.RecordTokenUsage(u); err != nil {
+			t.Fatalf("RecordTokenUsage failed: %v", err)
+		}
+	}
+	if err := collector.RecordGitChange(GitChange{
+		AgentID:   "agent-1",
+		BeadID:    beadID,
+		Timestamp: "2026-01-21T12:00:00Z",
+		CommitSHA: "sha-timeline",
+		Branch:    "main",
+		Message:   "wip",
+	}); err != nil {
+		t.Fatalf("RecordGitChange failed: %v", err)
+	}
+
+	bt, err := collector.GetBeadTelemetry(beadID)
+	if err != nil {
+		t.Fatalf("GetBeadTelemetry failed: %v", err)
+	}
+
+	buckets := bt.CostTimeline.Buckets
+	if len(buckets) != 3 {
+		t.Fatalf("expected 3 daily buckets, got %+v", buckets)
+	}
+	if buckets[0].Day != "2026-01-20" || buckets[0].InputTokens != 3000 || buckets[0].OutputTokens != 300 {
+		t.Errorf("unexpected first bucket: %+v", buckets[0])
+	}
+	if buckets[1].Day != "2026-01-21" || buckets[1].CostUSD != 0 {
+		t.Errorf("expected an empty bucket for the idle day, got %+v", buckets[1])
+	}
+	if buckets[2].CumulativeCostUSD <= buckets[0].CumulativeCostUSD {
+		t.Errorf("expected cumulative cost to grow, got %+v", buckets)
+	}
+	if diff := buckets[2].CumulativeCostUSD - bt.TokenSummary.TotalCostUSD; diff > 1e-9 || diff < -1e-9 {
+		t.Errorf("expected cumulative cost %f to match summary %f", buckets[2].CumulativeCostUSD, bt.TokenSummary.TotalCostUSD)
+	}
+
+	commits := bt.CostTimeline.Commits
+	if len(commits) != 1 || commits[0].CommitSHA != "sha-timeline" || commits[0].Day != "2026-01-21" {
+		t.Errorf("unexpected commit annotations: %+v", commits)
+	}
+}
+
+// TestBuildCostTimeline_WideSpanIsSparse verifies a span past the padding cap
+// only gets buckets for days with usage or commits.
+func TestBuildCostTimeline_WideSpanIsSparse(t *testing.T) {
+	usage := []TokenUsage{
+		{Timestamp: "0001-01-01T00:00:00Z", InputTokens: 1, Model: "claude-sonnet-4"},
+		{Timestamp: "2026-01-20T09:00:00Z", InputTokens: 1000, Model: "claude-sonnet-4"},
+	}
+	changes := []GitChange{{Timestamp: "2026-01-21T12:00:00Z", CommitSHA: "c1"}}
+
+	timeline := buildCostTimeline(usage, changes)
+	if !timeline.Sparse {
+		t.Error("expected a sparse timeline")
+	}
+	var days []string
+	for _, b := range timeline.Buckets {
+		days = append(days, b.Day)
+	}
+	if want := []string{"0001-01-01", "2026-01-20", "2026-01-21"}; !reflect.DeepEqual(days, want) {
+		t.Errorf("bucket days = %v, want %v", days, want)
+	}
+	if last := timeline.Buckets[2]; last.CostUSD != 0 || last.CumulativeCostUSD != timeline.Buckets[1].CumulativeCostUSD {
+		t.Errorf("expected the commit-only day to carry the cumulative cost, got %+v", last)
+	}
+
+	if padded := buildCostTimeline(usage[1:], changes); padded.Sparse || len(padded.Buckets) != 2 {
+		t.Errorf("expected a padded 2-day timeline, got %+v", padded)
+	}
+}
+
 // TestNewSQLiteCollector verifies collector creation and cleanup.
 func TestNewSQLiteCollector(t *testing.T) {
 	collector, cleanup := createTestCollector(t)
@@ -406,6 +505,24 @@ func TestNewSQLiteCollector(t *testing.T) {
 	}
 }
 
+// TestTelemetry_IntegrityCheckAndVacuum verifies startup integrity checks pass and Vacuum runs.
+func TestTelemetry_IntegrityCheckAndVacuum(t *testing.T) {
+	collector, cleanup := createTestCollector(t)
+	defer cleanup()
+
+	result, err := collector.integrityCheck()
+	if err != nil {
+		t.Fatalf("integrityCheck failed: %v", err)
+	}
+	if result != "ok" {
+		t.Errorf("expected integrity_check=ok, got %s", result)
+	}
+
+	if err := collector.Vacuum(); err != nil {
+		t.Fatalf("Vacuum failed: %v", err)
+	}
+}
+
 // TestCollectorInterfaceCompliance ensures SQLiteCollector implements Collector.
 func TestCollectorInterfaceCompliance(t *testing.T) {
 	collector, cleanup := createTestCollector(t)
@@ -736,7 +853,7 @@ func TestTelemetry_GetRegressions_DetectsNewFailures(t *testing.T) {
 			Command:   "go test",
 			Results: []TestResult{
 				{TestFile: "stable_test.go", TestName: "TestStable", Status: "passed", DurationMS: 100},
-				{TestFile: "regressed_test.go", TestName: "TestRegressed", Status: "failed", DurationMS: 100, ErrorMessage: "expected true, got false"},
+				{TestFile: "regressed_test.go", TestName: "TestRegressed", Status: "failed", DurationMS: 100, ErrorMessage: "expected true, got false", StackTrace: "regressed_test.go:42"},
 				{TestFile: "always_failed_test.go", TestName: "TestAlwaysFailed", Status: "failed", DurationMS: 100},
 				{TestFile: "recovered_test.go", TestName: "TestRecovered", Status: "passed", DurationMS: 100},
 			},
@@ -778,6 +895,9 @@ func TestTelemetry_GetRegressions_DetectsNewFailures(t *testing.T) {
 	if reg.ErrorMessage != "expected true, got false" {
 		t.Errorf("expected error message 'expected true, got false', got '%s'", reg.ErrorMessage)
 	}
+	if reg.StackTrace != "regressed_test.go:42" {
+		t.Errorf("expected stack trace 'regressed_test.go:42', got '%s'", reg.StackTrace)
+	}
 
 	// Test with no regressions
 	noRegressions, err := collector.GetRegressions("2026-01-25T00:00:00Z")
@@ -789,6 +909,176 @@ func TestTelemetry_GetRegressions_DetectsNewFailures(t *testing.T) {
 	}
 }
 
+// TestTelemetry_GetRegressionsByCommit_GroupsByCulprit verifies regressions are grouped by first-failed commit.
+func TestTelemetry_GetRegressionsByCommit_GroupsByCulprit(t *testing.T) {
+	collector, cleanup := createTestCollector(t)
+	defer cleanup()
+
+	runs := []TestRun{
+		{
+			AgentID:   "agent-1",
+			Timestamp: "2026-01-24T10:00:00Z",
+			CommitSHA: "good",
+			Command:   "go test",
+			Results: []TestResult{
+				{TestFile: "a_test.go", TestName: "TestA", Status: "passed"},
+				{TestFile: "b_test.go", TestName: "TestB", Status: "passed"},
+				{TestFile: "c_test.go", TestName: "TestC", Status: "passed"},
+			},
+		},
+		{
+			AgentID:   "agent-1",
+			Timestamp: "2026-01-24T11:00:00Z",
+			CommitSHA: "bad1",
+			Command:   "go test",
+			Results: []TestResult{
+				{TestFile: "a_test.go", TestName: "TestA", Status: "failed"},
+				{TestFile: "b_test.go", TestName: "TestB", Status: "failed"},
+				{TestFile: "c_test.go", TestName: "TestC", Status: "passed"},
+			},
+		},
+		{
+			AgentID:   "agent-1",
+			Timestamp: "2026-01-24T12:00:00Z",
+			CommitSHA: "bad2",
+			Command:   "go test",
+			Results: []TestResult{
+				{TestFile: "a_test.go", TestName: "TestA", Status: "failed"},
+				{TestFile: "b_test.go", TestName: "TestB", Status: "failed"},
+				{TestFile: "c_test.go", TestName: "TestC", Status: "failed"},
+			},
+		},
+	}
+
+	for _, run := range runs {
+		if err := collector.RecordTestRun(run); err != nil {
+			t.Fatalf("RecordTestRun failed: %v", err)
+		}
+	}
+
+	grouped, err := collector.GetRegressionsByCommit("2026-01-24T00:00:00Z")
+	if err != nil {
+		t.Fatalf("GetRegressionsByCommit failed: %v", err)
+	}
+
+	if len(grouped) != 2 {
+		t.Fatalf("expected 2 commit groups, got %d", len(grouped))
+	}
+	if len(grouped["bad1"]) != 2 {
+		t.Errorf("expected 2 regressions for bad1, got %d", len(grouped["bad1"]))
+	}
+	if len(grouped["bad2"]) != 1 {
+		t.Errorf("expected 1 regression for bad2, got %d", len(grouped["bad2"]))
+	}
+}
+
+func TestTelemetry_GetBeadRegressions(t *testing.T) {
+	collector, cleanup := createTestCollector(t)
+	defer cleanup()
+
+	runs := []TestRun{
+		{AgentID: "agent-1", Timestamp: "2026-01-24T10:00:00Z", CommitSHA: "base", Command: "go test",
+			Results: []TestResult{
+				{TestFile: "a_test.go", TestName: "TestA", Status: "passed"},
+				{TestFile: "b_test.go", TestName: "TestB", Status: "passed"},
+			}},
+		{AgentID: "agent-1", Timestamp: "2026-01-24T11:00:00Z", CommitSHA: "bead-commit", Command: "go test",
+			Results: []TestResult{
+				{TestFile: "a_test.go", TestName: "TestA", Status: "failed"},
+				{TestFile: "b_test.go", TestName: "TestB", Status: "passed"},
+			}},
+		{AgentID: "agent-2", Timestamp: "2026-01-24T12:00:00Z", CommitSHA: "other-commit", Command: "go test",
+			Results: []TestResult{
+				{TestFile: "a_test.go", TestName: "TestA", Status: "failed"},
+				{TestFile: "b_test.go", TestName: "TestB", Status: "failed"},
+			}},
+	}
+	for _, run := range runs {
+		if err := collector.RecordTestRun(run); err != nil {
+			t.Fatalf("RecordTestRun failed: %v", err)
+		}
+	}
+	changes := []GitChange{
+		{AgentID: "agent-1", BeadID: "bead-1", Timestamp: "2026-01-24T09:55:00Z", CommitSHA: "base", Branch: "main"},
+		{AgentID: "agent-1", BeadID: "bead-1", Timestamp: "2026-01-24T10:55:00Z", CommitSHA: "bead-commit", Branch: "main"},
+		{AgentID: "agent-2", BeadID: "bead-2", Timestamp: "2026-01-24T11:55:00Z", CommitSHA: "other-commit", Branch: "main"},
+	}
+	for _, change := range changes {
+		if err := collector.RecordGitChange(change); err != nil {
+			t.Fatalf("RecordGitChange failed: %v", err)
+		}
+	}
+
+	regressions, err := collector.GetBeadRegressions("bead-1")
+	if err != nil {
+		t.Fatalf("GetBeadRegressions failed: %v", err)
+	}
+	if len(regressions) != 1 || regressions[0].TestName != "TestA" {
+		t.Errorf("expected only TestA to be blamed on bead-1, got %+v", regressions)
+	}
+
+	bt, err := collector.GetBeadTelemetry("bead-2")
+	if err != nil {
+		t.Fatalf("GetBeadTelemetry failed: %v", err)
+	}
+	if len(bt.Regressions) != 1 || bt.Regressions[0].TestName != "TestB" {
+		t.Errorf("expected TestB on bead-2's telemetry, got %+v", bt.Regressions)
+	}
+
+	none, err := collector.GetBeadRegressions("bead-unknown")
+	if err != nil || none == nil || len(none) != 0 {
+		t.Errorf("expected an empty list for a bead without commits, got %v, %v", none, err)
+	}
+}
+
+func TestTelemetry_GetRegressionsWithOptions_SuppressesFlaky(t *testing.T) {
+	collector, cleanup := createTestCollector(t)
+	defer cleanup()
+
+	// TestFlaky alternates pass/fail; TestBroken passed then failed once.
+	statuses := [][2]string{
+		{"passed", "passed"},
+		{"failed", "passed"},
+		{"passed", "passed"},
+		{"failed", "failed"},
+	}
+	for i, st := range statuses {
+		run := TestRun{
+			AgentID:   "agent-1",
+			Timestamp: fmt.Sprintf("2026-01-24T1%d:00:00Z", i),
+			CommitSHA: fmt.Sprintf("commit-%d", i),
+			Command:   "go test",
+			Results: []TestResult{
+				{TestFile: "flaky_test.go", TestName: "TestFlaky", Status: st[0], DurationMS: 10},
+				{TestFile: "broken_test.go", TestName: "TestBroken", Status: st[1], DurationMS: 10},
+			},
+		}
+		if err := collector.RecordTestRun(run); err != nil {
+			t.Fatalf("RecordTestRun failed: %v", err)
+		}
+	}
+
+	since := "2026-01-24T12:30:00Z"
+	all, suppressed, err := collector.GetRegressionsWithOptions(RegressionOptions{Since: since})
+	if err != nil {
+		t.Fatalf("GetRegressionsWithOptions failed: %v", err)
+	}
+	if len(all) != 2 || suppressed != 0 {
+		t.Fatalf("expected 2 regressions unsuppressed, got %d (suppressed %d)", len(all), suppressed)
+	}
+
+	kept, suppressed, err := collector.GetRegressionsWithOptions(RegressionOptions{Since: since, SuppressFlaky: true})
+	if err != nil {
+		t.Fatalf("GetRegressionsWithOptions failed: %v", err)
+	}
+	if len(kept) != 1 || kept[0].TestName != "TestBroken" {
+		t.Errorf("expected only TestBroken, got %+v", kept)
+	}
+	if suppressed != 1 {
+		t.Errorf("expected 1 suppressed regression, got %d", suppressed)
+	}
+}
+
 // TestTelemetry_GetTestSuiteStatus_ReturnsAllTestsWithLastPassed verifies suite status is complete.
 // ADR-014 AC-5: GetTestSuiteStatus returns all tests with last_passed info
 func TestTelemetry_GetTestSuiteStatus_ReturnsAllTestsWithLastPassed(t *testing.T) {
@@ -842,7 +1132,7 @@ func TestTelemetry_GetTestSuiteStatus_ReturnsAllTestsWithLastPassed(t *testing.T
 	}
 
 	// Get test suite status
-	status, err := collector.GetTestSuiteStatus()
+	status, err := collector.GetTestSuiteStatus(StatusFilterAll)
 	if err != nil {
 		t.Fatalf("GetTestSuiteStatus failed: %v", err)
 	}
@@ -918,7 +1208,7 @@ func TestTelemetry_GetTestSuiteStatus_EmptyDatabase(t *testing.T) {
 	collector, cleanup := createTestCollector(t)
 	defer cleanup()
 
-	status, err := collector.GetTestSuiteStatus()
+	status, err := collector.GetTestSuiteStatus(StatusFilterAll)
 	if err != nil {
 		t.Fatalf("GetTestSuiteStatus on empty DB failed: %v", err)
 	}
@@ -926,3 +1216,1152 @@ func TestTelemetry_GetTestSuiteStatus_EmptyDatabase(t *testing.T) {
 		t.Errorf("expected 0 tests in empty DB, got %d", len(status))
 	}
 }
+
+// TestTelemetry_ErroredTests verifies errors are counted apart from failures and still regress.
+func TestTelemetry_ErroredTests(t *testing.T) {
+	collector, cleanup := createTestCollector(t)
+	defer cleanup()
+
+	runs := []TestRun{
+		{
+			AgentID:   "agent-1",
+			Timestamp: "2026-01-24T10:00:00Z",
+			CommitSHA: "commit-good",
+			Command:   "go test",
+			Results: []TestResult{
+				{TestFile: "panic_test.go", TestName: "TestPanics", Status: "passed", DurationMS: 100},
+				{TestFile: "assert_test.go", TestName: "TestAsserts", Status: "passed", DurationMS: 100},
+			},
+		},
+		{
+			AgentID:   "agent-1",
+			Timestamp: "2026-01-24T14:00:00Z",
+			CommitSHA: "commit-bad",
+			Command:   "go test",
+			Results: []TestResult{
+				{TestFile: "panic_test.go", TestName: "TestPanics", Status: "error", DurationMS: 100, ErrorMessage: "panic: nil map"},
+				{TestFile: "assert_test.go", TestName: "TestAsserts", Status: "failed", DurationMS: 100},
+			},
+		},
+	}
+	for _, run := range runs {
+		if err := collector.RecordTestRun(run); err != nil {
+			t.Fatalf("RecordTestRun failed: %v", err)
+		}
+	}
+
+	summary, err := collector.GetTestSummary(TelemetryFilter{})
+	if err != nil {
+		t.Fatalf("GetTestSummary failed: %v", err)
+	}
+	if summary.TotalErrored != 1 || summary.TotalFailed != 1 {
+		t.Errorf("expected 1 errored and 1 failed, got errored=%d failed=%d", summary.TotalErrored, summary.TotalFailed)
+	}
+
+	regressions, err := collector.GetRegressions("2026-01-24T12:00:00Z")
+	if err != nil {
+		t.Fatalf("GetRegressions failed: %v", err)
+	}
+	if len(regressions) != 2 {
+		t.Fatalf("expected 2 regressions, got %d", len(regressions))
+	}
+	for _, r := range regressions {
+		want := "failed"
+		if r.TestName == "TestPanics" {
+			want = "error"
+		}
+		if r.Status != want {
+			t.Errorf("%s: expected status %q, got %q", r.TestName, want, r.Status)
+		}
+	}
+
+	status, err := collector.GetTestSuiteStatus(StatusFilterAll)
+	if err != nil {
+		t.Fatalf("GetTestSuiteStatus failed: %v", err)
+	}
+	for _, s := range status {
+		switch s.TestName {
+		case "TestPanics":
+			if s.CurrentStatus != "error" || s.FailCount != 1 || s.ErrorCount != 1 {
+				t.Errorf("TestPanics: unexpected status %+v", s)
+			}
+		case "TestAsserts":
+			if s.CurrentStatus != "failed" || s.FailCount != 1 || s.ErrorCount != 0 {
+				t.Errorf("TestAsserts: unexpected status %+v", s)
+			}
+		}
+	}
+}
+
+// TestTelemetry_CheckBeadBudget_ReportsConsumption verifies budget status and one-time exceeded marking.
+func TestTelemetry_CheckBeadBudget_ReportsConsumption(t *testing.T) {
+	collector, cleanup := createTestCollector(t)
+	defer cleanup()
+
+	// 1M sonnet input tokens = $3
+	usage := TokenUsage{
+		AgentID:      "agent-1",
+		BeadID:       "bead-budget",
+		Timestamp:    "2026-01-24T10:00:00Z",
+		InputTokens:  1000000,
+		OutputTokens: 0,
+		Model:        "claude-sonnet-4-5",
+		RequestType:  "chat",
+	}
+	if err := collector.RecordTokenUsage(usage); err != nil {
+		t.Fatalf("RecordTokenUsage failed: %v", err)
+	}
+
+	if err := collector.SetBeadBudget(BeadBudget{BeadID: "bead-budget", BudgetUSD: 2}); err != nil {
+		t.Fatalf("SetBeadBudget failed: %v", err)
+	}
+
+	budget, err := collector.GetBeadBudget("bead-budget")
+	if err != nil {
+		t.Fatalf("GetBeadBudget failed: %v", err)
+	}
+	if budget == nil || budget.AlertThresholdPct != 80 {
+		t.Fatalf("expected budget with default threshold 80, got %+v", budget)
+	}
+
+	status, err := collector.CheckBeadBudget("bead-budget", budget.BudgetUSD)
+	if err != nil {
+		t.Fatalf("CheckBeadBudget failed: %v", err)
+	}
+	if status.SpentUSD != 3 {
+		t.Errorf("expected SpentUSD=3, got %f", status.SpentUSD)
+	}
+	if !status.OverBudget || !status.AlertTriggered {
+		t.Errorf("expected over budget with alert, got %+v", status)
+	}
+	if status.PercentConsumed != 150 {
+		t.Errorf("expected PercentConsumed=150, got %f", status.PercentConsumed)
+	}
+
+	first, err := collector.MarkBudgetExceeded("bead-budget")
+	if err != nil || !first {
+		t.Fatalf("expected first MarkBudgetExceeded to return true, got %v (err %v)", first, err)
+	}
+	again, err := collector.MarkBudgetExceeded("bead-budget")
+	if err != nil || again {
+		t.Errorf("expected second MarkBudgetExceeded to return false, got %v (err %v)", again, err)
+	}
+
+	first, err = collector.MarkBudgetAlerted("bead-budget")
+	if err != nil || !first {
+		t.Fatalf("expected first MarkBudgetAlerted to return true, got %v (err %v)", first, err)
+	}
+	if again, err := collector.MarkBudgetAlerted("bead-budget"); err != nil || again {
+		t.Errorf("expected second MarkBudgetAlerted to return false, got %v (err %v)", again, err)
+	}
+	if budget, err := collector.GetBeadBudget("bead-budget"); err != nil || budget.AlertedAt == "" || budget.ExceededAt == "" {
+		t.Errorf("expected both markers on the budget, got %+v (err %v)", budget, err)
+	}
+
+	// A new budget re-arms both alerts
+	if err := collector.SetBeadBudget(BeadBudget{BeadID: "bead-budget", BudgetUSD: 5}); err != nil {
+		t.Fatalf("SetBeadBudget failed: %v", err)
+	}
+	if first, err := collector.MarkBudgetAlerted("bead-budget"); err != nil || !first {
+		t.Errorf("expected MarkBudgetAlerted to fire again after a reset, got %v (err %v)", first, err)
+	}
+	if first, err := collector.MarkBudgetExceeded("bead-budget"); err != nil || !first {
+		t.Errorf("expected MarkBudgetExceeded to fire again after a reset, got %v (err %v)", first, err)
+	}
+}
+
+// TestTelemetry_FilterByRig verifies telemetry can be scoped to a rig.
+func TestTelemetry_FilterByRig(t *testing.T) {
+	collector, cleanup := createTestCollector(t)
+	defer cleanup()
+
+	for _, rig := range []string{"townview", "townview", "gastown"} {
+		usage := TokenUsage{
+			AgentID:      "agent-1",
+			Rig:          rig,
+			Timestamp:    "2026-01-24T10:00:00Z",
+			InputTokens:  100,
+			OutputTokens: 50,
+			Model:        "claude-sonnet-4-5",
+			RequestType:  "chat",
+		}
+		if err := collector.RecordTokenUsage(usage); err != nil {
+			t.Fatalf("RecordTokenUsage failed: %v", err)
+		}
+	}
+
+	summary, err := collector.GetTokenSummary(TelemetryFilter{Rig: "townview"})
+	if err != nil {
+		t.Fatalf("GetTokenSummary failed: %v", err)
+	}
+	if summary.TotalInput != 200 {
+		t.Errorf("expected TotalInput=200 for townview, got %d", summary.TotalInput)
+	}
+
+	usage, err := collector.GetTokenUsage(TelemetryFilter{Rig: "gastown"})
+	if err != nil {
+		t.Fatalf("GetTokenUsage failed: %v", err)
+	}
+	if len(usage) != 1 || usage[0].Rig != "gastown" {
+		t.Errorf("expected 1 gastown record, got %+v", usage)
+	}
+}
+
+// TestTelemetry_ConvoyProgressHistory verifies snapshots are recorded on change and returned oldest first.
+func TestTelemetry_ConvoyProgressHistory(t *testing.T) {
+	collector, cleanup := createTestCollector(t)
+	defer cleanup()
+
+	snapshots := []ProgressSnapshot{
+		{ConvoyID: "to-convoy", Rig: "townview", Timestamp: "2026-01-24T10:00:00Z", Completed: 0, Total: 4},
+		{ConvoyID: "to-convoy", Rig: "townview", Timestamp: "2026-01-24T11:00:00Z", Completed: 0, Total: 4}, // unchanged
+		{ConvoyID: "to-convoy", Rig: "townview", Timestamp: "2026-01-24T12:00:00Z", Completed: 1, Total: 4},
+		{ConvoyID: "to-other", Rig: "townview", Timestamp: "2026-01-24T12:00:00Z", Completed: 2, Total: 2},
+		{ConvoyID: "to-convoy", Rig: "otherrig", Timestamp: "2026-01-24T12:30:00Z", Completed: 0, Total: 4}, // same ID, other rig
+	}
+	for _, s := range snapshots {
+		if err := collector.RecordConvoyProgress(s); err != nil {
+			t.Fatalf("RecordConvoyProgress failed: %v", err)
+		}
+	}
+
+	history, err := collector.GetConvoyProgressHistory("townview", "to-convoy")
+	if err != nil {
+		t.Fatalf("GetConvoyProgressHistory failed: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("expected 2 snapshots, got %d: %+v", len(history), history)
+	}
+	if history[0].Timestamp != "2026-01-24T10:00:00Z" || history[1].Completed != 1 {
+		t.Errorf("unexpected history order: %+v", history)
+	}
+	if history[1].Percentage != 25 {
+		t.Errorf("expected 25%% complete, got %v", history[1].Percentage)
+	}
+
+	other, err := collector.GetConvoyProgressHistory("otherrig", "to-convoy")
+	if err != nil {
+		t.Fatalf("GetConvoyProgressHistory failed: %v", err)
+	}
+	if len(other) != 1 || other[0].Rig != "otherrig" {
+		t.Errorf("expected only otherrig's snapshot, got %+v", other)
+	}
+
+	empty, err := collector.GetConvoyProgressHistory("townview", "to-missing")
+	if err != nil {
+		t.Fatalf("GetConvoyProgressHistory failed: %v", err)
+	}
+	if len(empty) != 0 {
+		t.Errorf("expected no history, got %d", len(empty))
+	}
+}
+
+func TestTelemetry_EstimateConvoyCompletion(t *testing.T) {
+	collector, cleanup := createTestCollector(t)
+	defer cleanup()
+
+	snapshots := []ProgressSnapshot{
+		{ConvoyID: "to-convoy", Timestamp: "2026-01-24T10:00:00Z", Completed: 0, Total: 6},
+		{ConvoyID: "to-convoy", Timestamp: "2026-01-24T11:00:00Z", Completed: 1, Total: 6},
+		{ConvoyID: "to-convoy", Timestamp: "2026-01-24T12:00:00Z", Completed: 2, Total: 6},
+		{ConvoyID: "to-single", Timestamp: "2026-01-24T12:00:00Z", Completed: 1, Total: 3},
+	}
+	for _, s := range snapshots {
+		if err := collector.RecordConvoyProgress(s); err != nil {
+			t.Fatalf("RecordConvoyProgress failed: %v", err)
+		}
+	}
+
+	eta, err := collector.EstimateConvoyCompletion("", "to-convoy")
+	if err != nil {
+		t.Fatalf("EstimateConvoyCompletion failed: %v", err)
+	}
+	if eta == nil {
+		t.Fatal("expected an estimate")
+	}
+	if eta.EstimatedCompletion != "2026-01-24T16:00:00Z" {
+		t.Errorf("expected completion at 16:00, got %s", eta.EstimatedCompletion)
+	}
+	if eta.RatePerHour != 1 || eta.Remaining != 4 || eta.Samples != 3 {
+		t.Errorf("unexpected estimate: %+v", eta)
+	}
+
+	for _, id := range []string{"to-single", "to-missing"} {
+		eta, err := collector.EstimateConvoyCompletion("", id)
+		if err != nil {
+			t.Fatalf("EstimateConvoyCompletion(%s) failed: %v", id, err)
+		}
+		if eta != nil {
+			t.Errorf("expected no estimate for %s, got %+v", id, eta)
+		}
+	}
+}
+
+func TestTelemetry_GetCommitActivity(t *testing.T) {
+	collector, cleanup := createTestCollector(t)
+	defer cleanup()
+
+	if err := collector.RecordGitChange(GitChange{
+		AgentID: "agent-1", Timestamp: "2026-01-24T10:00:00Z", CommitSHA: "abc123",
+		Branch: "main", FilesChanged: 2, Insertions: 10, Message: "change",
+	}); err != nil {
+		t.Fatalf("RecordGitChange failed: %v", err)
+	}
+	runs := []TestRun{
+		{AgentID: "agent-1", Timestamp: "2026-01-24T10:05:00Z", CommitSHA: "abc123", Command: "go test", Results: []TestResult{
+			{TestFile: "a_test.go", TestName: "TestA", Status: "passed", DurationMS: 10},
+			{TestFile: "b_test.go", TestName: "TestB", Status: "failed", DurationMS: 10},
+		}},
+		{AgentID: "agent-1", Timestamp: "2026-01-24T11:00:00Z", CommitSHA: "def456", Command: "go test", Results: []TestResult{
+			{TestFile: "a_test.go", TestName: "TestA", Status: "passed", DurationMS: 10},
+		}},
+	}
+	for _, run := range runs {
+		if err := collector.RecordTestRun(run); err != nil {
+			t.Fatalf("RecordTestRun failed: %v", err)
+		}
+	}
+
+	activity, err := collector.GetCommitActivity("abc123")
+	if err != nil {
+		t.Fatalf("GetCommitActivity failed: %v", err)
+	}
+	if len(activity.GitChanges) != 1 || activity.GitChanges[0].FilesChanged != 2 {
+		t.Errorf("expected the commit's git change, got %+v", activity.GitChanges)
+	}
+	if len(activity.TestRuns) != 1 || len(activity.TestRuns[0].Results) != 2 {
+		t.Errorf("expected one run with 2 results, got %+v", activity.TestRuns)
+	}
+	if activity.Tests.Total != 2 || activity.Tests.Failed != 1 {
+		t.Errorf("unexpected test status: %+v", activity.Tests)
+	}
+
+	empty, err := collector.GetCommitActivity("missing")
+	if err != nil {
+		t.Fatalf("GetCommitActivity failed: %v", err)
+	}
+	if len(empty.GitChanges) != 0 || len(empty.TestRuns) != 0 {
+		t.Errorf("expected no activity, got %+v", empty)
+	}
+}
+
+// TestTelemetry_GetCoverageGaps verifies bead commits without a test run at the same commit are flagged.
+func TestTelemetry_GetCoverageGaps(t *testing.T) {
+	collector, cleanup := createTestCollector(t)
+	defer cleanup()
+
+	changes := []GitChange{
+		{AgentID: "agent-1", BeadID: "bead-1", Timestamp: "2026-01-20T10:00:00Z", CommitSHA: "tested", Branch: "main", FilesChanged: 1, Message: "tested change", DiffSummary: "a.go: +1 -0"},
+		{AgentID: "agent-1", BeadID: "bead-1", Timestamp: "2026-01-20T11:00:00Z", CommitSHA: "untested", Branch: "main", FilesChanged: 2, Message: "untested change", DiffSummary: "a.go: +3 -1\nb.go: +2 -0"},
+		{AgentID: "agent-2", Timestamp: "2026-01-20T12:00:00Z", CommitSHA: "no-bead", Branch: "main", FilesChanged: 1, Message: "chore"},
+	}
+	for _, g := range changes {
+		if err := collector.RecordGitChange(g); err != nil {
+			t.Fatalf("RecordGitChange failed: %v", err)
+		}
+	}
+	if err := collector.RecordTestRun(TestRun{
+		AgentID:   "agent-1",
+		BeadID:    "bead-1",
+		Timestamp: "2026-01-20T10:05:00Z",
+		CommitSHA: "tested",
+		Command:   "go test ./...",
+		Total:     1,
+		Passed:    1,
+		Results:   []TestResult{{TestFile: "a_test.go", TestName: "TestA", Status: "passed"}},
+	}); err != nil {
+		t.Fatalf("RecordTestRun failed: %v", err)
+	}
+
+	gaps, err := collector.GetCoverageGaps(TelemetryFilter{})
+	if err != nil {
+		t.Fatalf("GetCoverageGaps failed: %v", err)
+	}
+	if len(gaps) != 1 {
+		t.Fatalf("expected 1 coverage gap, got %+v", gaps)
+	}
+	gap := gaps[0]
+	if gap.BeadID != "bead-1" || gap.CommitSHA != "untested" || gap.FilesChanged != 2 {
+		t.Errorf("unexpected gap: %+v", gap)
+	}
+	if len(gap.Files) != 2 || gap.Files[0] != "a.go" || gap.Files[1] != "b.go" {
+		t.Errorf("expected files [a.go b.go], got %v", gap.Files)
+	}
+}
+
+// TestTelemetry_GetCommitGate verifies the merge verdict combines tests, regressions and cost.
+func TestTelemetry_GetCommitGate(t *testing.T) {
+	collector, cleanup := createTestCollector(t)
+	defer cleanup()
+
+	changes := []GitChange{
+		{AgentID: "agent-1", BeadID: "bead-gate", Timestamp: "2026-01-24T10:00:00Z", CommitSHA: "good", Branch: "main", Message: "first"},
+		{AgentID: "agent-1", BeadID: "bead-gate", Timestamp: "2026-01-24T14:00:00Z", CommitSHA: "bad", Branch: "main", Message: "second"},
+	}
+	for _, c := range changes {
+		if err := collector.RecordGitChange(c); err != nil {
+			t.Fatalf("RecordGitChange failed: %v", err)
+		}
+	}
+	// 1M sonnet input tokens = $3, spent between the two commits
+	if err := collector.RecordTokenUsage(TokenUsage{AgentID: "agent-1", BeadID: "bead-gate", Timestamp: "2026-01-24T12:00:00Z",
+		InputTokens: 1000000, Model: "claude-sonnet-4-5", RequestType: "chat"}); err != nil {
+		t.Fatalf("RecordTokenUsage failed: %v", err)
+	}
+
+	runs := []TestRun{
+		{AgentID: "agent-1", Timestamp: "2026-01-24T10:00:00Z", CommitSHA: "good", Command: "go test", Results: []TestResult{
+			{TestFile: "a_test.go", TestName: "TestA", Status: "passed"},
+			{TestFile: "b_test.go", TestName: "TestB", Status: "passed"},
+		}},
+		{AgentID: "agent-1", Timestamp: "2026-01-24T14:00:00Z", CommitSHA: "bad", Command: "go test", Results: []TestResult{
+			{TestFile: "a_test.go", TestName: "TestA", Status: "passed"},
+			{TestFile: "b_test.go", TestName: "TestB", Status: "error", ErrorMessage: "panic: boom"},
+		}},
+	}
+	for _, run := range runs {
+		if err := collector.RecordTestRun(run); err != nil {
+			t.Fatalf("RecordTestRun failed: %v", err)
+		}
+	}
+
+	good, err := collector.GetCommitGate("good")
+	if err != nil {
+		t.Fatalf("GetCommitGate failed: %v", err)
+	}
+	if !good.Pass || good.Tests.Passed != 2 {
+		t.Errorf("expected good commit to pass, got %+v", good)
+	}
+
+	bad, err := collector.GetCommitGate("bad")
+	if err != nil {
+		t.Fatalf("GetCommitGate failed: %v", err)
+	}
+	if bad.Pass {
+		t.Errorf("expected bad commit to fail the gate")
+	}
+	if bad.Tests.Errored != 1 || len(bad.Tests.Failing) != 1 || bad.Tests.Failing[0] != "TestB" {
+		t.Errorf("unexpected test status: %+v", bad.Tests)
+	}
+	if len(bad.Regressions) != 1 || bad.Regressions[0].LastPassedCommit != "good" {
+		t.Errorf("expected TestB to regress from commit good, got %+v", bad.Regressions)
+	}
+	if bad.CostUSD != 3 {
+		t.Errorf("expected $3 cost for bad commit, got %v", bad.CostUSD)
+	}
+
+	unknown, err := collector.GetCommitGate("missing")
+	if err != nil {
+		t.Fatalf("GetCommitGate failed: %v", err)
+	}
+	if unknown.Pass {
+		t.Errorf("expected commit without test results to fail the gate")
+	}
+}
+
+// TestTelemetry_BackfillsRigFromAgentID verifies rows recorded before the rig column
+// existed get their rig inferred from the agent ID on startup.
+func TestTelemetry_BackfillsRigFromAgentID(t *testing.T) {
+	tmpFile, err := os.CreateTemp("", "telemetry_backfill_*.db")
+	if err != nil {
+		t.Fatalf("create temp file: %v", err)
+	}
+	tmpFile.Close()
+	defer os.Remove(tmpFile.Name())
+
+	// Simulate a database from before the rig column existed
+	db, err := sql.Open("sqlite", tmpFile.Name())
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	_, err = db.Exec(`
+		CREATE TABLE token_usage (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			agent_id TEXT NOT NULL,
+			bead_id TEXT,
+			timestamp TEXT NOT NULL,
+			input_tokens INTEGER NOT NULL,
+			output_tokens INTEGER NOT NULL,
+			model TEXT NOT NULL,
+			request_type TEXT NOT NULL
+		);
+		INSERT INTO token_usage (agent_id, timestamp, input_tokens, output_tokens, model, request_type)
+		VALUES ('townview/crew/jeremy', '2026-01-24T10:00:00Z', 10, 5, 'claude-sonnet-4-5', 'chat'),
+		       ('mayor', '2026-01-24T10:00:00Z', 10, 5, 'claude-sonnet-4-5', 'chat');
+	`)
+	db.Close()
+	if err != nil {
+		t.Fatalf("create legacy schema: %v", err)
+	}
+
+	collector, err := NewSQLiteCollector(tmpFile.Name())
+	if err != nil {
+		t.Fatalf("create collector: %v", err)
+	}
+	defer collector.Close()
+
+	usage, err := collector.GetTokenUsage(TelemetryFilter{Rig: "townview"})
+	if err != nil {
+		t.Fatalf("GetTokenUsage failed: %v", err)
+	}
+	if len(usage) != 1 || usage[0].AgentID != "townview/crew/jeremy" {
+		t.Errorf("expected backfilled townview row, got %+v", usage)
+	}
+
+	all, err := collector.GetTokenUsage(TelemetryFilter{})
+	if err != nil {
+		t.Fatalf("GetTokenUsage failed: %v", err)
+	}
+	for _, u := range all {
+		if u.AgentID == "mayor" && u.Rig != "" {
+			t.Errorf("expected town-level agent to keep an empty rig, got %q", u.Rig)
+		}
+	}
+}
+
+// TestTelemetry_GetTestSuiteStatus_Filters verifies failing, passing and flaky filtering.
+func TestTelemetry_GetTestSuiteStatus_Filters(t *testing.T) {
+	collector, cleanup := createTestCollector(t)
+	defer cleanup()
+
+	// TestFlaky: pass, fail, pass; TestBroken: pass, pass, fail; TestGreen: always passes
+	sequences := map[string][]string{
+		"TestFlaky":  {"passed", "failed", "passed"},
+		"TestBroken": {"passed", "passed", "failed"},
+		"TestGreen":  {"passed", "passed", "passed"},
+	}
+	timestamps := []string{"2026-01-24T10:00:00Z", "2026-01-24T11:00:00Z", "2026-01-24T12:00:00Z"}
+	for i, ts := range timestamps {
+		run := TestRun{AgentID: "agent-1", Timestamp: ts, Command: "go test"}
+		for name, seq := range sequences {
+			run.Results = append(run.Results, TestResult{TestFile: "suite_test.go", TestName: name, Status: seq[i]})
+		}
+		if err := collector.RecordTestRun(run); err != nil {
+			t.Fatalf("RecordTestRun failed: %v", err)
+		}
+	}
+
+	names := func(filter StatusFilter) []string {
+		status, err := collector.GetTestSuiteStatus(filter)
+		if err != nil {
+			t.Fatalf("GetTestSuiteStatus(%q) failed: %v", filter, err)
+		}
+		var out []string
+		for _, s := range status {
+			out = append(out, s.TestName)
+		}
+		return out
+	}
+
+	if got := names(StatusFilterAll); len(got) != 3 {
+		t.Errorf("expected 3 tests for all, got %v", got)
+	}
+	if got := names(StatusFilterFailing); len(got) != 1 || got[0] != "TestBroken" {
+		t.Errorf("expected only TestBroken failing, got %v", got)
+	}
+	if got := names(StatusFilterPassing); len(got) != 2 || got[0] != "TestFlaky" || got[1] != "TestGreen" {
+		t.Errorf("expected TestFlaky and TestGreen passing, got %v", got)
+	}
+	if got := names(StatusFilterFlaky); len(got) != 1 || got[0] != "TestFlaky" {
+		t.Errorf("expected only TestFlaky flaky, got %v", got)
+	}
+}
+
+// TestTelemetry_CheckOrphans verifies foreign keys are enforced and legacy orphans are cleaned up.
+func TestTelemetry_CheckOrphans(t *testing.T) {
+	collector, cleanup := createTestCollector(t)
+	defer cleanup()
+
+	if err := collector.RecordTestRun(TestRun{AgentID: "agent-1", Timestamp: "2026-01-24T10:00:00Z", Command: "go test",
+		Results: []TestResult{{TestFile: "a_test.go", TestName: "TestA", Status: "passed"}}}); err != nil {
+		t.Fatalf("RecordTestRun failed: %v", err)
+	}
+
+	orphan := `INSERT INTO test_results (run_id, agent_id, timestamp, test_file, test_name, status, duration_ms)
+		VALUES (9999, 'agent-1', '2026-01-24T10:00:00Z', 'b_test.go', 'TestB', 'failed', 0)`
+	if _, err := collector.db.Exec(orphan); err == nil {
+		t.Fatal("expected foreign key violation inserting an orphaned result")
+	}
+
+	// Write an orphan through a connection without foreign key enforcement
+	var file string
+	if err := collector.db.QueryRow("SELECT file FROM pragma_database_list WHERE name = 'main'").Scan(&file); err != nil {
+		t.Fatalf("resolve database file: %v", err)
+	}
+	raw, err := sql.Open("sqlite", file)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	_, err = raw.Exec(orphan)
+	raw.Close()
+	if err != nil {
+		t.Fatalf("insert orphan: %v", err)
+	}
+
+	removed, err := collector.CheckOrphans()
+	if err != nil {
+		t.Fatalf("CheckOrphans failed: %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("expected 1 orphan removed, got %d", removed)
+	}
+
+	runs, err := collector.GetTestRuns(TelemetryFilter{})
+	if err != nil {
+		t.Fatalf("GetTestRuns failed: %v", err)
+	}
+	if len(runs) != 1 || len(runs[0].Results) != 1 {
+		t.Errorf("expected the parented result to remain, got %+v", runs)
+	}
+}
+
+// TestTelemetry_TestOwners verifies owners are loaded from file and attached by longest prefix.
+func TestTelemetry_TestOwners(t *testing.T) {
+	collector, cleanup := createTestCollector(t)
+	defer cleanup()
+
+	path := filepath.Join(t.TempDir(), "TESTOWNERS")
+	content := "# test ownership\n/internal/ core-team\ninternal/telemetry telemetry-team\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write owners file: %v", err)
+	}
+	owners, err := LoadOwners(path)
+	if err != nil {
+		t.Fatalf("LoadOwners failed: %v", err)
+	}
+	collector.SetOwners(owners)
+
+	run := TestRun{AgentID: "agent-1", Timestamp: "2026-01-24T10:00:00Z", Command: "go test", Results: []TestResult{
+		{TestFile: "github.com/gastown/townview/internal/telemetry", TestName: "TestA", Status: "passed"},
+		{TestFile: "github.com/gastown/townview/internal/events", TestName: "TestB", Status: "passed"},
+		{TestFile: "github.com/gastown/townview/cmd/townview", TestName: "TestC", Status: "passed"},
+	}}
+	if err := collector.RecordTestRun(run); err != nil {
+		t.Fatalf("RecordTestRun failed: %v", err)
+	}
+
+	status, err := collector.GetTestSuiteStatus(StatusFilterAll)
+	if err != nil {
+		t.Fatalf("GetTestSuiteStatus failed: %v", err)
+	}
+	want := map[string]string{"TestA": "telemetry-team", "TestB": "core-team", "TestC": ""}
+	for _, s := range status {
+		if s.Owner != want[s.TestName] {
+			t.Errorf("%s: expected owner %q, got %q", s.TestName, want[s.TestName], s.Owner)
+		}
+	}
+}
+
+// TestTelemetry_GetTestSuiteStatus_Missing verifies tests absent from a later run of the same command are reported missing.
+func TestTelemetry_GetTestSuiteStatus_Missing(t *testing.T) {
+	collector, cleanup := createTestCollector(t)
+	defer cleanup()
+
+	runs := []TestRun{
+		{AgentID: "agent-1", Timestamp: "2026-01-24T10:00:00Z", Command: "go test ./...", Results: []TestResult{
+			{TestFile: "a_test.go", TestName: "TestKept", Status: "passed"},
+			{TestFile: "a_test.go", TestName: "TestDropped", Status: "passed"},
+		}},
+		{AgentID: "agent-1", Timestamp: "2026-01-24T11:00:00Z", Command: "go test ./...", Results: []TestResult{
+			{TestFile: "a_test.go", TestName: "TestKept", Status: "passed"},
+		}},
+		// A different command does not make tests from the first command missing
+		{AgentID: "agent-1", Timestamp: "2026-01-24T12:00:00Z", Command: "go test ./cmd/...", Results: []TestResult{
+			{TestFile: "b_test.go", TestName: "TestOther", Status: "passed"},
+		}},
+	}
+	for _, run := range runs {
+		if err := collector.RecordTestRun(run); err != nil {
+			t.Fatalf("RecordTestRun failed: %v", err)
+		}
+	}
+
+	missing, err := collector.GetTestSuiteStatus(StatusFilterMissing)
+	if err != nil {
+		t.Fatalf("GetTestSuiteStatus failed: %v", err)
+	}
+	if len(missing) != 1 || missing[0].TestName != "TestDropped" {
+		t.Fatalf("expected only TestDropped missing, got %+v", missing)
+	}
+	if missing[0].CurrentStatus != TestStatusMissing {
+		t.Errorf("expected current_status %q, got %q", TestStatusMissing, missing[0].CurrentStatus)
+	}
+
+	passing, err := collector.GetTestSuiteStatus(StatusFilterPassing)
+	if err != nil {
+		t.Fatalf("GetTestSuiteStatus failed: %v", err)
+	}
+	if len(passing) != 2 {
+		t.Errorf("expected TestKept and TestOther passing, got %+v", passing)
+	}
+}
+
+// TestTelemetry_CompactDiff verifies unified diffs reduce to per-file churn and other input passes through.
+func TestTelemetry_CompactDiff(t *testing.T) {
+	diff := `diff --git a/server/main.go b/server/main.go
+index 1111111..2222222 100644
+--- a/server/main.go
++++ b/server/main.go
+@@ -1,3 +1,4 @@
+ package main
+-import "fmt"
++import (
++	"fmt"
++)
+diff --git a/old.txt b/old.txt
+deleted file mode 100644
+--- a/old.txt
++++ /dev/null
+@@ -1,2 +0,0 @@
+-line one
+---- not a header
+`
+	want := "server/main.go: +3 -1\nold.txt: +0 -2"
+	if got := CompactDiff(diff); got != want {
+		t.Errorf("CompactDiff() = %q, want %q", got, want)
+	}
+
+	if got := CompactDiff(want); got != want {
+		t.Errorf("expected compact summary to pass through, got %q", got)
+	}
+}
+
+// TestTelemetry_CapRunOutput verifies failure output is capped across a whole run.
+func TestTelemetry_CapRunOutput(t *testing.T) {
+	run := TestRun{Results: []TestResult{
+		{TestName: "TestA", Status: "failed", ErrorMessage: "0123456789"},
+		{TestName: "TestB", Status: "failed", ErrorMessage: "abcdefghij", StackTrace: "stack"},
+		{TestName: "TestC", Status: "failed", ErrorMessage: "klmnop"},
+	}}
+
+	if n := CapRunOutput(&run, 15); n != 3 {
+		t.Fatalf("expected 3 truncated fields, got %d", n)
+	}
+	if run.Results[0].ErrorMessage != "0123456789" {
+		t.Errorf("expected first message intact, got %q", run.Results[0].ErrorMessage)
+	}
+	if run.Results[1].ErrorMessage != "abcde"+runOutputMarker {
+		t.Errorf("expected second message cut at the limit, got %q", run.Results[1].ErrorMessage)
+	}
+	if run.Results[1].StackTrace != runOutputMarker || run.Results[2].ErrorMessage != runOutputMarker {
+		t.Errorf("expected later output replaced by the marker, got %q and %q",
+			run.Results[1].StackTrace, run.Results[2].ErrorMessage)
+	}
+
+	small := TestRun{Results: []TestResult{{TestName: "TestA", ErrorMessage: "short"}}}
+	if n := CapRunOutput(&small, 0); n != 0 || small.Results[0].ErrorMessage != "short" {
+		t.Errorf("expected no truncation with limit 0, got %d %q", n, small.Results[0].ErrorMessage)
+	}
+}
+
+func TestTelemetry_GetAgentTestHealth(t *testing.T) {
+	collector, cleanup := createTestCollector(t)
+	defer cleanup()
+
+	runs := []TestRun{
+		{
+			AgentID:   "agent-1",
+			Timestamp: "2026-01-24T10:00:00Z",
+			CommitSHA: "commit-a",
+			Command:   "go test",
+			Results: []TestResult{
+				{TestFile: "a_test.go", TestName: "TestA", Status: "passed", DurationMS: 10},
+				{TestFile: "b_test.go", TestName: "TestB", Status: "passed", DurationMS: 10},
+			},
+		},
+		{
+			AgentID:   "agent-2",
+			Timestamp: "2026-01-24T11:00:00Z",
+			CommitSHA: "commit-b",
+			Command:   "go test",
+			Results: []TestResult{
+				{TestFile: "a_test.go", TestName: "TestA", Status: "failed", DurationMS: 10},
+				{TestFile: "b_test.go", TestName: "TestB", Status: "error", DurationMS: 10},
+			},
+		},
+		{
+			AgentID:   "agent-2",
+			Timestamp: "2026-01-24T12:00:00Z",
+			CommitSHA: "commit-c",
+			Command:   "go test",
+			Results: []TestResult{
+				{TestFile: "a_test.go", TestName: "TestA", Status: "failed", DurationMS: 10},
+				{TestFile: "b_test.go", TestName: "TestB", Status: "passed", DurationMS: 10},
+			},
+		},
+	}
+	for _, run := range runs {
+		if err := collector.RecordTestRun(run); err != nil {
+			t.Fatalf("RecordTestRun failed: %v", err)
+		}
+	}
+	if err := collector.RecordGitChange(GitChange{
+		AgentID: "agent-2", Timestamp: "2026-01-24T10:30:00Z", CommitSHA: "commit-b",
+		Branch: "main", FilesChanged: 1, Message: "break things",
+	}); err != nil {
+		t.Fatalf("RecordGitChange failed: %v", err)
+	}
+
+	health, err := collector.GetAgentTestHealth("agent-2")
+	if err != nil {
+		t.Fatalf("GetAgentTestHealth failed: %v", err)
+	}
+	if health.TotalResults != 4 || health.Passed != 1 || health.Failed != 3 {
+		t.Errorf("expected 4 results (1 passed, 3 failed), got %+v", health)
+	}
+	if health.PassRate != 0.25 {
+		t.Errorf("expected pass rate 0.25, got %v", health.PassRate)
+	}
+	if len(health.TopFailedTests) != 2 || health.TopFailedTests[0].TestName != "TestA" || health.TopFailedTests[0].Failures != 2 {
+		t.Errorf("expected TestA first with 2 failures, got %+v", health.TopFailedTests)
+	}
+	if health.RegressionCount != 1 {
+		t.Errorf("expected 1 regression from commit-b, got %d", health.RegressionCount)
+	}
+
+	other, err := collector.GetAgentTestHealth("agent-1")
+	if err != nil {
+		t.Fatalf("GetAgentTestHealth failed: %v", err)
+	}
+	if other.PassRate != 1 || other.RegressionCount != 0 || len(other.TopFailedTests) != 0 {
+		t.Errorf("expected clean health for agent-1, got %+v", other)
+	}
+}
+
+func TestTelemetry_GetTokenAnomalies(t *testing.T) {
+	collector, cleanup := createTestCollector(t)
+	defer cleanup()
+
+	now := time.Now().UTC()
+	at := func(ago time.Duration) string { return now.Add(-ago).Format(time.RFC3339) }
+
+	usage := []TokenUsage{
+		// Established agents: 300 tokens over the 72h before the window.
+		{AgentID: "agent-spike", Timestamp: at(96 * time.Hour), InputTokens: 150, Model: "m", RequestType: "chat"},
+		{AgentID: "agent-spike", Timestamp: at(48 * time.Hour), InputTokens: 150, Model: "m", RequestType: "chat"},
+		{AgentID: "agent-steady", Timestamp: at(96 * time.Hour), InputTokens: 150, Model: "m", RequestType: "chat"},
+		{AgentID: "agent-steady", Timestamp: at(48 * time.Hour), InputTokens: 150, Model: "m", RequestType: "chat"},
+		// Window usage.
+		{AgentID: "agent-spike", BeadID: "bead-small", Timestamp: at(2 * time.Hour), InputTokens: 200, Model: "m", RequestType: "chat"},
+		{AgentID: "agent-spike", BeadID: "bead-big", Timestamp: at(time.Hour), InputTokens: 600, OutputTokens: 200, Model: "m", RequestType: "chat"},
+		{AgentID: "agent-steady", Timestamp: at(time.Hour), InputTokens: 100, Model: "m", RequestType: "chat"},
+		{AgentID: "new-a", Timestamp: at(time.Hour), InputTokens: 100, Model: "m", RequestType: "chat"},
+		{AgentID: "new-b", Timestamp: at(time.Hour), InputTokens: 100, Model: "m", RequestType: "chat"},
+		{AgentID: "new-hog", BeadID: "bead-hog", Timestamp: at(time.Hour), InputTokens: 5000, Model: "m", RequestType: "chat"},
+	}
+	for _, u := range usage {
+		if err := collector.RecordTokenUsage(u); err != nil {
+			t.Fatalf("RecordTokenUsage failed: %v", err)
+		}
+	}
+
+	anomalies, err := collector.GetTokenAnomalies(at(24 * time.Hour))
+	if err != nil {
+		t.Fatalf("GetTokenAnomalies failed: %v", err)
+	}
+	if len(anomalies) != 2 {
+		t.Fatalf("expected 2 anomalies, got %+v", anomalies)
+	}
+
+	hog, spike := anomalies[0], anomalies[1]
+	if hog.AgentID != "new-hog" || hog.Baseline != AnomalyBaselineTownMedian || hog.ExpectedTokens != 100 {
+		t.Errorf("expected new-hog against the town median first, got %+v", hog)
+	}
+	if spike.AgentID != "agent-spike" || spike.Baseline != AnomalyBaselineHistory || spike.ObservedTokens != 1000 {
+		t.Errorf("expected agent-spike against its history, got %+v", spike)
+	}
+	if len(spike.Beads) != 2 || spike.Beads[0] != "bead-big" {
+		t.Errorf("expected beads heaviest first, got %v", spike.Beads)
+	}
+
+	collector.SetAnomalyMultiplier(100)
+	anomalies, err = collector.GetTokenAnomalies(at(24 * time.Hour))
+	if err != nil {
+		t.Fatalf("GetTokenAnomalies failed: %v", err)
+	}
+	if len(anomalies) != 0 {
+		t.Errorf("expected no anomalies with a high multiplier, got %+v", anomalies)
+	}
+}
+
+func TestTelemetry_PerRigCollector_RoutesAndFansOut(t *testing.T) {
+	dir := t.TempDir()
+	sharedPath := filepath.Join(dir, "telemetry.db")
+	rigDir := filepath.Join(dir, "telemetry")
+
+	collector, err := NewPerRigCollector(sharedPath, rigDir)
+	if err != nil {
+		t.Fatalf("NewPerRigCollector failed: %v", err)
+	}
+
+	changes := []GitChange{
+		{AgentID: "a1", BeadID: "b1", Rig: "alpha", Timestamp: "2026-01-01T10:00:00Z", CommitSHA: "c1", Branch: "main", FilesChanged: 1},
+		{AgentID: "a2", BeadID: "b2", Rig: "beta/x", Timestamp: "2026-01-01T11:00:00Z", CommitSHA: "c2", Branch: "main", FilesChanged: 2},
+		{AgentID: "a3", Timestamp: "2026-01-01T12:00:00Z", CommitSHA: "c3", Branch: "main", FilesChanged: 3},
+	}
+	for _, c := range changes {
+		if err := collector.RecordGitChange(c); err != nil {
+			t.Fatalf("RecordGitChange failed: %v", err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(rigDir, "alpha.db")); err != nil {
+		t.Errorf("expected alpha's own database: %v", err)
+	}
+
+	all, err := collector.GetGitChanges(TelemetryFilter{})
+	if err != nil {
+		t.Fatalf("GetGitChanges failed: %v", err)
+	}
+	if len(all) != 3 || all[0].CommitSHA != "c3" || all[2].CommitSHA != "c1" {
+		t.Errorf("expected all changes newest first, got %+v", all)
+	}
+
+	limited, err := collector.GetGitChanges(TelemetryFilter{Limit: 2})
+	if err != nil {
+		t.Fatalf("GetGitChanges failed: %v", err)
+	}
+	if len(limited) != 2 || limited[1].CommitSHA != "c2" {
+		t.Errorf("expected the 2 newest changes, got %+v", limited)
+	}
+
+	alpha, err := collector.GetGitSummary(TelemetryFilter{Rig: "alpha"})
+	if err != nil {
+		t.Fatalf("GetGitSummary failed: %v", err)
+	}
+	if alpha.TotalCommits != 1 || alpha.TotalFilesChanged != 1 {
+		t.Errorf("expected only alpha's commit, got %+v", alpha)
+	}
+
+	// Rig databases are found again on reopen
+	collector.Close()
+	collector, err = NewPerRigCollector(sharedPath, rigDir)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer collector.Close()
+
+	beta, err := collector.GetGitChanges(TelemetryFilter{Rig: "beta/x"})
+	if err != nil {
+		t.Fatalf("GetGitChanges failed: %v", err)
+	}
+	if len(beta) != 1 || beta[0].CommitSHA != "c2" {
+		t.Errorf("expected beta/x's commit after reopen, got %+v", beta)
+	}
+
+	shared, err := collector.shared.GetGitChanges(TelemetryFilter{})
+	if err != nil {
+		t.Fatalf("GetGitChanges failed: %v", err)
+	}
+	if len(shared) != 1 || shared[0].CommitSHA != "c3" {
+		t.Errorf("expected only the rigless change in the shared database, got %+v", shared)
+	}
+}
+
+func TestTelemetry_PerRigCollector_UnknownRigsUseSharedDB(t *testing.T) {
+	dir := t.TempDir()
+	rigDir := filepath.Join(dir, "telemetry")
+	collector, err := NewPerRigCollector(filepath.Join(dir, "telemetry.db"), rigDir)
+	if err != nil {
+		t.Fatalf("NewPerRigCollector failed: %v", err)
+	}
+	defer collector.Close()
+	collector.SetRigValidator(func(rig string) bool { return rig == "alpha" })
+
+	for _, rig := range []string{"alpha", "bogus-1", "../bogus-2"} {
+		usage := TokenUsage{AgentID: "a1", Rig: rig, Timestamp: "2026-01-01T10:00:00Z", Model: "claude-sonnet-4", InputTokens: 100}
+		if err := collector.RecordTokenUsage(usage); err != nil {
+			t.Fatalf("RecordTokenUsage(%q) failed: %v", rig, err)
+		}
+		run := TestRun{AgentID: "a1", Rig: rig, Timestamp: "2026-01-01T10:00:00Z", CommitSHA: "c1", Total: 1, Passed: 1}
+		if err := collector.RecordTestRun(run); err != nil {
+			t.Fatalf("RecordTestRun(%q) failed: %v", rig, err)
+		}
+	}
+
+	entries, err := os.ReadDir(rigDir)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "alpha.db" {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Fatalf("expected only alpha.db, got %v", names)
+	}
+
+	usage, err := collector.GetTokenUsage(TelemetryFilter{Rig: "bogus-1"})
+	if err != nil {
+		t.Fatalf("GetTokenUsage failed: %v", err)
+	}
+	if len(usage) != 1 || usage[0].Rig != "bogus-1" {
+		t.Errorf("expected bogus-1's usage from the shared database, got %+v", usage)
+	}
+	runs, err := collector.GetTestRuns(TelemetryFilter{Rig: "bogus-1"})
+	if err != nil {
+		t.Fatalf("GetTestRuns failed: %v", err)
+	}
+	if len(runs) != 1 || runs[0].Rig != "bogus-1" {
+		t.Errorf("expected bogus-1's run from the shared database, got %+v", runs)
+	}
+
+	alphaUsage, err := collector.rigs["alpha"].GetTokenUsage(TelemetryFilter{})
+	if err != nil {
+		t.Fatalf("GetTokenUsage failed: %v", err)
+	}
+	if len(alphaUsage) != 1 {
+		t.Errorf("expected alpha's usage in its own database, got %+v", alphaUsage)
+	}
+	alphaRuns, err := collector.rigs["alpha"].GetTestRuns(TelemetryFilter{})
+	if err != nil {
+		t.Fatalf("GetTestRuns failed: %v", err)
+	}
+	if len(alphaRuns) != 1 {
+		t.Errorf("expected alpha's run in its own database, got %+v", alphaRuns)
+	}
+}
+
+func TestTelemetry_NopCollector(t *testing.T) {
+	var collector Collector = NopCollector{}
+
+	runs, err := collector.GetTestRuns(TelemetryFilter{})
+	if err != nil || runs == nil || len(runs) != 0 {
+		t.Errorf("expected empty non-nil runs, got %v, %v", runs, err)
+	}
+	summary, err := collector.GetTokenSummary(TelemetryFilter{})
+	if err != nil || summary.ByModel == nil || summary.ByAgent == nil {
+		t.Errorf("expected an empty summary with initialized maps, got %+v, %v", summary, err)
+	}
+	bt, err := collector.GetBeadTelemetry("bead-1")
+	if err != nil || bt.BeadID != "bead-1" || bt.TokenUsage == nil || bt.CostTimeline.Buckets == nil {
+		t.Errorf("expected empty bead telemetry, got %+v, %v", bt, err)
+	}
+
+	if err := collector.RecordGitChange(GitChange{AgentID: "a"}); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("expected ErrUnavailable from a write, got %v", err)
+	}
+	if _, err := collector.GetCommitGate("abc"); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("expected ErrUnavailable from the commit gate, got %v", err)
+	}
+	if _, err := collector.GetCommitActivity("abc"); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("expected ErrUnavailable from commit activity, got %v", err)
+	}
+}
+
+func TestTelemetry_RecordTokenUsage_CoalescesWithinWindow(t *testing.T) {
+	collector, cleanup := createTestCollector(t)
+	defer cleanup()
+
+	collector.SetTokenCoalesceWindow(time.Minute)
+
+	usage := []TokenUsage{
+		{AgentID: "chatty", BeadID: "b1", Timestamp: "2026-01-24T10:00:00Z", InputTokens: 10, OutputTokens: 1, Model: "m", RequestType: "chat"},
+		{AgentID: "chatty", BeadID: "b1", Timestamp: "2026-01-24T10:00:20Z", InputTokens: 20, OutputTokens: 2, Model: "m", RequestType: "chat"},
+		{AgentID: "chatty", BeadID: "b1", Timestamp: "2026-01-24T10:00:40Z", InputTokens: 30, OutputTokens: 3, Model: "m", RequestType: "tool_use"},
+		{AgentID: "chatty", BeadID: "b1", Timestamp: "2026-01-24T10:00:50Z", InputTokens: 40, OutputTokens: 4, Model: "m", RequestType: "chat"},
+		{AgentID: "chatty", BeadID: "b1", Timestamp: "2026-01-24T10:01:10Z", InputTokens: 50, OutputTokens: 5, Model: "m", RequestType: "chat"}, // past the first row's window
+	}
+	for _, u := range usage {
+		if err := collector.RecordTokenUsage(u); err != nil {
+			t.Fatalf("RecordTokenUsage failed: %v", err)
+		}
+	}
+
+	rows, err := collector.GetTokenUsage(TelemetryFilter{AgentID: "chatty"})
+	if err != nil {
+		t.Fatalf("GetTokenUsage failed: %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("expected 3 rows after coalescing, got %+v", rows)
+	}
+	first := rows[len(rows)-1]
+	if first.RequestType != "chat" || first.Timestamp != "2026-01-24T10:00:00Z" || first.InputTokens != 70 || first.OutputTokens != 7 {
+		t.Errorf("expected the first chat row to hold 3 calls, got %+v", first)
+	}
+
+	summary, err := collector.GetTokenSummary(TelemetryFilter{AgentID: "chatty"})
+	if err != nil {
+		t.Fatalf("GetTokenSummary failed: %v", err)
+	}
+	if summary.TotalInput != 150 || summary.TotalOutput != 15 {
+		t.Errorf("expected totals preserved, got input %d output %d", summary.TotalInput, summary.TotalOutput)
+	}
+}
+
+func TestTelemetry_RecordTokenUsage_CoalescesConcurrentWrites(t *testing.T) {
+	collector, cleanup := createTestCollector(t)
+	defer cleanup()
+
+	collector.SetTokenCoalesceWindow(time.Minute)
+
+	// Same instant in three zones, then concurrent heartbeats
+	const writers = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ts := []string{"2026-01-24T10:00:00Z", "2026-01-24T12:00:00+02:00", "2026-01-24T05:00:00-05:00"}[i%3]
+			errs <- collector.RecordTokenUsage(TokenUsage{AgentID: "busy", Timestamp: ts, InputTokens: 1, OutputTokens: 1, Model: "m", RequestType: "chat"})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("RecordTokenUsage failed: %v", err)
+		}
+	}
+
+	rows, err := collector.GetTokenUsage(TelemetryFilter{AgentID: "busy"})
+	if err != nil {
+		t.Fatalf("GetTokenUsage failed: %v", err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("expected concurrent writes to coalesce into 1 row, got %d: %+v", len(rows), rows)
+	}
+	if rows[0].InputTokens != writers || rows[0].Timestamp != "2026-01-24T10:00:00Z" {
+		t.Errorf("expected %d input tokens at the UTC timestamp, got %+v", writers, rows[0])
+	}
+}
+
+func TestTelemetry_EstimateCostUSD(t *testing.T) {
+	tests := []struct {
+		model string
+		want  float64
+	}{
+		{"claude-opus-4-5-20251101", 5 + 25},
+		{"Claude-Sonnet-4", 3 + 15},
+		{"haiku", 1 + 5},
+		{"unknown-model", 3 + 15}, // default price
+		{"sonnet-distilled-from-opus", 3 + 15},
+	}
+	for _, tt := range tests {
+		// Repeat so a match that depended on iteration order would show up
+		for i := 0; i < 20; i++ {
+			if got := EstimateCostUSD(tt.model, 1_000_000, 1_000_000); got != tt.want {
+				t.Fatalf("EstimateCostUSD(%q) = %v, want %v", tt.model, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestTelemetry_KnownModel(t *testing.T) {
+	tests := []struct {
+		model string
+		want  bool
+	}{
+		{"claude-opus-4-5-20251101", true},
+		{"Claude-Sonnet-4", true},
+		{"HAIKU", true},
+		{"gpt-4o", false},
+		{"unknown", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := KnownModel(tt.model); got != tt.want {
+			t.Errorf("KnownModel(%q) = %v, want %v", tt.model, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package telemetry
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DiffFormat selects how GitChange.DiffSummary is stored.
+type DiffFormat string
+
+const (
+	DiffFormatCompact DiffFormat = "compact" // one "path: +N -M" line per file
+	DiffFormatFull    DiffFormat = "full"    // the unified diff verbatim
+)
+
+// Valid reports whether f is a known format. The empty format means compact.
+func (f DiffFormat) Valid() bool {
+	switch f {
+	case "", DiffFormatCompact, DiffFormatFull:
+		return true
+	}
+	return false
+}
+
+// DiffFiles returns the paths of the files in a diff summary, whether it was
+// stored compact or as a full unified diff.
+func DiffFiles(summary string) []string {
+	files := []string{}
+	for _, line := range strings.Split(CompactDiff(summary), "\n") {
+		if i := strings.LastIndex(line, ": +"); i > 0 {
+			files = append(files, line[:i])
+		}
+	}
+	return files
+}
+
+// CompactDiff reduces a unified diff to one "path: +N -M" line per file.
+// Input that is not a unified diff (no file headers) is returned unchanged,
+// so an already-compact summary passes through.
+func CompactDiff(diff string) string {
+	type fileChurn struct {
+		path       string
+		insertions int
+		deletions  int
+	}
+
+	var files []*fileChurn
+	var current *fileChurn
+	inHunk := false
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			current = &fileChurn{}
+			if _, b, ok := strings.Cut(line, " b/"); ok {
+				current.path = b
+			}
+			files = append(files, current)
+			inHunk = false
+		case current == nil:
+			continue
+		case strings.HasPrefix(line, "--- ") && !inHunk:
+			if path := strings.TrimPrefix(line, "--- "); path != "/dev/null" && current.path == "" {
+				current.path = strings.TrimPrefix(path, "a/")
+			}
+		case strings.HasPrefix(line, "+++ ") && !inHunk:
+			if path := strings.TrimPrefix(line, "+++ "); path != "/dev/null" {
+				current.path = strings.TrimPrefix(path, "b/")
+			}
+		case strings.HasPrefix(line, "@@"):
+			inHunk = true
+		case inHunk && strings.HasPrefix(line, "+"):
+			current.insertions++
+		case inHunk && strings.HasPrefix(line, "-"):
+			current.deletions++
+		}
+	}
+
+	if len(files) == 0 {
+		return diff
+	}
+
+	lines := make([]string, len(files))
+	for i, f := range files {
+		lines[i] = fmt.Sprintf("%s: +%d -%d", f.path, f.insertions, f.deletions)
+	}
+	return strings.Join(lines, "\n")
+}
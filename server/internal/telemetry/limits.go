@@ -0,0 +1,42 @@
+package telemetry
+
+import "unicode/utf8"
+
+// DefaultMaxRunOutputBytes is the default cap on error and stack output stored
+// for one test run.
+const DefaultMaxRunOutputBytes = 1 << 20
+
+// runOutputMarker replaces output dropped by CapRunOutput.
+const runOutputMarker = "\n...[truncated: test run output limit reached]"
+
+// CapRunOutput limits the combined size of error messages and stack traces in
+// a run to maxBytes, in result order. The field that crosses the limit keeps
+// its head plus a marker; later fields are reduced to the marker. Returns the
+// number of fields truncated. A maxBytes of 0 or less disables the cap.
+func CapRunOutput(run *TestRun, maxBytes int) int {
+	if maxBytes <= 0 {
+		return 0
+	}
+
+	remaining := maxBytes
+	truncated := 0
+	capField := func(s string) string {
+		if len(s) <= remaining {
+			remaining -= len(s)
+			return s
+		}
+		truncated++
+		cut := remaining
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		remaining = 0
+		return s[:cut] + runOutputMarker
+	}
+
+	for i := range run.Results {
+		run.Results[i].ErrorMessage = capField(run.Results[i].ErrorMessage)
+		run.Results[i].StackTrace = capField(run.Results[i].StackTrace)
+	}
+	return truncated
+}
@@ -0,0 +1,150 @@
+package telemetry
+
+import "errors"
+
+// ErrUnavailable is returned by NopCollector for operations that need a real
+// telemetry store: writes, and verdicts that would be wrong without data.
+var ErrUnavailable = errors.New("telemetry collector not configured")
+
+// NopCollector is the Collector used when no telemetry database could be
+// opened. Queries return empty, non-nil results so read endpoints degrade to
+// "no data"; writes return ErrUnavailable.
+type NopCollector struct{}
+
+var _ Collector = NopCollector{}
+
+func (NopCollector) RecordTokenUsage(TokenUsage) error { return ErrUnavailable }
+func (NopCollector) RecordGitChange(GitChange) error   { return ErrUnavailable }
+func (NopCollector) RecordTestRun(TestRun) error       { return ErrUnavailable }
+
+func (NopCollector) GetTokenUsage(TelemetryFilter) ([]TokenUsage, error) {
+	return []TokenUsage{}, nil
+}
+
+func (NopCollector) GetTokenSummary(TelemetryFilter) (TokenSummary, error) {
+	return summarizeTokenUsage(nil), nil
+}
+
+func (NopCollector) GetTokenAnomalies(string) ([]TokenAnomaly, error) {
+	return []TokenAnomaly{}, nil
+}
+
+func (NopCollector) GetGitChanges(TelemetryFilter) ([]GitChange, error) {
+	return []GitChange{}, nil
+}
+
+func (NopCollector) GetGitSummary(TelemetryFilter) (GitSummary, error) {
+	return summarizeGitChanges(nil), nil
+}
+
+func (NopCollector) GetTestRuns(TelemetryFilter) ([]TestRun, error) {
+	return []TestRun{}, nil
+}
+
+func (NopCollector) GetTestSummary(TelemetryFilter) (TestSummary, error) {
+	return summarizeTestRuns(nil), nil
+}
+
+func (NopCollector) GetTestHistory(string, int) ([]TestHistoryEntry, error) {
+	return []TestHistoryEntry{}, nil
+}
+
+func (NopCollector) GetLastPassedCommit(string) (string, error) { return "", nil }
+
+func (NopCollector) GetRegressions(string) ([]TestRegression, error) {
+	return []TestRegression{}, nil
+}
+
+func (NopCollector) GetRegressionsByCommit(string) (map[string][]TestRegression, error) {
+	return map[string][]TestRegression{}, nil
+}
+
+func (NopCollector) GetRegressionsWithOptions(RegressionOptions) ([]TestRegression, int, error) {
+	return []TestRegression{}, 0, nil
+}
+
+func (NopCollector) GetTestSuiteStatus(StatusFilter) ([]TestStatus, error) {
+	return []TestStatus{}, nil
+}
+
+func (NopCollector) GetTestStatusAtCommit(commitSHA string) (CommitTestStatus, error) {
+	return CommitTestStatus{CommitSHA: commitSHA}, nil
+}
+
+// GetCommitActivity returns ErrUnavailable: without telemetry every commit
+// would look unknown, which is misleading.
+func (NopCollector) GetCommitActivity(string) (CommitActivity, error) {
+	return CommitActivity{}, ErrUnavailable
+}
+
+func (NopCollector) GetCoverageGaps(TelemetryFilter) ([]CoverageGap, error) {
+	return []CoverageGap{}, nil
+}
+
+func (NopCollector) GetRegressionsAtCommit(string) ([]TestRegression, error) {
+	return []TestRegression{}, nil
+}
+
+func (NopCollector) GetBeadRegressions(string) ([]TestRegression, error) {
+	return []TestRegression{}, nil
+}
+
+func (NopCollector) GetBeadTelemetry(beadID string) (BeadTelemetry, error) {
+	return BeadTelemetry{
+		BeadID:       beadID,
+		TokenUsage:   []TokenUsage{},
+		GitChanges:   []GitChange{},
+		TestRuns:     []TestRun{},
+		TokenSummary: summarizeTokenUsage(nil),
+		GitSummary:   summarizeGitChanges(nil),
+		TestSummary:  summarizeTestRuns(nil),
+		CostTimeline: buildCostTimeline(nil, nil),
+		Regressions:  []TestRegression{},
+	}, nil
+}
+
+func (NopCollector) GetAgentTelemetry(agentID string) (AgentTelemetry, error) {
+	return AgentTelemetry{
+		AgentID:      agentID,
+		TokenUsage:   []TokenUsage{},
+		GitChanges:   []GitChange{},
+		TestRuns:     []TestRun{},
+		TokenSummary: summarizeTokenUsage(nil),
+		GitSummary:   summarizeGitChanges(nil),
+		TestSummary:  summarizeTestRuns(nil),
+	}, nil
+}
+
+func (NopCollector) GetAgentTestHealth(agentID string) (AgentTestHealth, error) {
+	return AgentTestHealth{AgentID: agentID, TopFailedTests: []FailedTestCount{}}, nil
+}
+
+func (NopCollector) SetBeadBudget(BeadBudget) error { return ErrUnavailable }
+
+func (NopCollector) GetBeadBudget(string) (*BeadBudget, error) { return nil, nil }
+
+func (NopCollector) CheckBeadBudget(beadID string, budgetUSD float64) (BudgetStatus, error) {
+	return budgetStatus(beadID, budgetUSD, 0, nil), nil
+}
+
+func (NopCollector) MarkBudgetAlerted(string) (bool, error) { return false, ErrUnavailable }
+
+func (NopCollector) MarkBudgetExceeded(string) (bool, error) { return false, ErrUnavailable }
+
+func (NopCollector) GetCommitCost(string) (float64, error) { return 0, nil }
+
+// GetCommitGate returns ErrUnavailable: without telemetry every commit would
+// fail the gate for having no tests, which is misleading.
+func (NopCollector) GetCommitGate(string) (CommitGate, error) {
+	return CommitGate{}, ErrUnavailable
+}
+
+func (NopCollector) RecordConvoyProgress(ProgressSnapshot) error { return ErrUnavailable }
+
+func (NopCollector) GetConvoyProgressHistory(string, string) ([]ProgressSnapshot, error) {
+	return []ProgressSnapshot{}, nil
+}
+
+func (NopCollector) EstimateConvoyCompletion(string, string) (*ConvoyETA, error) { return nil, nil }
+
+func (NopCollector) Close() error { return nil }
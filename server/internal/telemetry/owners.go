@@ -0,0 +1,70 @@
+package telemetry
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// OwnerRule maps a test file path prefix to an owning team.
+type OwnerRule struct {
+	Prefix string `json:"prefix"`
+	Owner  string `json:"owner"`
+}
+
+// Owners is a CODEOWNERS-style list of path prefix rules.
+type Owners []OwnerRule
+
+// LoadOwners reads an ownership file. Each non-blank line holds a path prefix
+// followed by an owner; lines starting with # are comments.
+func LoadOwners(path string) (Owners, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open owners file: %w", err)
+	}
+	defer f.Close()
+
+	var owners Owners
+	scanner := bufio.NewScanner(f)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("owners file line %d: expected \"<prefix> <owner>\"", lineNum)
+		}
+		owners = append(owners, OwnerRule{
+			Prefix: strings.TrimPrefix(fields[0], "/"),
+			Owner:  fields[1],
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read owners file: %w", err)
+	}
+
+	return owners, nil
+}
+
+// OwnerOf returns the owner of a test file, or "" if no rule matches.
+// A rule matches when the file starts with its prefix or contains it after a
+// path separator, so repo-relative prefixes match full package paths.
+// The longest matching prefix wins.
+func (o Owners) OwnerOf(testFile string) string {
+	owner := ""
+	longest := -1
+	for _, rule := range o {
+		if len(rule.Prefix) <= longest {
+			continue
+		}
+		if strings.HasPrefix(testFile, rule.Prefix) || strings.Contains(testFile, "/"+rule.Prefix) {
+			owner = rule.Owner
+			longest = len(rule.Prefix)
+		}
+	}
+	return owner
+}
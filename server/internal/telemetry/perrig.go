@@ -0,0 +1,768 @@
+package telemetry
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PerRigCollector implements Collector with one SQLite database per rig, so a
+// noisy or corrupt rig cannot affect another rig's telemetry. Records are
+// routed by their Rig field; records without a rig or for a rig the validator
+// doesn't know, bead budgets and convoy progress live in a shared database. Queries filtered to a rig read only that
+// rig's database (plus the shared one); unfiltered queries fan out and merge.
+type PerRigCollector struct {
+	dir    string
+	shared *SQLiteCollector
+
+	mu   sync.Mutex
+	rigs map[string]*SQLiteCollector
+
+	owners            Owners
+	anomalyMultiplier float64
+	coalesceWindow    time.Duration
+
+	// Reports whether a rig may get its own database; nil allows every rig
+	rigValidator func(rig string) bool
+}
+
+// rigDBExt is the file extension of per-rig telemetry databases.
+const rigDBExt = ".db"
+
+// NewPerRigCollector opens the shared database at sharedPath and every rig
+// database already present in rigDir. Rig databases are created in rigDir on
+// first write.
+func NewPerRigCollector(sharedPath, rigDir string) (*PerRigCollector, error) {
+	if err := os.MkdirAll(rigDir, 0755); err != nil {
+		return nil, fmt.Errorf("create rig telemetry dir: %w", err)
+	}
+
+	shared, err := NewSQLiteCollector(sharedPath)
+	if err != nil {
+		return nil, err
+	}
+	p := &PerRigCollector{dir: rigDir, shared: shared, rigs: make(map[string]*SQLiteCollector)}
+
+	rigs, err := RigDatabases(rigDir)
+	if err != nil {
+		p.Close()
+		return nil, err
+	}
+	for _, rig := range rigs {
+		if _, err := p.rigCollector(rig, true); err != nil {
+			p.Close()
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
+// RigDatabases lists the rigs with a telemetry database in rigDir, in name
+// order. A missing rigDir has none.
+func RigDatabases(rigDir string) ([]string, error) {
+	entries, err := os.ReadDir(rigDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read rig telemetry dir: %w", err)
+	}
+
+	var rigs []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, rigDBExt) {
+			continue
+		}
+		rig, err := url.PathUnescape(strings.TrimSuffix(name, rigDBExt))
+		if err != nil || rig == "" {
+			continue
+		}
+		rigs = append(rigs, rig)
+	}
+	return rigs, nil
+}
+
+// rigCollector returns the collector for a rig, opening its database when
+// create is set. Returns nil without error for an unknown rig when create is unset.
+func (p *PerRigCollector) rigCollector(rig string, create bool) (*SQLiteCollector, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if c, ok := p.rigs[rig]; ok || !create {
+		return c, nil
+	}
+
+	c, err := NewSQLiteCollector(filepath.Join(p.dir, url.PathEscape(rig)+rigDBExt))
+	if err != nil {
+		return nil, fmt.Errorf("open telemetry for rig %s: %w", rig, err)
+	}
+	c.SetOwners(p.owners)
+	c.SetAnomalyMultiplier(p.anomalyMultiplier)
+	c.SetTokenCoalesceWindow(p.coalesceWindow)
+	p.rigs[rig] = c
+	return c, nil
+}
+
+// SetRigValidator sets the check deciding which rigs get their own database.
+// Records for other rigs are kept in the shared database, so client-supplied
+// rig names cannot create files. Call before serving requests.
+func (p *PerRigCollector) SetRigValidator(validator func(rig string) bool) {
+	p.mu.Lock()
+	p.rigValidator = validator
+	p.mu.Unlock()
+}
+
+// forRecord returns the collector a record for rig is written to: the rig's
+// database if it has one or the validator knows the rig, the shared one otherwise.
+func (p *PerRigCollector) forRecord(rig string) (*SQLiteCollector, error) {
+	if rig == "" {
+		return p.shared, nil
+	}
+	if c, _ := p.rigCollector(rig, false); c != nil {
+		return c, nil
+	}
+	p.mu.Lock()
+	validator := p.rigValidator
+	p.mu.Unlock()
+	if validator != nil && !validator(rig) {
+		return p.shared, nil
+	}
+	return p.rigCollector(rig, true)
+}
+
+// forRig returns the collectors a query filtered to rig reads: the shared
+// database, which may hold rows recorded before per-rig mode was enabled, and
+// the rig's own database. An empty rig reads every database.
+func (p *PerRigCollector) forRig(rig string) []*SQLiteCollector {
+	if rig == "" {
+		return p.all()
+	}
+	cs := []*SQLiteCollector{p.shared}
+	if c, _ := p.rigCollector(rig, false); c != nil {
+		cs = append(cs, c)
+	}
+	return cs
+}
+
+// all returns the shared collector followed by each rig's, in rig name order.
+func (p *PerRigCollector) all() []*SQLiteCollector {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	names := make([]string, 0, len(p.rigs))
+	for name := range p.rigs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	cs := []*SQLiteCollector{p.shared}
+	for _, name := range names {
+		cs = append(cs, p.rigs[name])
+	}
+	return cs
+}
+
+// SetOwners sets the test ownership rules on every rig's collector.
+// Call before serving requests.
+func (p *PerRigCollector) SetOwners(owners Owners) {
+	p.mu.Lock()
+	p.owners = owners
+	p.mu.Unlock()
+	for _, c := range p.all() {
+		c.SetOwners(owners)
+	}
+}
+
+// SetAnomalyMultiplier sets the anomaly multiplier on every rig's collector.
+// Call before serving requests.
+func (p *PerRigCollector) SetAnomalyMultiplier(m float64) {
+	p.mu.Lock()
+	p.anomalyMultiplier = m
+	p.mu.Unlock()
+	for _, c := range p.all() {
+		c.SetAnomalyMultiplier(m)
+	}
+}
+
+// SetTokenCoalesceWindow sets the token usage coalesce window on every rig's
+// collector. Call before serving requests.
+func (p *PerRigCollector) SetTokenCoalesceWindow(window time.Duration) {
+	p.mu.Lock()
+	p.coalesceWindow = window
+	p.mu.Unlock()
+	for _, c := range p.all() {
+		c.SetTokenCoalesceWindow(window)
+	}
+}
+
+// fanOut calls query on each collector and concatenates the results.
+func fanOut[T any](cs []*SQLiteCollector, query func(c *SQLiteCollector) ([]T, error)) ([]T, error) {
+	var results []T
+	for _, c := range cs {
+		part, err := query(c)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, part...)
+	}
+	return results, nil
+}
+
+// newestFirst sorts merged results by timestamp, newest first, and applies limit.
+func newestFirst[T any](results []T, timestamp func(T) string, limit int) []T {
+	sort.SliceStable(results, func(i, j int) bool {
+		return timestamp(results[i]) > timestamp(results[j])
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// Close closes the shared and every rig database.
+func (p *PerRigCollector) Close() error {
+	var errs []error
+	for _, c := range p.all() {
+		if err := c.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RecordTokenUsage stores a token usage record in its rig's database.
+func (p *PerRigCollector) RecordTokenUsage(usage TokenUsage) error {
+	c, err := p.forRecord(usage.Rig)
+	if err != nil {
+		return err
+	}
+	return c.RecordTokenUsage(usage)
+}
+
+// RecordGitChange stores a git change record in its rig's database.
+func (p *PerRigCollector) RecordGitChange(change GitChange) error {
+	c, err := p.forRecord(change.Rig)
+	if err != nil {
+		return err
+	}
+	return c.RecordGitChange(change)
+}
+
+// RecordTestRun stores a test run and its results in the run's rig database.
+func (p *PerRigCollector) RecordTestRun(run TestRun) error {
+	c, err := p.forRecord(run.Rig)
+	if err != nil {
+		return err
+	}
+	return c.RecordTestRun(run)
+}
+
+// GetTokenUsage retrieves token usage records matching the filter.
+func (p *PerRigCollector) GetTokenUsage(filter TelemetryFilter) ([]TokenUsage, error) {
+	usage, err := fanOut(p.forRig(filter.Rig), func(c *SQLiteCollector) ([]TokenUsage, error) {
+		return c.GetTokenUsage(filter)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newestFirst(usage, func(u TokenUsage) string { return u.Timestamp }, filter.Limit), nil
+}
+
+// GetTokenSummary aggregates token usage statistics for the given filter.
+func (p *PerRigCollector) GetTokenSummary(filter TelemetryFilter) (TokenSummary, error) {
+	usage, err := p.GetTokenUsage(filter)
+	if err != nil {
+		return summarizeTokenUsage(nil), err
+	}
+	return summarizeTokenUsage(usage), nil
+}
+
+// GetTokenAnomalies flags anomalous agents in each rig. Agents are compared
+// against the other agents of their own rig.
+func (p *PerRigCollector) GetTokenAnomalies(since string) ([]TokenAnomaly, error) {
+	anomalies, err := fanOut(p.all(), func(c *SQLiteCollector) ([]TokenAnomaly, error) {
+		return c.GetTokenAnomalies(since)
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(anomalies, func(i, j int) bool {
+		return anomalies[i].Ratio > anomalies[j].Ratio
+	})
+	if anomalies == nil {
+		anomalies = []TokenAnomaly{}
+	}
+	return anomalies, nil
+}
+
+// GetGitChanges retrieves git change records matching the filter.
+func (p *PerRigCollector) GetGitChanges(filter TelemetryFilter) ([]GitChange, error) {
+	changes, err := fanOut(p.forRig(filter.Rig), func(c *SQLiteCollector) ([]GitChange, error) {
+		return c.GetGitChanges(filter)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newestFirst(changes, func(g GitChange) string { return g.Timestamp }, filter.Limit), nil
+}
+
+// GetGitSummary aggregates git change statistics for the given filter.
+func (p *PerRigCollector) GetGitSummary(filter TelemetryFilter) (GitSummary, error) {
+	changes, err := p.GetGitChanges(filter)
+	if err != nil {
+		return summarizeGitChanges(nil), err
+	}
+	return summarizeGitChanges(changes), nil
+}
+
+// GetTestRuns retrieves test run records matching the filter.
+func (p *PerRigCollector) GetTestRuns(filter TelemetryFilter) ([]TestRun, error) {
+	runs, err := fanOut(p.forRig(filter.Rig), func(c *SQLiteCollector) ([]TestRun, error) {
+		return c.GetTestRuns(filter)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newestFirst(runs, func(r TestRun) string { return r.Timestamp }, filter.Limit), nil
+}
+
+// GetTestSummary aggregates test result statistics for the given filter.
+func (p *PerRigCollector) GetTestSummary(filter TelemetryFilter) (TestSummary, error) {
+	filter.ExcludeResults = true
+	runs, err := p.GetTestRuns(filter)
+	if err != nil {
+		return summarizeTestRuns(nil), err
+	}
+	return summarizeTestRuns(runs), nil
+}
+
+// GetTestHistory returns chronological test results for a specific test across all rigs.
+func (p *PerRigCollector) GetTestHistory(testName string, limit int) ([]TestHistoryEntry, error) {
+	history, err := fanOut(p.all(), func(c *SQLiteCollector) ([]TestHistoryEntry, error) {
+		return c.GetTestHistory(testName, limit)
+	})
+	if err != nil {
+		return nil, err
+	}
+	history = newestFirst(history, func(e TestHistoryEntry) string { return e.Timestamp }, limit)
+	if history == nil {
+		history = []TestHistoryEntry{}
+	}
+	return history, nil
+}
+
+// GetLastPassedCommit returns the most recent commit SHA where the test passed in any rig.
+func (p *PerRigCollector) GetLastPassedCommit(testName string) (string, error) {
+	history, err := p.GetTestHistory(testName, 0)
+	if err != nil {
+		return "", err
+	}
+	for _, e := range history {
+		if e.Status == "passed" && e.CommitSHA != "" {
+			return e.CommitSHA, nil
+		}
+	}
+	return "", nil
+}
+
+// GetRegressions returns each rig's regressions since the given timestamp.
+// A test's history is only compared within its own rig.
+func (p *PerRigCollector) GetRegressions(since string) ([]TestRegression, error) {
+	regressions, err := fanOut(p.all(), func(c *SQLiteCollector) ([]TestRegression, error) {
+		return c.GetRegressions(since)
+	})
+	if err != nil {
+		return nil, err
+	}
+	regressions = newestFirst(regressions, func(r TestRegression) string { return r.FirstFailedAt }, 0)
+	if regressions == nil {
+		regressions = []TestRegression{}
+	}
+	return regressions, nil
+}
+
+// GetRegressionsByCommit returns regressions since the given timestamp grouped by
+// the commit where each test first failed.
+func (p *PerRigCollector) GetRegressionsByCommit(since string) (map[string][]TestRegression, error) {
+	regressions, err := p.GetRegressions(since)
+	if err != nil {
+		return nil, err
+	}
+	return GroupRegressionsByCommit(regressions), nil
+}
+
+// GetRegressionsWithOptions returns regressions filtered by opts, with flaky
+// tests judged within each rig.
+func (p *PerRigCollector) GetRegressionsWithOptions(opts RegressionOptions) ([]TestRegression, int, error) {
+	var regressions []TestRegression
+	suppressed := 0
+	for _, c := range p.all() {
+		part, n, err := c.GetRegressionsWithOptions(opts)
+		if err != nil {
+			return nil, 0, err
+		}
+		regressions = append(regressions, part...)
+		suppressed += n
+	}
+	regressions = newestFirst(regressions, func(r TestRegression) string { return r.FirstFailedAt }, 0)
+	if regressions == nil {
+		regressions = []TestRegression{}
+	}
+	return regressions, suppressed, nil
+}
+
+// GetTestSuiteStatus returns every rig's tests matching the filter, by test name.
+func (p *PerRigCollector) GetTestSuiteStatus(filter StatusFilter) ([]TestStatus, error) {
+	statuses, err := fanOut(p.all(), func(c *SQLiteCollector) ([]TestStatus, error) {
+		return c.GetTestSuiteStatus(filter)
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(statuses, func(i, j int) bool {
+		return statuses[i].TestName < statuses[j].TestName
+	})
+	if statuses == nil {
+		statuses = []TestStatus{}
+	}
+	return statuses, nil
+}
+
+// GetTestStatusAtCommit summarizes test results recorded at a commit in any rig.
+func (p *PerRigCollector) GetTestStatusAtCommit(commitSHA string) (CommitTestStatus, error) {
+	status := CommitTestStatus{CommitSHA: commitSHA}
+	for _, c := range p.all() {
+		part, err := c.GetTestStatusAtCommit(commitSHA)
+		if err != nil {
+			return status, err
+		}
+		status.Total += part.Total
+		status.Passed += part.Passed
+		status.Failed += part.Failed
+		status.Skipped += part.Skipped
+		status.Errored += part.Errored
+		status.Failing = append(status.Failing, part.Failing...)
+	}
+	sort.Strings(status.Failing)
+	return status, nil
+}
+
+// GetCommitActivity returns the git changes and test runs recorded for a
+// commit, with the test outcome at that commit.
+func (p *PerRigCollector) GetCommitActivity(commitSHA string) (CommitActivity, error) {
+	activity := CommitActivity{CommitSHA: commitSHA}
+	filter := TelemetryFilter{CommitSHA: commitSHA}
+
+	var err error
+	activity.GitChanges, err = p.GetGitChanges(filter)
+	if err != nil {
+		return activity, fmt.Errorf("get git changes: %w", err)
+	}
+	activity.TestRuns, err = p.GetTestRuns(filter)
+	if err != nil {
+		return activity, fmt.Errorf("get test runs: %w", err)
+	}
+	activity.Tests, err = p.GetTestStatusAtCommit(commitSHA)
+	if err != nil {
+		return activity, err
+	}
+
+	if activity.GitChanges == nil {
+		activity.GitChanges = []GitChange{}
+	}
+	if activity.TestRuns == nil {
+		activity.TestRuns = []TestRun{}
+	}
+	return activity, nil
+}
+
+// GetCoverageGaps returns bead commits with no test run at the same commit,
+// newest first. A commit counts as tested only by runs in its own rig.
+func (p *PerRigCollector) GetCoverageGaps(filter TelemetryFilter) ([]CoverageGap, error) {
+	gaps, err := fanOut(p.forRig(filter.Rig), func(c *SQLiteCollector) ([]CoverageGap, error) {
+		return c.GetCoverageGaps(filter)
+	})
+	if err != nil {
+		return nil, err
+	}
+	gaps = newestFirst(gaps, func(g CoverageGap) string { return g.Timestamp }, filter.Limit)
+	if gaps == nil {
+		gaps = []CoverageGap{}
+	}
+	return gaps, nil
+}
+
+// GetRegressionsAtCommit returns tests failing at the commit that last passed
+// at an earlier commit in the same rig.
+func (p *PerRigCollector) GetRegressionsAtCommit(commitSHA string) ([]TestRegression, error) {
+	regressions, err := fanOut(p.all(), func(c *SQLiteCollector) ([]TestRegression, error) {
+		return c.GetRegressionsAtCommit(commitSHA)
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(regressions, func(i, j int) bool {
+		return regressions[i].TestName < regressions[j].TestName
+	})
+	if regressions == nil {
+		regressions = []TestRegression{}
+	}
+	return regressions, nil
+}
+
+// GetBeadRegressions returns the regressions whose first failing commit is one
+// of the commits recorded for the bead, in any rig.
+func (p *PerRigCollector) GetBeadRegressions(beadID string) ([]TestRegression, error) {
+	changes, err := p.GetGitChanges(TelemetryFilter{BeadID: beadID})
+	if err != nil {
+		return nil, fmt.Errorf("get git changes: %w", err)
+	}
+	if len(changes) == 0 {
+		return []TestRegression{}, nil
+	}
+
+	commits := changeCommits(changes)
+	regressions, err := fanOut(p.all(), func(c *SQLiteCollector) ([]TestRegression, error) {
+		return c.regressionsFirstFailingAt(commits)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get regressions: %w", err)
+	}
+	regressions = newestFirst(regressions, func(r TestRegression) string { return r.FirstFailedAt }, 0)
+	if regressions == nil {
+		regressions = []TestRegression{}
+	}
+	return regressions, nil
+}
+
+// GetBeadTelemetry retrieves all telemetry data for a specific bead.
+func (p *PerRigCollector) GetBeadTelemetry(beadID string) (BeadTelemetry, error) {
+	filter := TelemetryFilter{BeadID: beadID}
+
+	bt := BeadTelemetry{BeadID: beadID}
+
+	var err error
+	bt.TokenUsage, err = p.GetTokenUsage(filter)
+	if err != nil {
+		return bt, fmt.Errorf("get token usage: %w", err)
+	}
+
+	bt.GitChanges, err = p.GetGitChanges(filter)
+	if err != nil {
+		return bt, fmt.Errorf("get git changes: %w", err)
+	}
+
+	bt.TestRuns, err = p.GetTestRuns(filter)
+	if err != nil {
+		return bt, fmt.Errorf("get test runs: %w", err)
+	}
+
+	bt.TokenSummary = summarizeTokenUsage(bt.TokenUsage)
+	bt.GitSummary = summarizeGitChanges(bt.GitChanges)
+	bt.TestSummary = summarizeTestRuns(bt.TestRuns)
+	bt.CostTimeline = buildCostTimeline(bt.TokenUsage, bt.GitChanges)
+
+	bt.Regressions, err = p.GetBeadRegressions(beadID)
+	if err != nil {
+		return bt, fmt.Errorf("get bead regressions: %w", err)
+	}
+
+	return bt, nil
+}
+
+// GetAgentTelemetry retrieves all telemetry data for a specific agent.
+func (p *PerRigCollector) GetAgentTelemetry(agentID string) (AgentTelemetry, error) {
+	filter := TelemetryFilter{AgentID: agentID}
+
+	at := AgentTelemetry{AgentID: agentID}
+
+	var err error
+	at.TokenUsage, err = p.GetTokenUsage(filter)
+	if err != nil {
+		return at, fmt.Errorf("get token usage: %w", err)
+	}
+
+	at.GitChanges, err = p.GetGitChanges(filter)
+	if err != nil {
+		return at, fmt.Errorf("get git changes: %w", err)
+	}
+
+	at.TestRuns, err = p.GetTestRuns(filter)
+	if err != nil {
+		return at, fmt.Errorf("get test runs: %w", err)
+	}
+
+	at.TokenSummary = summarizeTokenUsage(at.TokenUsage)
+	at.GitSummary = summarizeGitChanges(at.GitChanges)
+	at.TestSummary = summarizeTestRuns(at.TestRuns)
+
+	return at, nil
+}
+
+// GetAgentTestHealth merges the agent's test health from every rig. Top failed
+// tests are re-ranked from each rig's own top list.
+func (p *PerRigCollector) GetAgentTestHealth(agentID string) (AgentTestHealth, error) {
+	health := AgentTestHealth{AgentID: agentID, TopFailedTests: []FailedTestCount{}}
+
+	type testKey struct{ name, file string }
+	failures := make(map[testKey]int)
+	for _, c := range p.all() {
+		part, err := c.GetAgentTestHealth(agentID)
+		if err != nil {
+			return health, err
+		}
+		health.TotalResults += part.TotalResults
+		health.Passed += part.Passed
+		health.Failed += part.Failed
+		health.RegressionCount += part.RegressionCount
+		for _, f := range part.TopFailedTests {
+			failures[testKey{f.TestName, f.TestFile}] += f.Failures
+		}
+	}
+	if ran := health.Passed + health.Failed; ran > 0 {
+		health.PassRate = float64(health.Passed) / float64(ran)
+	}
+
+	for k, n := range failures {
+		health.TopFailedTests = append(health.TopFailedTests, FailedTestCount{TestName: k.name, TestFile: k.file, Failures: n})
+	}
+	sort.Slice(health.TopFailedTests, func(i, j int) bool {
+		a, b := health.TopFailedTests[i], health.TopFailedTests[j]
+		if a.Failures != b.Failures {
+			return a.Failures > b.Failures
+		}
+		return a.TestName < b.TestName
+	})
+	if len(health.TopFailedTests) > agentTopFailedLimit {
+		health.TopFailedTests = health.TopFailedTests[:agentTopFailedLimit]
+	}
+
+	return health, nil
+}
+
+// SetBeadBudget creates or replaces the budget for a bead in the shared database.
+func (p *PerRigCollector) SetBeadBudget(budget BeadBudget) error {
+	return p.shared.SetBeadBudget(budget)
+}
+
+// GetBeadBudget returns the budget configured for a bead, or nil if none is set.
+func (p *PerRigCollector) GetBeadBudget(beadID string) (*BeadBudget, error) {
+	return p.shared.GetBeadBudget(beadID)
+}
+
+// CheckBeadBudget compares the bead's token cost across all rigs against budgetUSD.
+func (p *PerRigCollector) CheckBeadBudget(beadID string, budgetUSD float64) (BudgetStatus, error) {
+	summary, err := p.GetTokenSummary(TelemetryFilter{BeadID: beadID})
+	if err != nil {
+		return BudgetStatus{BeadID: beadID, BudgetUSD: budgetUSD}, fmt.Errorf("get token summary: %w", err)
+	}
+
+	budget, err := p.GetBeadBudget(beadID)
+	if err != nil {
+		return BudgetStatus{BeadID: beadID, BudgetUSD: budgetUSD}, err
+	}
+	return budgetStatus(beadID, budgetUSD, summary.TotalCostUSD, budget), nil
+}
+
+// MarkBudgetAlerted records that a bead's spend reached its alert threshold.
+func (p *PerRigCollector) MarkBudgetAlerted(beadID string) (bool, error) {
+	return p.shared.MarkBudgetAlerted(beadID)
+}
+
+// MarkBudgetExceeded records that a bead's budget was exceeded.
+func (p *PerRigCollector) MarkBudgetExceeded(beadID string) (bool, error) {
+	return p.shared.MarkBudgetExceeded(beadID)
+}
+
+// commitCollector returns the collector that recorded a commit's git change
+// and the commit's bead, or nil when no rig recorded it.
+func (p *PerRigCollector) commitCollector(commitSHA string) (*SQLiteCollector, string, error) {
+	for _, c := range p.all() {
+		_, beadID, _, err := c.commitChange(commitSHA)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("query commit: %w", err)
+		}
+		return c, beadID, nil
+	}
+	return nil, "", nil
+}
+
+// GetCommitCost estimates the token cost of producing a commit from the
+// database of the rig that recorded it. Unrecorded commits cost 0.
+func (p *PerRigCollector) GetCommitCost(commitSHA string) (float64, error) {
+	c, _, err := p.commitCollector(commitSHA)
+	if err != nil || c == nil {
+		return 0, err
+	}
+	return c.GetCommitCost(commitSHA)
+}
+
+// GetCommitGate decides whether a commit is mergeable: tests were recorded and
+// all pass, nothing regressed, and the commit's bead (if budgeted) is within budget.
+func (p *PerRigCollector) GetCommitGate(commitSHA string) (CommitGate, error) {
+	gate := CommitGate{CommitSHA: commitSHA}
+
+	var err error
+	gate.Tests, err = p.GetTestStatusAtCommit(commitSHA)
+	if err != nil {
+		return gate, err
+	}
+	gate.Regressions, err = p.GetRegressionsAtCommit(commitSHA)
+	if err != nil {
+		return gate, err
+	}
+
+	c, beadID, err := p.commitCollector(commitSHA)
+	if err != nil {
+		return gate, err
+	}
+	if c != nil {
+		gate.CostUSD, err = c.GetCommitCost(commitSHA)
+		if err != nil {
+			return gate, err
+		}
+	}
+	if beadID != "" {
+		budget, err := p.GetBeadBudget(beadID)
+		if err != nil {
+			return gate, err
+		}
+		if budget != nil {
+			status, err := p.CheckBeadBudget(beadID, budget.BudgetUSD)
+			if err != nil {
+				return gate, err
+			}
+			gate.Budget = &status
+		}
+	}
+
+	judgeCommitGate(&gate)
+	return gate, nil
+}
+
+// RecordConvoyProgress stores a convoy progress snapshot in the shared database.
+func (p *PerRigCollector) RecordConvoyProgress(snapshot ProgressSnapshot) error {
+	return p.shared.RecordConvoyProgress(snapshot)
+}
+
+// GetConvoyProgressHistory returns a convoy's progress snapshots in a rig, oldest first.
+func (p *PerRigCollector) GetConvoyProgressHistory(rig, convoyID string) ([]ProgressSnapshot, error) {
+	return p.shared.GetConvoyProgressHistory(rig, convoyID)
+}
+
+// EstimateConvoyCompletion projects when a convoy will finish.
+func (p *PerRigCollector) EstimateConvoyCompletion(rig, convoyID string) (*ConvoyETA, error) {
+	return p.shared.EstimateConvoyCompletion(rig, convoyID)
+}
@@ -0,0 +1,49 @@
+package telemetry
+
+import "strings"
+
+// ModelPrice is the USD cost per million input and output tokens for a model family.
+type ModelPrice struct {
+	InputPerMillion  float64
+	OutputPerMillion float64
+}
+
+// modelPrices lists pricing by model family substring, longest family first.
+// Models are matched by substring so dated model IDs resolve to their family;
+// the first match wins, so a more specific family must precede any family it
+// contains.
+var modelPrices = []struct {
+	family string
+	price  ModelPrice
+}{
+	{"sonnet", ModelPrice{InputPerMillion: 3, OutputPerMillion: 15}},
+	{"haiku", ModelPrice{InputPerMillion: 1, OutputPerMillion: 5}},
+	{"opus", ModelPrice{InputPerMillion: 5, OutputPerMillion: 25}},
+}
+
+// defaultModelPrice is used for models that match no known family.
+var defaultModelPrice = ModelPrice{InputPerMillion: 3, OutputPerMillion: 15}
+
+// lookupModelPrice returns the price of the first family the model matches.
+func lookupModelPrice(model string) (ModelPrice, bool) {
+	lower := strings.ToLower(model)
+	for _, p := range modelPrices {
+		if strings.Contains(lower, p.family) {
+			return p.price, true
+		}
+	}
+	return defaultModelPrice, false
+}
+
+// KnownModel reports whether a model matches a priced model family, rather
+// than falling back to the default price.
+func KnownModel(model string) bool {
+	_, ok := lookupModelPrice(model)
+	return ok
+}
+
+// EstimateCostUSD returns the estimated USD cost of the given token counts for a model.
+func EstimateCostUSD(model string, inputTokens, outputTokens int) float64 {
+	price, _ := lookupModelPrice(model)
+	return float64(inputTokens)/1e6*price.InputPerMillion + float64(outputTokens)/1e6*price.OutputPerMillion
+}
@@ -0,0 +1,137 @@
+package telemetry
+
+import (
+	"sort"
+	"time"
+)
+
+// costDayLayout is the bucket key of a CostBucket: one bucket per UTC day.
+const costDayLayout = "2006-01-02"
+
+// maxPaddedCostDays caps the span a cost timeline fills with zero buckets, so
+// one stray timestamp years away can't produce a bucket for every day between.
+const maxPaddedCostDays = 366
+
+// CostBucket is the token spend on a bead during one UTC day.
+type CostBucket struct {
+	Day               string  `json:"day"` // YYYY-MM-DD
+	InputTokens       int     `json:"input_tokens"`
+	OutputTokens      int     `json:"output_tokens"`
+	CostUSD           float64 `json:"cost_usd"`
+	CumulativeCostUSD float64 `json:"cumulative_cost_usd"`
+}
+
+// CommitAnnotation marks a commit on a cost timeline.
+type CommitAnnotation struct {
+	Timestamp string `json:"timestamp"`
+	Day       string `json:"day"` // the CostBucket the commit falls in
+	CommitSHA string `json:"commit_sha"`
+	AgentID   string `json:"agent_id"`
+	Message   string `json:"message,omitempty"`
+}
+
+// CostTimeline is how a bead's spend accrued over the days it was worked,
+// with its commits placed on the same axis.
+type CostTimeline struct {
+	Buckets []CostBucket       `json:"buckets"` // oldest first, with zero buckets filling idle days unless Sparse
+	Commits []CommitAnnotation `json:"commits"` // oldest first
+
+	// Sparse is set when the span exceeds maxPaddedCostDays; only days with
+	// usage or commits then have a bucket
+	Sparse bool `json:"sparse,omitempty"`
+}
+
+// buildCostTimeline buckets token usage by UTC day and annotates it with
+// commits. Records with unparseable timestamps are left out.
+func buildCostTimeline(usage []TokenUsage, changes []GitChange) CostTimeline {
+	timeline := CostTimeline{
+		Buckets: []CostBucket{},
+		Commits: []CommitAnnotation{},
+	}
+
+	var first, last time.Time
+	seen := false
+	extend := func(day time.Time) {
+		if !seen || day.Before(first) {
+			first = day
+		}
+		if !seen || day.After(last) {
+			last = day
+		}
+		seen = true
+	}
+
+	byDay := map[string]*CostBucket{}
+	for _, u := range usage {
+		ts, err := time.Parse(time.RFC3339, u.Timestamp)
+		if err != nil {
+			continue
+		}
+		day := ts.UTC().Truncate(24 * time.Hour)
+		extend(day)
+
+		key := day.Format(costDayLayout)
+		b, ok := byDay[key]
+		if !ok {
+			b = &CostBucket{Day: key}
+			byDay[key] = b
+		}
+		b.InputTokens += u.InputTokens
+		b.OutputTokens += u.OutputTokens
+		b.CostUSD += EstimateCostUSD(u.Model, u.InputTokens, u.OutputTokens)
+	}
+
+	for _, g := range changes {
+		ts, err := time.Parse(time.RFC3339, g.Timestamp)
+		if err != nil {
+			continue
+		}
+		day := ts.UTC().Truncate(24 * time.Hour)
+		extend(day)
+
+		key := day.Format(costDayLayout)
+		if _, ok := byDay[key]; !ok {
+			byDay[key] = &CostBucket{Day: key}
+		}
+		timeline.Commits = append(timeline.Commits, CommitAnnotation{
+			Timestamp: g.Timestamp,
+			Day:       key,
+			CommitSHA: g.CommitSHA,
+			AgentID:   g.AgentID,
+			Message:   g.Message,
+		})
+	}
+	sort.SliceStable(timeline.Commits, func(i, j int) bool {
+		return timeline.Commits[i].Timestamp < timeline.Commits[j].Timestamp
+	})
+
+	if !seen {
+		return timeline
+	}
+
+	var days []string
+	if last.Sub(first) > maxPaddedCostDays*24*time.Hour {
+		timeline.Sparse = true
+		for key := range byDay {
+			days = append(days, key)
+		}
+		sort.Strings(days)
+	} else {
+		for day := first; !day.After(last); day = day.Add(24 * time.Hour) {
+			days = append(days, day.Format(costDayLayout))
+		}
+	}
+
+	var cumulative float64
+	for _, key := range days {
+		b := CostBucket{Day: key}
+		if found, ok := byDay[key]; ok {
+			b = *found
+		}
+		cumulative += b.CostUSD
+		b.CumulativeCostUSD = cumulative
+		timeline.Buckets = append(timeline.Buckets, b)
+	}
+
+	return timeline
+}
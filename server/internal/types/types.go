@@ -1,37 +1,46 @@
 // Package types defines shared data types for Town View.
 package types
 
-import "time"
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gastown/townview/internal/registry"
+)
 
 // Issue represents a bead issue.
 type Issue struct {
-	ID              string             `json:"id"`
-	Title           string             `json:"title"`
-	Description     string             `json:"description"`
-	Status          string             `json:"status"`
-	Priority        int                `json:"priority"`
-	IssueType       string             `json:"issue_type"`
-	Owner           string             `json:"owner,omitempty"`
-	Assignee        string             `json:"assignee,omitempty"`
-	CreatedAt       time.Time          `json:"created_at"`
-	CreatedBy       string             `json:"created_by,omitempty"`
-	UpdatedAt       time.Time          `json:"updated_at"`
-	ClosedAt        *time.Time         `json:"closed_at,omitempty"`
-	CloseReason     string             `json:"close_reason,omitempty"`
-	Labels          []string           `json:"labels,omitempty"`
-	DependencyCount int                `json:"dependency_count"`
-	DependentCount  int                `json:"dependent_count"`
-	Dependencies    []IssueDependency  `json:"dependencies,omitempty"` // Raw dependencies (for convoys)
-	Parent          string             `json:"parent,omitempty"`
-	Convoy          *ConvoyInfo        `json:"convoy,omitempty"`
-	RigID           string             `json:"rig_id,omitempty"` // Set by server for WebSocket grouping
+	ID              string            `json:"id"`
+	Title           string            `json:"title"`
+	Description     string            `json:"description"`
+	Status          string            `json:"status"`
+	Blocked         bool              `json:"blocked"` // Computed: a blocks dependency is still open
+	Priority        int               `json:"priority"`
+	IssueType       string            `json:"issue_type"`
+	Owner           string            `json:"owner,omitempty"`
+	Assignee        string            `json:"assignee,omitempty"`
+	CreatedAt       time.Time         `json:"created_at"`
+	CreatedBy       string            `json:"created_by,omitempty"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+	ClosedAt        *time.Time        `json:"closed_at,omitempty"`
+	CloseReason     string            `json:"close_reason,omitempty"`
+	Labels          []string          `json:"labels,omitempty"`
+	DependencyCount int               `json:"dependency_count"`
+	DependentCount  int               `json:"dependent_count"`
+	Dependencies    []IssueDependency `json:"dependencies,omitempty"` // Raw dependencies (for convoys)
+	Parent          string            `json:"parent,omitempty"`
+	Convoy          *ConvoyInfo       `json:"convoy,omitempty"`
+	RigID           string            `json:"rig_id,omitempty"` // Set by server for WebSocket grouping
 }
 
 // Dependency represents a dependency relationship between issues.
 type Dependency struct {
-	FromID   string `json:"from_id"`
-	ToID     string `json:"to_id"`
-	Type     string `json:"type"` // "blocks", "parent-child"
+	FromID string `json:"from_id"`
+	ToID   string `json:"to_id"`
+	Type   string `json:"type"` // "blocks", "parent-child"
 }
 
 // IssueDependency represents a raw dependency entry from beads.
@@ -54,49 +63,185 @@ type DependencyAdd struct {
 	BlockerID string `json:"blocker_id"` // The issue that blocks
 }
 
+// DependencyEdge is one edge in a batch dependency-add request.
+type DependencyEdge struct {
+	IssueID   string `json:"issue_id"`
+	BlockerID string `json:"blocker_id"`
+	Type      string `json:"type,omitempty"` // "blocks" (default), "tracks", "parent-child"
+}
+
+// DependencyEdgeResult reports the outcome of one edge in a batch.
+type DependencyEdgeResult struct {
+	DependencyEdge
+	Status string `json:"status"` // "added", "invalid", "cycle", "failed"
+	Error  string `json:"error,omitempty"`
+}
+
+// DependencyBatchResult is the response for a batch dependency-add request.
+type DependencyBatchResult struct {
+	Results []DependencyEdgeResult `json:"results"`
+	Added   int                    `json:"added"`
+	Failed  int                    `json:"failed"`
+}
+
 // AgentHealth represents health status for sidebar indicators.
 // nil means the role doesn't exist for this rig.
+// Agents missing heartbeats show as "degraded" or "unhealthy" instead.
 type AgentHealth struct {
-	Witness  *string `json:"witness"`  // nil, "idle", "working", "stuck", "paused"
-	Refinery *string `json:"refinery"` // nil, "idle", "working", "stuck", "paused"
-	Crew     *string `json:"crew"`     // nil, "idle", "working", "stuck", "paused"
+	Witness  *string `json:"witness"`  // nil, "idle", "working", "stuck", "paused", "degraded", "unhealthy"
+	Refinery *string `json:"refinery"` // nil, "idle", "working", "stuck", "paused", "degraded", "unhealthy"
+	Crew     *string `json:"crew"`     // nil, "idle", "working", "stuck", "paused", "degraded", "unhealthy"
 }
 
 // Rig represents a Gas Town rig.
 type Rig struct {
-	ID          string       `json:"id"`
-	Name        string       `json:"name"`
-	Prefix      string       `json:"prefix"`
-	Path        string       `json:"path"`
-	BeadsPath   string       `json:"beads_path"`
-	IssueCount  int          `json:"issue_count"`
-	OpenCount   int          `json:"open_count"`
-	AgentCount  int          `json:"agent_count"`
-	AgentHealth *AgentHealth `json:"agent_health,omitempty"`
+	ID           string                     `json:"id"`
+	Name         string                     `json:"name"`
+	Prefix       string                     `json:"prefix"`
+	Path         string                     `json:"path"`
+	BeadsPath    string                     `json:"beads_path"`
+	IssueCount   int                        `json:"issue_count"`
+	OpenCount    int                        `json:"open_count"`
+	AgentCount   int                        `json:"agent_count"`
+	AgentHealth  *AgentHealth               `json:"agent_health,omitempty"`
+	RoleCounts   map[registry.AgentRole]int `json:"role_counts,omitempty"` // Agents per role, e.g. {"polecat": 5, "crew": 2}
+	RecentEvents int                        `json:"recent_events"`         // Events in the last hour
+
+	// Whether the rig's database answered its last query; aggregates skip a
+	// failing rig, so this is how operators see it dropped out
+	DBHealth *RigDBHealth `json:"db_health,omitempty"`
+}
+
+// Rig database health statuses.
+const (
+	RigDBOK          = "ok"
+	RigDBError       = "error"       // The last query failed
+	RigDBUnavailable = "unavailable" // The rig has no query service
+)
+
+// RigDBHealth reports how a rig's database last responded.
+type RigDBHealth struct {
+	Status      string     `json:"status"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
 }
 
 // Agent represents a Gas Town agent.
 type Agent struct {
-	ID             string     `json:"id"`
-	Name           string     `json:"name"`
-	RoleType       string     `json:"role_type"`
-	Rig            string     `json:"rig"`
-	State          string     `json:"state"`
-	HookBead       string     `json:"hook_bead,omitempty"`
-	UpdatedAt      time.Time  `json:"updated_at"`
-	LastActivityAt *time.Time `json:"last_activity_at,omitempty"`
+	ID                  string            `json:"id"`
+	Name                string            `json:"name"`
+	RoleType            string            `json:"role_type"`
+	Rig                 string            `json:"rig"`
+	State               string            `json:"state"`
+	HookBead            string            `json:"hook_bead,omitempty"`
+	CurrentBeadStarted  *time.Time        `json:"current_bead_started,omitempty"`  // When work on HookBead started
+	BeadDurationSeconds int64             `json:"bead_duration_seconds,omitempty"` // Time since CurrentBeadStarted
+	UpdatedAt           time.Time         `json:"updated_at"`
+	LastActivityAt      *time.Time        `json:"last_activity_at,omitempty"`
+	Labels              map[string]string `json:"labels,omitempty"`
+
+	// False while the agent's state is inferred from discovery only
+	HasHeartbeated bool `json:"has_heartbeated"`
+
+	// When the agent last reported its own heartbeat; UpdatedAt also moves on
+	// discovery refreshes
+	LastSelfHeartbeat *time.Time `json:"last_self_heartbeat,omitempty"`
+}
+
+// StuckAgent is an agent in stuck status with how long it has been stuck.
+type StuckAgent struct {
+	Agent
+	StuckReason          string    `json:"stuck_reason,omitempty"`
+	StuckSince           time.Time `json:"stuck_since"`
+	StuckDurationSeconds int64     `json:"stuck_duration_seconds"`
+}
+
+// AgentList is an agent list response with an optional per-role health roll-up.
+type AgentList struct {
+	Agents []Agent      `json:"agents"`
+	Health *AgentHealth `json:"health,omitempty"`
 }
 
 // IssueUpdate represents a partial update to an issue.
 type IssueUpdate struct {
 	Status      *string   `json:"status,omitempty"`
-	Priority    *int      `json:"priority,omitempty"`
+	Priority    *Priority `json:"priority,omitempty"`
 	Title       *string   `json:"title,omitempty"`
 	Description *string   `json:"description,omitempty"`
 	Assignee    *string   `json:"assignee,omitempty"`
 	Labels      *[]string `json:"labels,omitempty"`
 }
 
+// Priority bounds; 0 is the most urgent.
+const (
+	PriorityMin = 0
+	PriorityMax = 4
+)
+
+// Priority is an issue priority accepted as a number (1), a numeric string ("1"),
+// or the bd shorthand ("P1"). Range is checked by Valid, not during decoding.
+type Priority int
+
+// UnmarshalJSON accepts numbers, numeric strings and "P<n>" strings.
+func (p *Priority) UnmarshalJSON(data []byte) error {
+	var n int
+	if err := json.Unmarshal(data, &n); err == nil {
+		*p = Priority(n)
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("priority must be a number or string")
+	}
+	s = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(s)), "P")
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("invalid priority %q", s)
+	}
+	*p = Priority(n)
+	return nil
+}
+
+// Valid reports whether p is within PriorityMin..PriorityMax.
+func (p Priority) Valid() bool {
+	return p >= PriorityMin && p <= PriorityMax
+}
+
+// IssueCreate represents a request to create an issue. With ?template= the
+// description is rendered from the template using Variables.
+type IssueCreate struct {
+	Title       string            `json:"title"`
+	Description string            `json:"description,omitempty"`
+	Type        string            `json:"type,omitempty"` // default: task
+	Priority    *Priority         `json:"priority,omitempty"`
+	Assignee    string            `json:"assignee,omitempty"`
+	Labels      []string          `json:"labels,omitempty"`
+	Variables   map[string]string `json:"variables,omitempty"`
+}
+
+// IssueTemplate is a named description boilerplate for new issues.
+type IssueTemplate struct {
+	Name      string   `json:"name"`
+	Variables []string `json:"variables"` // {{name}} placeholders in Body
+	Body      string   `json:"body"`
+}
+
+// IssueMove represents a request to move an issue to another rig.
+type IssueMove struct {
+	TargetRig string `json:"target_rig"`
+}
+
+// IssueMoveResult describes a completed move. Warnings lists the status and
+// dependency edges that could not be carried over to the copy.
+type IssueMoveResult struct {
+	SourceRig string   `json:"source_rig"`
+	SourceID  string   `json:"source_id"`
+	TargetRig string   `json:"target_rig"`
+	TargetID  string   `json:"target_id"`
+	Warnings  []string `json:"warnings,omitempty"`
+}
+
 // WSMessage represents a Server-Sent Events message.
 type WSMessage struct {
 	Type    string      `json:"type"`
@@ -0,0 +1,69 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestPriority_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		want      Priority
+		wantValid bool
+		wantErr   bool
+	}{
+		{name: "number", input: `1`, want: 1, wantValid: true},
+		{name: "numeric string", input: `"3"`, want: 3, wantValid: true},
+		{name: "shorthand", input: `"P1"`, want: 1, wantValid: true},
+		{name: "lowercase shorthand with spaces", input: `" p0 "`, want: 0, wantValid: true},
+		{name: "highest", input: `"P4"`, want: 4, wantValid: true},
+		{name: "above range", input: `5`, want: 5, wantValid: false},
+		{name: "below range", input: `-1`, want: -1, wantValid: false},
+		{name: "shorthand above range", input: `"P9"`, want: 9, wantValid: false},
+		{name: "word", input: `"high"`, wantErr: true},
+		{name: "bare prefix", input: `"P"`, wantErr: true},
+		{name: "fraction", input: `1.5`, wantErr: true},
+		{name: "bool", input: `true`, wantErr: true},
+		{name: "object", input: `{"p":1}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p Priority
+			err := json.Unmarshal([]byte(tt.input), &p)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected an error, got %d", p)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if p != tt.want {
+				t.Errorf("got %d, want %d", p, tt.want)
+			}
+			if p.Valid() != tt.wantValid {
+				t.Errorf("Valid() = %v, want %v", p.Valid(), tt.wantValid)
+			}
+		})
+	}
+}
+
+func TestIssueCreate_Priority(t *testing.T) {
+	var withPriority IssueCreate
+	if err := json.Unmarshal([]byte(`{"title":"t","priority":"P2"}`), &withPriority); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if withPriority.Priority == nil || *withPriority.Priority != 2 {
+		t.Errorf("expected priority 2, got %v", withPriority.Priority)
+	}
+
+	var without IssueCreate
+	if err := json.Unmarshal([]byte(`{"title":"t"}`), &without); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if without.Priority != nil {
+		t.Errorf("expected no priority, got %d", *without.Priority)
+	}
+}
@@ -26,6 +26,14 @@ const (
 // ClientMessage represents a message from the client.
 type ClientMessage struct {
 	Type string `json:"type"`
+	Rig  string `json:"rig,omitempty"` // For subscribe/unsubscribe
+}
+
+// ReplyMessage is sent back to the one client whose message it answers.
+type ReplyMessage struct {
+	Type    string `json:"type"`              // subscribed or error
+	Rig     string `json:"rig,omitempty"`     // For subscribed
+	Message string `json:"message,omitempty"` // For error
 }
 
 // Client represents a WebSocket client connection.
@@ -38,6 +46,11 @@ type Client struct {
 	closeMu sync.RWMutex
 	// closed is true when the send channel has been closed
 	closed bool
+
+	// Mutex protecting rigs
+	rigsMu sync.RWMutex
+	// rigs the client subscribed to; empty means all rigs
+	rigs map[string]bool
 }
 
 // NewClient creates a new Client instance.
@@ -68,6 +81,36 @@ func (c *Client) Send(message []byte) bool {
 	}
 }
 
+// WantsRig reports whether rig-scoped messages for rig should go to this client.
+// Clients with no subscriptions receive every rig; messages with no rig go to all.
+func (c *Client) WantsRig(rig string) bool {
+	if rig == "" {
+		return true
+	}
+	c.rigsMu.RLock()
+	defer c.rigsMu.RUnlock()
+	return len(c.rigs) == 0 || c.rigs[rig]
+}
+
+// subscribe limits rig-scoped messages to the given rig (plus any others
+// already subscribed).
+func (c *Client) subscribe(rig string) {
+	c.rigsMu.Lock()
+	defer c.rigsMu.Unlock()
+	if c.rigs == nil {
+		c.rigs = make(map[string]bool)
+	}
+	c.rigs[rig] = true
+}
+
+// unsubscribe drops a rig subscription. Dropping the last one returns the
+// client to receiving every rig.
+func (c *Client) unsubscribe(rig string) {
+	c.rigsMu.Lock()
+	defer c.rigsMu.Unlock()
+	delete(c.rigs, rig)
+}
+
 // Close marks the client as closed and closes the send channel.
 // Safe to call multiple times.
 func (c *Client) Close() {
@@ -107,6 +150,7 @@ func (c *Client) ReadPump() {
 		var msg ClientMessage
 		if err := json.Unmarshal(message, &msg); err != nil {
 			slog.Debug("Failed to parse client message", "error", err)
+			c.replyError("malformed message: expected a JSON object with a type")
 			continue
 		}
 
@@ -116,14 +160,44 @@ func (c *Client) ReadPump() {
 			// Client requested a refresh - trigger broadcast
 			c.hub.TriggerBroadcast()
 		case "subscribe", "unsubscribe":
-			// Future: handle topic subscriptions
-			slog.Debug("Subscription message received", "type", msg.Type)
+			if msg.Rig == "" {
+				slog.Debug("Subscription message without rig", "type", msg.Type)
+				c.replyError(msg.Type + " requires a rig")
+				continue
+			}
+			if msg.Type == "subscribe" {
+				if !c.hub.rigExists(msg.Rig) {
+					c.replyError("unknown rig: " + msg.Rig)
+					continue
+				}
+				c.subscribe(msg.Rig)
+				c.reply(ReplyMessage{Type: "subscribed", Rig: msg.Rig})
+			} else {
+				c.unsubscribe(msg.Rig)
+			}
+			slog.Debug("Subscription updated", "type", msg.Type, "rig", msg.Rig)
 		default:
 			slog.Debug("Unknown message type", "type", msg.Type)
+			c.replyError("unknown message type: " + msg.Type)
 		}
 	}
 }
 
+// reply queues a message for this client only.
+func (c *Client) reply(msg ReplyMessage) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		slog.Error("Failed to marshal WebSocket reply", "error", err)
+		return
+	}
+	c.Send(data)
+}
+
+// replyError tells this client its last message was rejected.
+func (c *Client) replyError(message string) {
+	c.reply(ReplyMessage{Type: "error", Message: message})
+}
+
 // WritePump pumps messages from the hub to the WebSocket connection.
 func (c *Client) WritePump() {
 	ticker := time.NewTicker(pingPeriod)
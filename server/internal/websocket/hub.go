@@ -26,6 +26,12 @@ type Hub struct {
 	// Snapshot provider function
 	snapshotProvider func() ([]byte, error)
 
+	// Optional provider for the first frame sent to a new client
+	initialProvider func() ([]byte, error)
+
+	// Optional check that a rig exists before a subscription is confirmed
+	rigValidator func(rig string) bool
+
 	// Broadcast interval
 	broadcastInterval time.Duration
 
@@ -60,6 +66,10 @@ func (h *Hub) Run() {
 	for {
 		select {
 		case client := <-h.register:
+			// Queue the initial frame before the client can receive broadcasts,
+			// so it always arrives first.
+			h.sendInitialToClient(client)
+
 			h.mu.Lock()
 			h.clients[client] = true
 			h.mu.Unlock()
@@ -88,10 +98,24 @@ func (h *Hub) Run() {
 
 // broadcastMessage sends a message to all connected clients.
 func (h *Hub) broadcastMessage(message []byte) {
+	h.sendTo(message, func(*Client) bool { return true })
+}
+
+// Publish sends a rig-scoped message to the clients subscribed to that rig.
+// An empty rig reaches every client.
+func (h *Hub) Publish(rig string, message []byte) {
+	h.sendTo(message, func(c *Client) bool { return c.WantsRig(rig) })
+}
+
+// sendTo sends a message to the connected clients accepted by want,
+// dropping clients that can't keep up.
+func (h *Hub) sendTo(message []byte, want func(*Client) bool) {
 	h.mu.RLock()
 	clients := make([]*Client, 0, len(h.clients))
 	for client := range h.clients {
-		clients = append(clients, client)
+		if want(client) {
+			clients = append(clients, client)
+		}
 	}
 	h.mu.RUnlock()
 
@@ -131,6 +155,39 @@ func (h *Hub) broadcastSnapshot() {
 	h.broadcastMessage(snapshot)
 }
 
+// SetInitialProvider sets a provider for a lightweight frame sent to each new
+// client before any other message. Call before Run.
+func (h *Hub) SetInitialProvider(provider func() ([]byte, error)) {
+	h.initialProvider = provider
+}
+
+// SetRigValidator sets the check subscribe requests are validated with; rigs
+// it rejects get an error reply instead of an ack. Without one every rig is
+// accepted. Call before Run.
+func (h *Hub) SetRigValidator(validator func(rig string) bool) {
+	h.rigValidator = validator
+}
+
+// rigExists reports whether a client may subscribe to rig.
+func (h *Hub) rigExists(rig string) bool {
+	return h.rigValidator == nil || h.rigValidator(rig)
+}
+
+// sendInitialToClient queues the initial frame for a new client.
+func (h *Hub) sendInitialToClient(client *Client) {
+	if h.initialProvider == nil {
+		return
+	}
+
+	message, err := h.initialProvider()
+	if err != nil {
+		slog.Error("Failed to get initial frame for new client", "error", err)
+		return
+	}
+
+	client.Send(message)
+}
+
 // sendSnapshotToClient sends current snapshot to a specific client.
 func (h *Hub) sendSnapshotToClient(client *Client) {
 	if h.snapshotProvider == nil {
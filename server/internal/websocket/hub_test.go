@@ -0,0 +1,180 @@
+package websocket
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// dialTestHub starts hub and serves it over a test server, returning a
+// connected client connection.
+func dialTestHub(t *testing.T, hub *Hub) *websocket.Conn {
+	t.Helper()
+	go hub.Run()
+
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("failed to upgrade: %v", err)
+			return
+		}
+		client := NewClient(hub, conn)
+		hub.Register(client)
+		go client.WritePump()
+		go client.ReadPump()
+	}))
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("failed to dial hub: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// readReply reads the next message from conn as a reply.
+func readReply(t *testing.T, conn *websocket.Conn) ReplyMessage {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("failed to read reply: %v", err)
+	}
+	var reply ReplyMessage
+	if err := json.Unmarshal(data, &reply); err != nil {
+		t.Fatalf("failed to decode reply %q: %v", data, err)
+	}
+	return reply
+}
+
+func TestClient_RepliesToMessages(t *testing.T) {
+	hub := NewHub(nil)
+	hub.SetRigValidator(func(rig string) bool { return rig == "rig-a" })
+	conn := dialTestHub(t, hub)
+
+	tests := []struct {
+		name    string
+		message string
+		want    ReplyMessage
+	}{
+		{"subscribe to known rig", `{"type":"subscribe","rig":"rig-a"}`, ReplyMessage{Type: "subscribed", Rig: "rig-a"}},
+		{"subscribe to unknown rig", `{"type":"subscribe","rig":"rig-x"}`, ReplyMessage{Type: "error", Message: "unknown rig: rig-x"}},
+		{"subscribe without rig", `{"type":"subscribe"}`, ReplyMessage{Type: "error", Message: "subscribe requires a rig"}},
+		{"malformed JSON", `{"type":`, ReplyMessage{Type: "error", Message: "malformed message: expected a JSON object with a type"}},
+		{"not an object", `["subscribe"]`, ReplyMessage{Type: "error", Message: "malformed message: expected a JSON object with a type"}},
+		{"unknown type", `{"type":"dance"}`, ReplyMessage{Type: "error", Message: "unknown message type: dance"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(tt.message)); err != nil {
+				t.Fatalf("failed to send message: %v", err)
+			}
+			if got := readReply(t, conn); got != tt.want {
+				t.Errorf("expected reply %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestClient_SubscribeLimitsPublishedRigs(t *testing.T) {
+	hub := NewHub(nil)
+	hub.SetRigValidator(func(rig string) bool { return rig == "rig-a" })
+	conn := dialTestHub(t, hub)
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"subscribe","rig":"rig-a"}`)); err != nil {
+		t.Fatalf("failed to send message: %v", err)
+	}
+	if got := readReply(t, conn); got.Type != "subscribed" {
+		t.Fatalf("expected a subscribe ack, got %+v", got)
+	}
+	// A rejected subscription leaves the client scoped to rig-a
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"subscribe","rig":"rig-x"}`)); err != nil {
+		t.Fatalf("failed to send message: %v", err)
+	}
+	if got := readReply(t, conn); got.Type != "error" {
+		t.Fatalf("expected an error reply, got %+v", got)
+	}
+
+	hub.Publish("rig-x", []byte(`{"type":"event","rig":"rig-x"}`))
+	hub.Publish("rig-a", []byte(`{"type":"event","rig":"rig-a"}`))
+	if got := readReply(t, conn); got.Rig != "rig-a" {
+		t.Errorf("expected only rig-a's message, got %+v", got)
+	}
+}
+
+func TestHub_InitialFrameArrivesBeforeBroadcasts(t *testing.T) {
+	hub := NewHub(func() ([]byte, error) { return []byte(`{"type":"snapshot"}`), nil })
+	hub.SetInitialProvider(func() ([]byte, error) {
+		// Give broadcasts time to race the initial frame
+		time.Sleep(50 * time.Millisecond)
+		return []byte(`{"type":"initial"}`), nil
+	})
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	t.Cleanup(func() {
+		close(stop)
+		<-done
+	})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(time.Millisecond):
+				hub.Publish("", []byte(`{"type":"event"}`))
+			}
+		}
+	}()
+
+	conn := dialTestHub(t, hub)
+
+	// The write pump batches queued messages into one frame, newline-separated
+	var types []string
+	for !containsType(types, "snapshot") {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("failed to read message after %v: %v", types, err)
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			var msg struct {
+				Type string `json:"type"`
+			}
+			if err := json.Unmarshal([]byte(line), &msg); err != nil {
+				t.Fatalf("failed to decode message %q: %v", line, err)
+			}
+			types = append(types, msg.Type)
+		}
+	}
+
+	if types[0] != "initial" {
+		t.Errorf("expected the initial frame first, got %v", types)
+	}
+	if n := countType(types, "initial"); n != 1 {
+		t.Errorf("expected one initial frame, got %d", n)
+	}
+}
+
+func containsType(types []string, want string) bool {
+	return countType(types, want) > 0
+}
+
+func countType(types []string, want string) int {
+	n := 0
+	for _, typ := range types {
+		if typ == want {
+			n++
+		}
+	}
+	return n
+}